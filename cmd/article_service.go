@@ -1,6 +1,8 @@
 package main
 
 import (
+	"github.com/mimir-news/news-ranker/pkg/domain"
+	"github.com/mimir-news/news-ranker/pkg/repository"
 	"github.com/mimir-news/pkg/mq"
 	"github.com/mimir-news/pkg/schema/news"
 )
@@ -10,34 +12,63 @@ func (e *env) handleScrapedArticleMessage(msg mq.Message, msgID string) error {
 	scrapedArticle, err := parseScrapedArticle(msg)
 	if err != nil {
 		logger.Errorw("Parsing ScrapedArticle failed", "msgID", msgID, "err", err)
+		return permanent(err)
+	}
+
+	scrapedArticle.Article.URL = e.canonicalURL(scrapedArticle.Article.URL)
+	stored, err := e.articleRepo.FindByURL(scrapedArticle.Article.URL)
+	if err == nil && stored.ID != scrapedArticle.Article.ID {
+		e.rankDuplicateScrape(stored, scrapedArticle)
+		return nil
+	} else if err != nil && err != repository.ErrNoSuchArticle {
+		logger.Errorw("Getting article from repository failed", "msgID", msgID, "err", err)
 		return err
 	}
 
 	article, err := e.updateAndStoreScrapedArticle(scrapedArticle)
 	if err != nil {
 		logger.Errorw("Failed to store scraped article", "msgID", msgID, "err", err)
-		return nil
+		return err
 	}
 
-	e.clusterArticle(article)
+	err = e.clusterArticle(article)
+	if err != nil {
+		logger.Errorw("Failed to cluster scraped article", "msgID", msgID, "err", err)
+		return err
+	}
 	logger.Infow("Success in handling ScrapedArticle", "msgID", msgID)
 	return nil
 }
 
 func (e *env) updateAndStoreScrapedArticle(scrapedArticle news.ScrapedArticle) (news.Article, error) {
+	unlock := e.articleLocks.lock(scrapedArticle.Article.ID)
+	defer unlock()
+
 	referers, err := e.articleRepo.FindArticleReferers(scrapedArticle.Article.ID)
 	if err != nil {
 		return news.Article{}, err
 	}
 
-	mergedReferers := mergeReferers(referers, scrapedArticle.Referer)
-	referenceScore := calcReferenceScore(e.config.TwitterUsers, e.config.ReferenceWeight, mergedReferers...)
+	mergedReferers := mergeReferers(referers, domain.NewReferer(scrapedArticle.Referer))
+	pending, isPending, unlockPending := e.lockPendingScrape(scrapedArticle.Article.ID)
+	defer unlockPending()
+	if isPending {
+		e.recordRedirect(pending.URL, scrapedArticle.Article.URL)
+		scrapedArticle.Subjects = pending.MergeSubjects(scrapedArticle.Subjects, scrapedArticle.Article.ID)
+		mergedReferers = pending.MergeReferers(mergedReferers, scrapedArticle.Article.ID)
+	}
+	referenceScore := e.scorer.Score(mergedReferers...)
 	scrapedArticle.Article.ReferenceScore = referenceScore
 
-	err = e.articleRepo.SaveScrapedArticle(scrapedArticle)
+	// The referer of the scraped article and those coalesced while it was being scraped are stored with it.
+	err = e.articleRepo.SaveScrapedArticle(scrapedArticle, mergedReferers[len(referers):])
 	if err != nil {
 		return news.Article{}, err
 	}
+
+	if isPending {
+		e.deletePendingScrape(pending)
+	}
 	return scrapedArticle.Article, nil
 }
 
@@ -47,8 +78,8 @@ func parseScrapedArticle(msg mq.Message) (news.ScrapedArticle, error) {
 	return sa, err
 }
 
-func mergeReferers(referers []news.Referer, newReferer news.Referer) []news.Referer {
-	merged := make([]news.Referer, len(referers))
+func mergeReferers(referers []domain.Referer, newReferer domain.Referer) []domain.Referer {
+	merged := make([]domain.Referer, len(referers))
 	copy(merged, referers)
 
 	for _, referer := range referers {
@@ -4,6 +4,8 @@ import (
 	"testing"
 	"time"
 
+	"github.com/mimir-news/news-ranker/pkg/domain"
+	"github.com/mimir-news/news-ranker/pkg/repository"
 	"github.com/mimir-news/pkg/id"
 	"github.com/mimir-news/pkg/mq/mqtest"
 	"github.com/mimir-news/pkg/schema/news"
@@ -33,29 +35,61 @@ func TestHandleScrapedArticleMessage_Success(t *testing.T) {
 	}
 
 	articleRepo := &mockArticleRepo{
-		articleReferers:        oldReferers,
+		findByURLErr:           repository.ErrNoSuchArticle,
+		articleReferers:        domainReferers(oldReferers),
 		findArticleReferersErr: nil,
 		saveScrapedArticleErr:  nil,
 		articleSubjects:        nil, // Set up to prevent clusterering which is not in scope for the test.
-		findArticleSubjectsErr: errMock,
+		findArticleSubjectsErr: repository.ErrNoSubjects,
 	}
 	mockEnv := &env{
-		config:      config{TwitterUsers: 12000, ReferenceWeight: 2.0},
+		scorer:      domain.NewLinearScorer(12000, 2.0),
 		articleRepo: articleRepo,
 	}
 
 	err := mockEnv.handleScrapedArticleMessage(message, id.New())
 	assert.Nil(err)
 
+	articleRepo.findArticleSubjectsErr = errMock
+	err = mockEnv.handleScrapedArticleMessage(mqtest.NewMessage(scrapedArticle, false, false), id.New())
+	assert.Equal(errMock, err)
+
 	assert.Equal(scrapedArticle.Article.ID, articleRepo.findArticleReferersArg)
 	assertScore(0.5, articleRepo.saveScrapedArticleArg.Article.ReferenceScore, t)
 }
 
+func TestHandleScrapedArticleMessage_MemoryRepos(t *testing.T) {
+	assert := assert.New(t)
+
+	store := repository.NewMemoryStore()
+	articleRepo := repository.NewMemoryArticleRepo(store)
+	clusterRepo := repository.NewMemoryClusterRepo(store)
+	mockEnv := newMockEnv(articleRepo, clusterRepo, nil)
+
+	scrapedArticle := getTestScrapedArticle()
+	err := mockEnv.handleScrapedArticleMessage(mqtest.NewMessage(scrapedArticle, false, false), id.New())
+	assert.Nil(err)
+
+	article, err := articleRepo.FindByID(scrapedArticle.Article.ID)
+	assert.Nil(err)
+	assertScore(1.0, article.ReferenceScore, t)
+
+	for _, subject := range scrapedArticle.Subjects {
+		clusterHash := domain.CalcClusterHash(article.Title, subject.Symbol, article.ArticleDate)
+		cluster, err := clusterRepo.FindByHash(clusterHash)
+		assert.Nil(err)
+		assert.Equal(article.ID, cluster.LeadArticleID)
+		assert.Equal(1, len(cluster.Members))
+		assertScore(1.0+subject.Score, cluster.Score, t)
+	}
+}
+
 func TestHandleScrapedArticleMessage_FailedParse(t *testing.T) {
 	message := mqtest.NewMessage([]byte("will not parse"), false, false)
 	mockEnv := &env{}
 	err := mockEnv.handleScrapedArticleMessage(message, id.New())
 	assert.NotNil(t, err)
+	assert.True(t, isPermanent(err))
 }
 
 func TestHandleScrapedArticleMessage_FailedDBInteractions(t *testing.T) {
@@ -66,17 +100,18 @@ func TestHandleScrapedArticleMessage_FailedDBInteractions(t *testing.T) {
 	message := mqtest.NewMessage(scrapedArticle, false, false)
 
 	articleRepoNoReferers := &mockArticleRepo{
+		findByURLErr:           repository.ErrNoSuchArticle,
 		articleReferers:        nil,
 		findArticleReferersErr: errMock,
 	}
 
 	mockEnv := &env{
-		config:      config{TwitterUsers: 6000, ReferenceWeight: 3.0},
+		scorer:      domain.NewLinearScorer(6000, 3.0),
 		articleRepo: articleRepoNoReferers,
 	}
 
 	err := mockEnv.handleScrapedArticleMessage(message, id.New())
-	assert.Nil(err)
+	assert.Equal(errMock, err)
 	assert.Equal(scrapedArticle.Article.ID, articleRepoNoReferers.findArticleReferersArg)
 	assert.Equal("", articleRepoNoReferers.saveScrapedArticleArg.Article.ID)
 
@@ -90,7 +125,8 @@ func TestHandleScrapedArticleMessage_FailedDBInteractions(t *testing.T) {
 	}
 
 	articleRepoFailedSave := &mockArticleRepo{
-		articleReferers:        oldReferers,
+		findByURLErr:           repository.ErrNoSuchArticle,
+		articleReferers:        domainReferers(oldReferers),
 		findArticleReferersErr: nil,
 		saveScrapedArticleErr:  errMock,
 	}
@@ -98,7 +134,7 @@ func TestHandleScrapedArticleMessage_FailedDBInteractions(t *testing.T) {
 	mockEnv.articleRepo = articleRepoFailedSave
 
 	err = mockEnv.handleScrapedArticleMessage(message, id.New())
-	assert.Nil(err)
+	assert.Equal(errMock, err)
 	assert.Equal(scrapedArticle.Article.ID, articleRepoFailedSave.findArticleReferersArg)
 	assert.Equal(scrapedArticle.Article.ID, articleRepoFailedSave.saveScrapedArticleArg.Article.ID)
 	assertScore(0.5, articleRepoFailedSave.saveScrapedArticleArg.Article.ReferenceScore, t)
@@ -142,7 +178,23 @@ func getTestScrapedArticle() news.ScrapedArticle {
 	}
 }
 
+func domainReferers(referers []news.Referer) []domain.Referer {
+	wrapped := make([]domain.Referer, 0, len(referers))
+	for _, referer := range referers {
+		wrapped = append(wrapped, domain.NewReferer(referer))
+	}
+	return wrapped
+}
+
 type mockArticleRepo struct {
+	findByIDArg     string
+	findByIDArticle news.Article
+	findByIDErr     error
+
+	findByIDsArg      []string
+	findByIDsArticles []news.Article
+	findByIDsErr      error
+
 	findByURLArg     string
 	findByURLArticle news.Article
 	findByURLErr     error
@@ -150,19 +202,46 @@ type mockArticleRepo struct {
 	findArticleSubjectsArg string
 	articleSubjects        []news.Subject
 	findArticleSubjectsErr error
+	articleSubjectsByID    map[string][]news.Subject
 
 	findArticleReferersArg string
-	articleReferers        []news.Referer
+	articleReferers        []domain.Referer
 	findArticleReferersErr error
+	articleReferersByID    map[string][]domain.Referer
 
-	updateArg news.Article
-	updateErr error
+	findArticlesFilter  repository.Filter
+	findArticlesBatches [][]news.Article
+	findArticlesErr     error
 
-	saveRefererArg news.Referer
-	saveRefererErr error
+	updateArg   news.Article
+	updateErr   error
+	updateCalls int
 
-	saveScrapedArticleArg news.ScrapedArticle
-	saveScrapedArticleErr error
+	updateWithRefererArticleArg news.Article
+	updateWithRefererRefererArg domain.Referer
+	updateWithRefererErr        error
+
+	saveScrapedArticleArg         news.ScrapedArticle
+	saveScrapedArticleReferersArg []domain.Referer
+	saveScrapedArticleErr         error
+
+	mergeArticlesArg          news.Article
+	mergeArticlesDuplicateIDs []string
+	mergeArticlesErr          error
+}
+
+func (r *mockArticleRepo) FindByID(id string) (news.Article, error) {
+	r.findByIDArg = id
+	return r.findByIDArticle, r.findByIDErr
+}
+
+func (r *mockArticleRepo) FindByIDs(ids []string) (map[string]news.Article, error) {
+	r.findByIDsArg = ids
+	articles := make(map[string]news.Article, len(r.findByIDsArticles))
+	for _, article := range r.findByIDsArticles {
+		articles[article.ID] = article
+	}
+	return articles, r.findByIDsErr
 }
 
 func (r *mockArticleRepo) FindByURL(url string) (news.Article, error) {
@@ -172,25 +251,65 @@ func (r *mockArticleRepo) FindByURL(url string) (news.Article, error) {
 
 func (r *mockArticleRepo) FindArticleSubjects(articleID string) ([]news.Subject, error) {
 	r.findArticleSubjectsArg = articleID
+	if r.articleSubjectsByID != nil {
+		return r.articleSubjectsByID[articleID], r.findArticleSubjectsErr
+	}
 	return r.articleSubjects, r.findArticleSubjectsErr
 }
 
-func (r *mockArticleRepo) FindArticleReferers(articleID string) ([]news.Referer, error) {
+func (r *mockArticleRepo) FindReferersByArticleIDs(articleIDs []string) (map[string][]domain.Referer, error) {
+	referers := make(map[string][]domain.Referer, len(articleIDs))
+	for _, articleID := range articleIDs {
+		articleReferers, err := r.FindArticleReferers(articleID)
+		if err != nil {
+			return nil, err
+		}
+		if len(articleReferers) > 0 {
+			referers[articleID] = articleReferers
+		}
+	}
+	return referers, nil
+}
+
+func (r *mockArticleRepo) FindArticleReferers(articleID string) ([]domain.Referer, error) {
 	r.findArticleReferersArg = articleID
+	if r.articleReferersByID != nil {
+		return r.articleReferersByID[articleID], r.findArticleReferersErr
+	}
 	return r.articleReferers, r.findArticleReferersErr
 }
 
+// FindArticles returns the configured batches in order, followed by an empty batch.
+func (r *mockArticleRepo) FindArticles(filter repository.Filter, afterID string, limit int) ([]news.Article, error) {
+	r.findArticlesFilter = filter
+	if len(r.findArticlesBatches) == 0 {
+		return []news.Article{}, r.findArticlesErr
+	}
+	batch := r.findArticlesBatches[0]
+	r.findArticlesBatches = r.findArticlesBatches[1:]
+	return batch, r.findArticlesErr
+}
+
 func (r *mockArticleRepo) Update(article news.Article) error {
 	r.updateArg = article
+	r.updateCalls++
 	return r.updateErr
 }
 
-func (r *mockArticleRepo) SaveReferer(referer news.Referer) error {
-	r.saveRefererArg = referer
-	return r.saveRefererErr
+func (r *mockArticleRepo) UpdateWithReferer(article news.Article, referer domain.Referer) error {
+	r.updateWithRefererArticleArg = article
+	r.updateWithRefererRefererArg = referer
+	return r.updateWithRefererErr
 }
 
-func (r *mockArticleRepo) SaveScrapedArticle(scrapedArticle news.ScrapedArticle) error {
+func (r *mockArticleRepo) SaveScrapedArticle(scrapedArticle news.ScrapedArticle, referers []domain.Referer) error {
 	r.saveScrapedArticleArg = scrapedArticle
+	r.saveScrapedArticleReferersArg = referers
 	return r.saveScrapedArticleErr
 }
+
+func (r *mockArticleRepo) MergeArticles(article news.Article, duplicateIDs []string, rescore repository.ClusterRescorer) error {
+	r.mergeArticlesArg = article
+	r.mergeArticlesDuplicateIDs = duplicateIDs
+	return r.mergeArticlesErr
+}
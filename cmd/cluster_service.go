@@ -1,78 +1,288 @@
 package main
 
 import (
+	"time"
+
 	"github.com/mimir-news/news-ranker/pkg/domain"
 	"github.com/mimir-news/news-ranker/pkg/repository"
 	"github.com/mimir-news/pkg/schema/news"
 )
 
-func (e *env) clusterArticle(article news.Article) {
+// clusterArticle adds the article to a cluster for each of its subjects. The article is clustered
+// with the remaining subjects if clustering with one fails, the first error is then returned.
+func (e *env) clusterArticle(article news.Article) error {
 	subjects, err := e.articleRepo.FindArticleSubjects(article.ID)
 	if err != nil && err != repository.ErrNoSubjects {
 		logger.Errorw("Failed retrieving subjects", "articleId", article.ID, "err", err)
-		return
+		return err
 	}
 
+	var firstErr error
 	for _, subject := range subjects {
-		e.clusterArticleWithSubject(article, subject)
+		err = e.clusterArticleWithSubject(article, subject)
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
 	}
+	return firstErr
 }
 
-func (e *env) clusterArticleWithSubject(article news.Article, subject news.Subject) {
+// maxClusterUpdateAttempts is the number of times a cluster update is attempted
+// when the cluster is concurrently updated by someone else.
+const maxClusterUpdateAttempts = 5
+
+func (e *env) clusterArticleWithSubject(article news.Article, subject news.Subject) error {
 	clusterHash := domain.CalcClusterHash(article.Title, subject.Symbol, article.ArticleDate)
+	unlock := e.clusterLocks.lock(e.clusterLockKey(clusterHash, subject.Symbol))
+	defer unlock()
+
+	return retryConcurrentClusterUpdate(func() error {
+		return e.tryClusterArticleWithSubject(clusterHash, article, subject)
+	}, "clusterHash", clusterHash, "articleId", article.ID)
+}
+
+// retryConcurrentClusterUpdate calls update until it returns something other than
+// repository.ErrConcurrentUpdate or maxClusterUpdateAttempts is reached. The update is
+// expected to re-read the cluster on every attempt.
+func retryConcurrentClusterUpdate(update func() error, keysAndValues ...interface{}) error {
+	for attempt := 1; attempt <= maxClusterUpdateAttempts; attempt++ {
+		err := update()
+		if err != repository.ErrConcurrentUpdate {
+			return err
+		}
+		logger.Infow("Cluster concurrently updated, retrying", append(keysAndValues, "attempt", attempt)...)
+	}
+
+	logger.Errorw("Giving up cluster update after repeated concurrent updates",
+		append(keysAndValues, "attempts", maxClusterUpdateAttempts)...)
+	return repository.ErrConcurrentUpdate
+}
 
+// tryClusterArticleWithSubject reads the cluster the article belongs to and adds the article to it.
+// Returns repository.ErrConcurrentUpdate if the cluster was changed after it was read.
+func (e *env) tryClusterArticleWithSubject(clusterHash string, article news.Article, subject news.Subject) error {
 	cluster, err := e.clusterRepo.FindByHash(clusterHash)
 	if err == repository.ErrNoSuchCluster {
-		e.createNewCluster(clusterHash, article, subject)
-		return
+		cluster, err = e.findSimilarCluster(article, subject)
+	}
+
+	if err == repository.ErrNoSuchCluster {
+		return e.createNewCluster(clusterHash, article, subject)
 	} else if err != nil {
 		logger.Errorw("Failed retrieving cluster", "clusterHash", clusterHash, "err", err)
-		return
+		return err
+	}
+
+	return e.updateArticleCluster(cluster, article, subject)
+}
+
+// clusterLockKey returns the key that cluster updates are serialized on. Similarity clustering
+// can match any recent cluster of a subject, so its updates are serialized per symbol instead.
+func (e *env) clusterLockKey(clusterHash, symbol string) string {
+	if e.config.Clustering.Strategy == domain.SimilarityClustering {
+		return symbol
+	}
+	return clusterHash
+}
+
+func (e *env) findSimilarCluster(article news.Article, subject news.Subject) (domain.ArticleCluster, error) {
+	if e.config.Clustering.Strategy != domain.SimilarityClustering {
+		return domain.ArticleCluster{}, repository.ErrNoSuchCluster
+	}
+
+	since := article.ArticleDate.Add(-e.config.Clustering.Window)
+	candidates, err := e.clusterRepo.FindCandidates(subject.Symbol, since, e.config.Clustering.CandidateLimit)
+	if err != nil {
+		return domain.ArticleCluster{}, err
+	}
+
+	fingerprint := domain.CalcFingerprint(article.Title, article.Body)
+	match, ok := domain.MostSimilarCluster(fingerprint, candidates, e.config.Clustering.SimilarityThreshold)
+	if !ok {
+		return domain.ArticleCluster{}, repository.ErrNoSuchCluster
 	}
 
-	e.updateArticleCluster(cluster, article, subject)
+	logger.Infow("Found similar cluster", "clusterHash", match.Hash, "articleId", article.ID)
+	return e.clusterRepo.FindByHash(match.Hash)
 }
 
-func (e *env) createNewCluster(clusterHash string, article news.Article, subject news.Subject) {
-	members := createNewClusterMemebers(clusterHash, article, subject)
+func (e *env) createNewCluster(clusterHash string, article news.Article, subject news.Subject) error {
+	members := []domain.ClusterMember{e.newClusterMember(clusterHash, article, subject)}
 
 	cluster := domain.NewArticleCluster(
 		article.Title, subject.Symbol, article.ArticleDate,
 		article.ID, members[0].Score(), members)
-
-	err := e.clusterRepo.Save(*cluster)
+	cluster.ElectLeaderAndScore(e.leaderPolicy)
+	cluster.Fingerprint = domain.CalcFingerprint(article.Title, article.Body)
+	err := e.applyDecay(cluster, time.Now())
 	if err != nil {
+		logger.Errorw("Failed to decay cluster score", "clusterHash", cluster.Hash, "articleId", article.ID, "err", err)
+		return err
+	}
+
+	err = e.clusterRepo.Save(*cluster)
+	recordClusterWrite(clusterCreated, err)
+	if err != nil && err != repository.ErrConcurrentUpdate {
 		logger.Errorw("Failed store cluster",
 			"clusterHash", cluster.Hash,
 			"articleId", article.ID,
 			"err", err)
 	}
+	if err != nil {
+		return err
+	}
+
+	e.publishClusterUpdated(domain.NewClusterUpdated(*cluster, 0, domain.ClusterCreatedReason))
+	return nil
 }
 
-func (e *env) updateArticleCluster(cluster domain.ArticleCluster, article news.Article, subject news.Subject) {
-	updateClusterMembers(&cluster, article, subject)
-	cluster.ElectLeaderAndScore()
+// updateArticleCluster adds the article to the cluster or refreshes its member scores,
+// the cluster is left untouched if the scores of an existing member are unchanged.
+func (e *env) updateArticleCluster(cluster domain.ArticleCluster, article news.Article, subject news.Subject) error {
+	previousScore := cluster.Score
+	member := e.newClusterMember(cluster.Hash, article, subject)
+	if e.config.Clustering.DedupeAuthors {
+		err := e.dedupeMemberReferers(&cluster, &member)
+		if err != nil {
+			logger.Errorw("Failed to deduplicate cluster referers",
+				"clusterHash", cluster.Hash,
+				"articleId", article.ID,
+				"err", err)
+			return err
+		}
+	}
 
-	err := e.clusterRepo.Update(cluster)
+	delta := cluster.UpsertMember(member, e.leaderPolicy)
+	if !delta.Changed() {
+		logger.Infow("Article already in cluster with the same scores", "articleId", article.ID, "clusterHash", cluster.Hash)
+		return nil
+	}
+	err := e.applyDecay(&cluster, time.Now())
 	if err != nil {
+		logger.Errorw("Failed to decay cluster score", "clusterHash", cluster.Hash, "articleId", article.ID, "err", err)
+		return err
+	}
+
+	err = e.clusterRepo.Update(cluster)
+	recordClusterWrite(clusterUpdated, err)
+	if err != nil && err != repository.ErrConcurrentUpdate {
 		logger.Errorw("Failed to update cluster",
 			"clusterHash", cluster.Hash,
 			"articleId", article.ID,
 			"err", err)
 	}
+	if err != nil {
+		return err
+	}
+
+	recordMemberDelta(cluster.Hash, delta)
+	reason := domain.MemberAddedReason
+	if !delta.Added {
+		reason = domain.MemberUpdatedReason
+	}
+	e.publishClusterUpdated(domain.NewClusterUpdated(cluster, previousScore, reason))
+	return nil
+}
+
+// dedupeMemberReferers scores the member and the other members of the cluster with referers
+// deduplicated across the cluster, before the member is upserted into the cluster.
+func (e *env) dedupeMemberReferers(cluster *domain.ArticleCluster, member *domain.ClusterMember) error {
+	members := make([]domain.ClusterMember, 0, len(cluster.Members)+1)
+	for _, other := range cluster.Members {
+		if other.ArticleID != member.ArticleID {
+			members = append(members, other)
+		}
+	}
+	members = append(members, *member)
+
+	err := e.scoreClusterReferers(members)
+	if err != nil {
+		return err
+	}
+
+	scores := make(map[string]float64, len(members))
+	for _, scored := range members {
+		scores[scored.ArticleID] = scored.ReferenceScore
+	}
+	for i, other := range cluster.Members {
+		if other.ArticleID != member.ArticleID {
+			cluster.Members[i].ReferenceScore = scores[other.ArticleID]
+		}
+	}
+	member.ReferenceScore = scores[member.ArticleID]
+	return nil
+}
+
+// scoreClusterReferers sets the reference scores of the members from the referers of their
+// articles, deduplicated by author across the members and weighted by publisher authority.
+func (e *env) scoreClusterReferers(members []domain.ClusterMember) error {
+	articleIDs := make([]string, 0, len(members))
+	for _, member := range members {
+		articleIDs = append(articleIDs, member.ArticleID)
+	}
+	referers, err := e.articleRepo.FindReferersByArticleIDs(articleIDs)
+	if err != nil {
+		return err
+	}
+
+	deduped := domain.DedupeClusterReferers(members, referers, e.config.Clustering.RepeatAuthorWeight)
+	for i, member := range members {
+		authority := e.publishers.Find(member.Source).Authority()
+		members[i].ReferenceScore = e.scorer.Score(deduped[member.ArticleID]...) * authority
+	}
+	return nil
 }
 
-func updateClusterMembers(cluster *domain.ArticleCluster, article news.Article, subject news.Subject) {
-	newMember := createNewClusterMember(cluster, article, subject)
-	cluster.AddMember(newMember)
+func recordMemberDelta(clusterHash string, delta domain.MemberDelta) {
+	if delta.Added {
+		return
+	}
+
+	clusterMembersRefreshed.Inc()
+	logger.Infow("Refreshed cluster member scores",
+		"clusterHash", clusterHash,
+		"articleId", delta.ArticleID,
+		"referenceScoreDelta", delta.ReferenceScore,
+		"subjectScoreDelta", delta.SubjectScore,
+		"leaderChanged", delta.LeaderChanged())
 }
 
-func createNewClusterMember(c *domain.ArticleCluster, a news.Article, s news.Subject) domain.ClusterMember {
-	return *domain.NewClusterMember(c.Hash, a.ID, a.ReferenceScore, s.Score)
+// publishClusterUpdated sends the event to the exchange if a routing key for cluster updates
+// is configured. Failing to publish does not fail clustering as the cluster is already stored.
+func (e *env) publishClusterUpdated(event domain.ClusterUpdated) {
+	routingKey := e.config.MQ.ClusterUpdatesKey
+	if routingKey == "" {
+		return
+	}
+
+	err := e.mqClient.Send(event, e.exchange(), routingKey)
+	if err != nil {
+		logger.Errorw("Publishing cluster update failed", "clusterHash", event.Hash, "reason", event.Reason, "err", err)
+		return
+	}
+	clusterEventsPublished.WithLabelValues(event.Reason).Inc()
+}
+
+func recordClusterWrite(operation string, err error) {
+	if err == repository.ErrConcurrentUpdate {
+		clusterWrites.WithLabelValues(clusterConflict).Inc()
+	} else if err == nil {
+		clusterWrites.WithLabelValues(operation).Inc()
+	}
 }
 
-func createNewClusterMemebers(clusterHash string, article news.Article, subject news.Subject) []domain.ClusterMember {
-	return []domain.ClusterMember{
-		*domain.NewClusterMember(clusterHash, article.ID, article.ReferenceScore, subject.Score),
+// newClusterMember creates a member scored by the authority of the article publisher, with the
+// article attributes used to elect leaders. Articles just scraped are not yet read back with
+// their creation time and are first seen now.
+func (e *env) newClusterMember(clusterHash string, article news.Article, subject news.Subject) domain.ClusterMember {
+	authority := e.authority(article.URL)
+	member := domain.NewClusterMember(
+		clusterHash, article.ID, article.ReferenceScore*authority, subject.Score*authority)
+	member.FirstSeenAt = article.CreatedAt
+	if member.FirstSeenAt.IsZero() {
+		member.FirstSeenAt = member.ReferencedAt
 	}
+	member.Source = domain.ArticleSource(article.URL)
+	member.BodyLength = len(article.Body)
+	return *member
 }
@@ -147,6 +147,188 @@ func TestUpdateArticleCluster(t *testing.T) {
 	assert.Equal(3, len(cluster.Members))
 }
 
+func TestClusterArticleWithSubject_ConcurrentUpdate(t *testing.T) {
+	assert := assert.New(t)
+
+	articleDate, err := time.Parse("2006-01-02", "2018-10-25")
+	assert.Nil(err)
+	symbol := "symbol-0"
+	article := news.Article{
+		ID:             "a-new",
+		URL:            "http://url.com",
+		Title:          "title-0",
+		ReferenceScore: 0.5,
+		ArticleDate:    articleDate,
+	}
+	subject := news.Subject{
+		Symbol:    symbol,
+		Score:     0.3,
+		ArticleID: "a-new",
+	}
+	clusterHash := domain.CalcClusterHash(article.Title, symbol, articleDate)
+	existingCluster := *domain.NewArticleCluster(article.Title, symbol, articleDate, "a-0", 0.4, []domain.ClusterMember{
+		*domain.NewClusterMember(clusterHash, "a-0", 0.3, 0.1),
+	})
+
+	clusterRepo := &mockClusterRepo{
+		findByHashCluster: existingCluster,
+		updateConflicts:   2,
+	}
+	mockEnv := newMockEnv(nil, clusterRepo, nil)
+	mockEnv.clusterArticleWithSubject(article, subject)
+	assert.Equal(3, clusterRepo.updateCalls)
+	assert.Equal(2, len(clusterRepo.updateArg.Members))
+
+	clusterRepo = &mockClusterRepo{
+		findByHashCluster: existingCluster,
+		updateConflicts:   maxClusterUpdateAttempts + 1,
+	}
+	mockEnv = newMockEnv(nil, clusterRepo, nil)
+	mockEnv.clusterArticleWithSubject(article, subject)
+	assert.Equal(maxClusterUpdateAttempts, clusterRepo.updateCalls)
+
+	clusterRepo = &mockClusterRepo{
+		findByHashErr: repository.ErrNoSuchCluster,
+		saveReturn:    repository.ErrConcurrentUpdate,
+	}
+	mockEnv = newMockEnv(nil, clusterRepo, nil)
+	mockEnv.clusterArticleWithSubject(article, subject)
+	assert.Equal(maxClusterUpdateAttempts, clusterRepo.saveCalls)
+
+	clusterRepo = &mockClusterRepo{
+		findByHashCluster: existingCluster,
+		updateReturn:      errMock,
+	}
+	mockEnv = newMockEnv(nil, clusterRepo, nil)
+	mockEnv.clusterArticleWithSubject(article, subject)
+	assert.Equal(1, clusterRepo.updateCalls)
+}
+
+func TestClusterArticleWithSubject_PublishesEvents(t *testing.T) {
+	assert := assert.New(t)
+
+	articleDate, err := time.Parse("2006-01-02", "2018-10-25")
+	assert.Nil(err)
+	article := news.Article{
+		ID:             "a-new",
+		Title:          "title-0",
+		ReferenceScore: 0.5,
+		ArticleDate:    articleDate,
+	}
+	subject := news.Subject{
+		Symbol:    "symbol-0",
+		Score:     0.3,
+		ArticleID: "a-new",
+	}
+	clusterHash := domain.CalcClusterHash(article.Title, subject.Symbol, articleDate)
+
+	client := newRecordingMQClient(nil)
+	clusterRepo := &mockClusterRepo{
+		findByHashErr: repository.ErrNoSuchCluster,
+	}
+	mockEnv := newMockEnv(nil, clusterRepo, client)
+	mockEnv.config.MQ.ClusterUpdatesKey = "cluster-updates"
+
+	mockEnv.clusterArticleWithSubject(article, subject)
+	assert.Equal(1, len(client.sent))
+	assert.Equal("x-news", client.sent[0].exchange)
+	assert.Equal("cluster-updates", client.sent[0].routingKey)
+	event := client.sent[0].msg.(domain.ClusterUpdated)
+	assert.Equal(clusterHash, event.Hash)
+	assert.Equal(domain.ClusterCreatedReason, event.Reason)
+	assert.Equal(0.0, event.PreviousScore)
+	assert.Equal(1, event.MemberCount)
+	assert.Equal(domain.OnlyMemberReason, event.LeaderReason)
+
+	existingCluster := *domain.NewArticleCluster(article.Title, subject.Symbol, articleDate, "a-0", 0.4, []domain.ClusterMember{
+		*domain.NewClusterMember(clusterHash, "a-0", 0.3, 0.1),
+	})
+	clusterRepo.findByHashErr = nil
+	clusterRepo.findByHashCluster = existingCluster
+
+	mockEnv.clusterArticleWithSubject(article, subject)
+	assert.Equal(2, len(client.sent))
+	event = client.sent[1].msg.(domain.ClusterUpdated)
+	assert.Equal(domain.MemberAddedReason, event.Reason)
+	assert.Equal(0.4, event.PreviousScore)
+	assert.Equal(clusterRepo.updateArg.Score, event.Score)
+	assert.Equal(2, event.MemberCount)
+	assert.Equal("a-new", event.LeadArticleID)
+	assert.Equal(domain.HighestScoreLeader, event.LeaderReason)
+
+	clusterRepo.updateReturn = errMock
+	err = mockEnv.clusterArticleWithSubject(article, subject)
+	assert.Equal(errMock, err)
+	assert.Equal(2, len(client.sent))
+
+	mockEnv.config.MQ.ClusterUpdatesKey = ""
+	clusterRepo.updateReturn = nil
+	mockEnv.clusterArticleWithSubject(article, subject)
+	assert.Equal(2, len(client.sent))
+}
+
+func TestClusterArticleWithSubject_Similarity(t *testing.T) {
+	assert := assert.New(t)
+
+	articleDate, err := time.Parse("2006-01-02", "2018-10-25")
+	assert.Nil(err)
+	symbol := "AAPL"
+
+	article := news.Article{
+		ID:             "a-new",
+		URL:            "http://url.com",
+		Title:          "Apple Beats Q3 Estimates, Shares Rise",
+		ReferenceScore: 0.5,
+		ArticleDate:    articleDate,
+	}
+	subject := news.Subject{
+		Symbol:    symbol,
+		Score:     0.3,
+		ArticleID: article.ID,
+	}
+
+	oldMembers := []domain.ClusterMember{
+		*domain.NewClusterMember("", "a-0", 0.3, 0.1),
+	}
+	existingCluster := *domain.NewArticleCluster("Apple beats Q3 estimates", symbol, articleDate, "a-0", 0.4, oldMembers)
+	existingCluster.Fingerprint = domain.CalcFingerprint(existingCluster.Title, "")
+
+	clusterRepo := &mockClusterRepo{
+		findByHashClusters: map[string]domain.ArticleCluster{
+			existingCluster.Hash: existingCluster,
+		},
+		findCandidatesClusters: []domain.ArticleCluster{existingCluster},
+	}
+	mockEnv := newMockEnv(nil, clusterRepo, nil)
+	mockEnv.config.Clustering = clusteringConfig{
+		Strategy:            domain.SimilarityClustering,
+		SimilarityThreshold: 0.7,
+		Window:              48 * time.Hour,
+		CandidateLimit:      100,
+	}
+
+	mockEnv.clusterArticleWithSubject(article, subject)
+	assert.Equal(symbol, clusterRepo.findCandidatesSymbol)
+	assert.Equal(100, clusterRepo.findCandidatesLimit)
+	assert.Equal(existingCluster.Hash, clusterRepo.updateArg.Hash)
+	assert.Equal(2, len(clusterRepo.updateArg.Members))
+	assert.Equal("", clusterRepo.saveArg.Hash)
+
+	mockEnv.config.Clustering.Strategy = domain.ExactClustering
+	clusterRepo = &mockClusterRepo{
+		findByHashClusters: map[string]domain.ArticleCluster{
+			existingCluster.Hash: existingCluster,
+		},
+		findCandidatesClusters: []domain.ArticleCluster{existingCluster},
+	}
+	mockEnv.clusterRepo = clusterRepo
+
+	mockEnv.clusterArticleWithSubject(article, subject)
+	assert.Equal("", clusterRepo.findCandidatesSymbol)
+	assert.Equal("", clusterRepo.updateArg.Hash)
+	assert.Equal(domain.CalcClusterHash(article.Title, symbol, articleDate), clusterRepo.saveArg.Hash)
+}
+
 func TestClusterArticle(t *testing.T) {
 	// Test setup
 	assert := assert.New(t)
@@ -172,9 +354,10 @@ func TestClusterArticle(t *testing.T) {
 	mockEnv := newMockEnv(articleRepo, nil, nil)
 
 	// Method call
-	mockEnv.clusterArticle(article)
+	err = mockEnv.clusterArticle(article)
 
 	// Tests
+	assert.Equal(errMock, err)
 	assert.Equal(article.ID, articleRepo.findArticleSubjectsArg)
 
 	// Test setup
@@ -207,14 +390,67 @@ func TestClusterArticle(t *testing.T) {
 	mockEnv = newMockEnv(articleRepo, clusterRepo, nil)
 
 	// Method call
-	mockEnv.clusterArticle(article)
+	err = mockEnv.clusterArticle(article)
 
 	// Tests
+	assert.Equal(errMock, err)
 	assert.Equal(article.ID, articleRepo.findArticleSubjectsArg)
 	expectedHash := domain.CalcClusterHash(article.Title, subjects[1].Symbol, articleDate)
 	assert.Equal(expectedHash, clusterRepo.findByHashArg)
 }
 
+func TestUpdateArticleCluster_DedupesAuthors(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, c := range []struct {
+		dedupe          bool
+		repeatWeight    float64
+		referenceScores []float64
+	}{
+		{dedupe: false, referenceScores: []float64{1.0, 2.0}},
+		{dedupe: true, repeatWeight: 0, referenceScores: []float64{1.0, 1.0}},
+		{dedupe: true, repeatWeight: 0.5, referenceScores: []float64{1.0, 1.5}},
+	} {
+		store := repository.NewMemoryStore()
+		mockEnv := newMockEnv(repository.NewMemoryArticleRepo(store), repository.NewMemoryClusterRepo(store), nil)
+		mockEnv.config.Clustering.DedupeAuthors = c.dedupe
+		mockEnv.config.Clustering.RepeatAuthorWeight = c.repeatWeight
+
+		first := getTestScrapedArticle()
+		first.Subjects = first.Subjects[:1]
+		first.Referer = news.Referer{ID: "r-0", ExternalID: "author-0", FollowerCount: 1000, ArticleID: "a-0"}
+		first.Article.ReferenceScore = 1.0
+		assert.Nil(mockEnv.articleRepo.SaveScrapedArticle(first, domainReferers([]news.Referer{first.Referer})))
+		mockEnv.clusterArticle(first.Article)
+
+		// The second article is referred to by the author of the first and by a new author.
+		second := first
+		second.Article.ID = "a-1"
+		second.Article.URL = "http://url.com/1"
+		second.Article.ReferenceScore = 2.0
+		second.Subjects = []news.Subject{first.Subjects[0]}
+		second.Subjects[0].ID = "s-2"
+		second.Subjects[0].ArticleID = "a-1"
+		second.Referer = news.Referer{ID: "r-1", ExternalID: "author-0", FollowerCount: 1000, ArticleID: "a-1"}
+		assert.Nil(mockEnv.articleRepo.SaveScrapedArticle(second, domainReferers([]news.Referer{second.Referer})))
+		newReferer := domain.NewReferer(news.Referer{ID: "r-2", ExternalID: "author-1", FollowerCount: 1000, ArticleID: "a-1"})
+		assert.Nil(mockEnv.articleRepo.UpdateWithReferer(second.Article, newReferer))
+		mockEnv.clusterArticle(second.Article)
+
+		clusterHash := domain.CalcClusterHash(first.Article.Title, "S0", first.Article.ArticleDate)
+		cluster, err := mockEnv.clusterRepo.FindByHash(clusterHash)
+		assert.Nil(err)
+		assert.Equal(2, len(cluster.Members))
+		scores := make(map[string]float64)
+		for _, member := range cluster.Members {
+			scores[member.ArticleID] = member.ReferenceScore
+		}
+		assertScore(c.referenceScores[0], scores["a-0"], t)
+		assertScore(c.referenceScores[1], scores["a-1"], t)
+		assertScore(c.referenceScores[0]+c.referenceScores[1]+0.1, cluster.Score, t)
+	}
+}
+
 func assertScore(expected, actual float64, t *testing.T) {
 	expectedInt := int(expected * 10)
 	actualInt := int(actual * 10)
@@ -242,44 +478,137 @@ func newMockEnv(
 	mqClient mq.Client) *env {
 	return &env{
 		config: config{
-			TwitterUsers:    1000,
-			ReferenceWeight: 1.0,
 			MQ: mqConfig{
 				Exchange:     "x-news",
 				ScrapeQueue:  "q-scrape-targets",
 				ScrapedQueue: "q-scraped-articles",
 				RankQueue:    "q-rank-objects",
 			},
+			Decay: decayConfig{Model: domain.NoDecay},
 		},
-		articleRepo: articleRepo,
-		clusterRepo: clusterRepo,
-		mqClient:    mqClient,
+		articleRepo:  articleRepo,
+		clusterRepo:  clusterRepo,
+		scorer:       domain.NewLinearScorer(1000, 1.0),
+		decayer:      newTestDecayer(domain.NoDecay),
+		leaderPolicy: newTestLeaderPolicy(domain.HighestScoreLeader),
+		publishers:   newPublisherCache(repository.NewMemoryPublisherRepo()),
+		mqClient:     mqClient,
+
+		pendingScrapeRepo: repository.NewMemoryPendingScrapeRepo(repository.NewMemoryStore()),
+		refererQuality:    newTestRefererQuality(),
 	}
 }
 
+func newTestLeaderPolicy(policy string) domain.LeaderPolicy {
+	leaderPolicy, err := domain.NewLeaderPolicy(domain.LeaderConfig{Policy: policy})
+	if err != nil {
+		panic(err)
+	}
+	return leaderPolicy
+}
+
+func newTestDecayer(model string) domain.Decayer {
+	decayer, err := domain.NewDecayer(domain.DecayConfig{Model: model, HalfLife: 24 * time.Hour})
+	if err != nil {
+		panic(err)
+	}
+	return decayer
+}
+
 type mockClusterRepo struct {
-	findByHashArg     string
-	findByHashCluster domain.ArticleCluster
-	findByHashErr     error
+	findByHashArg      string
+	findByHashCluster  domain.ArticleCluster
+	findByHashErr      error
+	findByHashClusters map[string]domain.ArticleCluster
+
+	findBySymbolAndDateArgs     []interface{}
+	findBySymbolAndDateClusters []domain.ArticleCluster
+	findBySymbolAndDateErr      error
+
+	findCandidatesSymbol   string
+	findCandidatesLimit    int
+	findCandidatesClusters []domain.ArticleCluster
+	findCandidatesErr      error
+
+	findSinceArg      time.Time
+	findSinceClusters []domain.ArticleCluster
+	findSinceErr      error
+
+	findClustersFilter  repository.Filter
+	findClustersBatches [][]domain.ArticleCluster
+	findClustersErr     error
 
 	saveArg    domain.ArticleCluster
 	saveReturn error
+	saveCalls  int
+
+	updateArg       domain.ArticleCluster
+	updateReturn    error
+	updateCalls     int
+	updateConflicts int // Number of updates failing with ErrConcurrentUpdate before updateReturn is returned.
 
-	updateArg    domain.ArticleCluster
-	updateReturn error
+	updateScoreArgs   map[string]float64
+	updateScoreReturn error
 }
 
 func (r *mockClusterRepo) FindByHash(arg string) (domain.ArticleCluster, error) {
 	r.findByHashArg = arg
+	if r.findByHashClusters != nil {
+		cluster, ok := r.findByHashClusters[arg]
+		if !ok {
+			return emptyCluster, repository.ErrNoSuchCluster
+		}
+		return cluster, nil
+	}
 	return r.findByHashCluster, r.findByHashErr
 }
 
+func (r *mockClusterRepo) FindCandidates(symbol string, since time.Time, limit int) ([]domain.ArticleCluster, error) {
+	r.findCandidatesSymbol = symbol
+	r.findCandidatesLimit = limit
+	return r.findCandidatesClusters, r.findCandidatesErr
+}
+
 func (r *mockClusterRepo) Save(arg domain.ArticleCluster) error {
 	r.saveArg = arg
+	r.saveCalls++
 	return r.saveReturn
 }
 
 func (r *mockClusterRepo) Update(arg domain.ArticleCluster) error {
 	r.updateArg = arg
+	r.updateCalls++
+	if r.updateCalls <= r.updateConflicts {
+		return repository.ErrConcurrentUpdate
+	}
 	return r.updateReturn
 }
+
+// FindClusters returns the configured batches in order, followed by an empty batch.
+func (r *mockClusterRepo) FindClusters(filter repository.Filter, afterHash string, limit int) ([]domain.ArticleCluster, error) {
+	r.findClustersFilter = filter
+	if len(r.findClustersBatches) == 0 {
+		return []domain.ArticleCluster{}, r.findClustersErr
+	}
+	batch := r.findClustersBatches[0]
+	r.findClustersBatches = r.findClustersBatches[1:]
+	return batch, r.findClustersErr
+}
+
+func (r *mockClusterRepo) FindSince(arg time.Time) ([]domain.ArticleCluster, error) {
+	r.findSinceArg = arg
+	return r.findSinceClusters, r.findSinceErr
+}
+
+func (r *mockClusterRepo) UpdateScore(cluster domain.ArticleCluster) error {
+	if r.updateScoreArgs == nil {
+		r.updateScoreArgs = make(map[string]float64)
+	}
+	r.updateScoreArgs[cluster.Hash] = cluster.Score
+	return r.updateScoreReturn
+}
+
+func (r *mockClusterRepo) FindBySymbolAndDate(symbol string, date time.Time, limit int) ([]domain.ArticleCluster, error) {
+	r.findBySymbolAndDateArgs = []interface{}{symbol, date, limit}
+	return r.findBySymbolAndDateClusters, r.findBySymbolAndDateErr
+}
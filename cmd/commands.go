@@ -0,0 +1,224 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/mimir-news/news-ranker/pkg/domain"
+	"github.com/mimir-news/news-ranker/pkg/repository"
+)
+
+// Command names.
+const (
+	replayDeadLettersCommand = "replay-dlq"
+	rescoreCommand           = "rescore"
+	mergeDuplicatesCommand   = "merge-duplicates"
+	configCommand            = "config"
+	publishersCommand        = "publishers"
+)
+
+// Config subcommands.
+const (
+	validateConfigCommand = "validate"
+	printConfigCommand    = "print"
+)
+
+// Publishers subcommands.
+const (
+	listPublishersCommand  = "list"
+	addPublisherCommand    = "add"
+	updatePublisherCommand = "update"
+)
+
+func runCommand(name string, args []string) {
+	switch name {
+	case replayDeadLettersCommand:
+		runReplayDeadLetters(args)
+	case rescoreCommand:
+		runRescore(args)
+	case mergeDuplicatesCommand:
+		runMergeDuplicates(args)
+	case configCommand:
+		runConfig(args)
+	case publishersCommand:
+		runPublishers(args)
+	default:
+		logger.Fatalw("Unknown command", "command", name)
+	}
+}
+
+func runReplayDeadLetters(args []string) {
+	flags := flag.NewFlagSet(replayDeadLettersCommand, flag.ExitOnError)
+	idleTimeout := flags.Duration("idle-timeout", 10*time.Second, "time to wait for new dead letters before stopping")
+	flags.Parse(args)
+
+	conf := getConfig()
+	if conf.MQ.DeadLetterQueue == "" {
+		logger.Fatalw("No dead letter queue configured", "key", "MQ_DEAD_LETTER_QUEUE")
+	}
+
+	e := setupEnv(conf)
+	defer e.close()
+
+	err := e.replayDeadLetters(*idleTimeout)
+	if err != nil {
+		logger.Errorw("Dead letter replay failed", "err", err)
+	}
+}
+
+func runRescore(args []string) {
+	flags := flag.NewFlagSet(rescoreCommand, flag.ExitOnError)
+	dryRun := flags.Bool("dry-run", false, "log score changes without storing them")
+	from := flags.String("from", "1970-01-01", "first article date to rescore, YYYY-MM-DD")
+	to := flags.String("to", time.Now().UTC().Format(dateFormat), "last article date to rescore, YYYY-MM-DD")
+	symbol := flags.String("symbol", "", "only rescore articles and clusters with this symbol")
+	batchSize := flags.Int("batch-size", 500, "number of articles or clusters to read at a time")
+	flags.Parse(args)
+
+	opts := rescoreOptions{
+		filter: repository.Filter{
+			From:   mustParseDate("from", *from),
+			To:     mustParseDate("to", *to),
+			Symbol: *symbol,
+		},
+		batchSize: *batchSize,
+		dryRun:    *dryRun,
+	}
+	if opts.batchSize < 1 {
+		logger.Fatalw("Invalid batch size", "batchSize", opts.batchSize)
+	}
+
+	conf := getConfig()
+	e := setupEnv(conf)
+	defer e.close()
+
+	logger.Infow("Starting rescore",
+		"from", *from, "to", *to, "symbol", *symbol, "dryRun", *dryRun)
+	r := e.newRescorer(opts)
+	articleStats := r.rescoreArticles()
+	clusterStats := r.rescoreClusters()
+	logger.Infow("Rescore done",
+		"articles", articleStats,
+		"clusters", clusterStats,
+		"dryRun", *dryRun)
+}
+
+func runMergeDuplicates(args []string) {
+	flags := flag.NewFlagSet(mergeDuplicatesCommand, flag.ExitOnError)
+	dryRun := flags.Bool("dry-run", false, "log articles to merge without merging them")
+	batchSize := flags.Int("batch-size", 500, "number of articles to read at a time")
+	flags.Parse(args)
+
+	opts := mergeOptions{
+		batchSize: *batchSize,
+		dryRun:    *dryRun,
+	}
+	if opts.batchSize < 1 {
+		logger.Fatalw("Invalid batch size", "batchSize", opts.batchSize)
+	}
+
+	conf := getConfig()
+	e := setupEnv(conf)
+	defer e.close()
+
+	logger.Infow("Starting merge of duplicate articles", "dryRun", *dryRun)
+	stats := e.mergeDuplicateArticles(opts)
+	logger.Infow("Merge of duplicate articles done", "stats", stats, "dryRun", *dryRun)
+}
+
+// runConfig validates or prints the config read from env vars and the config file,
+// given by -file or CONFIG_FILE. Validation reports every invalid setting and exits
+// with status 1 if there are any.
+func runConfig(args []string) {
+	if len(args) < 1 || (args[0] != validateConfigCommand && args[0] != printConfigCommand) {
+		logger.Fatalw("Unknown config subcommand, expected validate or print", "args", args)
+	}
+	flags := flag.NewFlagSet(configCommand+" "+args[0], flag.ExitOnError)
+	path := flags.String("file", getenv("CONFIG_FILE", ""), "YAML or TOML config file to read")
+	flags.Parse(args[1:])
+
+	_, r := loadConfig(*path)
+	for _, key := range r.unusedFileSettings() {
+		fmt.Fprintf(os.Stderr, "warning: unknown setting %s in config file\n", fileKey(key))
+	}
+	for _, err := range r.errs {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+	}
+
+	if args[0] == printConfigCommand {
+		err := r.printConfig(os.Stdout)
+		if err != nil {
+			logger.Fatalw("Config print failed", "err", err)
+		}
+	}
+	if len(r.errs) > 0 {
+		fmt.Fprintf(os.Stderr, "config invalid: %d errors\n", len(r.errs))
+		os.Exit(1)
+	}
+	if args[0] == validateConfigCommand {
+		fmt.Println("config valid")
+	}
+}
+
+// runPublishers lists, adds or updates publishers in the registry. Update only changes
+// the settings given as flags. Running services apply changes on their next refresh.
+func runPublishers(args []string) {
+	if len(args) < 1 || (args[0] != listPublishersCommand &&
+		args[0] != addPublisherCommand && args[0] != updatePublisherCommand) {
+		logger.Fatalw("Unknown publishers subcommand, expected list, add or update", "args", args)
+	}
+	flags := flag.NewFlagSet(publishersCommand+" "+args[0], flag.ExitOnError)
+	host := flags.String("host", "", "publisher host, also matches its subdomains")
+	weight := flags.Float64("weight", domain.DefaultAuthority, "authority weight multiplying article scores")
+	paywalled := flags.Bool("paywalled", false, "publisher is paywalled and only leads clusters without other leaders")
+	blocked := flags.Bool("blocked", false, "publisher is blocked and its articles are scored zero")
+	flags.Parse(args[1:])
+
+	conf := getConfig()
+	db := connectDB(conf)
+	defer closeDB(db)
+	repo := repository.NewPublisherRepo(db)
+
+	if args[0] == listPublishersCommand {
+		err := listPublishers(repo, os.Stdout)
+		if err != nil {
+			logger.Fatalw("Listing publishers failed", "err", err)
+		}
+		return
+	}
+
+	publisher := domain.Publisher{
+		Host:      *host,
+		Weight:    *weight,
+		Paywalled: *paywalled,
+		Blocked:   *blocked,
+	}
+	if args[0] == addPublisherCommand {
+		publisher, err := addPublisher(repo, publisher)
+		if err != nil {
+			logger.Fatalw("Adding publisher failed", "host", *host, "err", err)
+		}
+		logger.Infow("Added publisher", "publisher", publisher)
+		return
+	}
+
+	set := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	publisher, err := updatePublisher(repo, publisher, set)
+	if err != nil {
+		logger.Fatalw("Updating publisher failed", "host", *host, "err", err)
+	}
+	logger.Infow("Updated publisher", "publisher", publisher)
+}
+
+func mustParseDate(name, value string) time.Time {
+	date, err := time.Parse(dateFormat, value)
+	if err != nil {
+		logger.Fatalw("Invalid date", "flag", name, "value", value, "err", err)
+	}
+	return date
+}
@@ -1,12 +1,16 @@
 package main
 
 import (
+	"encoding/json"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/mimir-news/news-ranker/pkg/domain"
 	"github.com/mimir-news/pkg/dbutil"
 	"github.com/mimir-news/pkg/mq"
+	"github.com/pkg/errors"
 )
 
 // Service metadata.
@@ -16,61 +20,333 @@ const (
 
 const initalWaitingTime = 5 * time.Second
 
+// Stores used in local mode.
+const (
+	localStorePostgres = "postgres"
+	localStoreMemory   = "memory"
+)
+
 type config struct {
 	MQ               mqConfig
 	DB               dbutil.Config
 	TwitterUsers     float64
 	ReferenceWeight  float64
+	Scoring          scoringConfig
+	Decay            decayConfig
+	Clustering       clusteringConfig
+	Server           serverConfig
+	Retry            retryPolicy
+	ShutdownTimeout  time.Duration
+	Health           healthConfig
+	Local            localConfig
+	Ledger           ledgerConfig
+	Scrape           scrapeConfig
+	URLs             urlConfig
+	Publishers       publisherConfig
+	RefererQuality   refererQualityConfig
 	HearbeatFile     string
 	HearbeatInterval int
 }
 
+type scoringConfig struct {
+	Strategy          string
+	DiminishingFactor float64
+	AuthorCap         float64
+	Sources           map[string]domain.SourceNormalization
+}
+
+type decayConfig struct {
+	Model    string
+	HalfLife time.Duration
+	Gravity  float64
+	Interval time.Duration
+	Window   time.Duration
+}
+
+func (c decayConfig) enabled() bool {
+	return c.Model != domain.NoDecay
+}
+
+type clusteringConfig struct {
+	Strategy            string
+	SimilarityThreshold float64
+	CandidateLimit      int
+	Window              time.Duration
+	LeaderPolicy        string
+	PreferredSources    []string
+	DedupeAuthors       bool
+	RepeatAuthorWeight  float64
+}
+
+type serverConfig struct {
+	Port string
+}
+
+type healthConfig struct {
+	MaxMessageAge time.Duration
+}
+
+// ledgerConfig configures for how long processed messages are remembered, zero disables the ledger.
+type ledgerConfig struct {
+	TTL time.Duration
+}
+
+// scrapeConfig configures for how long a URL sent for scraping is awaited before it is sent again,
+// zero disables coalescing of scrape requests for the same URL.
+type scrapeConfig struct {
+	PendingTimeout time.Duration
+	MaxAttempts    int
+}
+
+// urlConfig configures how article URLs are canonicalised, rules are given per domain.
+type urlConfig struct {
+	Rules            map[string]domain.URLRule
+	ResolveRedirects bool
+}
+
+// publisherConfig configures how often the publisher registry is reloaded.
+type publisherConfig struct {
+	RefreshInterval time.Duration
+}
+
+// refererQualityConfig configures detection of suspicious referers and how often the
+// flagged authors are reloaded.
+type refererQualityConfig struct {
+	BurstWindow        time.Duration
+	BurstSize          int
+	BurstGroupWindow   time.Duration
+	BurstGroupArticles int
+	SpreadWindow       time.Duration
+	SpreadArticles     int
+	FollowerJumpRatio  float64
+	Penalty            float64
+	Recovery           float64
+	RefreshInterval    time.Duration
+}
+
+// localConfig configures running without a broker, and optionally without postgres.
+type localConfig struct {
+	Enabled              bool
+	RankObjectsInput     string
+	ScrapedArticlesInput string
+	Output               string
+	InMemoryStore        bool
+}
+
 type mqConfig struct {
-	Host          string
-	Port          string
-	User          string
-	Password      string
-	Exchange      string
-	ScrapeQueue   string
-	ScrapedQueue  string
-	RankQueue     string
-	HealthTarget  string
-	PrefetchCount int
-}
-
-func mustGetMQConfig() mqConfig {
-	prefetchCount, err := strconv.Atoi(getenv("MQ_PREFETCH_COUNT", "1"))
-	if err != nil {
-		logger.Fatalw("MQ_PREFETCH_COUNT parsing failed", "err", err)
+	Host              string
+	Port              string
+	User              string
+	Password          string
+	Exchange          string
+	ScrapeQueue       string
+	ScrapedQueue      string
+	RankQueue         string
+	DeadLetterQueue   string
+	ClusterUpdatesKey string
+	HealthTarget      string
+	PrefetchCount     int
+	WorkersPerQueue   int
+}
+
+func getMQConfig(r *configReader) mqConfig {
+	prefetchCount := r.integer("MQ_PREFETCH_COUNT", "1", 0)
+	workers := r.integer("MQ_WORKERS_PER_QUEUE", "1", 1)
+	if prefetchCount < workers {
+		logger.Warnw("MQ_PREFETCH_COUNT is lower than MQ_WORKERS_PER_QUEUE, some workers will be idle",
+			"prefetchCount", prefetchCount, "workers", workers)
 	}
 
 	return mqConfig{
-		Host:          mustGetenv("MQ_HOST"),
-		Port:          getenv("MQ_PORT", "5672"),
-		User:          mustGetenv("MQ_USER"),
-		Password:      mustGetenv("MQ_PASSWORD"),
-		Exchange:      mustGetenv("MQ_EXCHANGE"),
-		ScrapeQueue:   mustGetenv("MQ_SCRAPE_QUEUE"),
-		ScrapedQueue:  mustGetenv("MQ_SCRAPED_QUEUE"),
-		RankQueue:     mustGetenv("MQ_RANK_QUEUE"),
-		HealthTarget:  mustGetenv("MQ_HEALTH_TARGET"),
-		PrefetchCount: prefetchCount,
+		Host:              r.required("MQ_HOST"),
+		Port:              r.lookup("MQ_PORT", "5672"),
+		User:              r.required("MQ_USER"),
+		Password:          r.required("MQ_PASSWORD"),
+		Exchange:          r.required("MQ_EXCHANGE"),
+		ScrapeQueue:       r.required("MQ_SCRAPE_QUEUE"),
+		ScrapedQueue:      r.required("MQ_SCRAPED_QUEUE"),
+		RankQueue:         r.required("MQ_RANK_QUEUE"),
+		DeadLetterQueue:   r.lookup("MQ_DEAD_LETTER_QUEUE", ""),
+		ClusterUpdatesKey: r.lookup("MQ_CLUSTER_UPDATES_ROUTING_KEY", ""),
+		HealthTarget:      r.required("MQ_HEALTH_TARGET"),
+		PrefetchCount:     prefetchCount,
+		WorkersPerQueue:   workers,
+	}
+}
+
+// getLocalMQConfig returns the queue names used in local mode, where no broker settings are needed.
+func getLocalMQConfig(r *configReader) mqConfig {
+	return mqConfig{
+		Exchange:          r.lookup("MQ_EXCHANGE", "x-news"),
+		ScrapeQueue:       r.lookup("MQ_SCRAPE_QUEUE", "q-scrape-targets"),
+		ScrapedQueue:      r.lookup("MQ_SCRAPED_QUEUE", "q-scraped-articles"),
+		RankQueue:         r.lookup("MQ_RANK_QUEUE", "q-rank-objects"),
+		DeadLetterQueue:   r.lookup("MQ_DEAD_LETTER_QUEUE", ""),
+		ClusterUpdatesKey: r.lookup("MQ_CLUSTER_UPDATES_ROUTING_KEY", ""),
+		WorkersPerQueue:   1,
+	}
+}
+
+func getLocalConfig(r *configReader) localConfig {
+	store := r.lookup("LOCAL_STORE", localStorePostgres)
+	if store != localStorePostgres && store != localStoreMemory {
+		r.invalid("LOCAL_STORE", "must be %s or %s", localStorePostgres, localStoreMemory)
+	}
+
+	return localConfig{
+		Enabled:              r.boolean("LOCAL_MODE", "false"),
+		RankObjectsInput:     r.lookup("LOCAL_RANK_OBJECTS_INPUT", ""),
+		ScrapedArticlesInput: r.lookup("LOCAL_SCRAPED_ARTICLES_INPUT", ""),
+		Output:               r.lookup("LOCAL_OUTPUT", stdio),
+		InMemoryStore:        store == localStoreMemory,
+	}
+}
+
+// getDBConfig reads the DB_ settings for connecting to postgres.
+func getDBConfig(r *configReader) dbutil.Config {
+	conf := dbutil.Config{
+		Host:     r.required("DB_HOST"),
+		Port:     r.required("DB_PORT"),
+		Name:     r.required("DB_NAME"),
+		User:     r.required("DB_USERNAME"),
+		Password: r.required("DB_PASSWORD"),
+	}
+	port, err := strconv.Atoi(conf.Port)
+	if !r.failed("DB_PORT") && (err != nil || port < 1 || port > 65535) {
+		r.invalid("DB_PORT", "not a port number")
 	}
+	return conf
 }
 
+// getConfig reads the config from env vars and the optional CONFIG_FILE,
+// logging every invalid setting before exiting if there are any.
 func getConfig() config {
-	interval, err := strconv.Atoi(getenv("HEARTBEAT_INTERVAL", "20"))
-	if err != nil {
-		logger.Fatalw("HEARTBEAT_INTERVAL parsing failed", "err", err)
+	conf, r := loadConfig(getenv("CONFIG_FILE", ""))
+	for _, key := range r.unusedFileSettings() {
+		logger.Warnw("Unknown setting in config file", "key", fileKey(key))
+	}
+	if len(r.errs) > 0 {
+		for _, err := range r.errs {
+			logger.Errorw("Invalid config", "err", err)
+		}
+		logger.Fatalw("Config validation failed", "errors", len(r.errs))
+	}
+
+	return conf
+}
+
+// loadConfig reads the config from env vars, falling back to the config file at the path
+// if it is not empty. The returned reader holds every error found and where values came from.
+func loadConfig(path string) (config, *configReader) {
+	settings := make(map[string]string)
+	var fileErrs []error
+	if path != "" {
+		settings, fileErrs = readConfigFile(path)
+	}
+	r := newConfigReader(settings)
+	r.errs = append(r.errs, fileErrs...)
+
+	twitterUsers := r.float("TWITTER_USERS", "320000000")
+	referenceWeight := r.float("REFERENCE_WEIGHT", "1000")
+
+	local := getLocalConfig(r)
+	var mqConf mqConfig
+	if local.Enabled {
+		mqConf = getLocalMQConfig(r)
+	} else {
+		mqConf = getMQConfig(r)
+	}
+	var dbConf dbutil.Config
+	if !local.Enabled || !local.InMemoryStore {
+		dbConf = getDBConfig(r)
 	}
 
-	return config{
-		MQ:               mustGetMQConfig(),
-		DB:               dbutil.MustGetConfig("DB"),
-		TwitterUsers:     getTwitterUsers(),
-		ReferenceWeight:  getReferenceWeight(),
-		HearbeatFile:     mustGetenv("HEARTBEAT_FILE"),
-		HearbeatInterval: interval,
+	conf := config{
+		MQ:               mqConf,
+		DB:               dbConf,
+		TwitterUsers:     twitterUsers,
+		ReferenceWeight:  referenceWeight,
+		Scoring:          getScoringConfig(r, twitterUsers, referenceWeight),
+		Decay:            getDecayConfig(r),
+		Clustering:       getClusteringConfig(r),
+		Server:           serverConfig{Port: r.lookup("SERVER_PORT", "8080")},
+		Retry:            getRetryPolicy(r),
+		ShutdownTimeout:  r.duration("SHUTDOWN_TIMEOUT", "20s"),
+		Health:           healthConfig{MaxMessageAge: r.duration("READINESS_MAX_MESSAGE_AGE", "0s")},
+		Local:            local,
+		Ledger:           ledgerConfig{TTL: r.duration("MESSAGE_LEDGER_TTL", "24h")},
+		Scrape:           getScrapeConfig(r),
+		URLs:             getURLConfig(r),
+		Publishers:       publisherConfig{RefreshInterval: r.duration("PUBLISHER_REFRESH_INTERVAL", "1m")},
+		RefererQuality:   getRefererQualityConfig(r),
+		HearbeatFile:     r.lookup("HEARTBEAT_FILE", ""),
+		HearbeatInterval: r.integer("HEARTBEAT_INTERVAL", "20", 1),
+	}
+	validateConfig(r, conf)
+	return conf, r
+}
+
+// validateConfig checks settings that are only invalid in combination, or that are checked
+// when the env is set up.
+func validateConfig(r *configReader, conf config) {
+	_, err := domain.NewScorer(conf.ScorerConfig())
+	switch errors.Cause(err) {
+	case nil:
+	case domain.ErrUnknownScoringStrategy:
+		r.invalid("SCORING_STRATEGY", "must be one of %s, %s, %s or %s",
+			domain.LinearScoring, domain.LogScoring, domain.DiminishingScoring, domain.CappedScoring)
+		// The users are not checked by NewScorer when the strategy is unknown.
+		invalidateUsers(r, conf)
+	case domain.ErrInvalidUsers:
+		invalidateUsers(r, conf)
+	default:
+		r.invalid("SCORING_STRATEGY", "%s", err)
+	}
+
+	_, err = domain.NewDecayer(conf.DecayConfig())
+	switch err {
+	case nil:
+	case domain.ErrInvalidHalfLife:
+		if !r.failed("DECAY_HALF_LIFE") {
+			r.invalid("DECAY_HALF_LIFE", "must be positive")
+		}
+	case domain.ErrInvalidGravity:
+		if !r.failed("DECAY_GRAVITY") {
+			r.invalid("DECAY_GRAVITY", "must be positive")
+		}
+	default:
+		r.invalid("DECAY_MODEL", "must be one of %s, %s or %s",
+			domain.NoDecay, domain.ExponentialDecay, domain.GravityDecay)
+	}
+	if conf.Decay.enabled() && conf.Decay.Interval <= 0 && !r.failed("DECAY_INTERVAL") {
+		r.invalid("DECAY_INTERVAL", "must be positive")
+	}
+
+	_, err = domain.NewLeaderPolicy(conf.LeaderConfig())
+	if err == domain.ErrNoPreferredSources {
+		r.invalid("CLUSTERING_PREFERRED_SOURCES", "required by the %s leader policy", domain.PreferredSourceLeader)
+	} else if err != nil {
+		r.invalid("CLUSTERING_LEADER_POLICY", "must be one of %s, %s, %s or %s", domain.HighestScoreLeader,
+			domain.FirstSeenLeader, domain.PreferredSourceLeader, domain.LongestBodyLeader)
+	}
+
+	if conf.Local.Enabled && conf.Local.RankObjectsInput == stdio && conf.Local.ScrapedArticlesInput == stdio {
+		r.invalid("LOCAL_SCRAPED_ARTICLES_INPUT", errMultipleStdinInputs.Error())
+	}
+}
+
+// invalidateUsers records an error for twitter and every referer source whose users are not positive,
+// unless the setting could not be read at all.
+func invalidateUsers(r *configReader, conf config) {
+	if conf.TwitterUsers <= 0 && !r.failed("TWITTER_USERS") {
+		r.invalid("TWITTER_USERS", "must be positive")
+	}
+	for _, source := range domain.RefererSources {
+		key := "REFERER_" + strings.ToUpper(source) + "_USERS"
+		norm, ok := conf.Scoring.Sources[source]
+		if ok && norm.Users <= 0 && !r.failed(key) {
+			r.invalid(key, "must be positive")
+		}
 	}
 }
 
@@ -78,33 +354,181 @@ func (c config) MQConfig() mq.Config {
 	return mq.NewConfig(c.MQ.Host, c.MQ.Port, c.MQ.User, c.MQ.Password, c.MQ.PrefetchCount)
 }
 
-func getTwitterUsers() float64 {
-	twitterUsersStr := getenv("TWITTER_USERS", "320000000")
-	twitterUsers, err := strconv.ParseFloat(twitterUsersStr, 64)
-	if err != nil {
-		logger.Fatalw("TWITTER_USERS parsing failed", "err", err)
+func (c config) ScorerConfig() domain.ScorerConfig {
+	return domain.ScorerConfig{
+		Strategy:          c.Scoring.Strategy,
+		TwitterUsers:      c.TwitterUsers,
+		ReferenceWeight:   c.ReferenceWeight,
+		DiminishingFactor: c.Scoring.DiminishingFactor,
+		AuthorCap:         c.Scoring.AuthorCap,
+		Sources:           c.Scoring.Sources,
 	}
+}
 
-	return twitterUsers
+func getScoringConfig(r *configReader, twitterUsers, referenceWeight float64) scoringConfig {
+	return scoringConfig{
+		Strategy:          r.lookup("SCORING_STRATEGY", domain.LinearScoring),
+		DiminishingFactor: r.float("SCORING_DIMINISHING_FACTOR", "0.5"),
+		AuthorCap:         r.float("SCORING_AUTHOR_CAP", "1.0"),
+		Sources:           getSourceNormalizations(r, twitterUsers, referenceWeight),
+	}
 }
 
-func getReferenceWeight() float64 {
-	weightStr := getenv("REFERENCE_WEIGHT", "1000")
-	weight, err := strconv.ParseFloat(weightStr, 64)
-	if err != nil {
-		logger.Fatalw("REFERENCE_WEIGHT parsing failed", "err", err)
+// getSourceNormalizations reads REFERER_<SOURCE>_USERS and REFERER_<SOURCE>_WEIGHT for each
+// non twitter referer source, sources default to being normalized as twitter.
+func getSourceNormalizations(r *configReader, twitterUsers, referenceWeight float64) map[string]domain.SourceNormalization {
+	sources := make(map[string]domain.SourceNormalization)
+	for _, source := range domain.RefererSources {
+		if source == domain.TwitterSource {
+			continue
+		}
+
+		prefix := "REFERER_" + strings.ToUpper(source)
+		sources[source] = domain.SourceNormalization{
+			Users:  r.float(prefix+"_USERS", formatFloat(twitterUsers)),
+			Weight: r.float(prefix+"_WEIGHT", formatFloat(referenceWeight)),
+		}
 	}
+	return sources
+}
 
-	return weight
+func (c config) RefererQualityConfig() domain.RefererQualityConfig {
+	return domain.RefererQualityConfig{
+		BurstWindow:        c.RefererQuality.BurstWindow,
+		BurstSize:          c.RefererQuality.BurstSize,
+		BurstGroupWindow:   c.RefererQuality.BurstGroupWindow,
+		BurstGroupArticles: c.RefererQuality.BurstGroupArticles,
+		SpreadWindow:       c.RefererQuality.SpreadWindow,
+		SpreadArticles:     c.RefererQuality.SpreadArticles,
+		FollowerJumpRatio:  c.RefererQuality.FollowerJumpRatio,
+		Penalty:            c.RefererQuality.Penalty,
+		Recovery:           c.RefererQuality.Recovery,
+	}
 }
 
-func mustGetenv(key string) string {
-	val := os.Getenv(key)
-	if val == "" {
-		logger.Fatalf("No value for key: %s", key)
+// getRefererQualityConfig reads the REFERER_QUALITY_ settings, a zero window, size, number of articles
+// or ratio disables the signal. All signals are disabled by default.
+func getRefererQualityConfig(r *configReader) refererQualityConfig {
+	conf := refererQualityConfig{
+		BurstWindow:        r.duration("REFERER_QUALITY_BURST_WINDOW", "10s"),
+		BurstSize:          r.integer("REFERER_QUALITY_BURST_SIZE", "0", 0),
+		BurstGroupWindow:   r.duration("REFERER_QUALITY_BURST_GROUP_WINDOW", "24h"),
+		BurstGroupArticles: r.integer("REFERER_QUALITY_BURST_GROUP_ARTICLES", "0", 0),
+		SpreadWindow:       r.duration("REFERER_QUALITY_SPREAD_WINDOW", "1h"),
+		SpreadArticles:     r.integer("REFERER_QUALITY_SPREAD_ARTICLES", "0", 0),
+		FollowerJumpRatio:  r.float("REFERER_QUALITY_FOLLOWER_JUMP_RATIO", "0"),
+		Penalty:            r.float("REFERER_QUALITY_PENALTY", "0.5"),
+		Recovery:           r.float("REFERER_QUALITY_RECOVERY", "0.05"),
+		RefreshInterval:    r.duration("REFERER_QUALITY_REFRESH_INTERVAL", "1m"),
+	}
+	if conf.BurstGroupArticles == 1 {
+		r.invalid("REFERER_QUALITY_BURST_GROUP_ARTICLES", "must be 0 or at least 2")
+	}
+	if conf.FollowerJumpRatio != 0 && conf.FollowerJumpRatio <= 1 {
+		r.invalid("REFERER_QUALITY_FOLLOWER_JUMP_RATIO", "must be 0 or greater than 1")
 	}
+	if conf.Penalty < 0 || conf.Penalty > 1 {
+		r.invalid("REFERER_QUALITY_PENALTY", "must be between 0 and 1")
+	}
+	if conf.Recovery < 0 || conf.Recovery > 1 {
+		r.invalid("REFERER_QUALITY_RECOVERY", "must be between 0 and 1")
+	}
+	return conf
+}
 
-	return val
+func (c config) LeaderConfig() domain.LeaderConfig {
+	return domain.LeaderConfig{
+		Policy:           c.Clustering.LeaderPolicy,
+		PreferredSources: c.Clustering.PreferredSources,
+	}
+}
+
+func (c config) DecayConfig() domain.DecayConfig {
+	return domain.DecayConfig{
+		Model:    c.Decay.Model,
+		HalfLife: c.Decay.HalfLife,
+		Gravity:  c.Decay.Gravity,
+	}
+}
+
+func getDecayConfig(r *configReader) decayConfig {
+	return decayConfig{
+		Model:    r.lookup("DECAY_MODEL", domain.NoDecay),
+		HalfLife: r.duration("DECAY_HALF_LIFE", "24h"),
+		Gravity:  r.float("DECAY_GRAVITY", "1.8"),
+		Interval: r.duration("DECAY_INTERVAL", "15m"),
+		Window:   r.duration("DECAY_WINDOW", "168h"),
+	}
+}
+
+func getClusteringConfig(r *configReader) clusteringConfig {
+	strategy := r.lookup("CLUSTERING_STRATEGY", domain.ExactClustering)
+	if !domain.ValidClusteringStrategy(strategy) {
+		r.invalid("CLUSTERING_STRATEGY", "unknown clustering strategy")
+	}
+
+	repeatAuthorWeight := r.float("CLUSTERING_REPEAT_AUTHOR_WEIGHT", "0")
+	if repeatAuthorWeight < 0 || repeatAuthorWeight > 1 {
+		r.invalid("CLUSTERING_REPEAT_AUTHOR_WEIGHT", "must be between 0 and 1")
+	}
+
+	// The default allows 6 of the 64 fingerprint bits to differ.
+	similarityThreshold := r.float("CLUSTERING_SIMILARITY_THRESHOLD", "0.9")
+	if similarityThreshold < 0 || similarityThreshold > 1 {
+		r.invalid("CLUSTERING_SIMILARITY_THRESHOLD", "must be between 0 and 1")
+	}
+
+	return clusteringConfig{
+		Strategy:            strategy,
+		SimilarityThreshold: similarityThreshold,
+		CandidateLimit:      r.integer("CLUSTERING_CANDIDATE_LIMIT", "500", 1),
+		Window:              r.duration("CLUSTERING_WINDOW", "48h"),
+		LeaderPolicy:        r.lookup("CLUSTERING_LEADER_POLICY", domain.HighestScoreLeader),
+		PreferredSources:    splitList(r.lookup("CLUSTERING_PREFERRED_SOURCES", "")),
+		DedupeAuthors:       r.boolean("CLUSTERING_DEDUPE_AUTHORS", "false"),
+		RepeatAuthorWeight:  repeatAuthorWeight,
+	}
+}
+
+// splitList splits a comma separated list, dropping empty items.
+func splitList(list string) []string {
+	items := make([]string, 0)
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func getRetryPolicy(r *configReader) retryPolicy {
+	return retryPolicy{
+		MaxAttempts:      r.integer("RETRY_MAX_ATTEMPTS", "3", 1),
+		DelayQueueSuffix: r.lookup("RETRY_DELAY_QUEUE_SUFFIX", ""),
+	}
+}
+
+func getScrapeConfig(r *configReader) scrapeConfig {
+	return scrapeConfig{
+		PendingTimeout: r.duration("PENDING_SCRAPE_TIMEOUT", "10m"),
+		MaxAttempts:    r.integer("PENDING_SCRAPE_MAX_ATTEMPTS", "3", 1),
+	}
+}
+
+// getURLConfig reads the per domain rules as a JSON object from URL_RULES,
+// e.g. {"youtube.com": {"keepParams": ["v"]}, "youtu.be": {"host": "youtube.com"}}.
+func getURLConfig(r *configReader) urlConfig {
+	rules := make(map[string]domain.URLRule)
+	err := json.Unmarshal([]byte(r.lookup("URL_RULES", "{}")), &rules)
+	if err != nil {
+		r.invalid("URL_RULES", "invalid JSON: %s", err)
+	}
+
+	return urlConfig{
+		Rules:            rules,
+		ResolveRedirects: r.boolean("URL_RESOLVE_REDIRECTS", "true"),
+	}
 }
 
 func getenv(key, defaultVal string) string {
@@ -0,0 +1,373 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/BurntSushi/toml"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// Config file formats, picked by file extension.
+const (
+	yamlFormat = "yaml"
+	tomlFormat = "toml"
+)
+
+// Sources of config values.
+const (
+	envSource     = "env"
+	fileSource    = "file"
+	defaultSource = "default"
+)
+
+// redacted replaces secret values when the config is printed.
+const redacted = "******"
+
+// configReader reads settings from env vars, falling back to the config file and then to
+// defaults. Invalid settings are collected so that all of them can be reported at once.
+type configReader struct {
+	file       map[string]string
+	values     map[string]configValue
+	failedKeys map[string]bool
+	errs       []error
+}
+
+// configValue is the value a setting was read as and where it came from.
+type configValue struct {
+	value  string
+	source string
+}
+
+func newConfigReader(file map[string]string) *configReader {
+	return &configReader{
+		file:       file,
+		values:     make(map[string]configValue),
+		failedKeys: make(map[string]bool),
+		errs:       make([]error, 0),
+	}
+}
+
+func (r *configReader) lookup(key, defaultVal string) string {
+	val := configValue{value: os.Getenv(key), source: envSource}
+	if val.value == "" {
+		val = configValue{value: r.file[key], source: fileSource}
+	}
+	if val.value == "" {
+		val = configValue{value: defaultVal, source: defaultSource}
+	}
+
+	r.values[key] = val
+	return val.value
+}
+
+func (r *configReader) required(key string) string {
+	val := r.lookup(key, "")
+	if val == "" {
+		r.failedKeys[key] = true
+		r.errs = append(r.errs, errors.Errorf("%s (%s) is required", key, fileKey(key)))
+	}
+	return val
+}
+
+func (r *configReader) duration(key, defaultVal string) time.Duration {
+	duration, err := time.ParseDuration(r.lookup(key, defaultVal))
+	if err != nil {
+		r.invalid(key, "not a duration")
+	}
+	return duration
+}
+
+func (r *configReader) float(key, defaultVal string) float64 {
+	f, err := strconv.ParseFloat(r.lookup(key, defaultVal), 64)
+	if err != nil {
+		r.invalid(key, "not a number")
+	}
+	return f
+}
+
+func (r *configReader) integer(key, defaultVal string, min int) int {
+	i, err := strconv.Atoi(r.lookup(key, defaultVal))
+	if err != nil {
+		r.invalid(key, "not an integer")
+	} else if i < min {
+		r.invalid(key, "must be at least %d", min)
+	}
+	return i
+}
+
+func (r *configReader) boolean(key, defaultVal string) bool {
+	b, err := strconv.ParseBool(r.lookup(key, defaultVal))
+	if err != nil {
+		r.invalid(key, "must be true or false")
+	}
+	return b
+}
+
+// invalid records an error for a setting that has been read.
+func (r *configReader) invalid(key, format string, args ...interface{}) {
+	val := r.values[key]
+	r.failedKeys[key] = true
+	r.errs = append(r.errs, errors.Errorf("%s (%s) %q from %s: %s",
+		key, fileKey(key), redact(key, val.value), val.source, fmt.Sprintf(format, args...)))
+}
+
+// failed returns true if an error has been recorded for any of the settings.
+func (r *configReader) failed(keys ...string) bool {
+	for _, key := range keys {
+		if r.failedKeys[key] {
+			return true
+		}
+	}
+	return false
+}
+
+// unusedFileSettings returns the keys of settings in the config file that were never read,
+// which are either misspelled or not used with the rest of the config.
+func (r *configReader) unusedFileSettings() []string {
+	keys := make([]string, 0)
+	for key := range r.file {
+		if _, ok := r.values[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// printConfig writes the settings that were read in the YAML config file format,
+// with secrets redacted and the source of each value as a comment.
+func (r *configReader) printConfig(w io.Writer) error {
+	keys := make([]string, 0, len(r.values))
+	for key := range r.values {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return fileKey(keys[i]) < fileKey(keys[j])
+	})
+
+	section := ""
+	for _, key := range keys {
+		parts := strings.SplitN(fileKey(key), ".", 2)
+		var err error
+		if parts[0] != section {
+			section = parts[0]
+			_, err = fmt.Fprintf(w, "%s:\n", section)
+		}
+		if err == nil && len(parts) == 2 {
+			val := r.values[key]
+			_, err = fmt.Fprintf(w, "  %s: %s # %s\n", parts[1], strconv.Quote(redact(key, val.value)), val.source)
+		}
+		if err != nil {
+			return errors.Wrap(err, "printing config failed")
+		}
+	}
+	return nil
+}
+
+// redact hides the values of passwords, secrets and tokens.
+func redact(key, value string) string {
+	for _, secret := range []string{"PASSWORD", "SECRET", "TOKEN"} {
+		if value != "" && strings.Contains(key, secret) {
+			return redacted
+		}
+	}
+	return value
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// readConfigFile reads a YAML or TOML config file into settings keyed by the env var they
+// stand in for. Files hold sections of scalar settings, so that e.g. maxMessageAge in the
+// readiness section is read as READINESS_MAX_MESSAGE_AGE. Every misplaced setting is reported.
+func readConfigFile(path string) (map[string]string, []error) {
+	format, err := configFileFormat(path)
+	if err != nil {
+		return nil, []error{err}
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, []error{errors.Wrap(err, "opening config file failed")}
+	}
+	defer file.Close()
+
+	if format == tomlFormat {
+		return parseTOMLConfig(file)
+	}
+	return parseYAMLConfig(file)
+}
+
+func configFileFormat(path string) (string, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yml", ".yaml":
+		return yamlFormat, nil
+	case ".toml":
+		return tomlFormat, nil
+	default:
+		return "", errors.Errorf("config file %s is neither .yaml, .yml nor .toml", path)
+	}
+}
+
+// parseYAMLConfig parses a YAML document of sections, rejecting keys given more than once.
+func parseYAMLConfig(input io.Reader) (map[string]string, []error) {
+	content, err := ioutil.ReadAll(input)
+	if err != nil {
+		return nil, []error{errors.Wrap(err, "reading config file failed")}
+	}
+
+	doc := make(map[string]interface{})
+	errs := make([]error, 0)
+	err = yaml.UnmarshalStrict(content, &doc)
+	if typeErr, ok := err.(*yaml.TypeError); ok {
+		for _, msg := range typeErr.Errors {
+			errs = append(errs, errors.Errorf("parsing config file failed: %s", msg))
+		}
+	} else if err != nil {
+		return nil, []error{errors.Wrap(err, "parsing config file failed")}
+	}
+
+	settings := make(map[string]string)
+	for _, key := range sortedKeys(doc) {
+		errs = addSettings(settings, "", key, yamlValue(doc[key]), errs)
+	}
+	return settings, errs
+}
+
+// yamlValue converts the mappings in a YAML value to maps keyed by strings, as TOML tables are decoded.
+func yamlValue(value interface{}) interface{} {
+	mapping, ok := value.(map[interface{}]interface{})
+	if !ok {
+		return value
+	}
+
+	section := make(map[string]interface{}, len(mapping))
+	for key, val := range mapping {
+		section[fmt.Sprint(key)] = yamlValue(val)
+	}
+	return section
+}
+
+// parseTOMLConfig parses a TOML document of tables.
+func parseTOMLConfig(input io.Reader) (map[string]string, []error) {
+	doc := make(map[string]interface{})
+	_, err := toml.DecodeReader(input, &doc)
+	if err != nil {
+		return nil, []error{errors.Wrap(err, "parsing config file failed")}
+	}
+
+	settings := make(map[string]string)
+	errs := make([]error, 0)
+	for _, key := range sortedKeys(doc) {
+		errs = addSettings(settings, "", key, doc[key], errs)
+	}
+	return settings, errs
+}
+
+// addSettings adds the setting, or every setting in it if it is a section, under the section.
+// Settings must be within a section and lists are joined into comma separated values.
+func addSettings(settings map[string]string, section, key string, value interface{}, errs []error) []error {
+	path := key
+	if section != "" {
+		path = section + "." + key
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for _, subKey := range sortedKeys(v) {
+			errs = addSettings(settings, path, subKey, v[subKey], errs)
+		}
+		return errs
+	case []interface{}:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			items = append(items, formatSetting(item))
+		}
+		value = strings.Join(items, ",")
+	}
+
+	if section == "" && value == nil {
+		return errs
+	} else if section == "" {
+		return append(errs, errors.Errorf("config file setting %s is not within a section", key))
+	}
+	name := envKey(section, key)
+	if _, exists := settings[name]; exists {
+		return append(errs, errors.Errorf("config file setting %s is set more than once", fileKey(name)))
+	}
+	settings[name] = formatSetting(value)
+	return errs
+}
+
+// formatSetting formats a setting value as it would be given in an env var.
+func formatSetting(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return formatFloat(v)
+	case time.Time:
+		return v.Format(time.RFC3339)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func sortedKeys(section map[string]interface{}) []string {
+	keys := make([]string, 0, len(section))
+	for key := range section {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// envKey returns the env var a setting in a section stands in for, e.g. maxMessageAge
+// in the readiness section stands in for READINESS_MAX_MESSAGE_AGE.
+func envKey(section, key string) string {
+	path := strings.Replace(section, ".", "_", -1)
+	if path != "" {
+		path += "_"
+	}
+
+	var name strings.Builder
+	for i, c := range key {
+		if c == '-' || c == '.' {
+			c = '_'
+		}
+		if unicode.IsUpper(c) && i > 0 && key[i-1] != '_' {
+			name.WriteRune('_')
+		}
+		name.WriteRune(unicode.ToUpper(c))
+	}
+	return strings.ToUpper(path) + name.String()
+}
+
+// fileKey returns the section and key a setting is written as in a config file.
+func fileKey(envKey string) string {
+	parts := strings.Split(strings.ToLower(envKey), "_")
+	if len(parts) == 1 {
+		return parts[0]
+	}
+
+	key := parts[1]
+	for _, part := range parts[2:] {
+		if part != "" {
+			key += strings.ToUpper(part[:1]) + part[1:]
+		}
+	}
+	return parts[0] + "." + key
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseYAMLConfig(t *testing.T) {
+	assert := assert.New(t)
+
+	input := `---
+# Broker settings
+mq:
+  host: message-queue
+  prefetchCount: 5 # per consumer
+  password: "pass # word"
+referer:
+  redditUsers: '330000000'
+server:
+  port: 8080
+`
+	settings, errs := parseYAMLConfig(strings.NewReader(input))
+	assert.Len(errs, 0)
+	assert.Equal(map[string]string{
+		"MQ_HOST":              "message-queue",
+		"MQ_PREFETCH_COUNT":    "5",
+		"MQ_PASSWORD":          "pass # word",
+		"REFERER_REDDIT_USERS": "330000000",
+		"SERVER_PORT":          "8080",
+	}, settings)
+
+	input = `host: message-queue
+mq:
+  port: 5672
+  port: 5673
+clustering:
+  preferredSources: [reuters.com, ft.com]
+  window:
+`
+	settings, errs = parseYAMLConfig(strings.NewReader(input))
+	assert.Len(errs, 2)
+	assert.Contains(errs[0].Error(), `line 4: key "port" already set in map`)
+	assert.Contains(errs[1].Error(), "host is not within a section")
+	assert.Equal("reuters.com,ft.com", settings["CLUSTERING_PREFERRED_SOURCES"])
+	assert.Equal("", settings["CLUSTERING_WINDOW"])
+
+	_, errs = parseYAMLConfig(strings.NewReader("mq:\n  password: \"unterminated\n"))
+	assert.Len(errs, 1)
+	assert.Contains(errs[0].Error(), "parsing config file failed")
+}
+
+func TestParseTOMLConfig(t *testing.T) {
+	assert := assert.New(t)
+
+	input := `# Broker settings
+[mq]
+host = "message-queue"
+prefetchCount = 5
+
+[referer.reddit]
+users = 330000000
+`
+	settings, errs := parseTOMLConfig(strings.NewReader(input))
+	assert.Len(errs, 0)
+	assert.Equal(map[string]string{
+		"MQ_HOST":              "message-queue",
+		"MQ_PREFETCH_COUNT":    "5",
+		"REFERER_REDDIT_USERS": "330000000",
+	}, settings)
+
+	_, errs = parseTOMLConfig(strings.NewReader("host = \"message-queue\"\n[referer]\nredditUsers = 1\n[referer.reddit]\nusers = 2\n"))
+	assert.Len(errs, 2)
+	assert.Contains(errs[0].Error(), "host is not within a section")
+	assert.Contains(errs[1].Error(), "referer.redditUsers is set more than once")
+
+	_, errs = parseTOMLConfig(strings.NewReader("[mq]\nhost message-queue\n"))
+	assert.Len(errs, 1)
+	assert.Contains(errs[0].Error(), "line 2")
+}
+
+func TestConfigFileFormat(t *testing.T) {
+	assert := assert.New(t)
+
+	format, err := configFileFormat("/etc/news-ranker/config.yml")
+	assert.Nil(err)
+	assert.Equal(yamlFormat, format)
+	format, err = configFileFormat("config.YAML")
+	assert.Nil(err)
+	assert.Equal(yamlFormat, format)
+	format, err = configFileFormat("config.toml")
+	assert.Nil(err)
+	assert.Equal(tomlFormat, format)
+	_, err = configFileFormat("config.json")
+	assert.NotNil(err)
+}
+
+func TestEnvAndFileKeys(t *testing.T) {
+	assert := assert.New(t)
+
+	cases := []struct {
+		section string
+		key     string
+		envKey  string
+	}{
+		{section: "mq", key: "host", envKey: "MQ_HOST"},
+		{section: "mq", key: "clusterUpdatesRoutingKey", envKey: "MQ_CLUSTER_UPDATES_ROUTING_KEY"},
+		{section: "readiness", key: "maxMessageAge", envKey: "READINESS_MAX_MESSAGE_AGE"},
+		{section: "referer", key: "stocktwitsWeight", envKey: "REFERER_STOCKTWITS_WEIGHT"},
+	}
+	for _, c := range cases {
+		assert.Equal(c.envKey, envKey(c.section, c.key))
+		assert.Equal(c.section+"."+c.key, fileKey(c.envKey))
+	}
+	assert.Equal("REFERER_REDDIT_USERS", envKey("referer.reddit", "users"))
+	assert.Equal("MQ_PREFETCH_COUNT", envKey("mq", "prefetch-count"))
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mimir-news/news-ranker/pkg/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadConfig(t *testing.T) {
+	assert := assert.New(t)
+
+	path := writeTestConfigFile(t, "config.yml", `local:
+  mode: true
+  store: memory
+scoring:
+  strategy: capped
+  authorCap: 0.5
+clustering:
+  window: 24h
+server:
+  port: 9090
+`)
+	defer os.RemoveAll(filepath.Dir(path))
+	os.Setenv("SERVER_PORT", "7070")
+	defer os.Unsetenv("SERVER_PORT")
+
+	conf, r := loadConfig(path)
+	assert.Len(r.errs, 0)
+	assert.True(conf.Local.Enabled)
+	assert.True(conf.Local.InMemoryStore)
+	assert.Equal(domain.CappedScoring, conf.Scoring.Strategy)
+	assert.Equal(0.5, conf.Scoring.AuthorCap)
+	assert.Equal(24*time.Hour, conf.Clustering.Window)
+	assert.Equal("7070", conf.Server.Port)
+	assert.Equal("q-rank-objects", conf.MQ.RankQueue)
+	assert.Equal(3, conf.Retry.MaxAttempts)
+
+	assert.Equal(configValue{value: "7070", source: envSource}, r.values["SERVER_PORT"])
+	assert.Equal(configValue{value: "capped", source: fileSource}, r.values["SCORING_STRATEGY"])
+	assert.Equal(configValue{value: "3", source: defaultSource}, r.values["RETRY_MAX_ATTEMPTS"])
+	assert.Len(r.unusedFileSettings(), 0)
+}
+
+func TestLoadConfig_ReportsAllErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	path := writeTestConfigFile(t, "config.toml", `[mq]
+prefetchCount = "many"
+workersPerQueue = 0
+
+[scoring]
+strategy = "loudest"
+
+[twitter]
+users = 0
+
+[decay]
+model = "gravity"
+halfLife = "a day"
+gravity = 0
+interval = "0s"
+
+[clustering]
+strategy = "fuzzy"
+similarityThreshold = 1.5
+
+[server]
+prot = 8080
+
+[referer]
+qualityPenalty = 1.5
+qualityFollowerJumpRatio = 0.5
+`)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	_, r := loadConfig(path)
+	errs := make([]string, 0, len(r.errs))
+	for _, err := range r.errs {
+		errs = append(errs, err.Error())
+	}
+	report := strings.Join(errs, "\n")
+	for _, key := range []string{
+		"MQ_PREFETCH_COUNT", "MQ_WORKERS_PER_QUEUE", "MQ_HOST", "MQ_PASSWORD", "DB_HOST",
+		"SCORING_STRATEGY", "TWITTER_USERS", "DECAY_HALF_LIFE", "DECAY_GRAVITY", "DECAY_INTERVAL",
+		"CLUSTERING_STRATEGY", "CLUSTERING_SIMILARITY_THRESHOLD", "REFERER_QUALITY_PENALTY", "REFERER_QUALITY_FOLLOWER_JUMP_RATIO",
+	} {
+		assert.Contains(report, key)
+	}
+	assert.Contains(report, `MQ_PREFETCH_COUNT (mq.prefetchCount) "many" from file: not an integer`)
+	assert.Equal([]string{"SERVER_PROT"}, r.unusedFileSettings())
+
+	_, r = loadConfig(filepath.Join(filepath.Dir(path), "missing.yml"))
+	assert.Contains(r.errs[0].Error(), "opening config file failed")
+}
+
+func TestGetDBConfig(t *testing.T) {
+	assert := assert.New(t)
+
+	r := newConfigReader(map[string]string{
+		"DB_HOST":     "db-pooler",
+		"DB_PORT":     "5432",
+		"DB_NAME":     "newsranker",
+		"DB_USERNAME": "newsranker",
+		"DB_PASSWORD": "secret",
+	})
+	conf := getDBConfig(r)
+	assert.Len(r.errs, 0)
+	assert.Equal("db-pooler", conf.Host)
+	assert.Equal("5432", conf.Port)
+	assert.Equal("newsranker", conf.User)
+
+	r = newConfigReader(map[string]string{"DB_PORT": "postgres"})
+	getDBConfig(r)
+	assert.Len(r.errs, 5)
+	assert.True(r.failed("DB_PORT"))
+	assert.True(r.failed("DB_SSL_MODE", "DB_HOST"))
+	assert.False(r.failed("DB_SSL_MODE"))
+	assert.Contains(r.errs[4].Error(), `DB_PORT (db.port) "postgres" from file: not a port number`)
+}
+
+func TestConfigReader_PrintConfig(t *testing.T) {
+	assert := assert.New(t)
+
+	r := newConfigReader(map[string]string{"MQ_PASSWORD": "secret"})
+	r.lookup("MQ_PASSWORD", "")
+	r.lookup("MQ_HOST", "localhost")
+	r.lookup("SERVER_PORT", "8080")
+
+	output := &bytes.Buffer{}
+	err := r.printConfig(output)
+	assert.Nil(err)
+	expected := `mq:
+  host: "localhost" # default
+  password: "******" # file
+server:
+  port: "8080" # default
+`
+	assert.Equal(expected, output.String())
+
+	settings, errs := parseYAMLConfig(output)
+	assert.Len(errs, 0)
+	assert.Equal(redacted, settings["MQ_PASSWORD"])
+}
+
+func writeTestConfigFile(t *testing.T, name, content string) string {
+	dir, err := ioutil.TempDir("", "news-ranker-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, name)
+	err = ioutil.WriteFile(path, []byte(content), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/mimir-news/pkg/mq"
+	"github.com/pkg/errors"
+)
+
+var errUnknownReplayQueue = errors.New("Unknown replay queue")
+
+// deadLetter is a message that could not be handled together with the reason why.
+type deadLetter struct {
+	Queue     string          `json:"queue"`
+	MessageID string          `json:"messageId"`
+	Reason    string          `json:"reason"`
+	Attempts  int             `json:"attempts"`
+	FailedAt  time.Time       `json:"failedAt"`
+	Body      json.RawMessage `json:"body,omitempty"`
+}
+
+// sendToDeadLetterQueue routes a message that exhausted its retries to the dead letter queue.
+// Returns an error if the dead letter could not be sent so that the message is rejected instead.
+func (h handler) sendToDeadLetterQueue(msg mq.Message, msgID string, attempts int, reason error) error {
+	dl := deadLetter{
+		Queue:     h.queue,
+		MessageID: msgID,
+		Reason:    reason.Error(),
+		Attempts:  attempts,
+		FailedAt:  time.Now().UTC(),
+	}
+
+	err := msg.Decode(&dl.Body)
+	if err != nil {
+		logger.Warnw("Message body is not valid JSON, dead lettering without body", "queue", h.queue, "msgID", msgID)
+		dl.Body = nil
+	}
+
+	err = h.client.Send(dl, h.exchange, h.deadLetterQueue)
+	if err != nil {
+		logger.Errorw("Sending to dead letter queue failed", "queue", h.queue, "msgID", msgID, "err", err)
+		return reason
+	}
+
+	messagesDeadLettered.WithLabelValues(h.queue).Inc()
+	logger.Warnw("Message sent to dead letter queue",
+		"queue", h.queue,
+		"deadLetterQueue", h.deadLetterQueue,
+		"msgID", msgID,
+		"attempts", attempts,
+		"reason", reason.Error())
+	return nil
+}
+
+// replayDeadLetters consumes the dead letter queue and sends each dead letter back to
+// the queue it failed in. Stops once no dead letter has arrived within the idle timeout.
+// Dead letters that cannot be replayed are logged and rejected.
+func (e *env) replayDeadLetters(idleTimeout time.Duration) error {
+	consumerID := newConsumerID()
+	messages, err := e.mqClient.Subscribe(e.config.MQ.DeadLetterQueue, consumerID)
+	if err != nil {
+		return errors.Wrap(err, "dead letter queue subscription failed")
+	}
+
+	replayed := 0
+	for {
+		select {
+		case msg, ok := <-messages:
+			if !ok {
+				logger.Infow("Dead letter replay done", "replayed", replayed)
+				return nil
+			}
+			err = e.replayDeadLetter(msg)
+			wrapMessageHandlingResult(msg, err, e.config.MQ.DeadLetterQueue)
+			if err == nil {
+				replayed++
+			}
+		case <-time.After(idleTimeout):
+			logger.Infow("Dead letter replay done", "replayed", replayed)
+			return nil
+		}
+	}
+}
+
+func (e *env) replayDeadLetter(msg mq.Message) error {
+	var dl deadLetter
+	err := msg.Decode(&dl)
+	if err != nil {
+		return errors.Wrap(err, "parsing dead letter failed")
+	}
+
+	if dl.Queue != e.rankQueue() && dl.Queue != e.scrapedQueue() {
+		return errors.Wrap(errUnknownReplayQueue, dl.Queue)
+	}
+
+	if dl.Body == nil {
+		return errors.Errorf("dead letter %s has no body to replay", dl.MessageID)
+	}
+
+	logger.Infow("Replaying dead letter", "queue", dl.Queue, "msgID", dl.MessageID, "reason", dl.Reason)
+	return e.mqClient.Send(dl.Body, e.exchange(), dl.Queue)
+}
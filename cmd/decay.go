@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/mimir-news/news-ranker/pkg/domain"
+	"github.com/mimir-news/news-ranker/pkg/repository"
+	"github.com/mimir-news/pkg/id"
+)
+
+func (e *env) decayClusterScores(ctx context.Context) {
+	for sleep(ctx, e.config.Decay.Interval) {
+		e.recomputeDecayedScores(time.Now())
+	}
+}
+
+func (e *env) recomputeDecayedScores(now time.Time) {
+	jobID := id.New()
+	clusters, err := e.clusterRepo.FindSince(now.Add(-e.config.Decay.Window))
+	if err != nil {
+		logger.Errorw("Failed retrieving clusters to decay", "jobId", jobID, "err", err)
+		return
+	}
+
+	failed, unchanged := 0, 0
+	for _, cluster := range clusters {
+		updated, err := e.decayClusterScore(cluster, now)
+		if err == nil && !updated {
+			unchanged++
+		} else if err == repository.ErrConcurrentUpdate {
+			// The cluster was rescored by a concurrent update, the next run decays it.
+			logger.Infow("Skipping concurrently updated cluster", "jobId", jobID, "clusterHash", cluster.Hash)
+			continue
+		} else if err != nil {
+			logger.Errorw("Failed to update decayed cluster score", "jobId", jobID, "clusterHash", cluster.Hash, "err", err)
+			failed++
+		}
+	}
+
+	logger.Infow("Cluster score decay done",
+		"jobId", jobID,
+		"succeded", len(clusters)-failed,
+		"unchanged", unchanged,
+		"failed", failed)
+}
+
+// decayClusterScore re-elects the cluster leader, as the policy or publishers may have changed since
+// the cluster was scored, and stores the decayed score. The leader is only stored if it changed and
+// nothing is stored if neither the leader nor the score changed, returns whether the cluster was stored.
+func (e *env) decayClusterScore(cluster domain.ArticleCluster, now time.Time) (bool, error) {
+	leadArticleID, leaderReason, score := cluster.LeadArticleID, cluster.LeaderReason, cluster.Score
+	cluster.ElectLeaderAndScore(e.leaderPolicy)
+	err := e.applyDecay(&cluster, now)
+	if err != nil {
+		return false, err
+	}
+	if cluster.LeadArticleID != leadArticleID || cluster.LeaderReason != leaderReason {
+		return true, e.clusterRepo.Update(cluster)
+	}
+	if !scoreChanged(score, cluster.Score) {
+		return false, nil
+	}
+	return true, e.clusterRepo.UpdateScore(cluster)
+}
+
+// applyDecay decays the cluster score with the reference score of each member split between
+// the referers of the member, so that each referer decays with the time it was observed.
+func (e *env) applyDecay(cluster *domain.ArticleCluster, now time.Time) error {
+	if !e.config.Decay.enabled() {
+		cluster.ApplyDecay(e.decayer, now)
+		return nil
+	}
+
+	articleIDs := make([]string, 0, len(cluster.Members))
+	for _, member := range cluster.Members {
+		articleIDs = append(articleIDs, member.ArticleID)
+	}
+	referers, err := e.articleRepo.FindReferersByArticleIDs(articleIDs)
+	if err != nil {
+		return err
+	}
+
+	for i, member := range cluster.Members {
+		cluster.Members[i].SplitReferences(e.scorer, referers[member.ArticleID])
+	}
+	cluster.ApplyDecay(e.decayer, now)
+	return nil
+}
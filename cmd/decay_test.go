@@ -0,0 +1,117 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mimir-news/news-ranker/pkg/domain"
+	"github.com/mimir-news/news-ranker/pkg/repository"
+	"github.com/mimir-news/pkg/schema/news"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecomputeDecayedScores(t *testing.T) {
+	assert := assert.New(t)
+
+	now := time.Now()
+	articleDate := now.Add(-24 * time.Hour)
+	members := []domain.ClusterMember{
+		*domain.NewClusterMember("hash-0", "a-0", 1.0, 2.0),
+		*domain.NewClusterMember("hash-0", "a-1", 2.0, 0.5),
+	}
+	members[0].ReferencedAt = articleDate
+	members[1].ReferencedAt = now
+	cluster := *domain.NewArticleCluster("title-0", "symbol-0", articleDate, "a-1", 5.5, members)
+
+	clusterRepo := &mockClusterRepo{
+		findSinceClusters: []domain.ArticleCluster{cluster},
+	}
+	mockEnv := newMockEnv(nil, clusterRepo, nil)
+	mockEnv.config.Decay.Window = 48 * time.Hour
+	mockEnv.decayer = newTestDecayer(domain.ExponentialDecay)
+
+	mockEnv.recomputeDecayedScores(now)
+
+	assert.Equal(now.Add(-48*time.Hour), clusterRepo.findSinceArg)
+	// Leader a-0 subject score 2.0 and reference score 1.0 decay one half life, a-1 reference score 2.0 none.
+	assert.Equal(0, len(clusterRepo.updateScoreArgs))
+	assert.Equal(1, clusterRepo.updateCalls)
+	assert.Equal("a-0", clusterRepo.updateArg.LeadArticleID)
+	assert.Equal(domain.HighestScoreLeader, clusterRepo.updateArg.LeaderReason)
+	assert.InDelta(1.0+0.5+2.0, clusterRepo.updateArg.Score, 1e-9)
+
+	// Only the score is stored if the same leader is re-elected.
+	cluster.ElectLeaderAndScore(mockEnv.leaderPolicy)
+	clusterRepo = &mockClusterRepo{
+		findSinceClusters: []domain.ArticleCluster{cluster},
+	}
+	mockEnv.clusterRepo = clusterRepo
+	mockEnv.recomputeDecayedScores(now)
+	assert.Equal(0, clusterRepo.updateCalls)
+	assert.InDelta(1.0+0.5+2.0, clusterRepo.updateScoreArgs[cluster.Hash], 1e-9)
+
+	// Nothing is stored if neither the leader nor the decayed score changed.
+	decayed := cluster
+	decayed.Score = 1.0 + 0.5 + 2.0
+	clusterRepo = &mockClusterRepo{
+		findSinceClusters: []domain.ArticleCluster{decayed},
+	}
+	mockEnv.clusterRepo = clusterRepo
+	mockEnv.recomputeDecayedScores(now)
+	assert.Equal(0, clusterRepo.updateCalls)
+	assert.Equal(0, len(clusterRepo.updateScoreArgs))
+
+	clusterRepo = &mockClusterRepo{
+		findSinceClusters: []domain.ArticleCluster{cluster},
+		updateScoreReturn: repository.ErrConcurrentUpdate,
+	}
+	mockEnv.clusterRepo = clusterRepo
+	mockEnv.recomputeDecayedScores(now)
+	assert.Equal(1, len(clusterRepo.updateScoreArgs))
+
+	clusterRepo = &mockClusterRepo{
+		findSinceErr: errMock,
+	}
+	mockEnv.clusterRepo = clusterRepo
+	mockEnv.recomputeDecayedScores(now)
+	assert.Equal(0, len(clusterRepo.updateScoreArgs))
+}
+
+func TestRecomputeDecayedScores_PerReferer(t *testing.T) {
+	assert := assert.New(t)
+
+	now := time.Now()
+	articleDate := now.Add(-24 * time.Hour)
+	members := []domain.ClusterMember{
+		*domain.NewClusterMember("hash-0", "a-0", 2.0, 1.0),
+	}
+	cluster := *domain.NewArticleCluster("title-0", "symbol-0", articleDate, "a-0", 3.0, members)
+	cluster.LeaderReason = domain.OnlyMemberReason
+
+	articleRepo := &mockArticleRepo{
+		articleReferers: []domain.Referer{
+			{Referer: news.Referer{ID: "r-0", ExternalID: "e-id-0", FollowerCount: 1000, ArticleID: "a-0"}, CreatedAt: articleDate},
+			{Referer: news.Referer{ID: "r-1", ExternalID: "e-id-1", FollowerCount: 1000, ArticleID: "a-0"}, CreatedAt: now},
+		},
+	}
+	clusterRepo := &mockClusterRepo{
+		findSinceClusters: []domain.ArticleCluster{cluster},
+	}
+	mockEnv := newMockEnv(articleRepo, clusterRepo, nil)
+	mockEnv.config.Decay = decayConfig{Model: domain.ExponentialDecay, Window: 48 * time.Hour}
+	mockEnv.decayer = newTestDecayer(domain.ExponentialDecay)
+
+	mockEnv.recomputeDecayedScores(now)
+
+	// Subject score 1.0 and the share 1.0 of the first referer decay one half life, the share of the latest none.
+	assert.Equal(0, clusterRepo.updateCalls)
+	assert.InDelta(0.5+0.5+1.0, clusterRepo.updateScoreArgs[cluster.Hash], 1e-9)
+
+	articleRepo.findArticleReferersErr = errMock
+	clusterRepo = &mockClusterRepo{
+		findSinceClusters: []domain.ArticleCluster{cluster},
+	}
+	mockEnv.clusterRepo = clusterRepo
+	mockEnv.recomputeDecayedScores(now)
+	assert.Equal(0, len(clusterRepo.updateScoreArgs))
+}
@@ -3,41 +3,144 @@ package main
 import (
 	"database/sql"
 
+	"github.com/mimir-news/news-ranker/pkg/domain"
 	"github.com/mimir-news/news-ranker/pkg/repository"
 	"github.com/mimir-news/pkg/dbutil"
 	"github.com/mimir-news/pkg/mq"
 )
 
 type env struct {
-	config      config
-	mqClient    mq.Client
-	articleRepo repository.ArticleRepo
-	clusterRepo repository.ClusterRepo
-	db          *sql.DB
+	config       config
+	mqClient     mq.Client
+	articleRepo  repository.ArticleRepo
+	clusterRepo  repository.ClusterRepo
+	scorer       domain.Scorer
+	decayer      domain.Decayer
+	leaderPolicy domain.LeaderPolicy
+	publishers   *publisherCache
+	db           *sql.DB
+	// Serializes reading and rewriting of the same article or cluster by concurrent workers.
+	articleLocks keyedMutex
+	clusterLocks keyedMutex
+	// Serializes registering and resolving pending scrapes of the same URL.
+	pendingScrapeLocks keyedMutex
+	// Tracked to report readiness.
+	subscriptions     *subscriptionStatus
+	messageLedger     repository.MessageLedger
+	pendingScrapeRepo repository.PendingScrapeRepo
+	urlCanonicalizer  domain.URLCanonicalizer
+	urlRedirectRepo   repository.URLRedirectRepo
+	refererQuality    *refererQuality
 }
 
 func setupEnv(conf config) *env {
-	mqClient, err := mq.NewClient(conf.MQConfig(), conf.MQ.HealthTarget)
+	decayer, err := domain.NewDecayer(conf.DecayConfig())
 	if err != nil {
-		logger.Fatalw("MQ connection failed", "err", err)
+		logger.Fatalw("Decayer creation failed", "err", err)
 	}
 
-	db, err := conf.DB.ConnectPostgres()
+	mqClient := newMQClient(conf)
+	store := repository.NewMemoryStore()
+	db, articleRepo, clusterRepo := setupRepos(conf, store)
+	messageLedger := repository.NewMemoryMessageLedger()
+	pendingScrapeRepo := repository.NewMemoryPendingScrapeRepo(store)
+	urlRedirectRepo := repository.NewMemoryURLRedirectRepo()
+	publisherRepo := repository.NewMemoryPublisherRepo()
+	refererQualityRepo := repository.NewMemoryRefererQualityRepo()
+	if db != nil {
+		messageLedger = repository.NewMessageLedger(db)
+		pendingScrapeRepo = repository.NewPendingScrapeRepo(db)
+		urlRedirectRepo = repository.NewURLRedirectRepo(db)
+		publisherRepo = repository.NewPublisherRepo(db)
+		refererQualityRepo = repository.NewRefererQualityRepo(db)
+	}
+
+	publishers := newPublisherCache(publisherRepo)
+	err = publishers.refresh()
 	if err != nil {
-		logger.Fatalw("DB connection failed", "err", err)
+		logger.Errorw("Loading publishers failed, using default authority", "err", err)
+	}
+
+	quality := newRefererQuality(refererQualityRepo, conf.RefererQualityConfig())
+	err = quality.refresh()
+	if err != nil {
+		logger.Errorw("Loading referer qualities failed, no referers are discounted", "err", err)
 	}
-	runMigrations(db)
 
-	articleRepo := repository.NewArticleRepo(db)
-	clusterRepo := repository.NewClusterRepo(db)
+	scorerConfig := conf.ScorerConfig()
+	scorerConfig.Qualities = quality
+	scorer, err := domain.NewScorer(scorerConfig)
+	if err != nil {
+		logger.Fatalw("Scorer creation failed", "err", err)
+	}
+
+	leaderConfig := conf.LeaderConfig()
+	leaderConfig.Publishers = publishers
+	leaderPolicy, err := domain.NewLeaderPolicy(leaderConfig)
+	if err != nil {
+		logger.Fatalw("Leader policy creation failed", "err", err)
+	}
 
 	return &env{
-		config:      conf,
-		mqClient:    mqClient,
-		articleRepo: articleRepo,
-		clusterRepo: clusterRepo,
-		db:          db,
+		config:            conf,
+		mqClient:          mqClient,
+		articleRepo:       newInstrumentedArticleRepo(articleRepo),
+		clusterRepo:       newInstrumentedClusterRepo(clusterRepo),
+		scorer:            scorer,
+		decayer:           decayer,
+		leaderPolicy:      leaderPolicy,
+		publishers:        publishers,
+		refererQuality:    quality,
+		db:                db,
+		subscriptions:     newSubscriptionStatus(),
+		messageLedger:     messageLedger,
+		pendingScrapeRepo: pendingScrapeRepo,
+		urlCanonicalizer:  domain.NewURLCanonicalizer(conf.URLs.Rules),
+		urlRedirectRepo:   urlRedirectRepo,
+	}
+}
+
+// newMQClient connects to the broker, or in local mode opens the local inputs and output.
+func newMQClient(conf config) mq.Client {
+	if conf.Local.Enabled {
+		client, err := openLocalMQClient(conf.Local, conf.MQ)
+		if err != nil {
+			logger.Fatalw("Local MQ setup failed", "err", err)
+		}
+		logger.Infow("Running in local mode",
+			"rankObjectsInput", conf.Local.RankObjectsInput,
+			"scrapedArticlesInput", conf.Local.ScrapedArticlesInput,
+			"output", conf.Local.Output)
+		return client
+	}
+
+	client, err := mq.NewClient(conf.MQConfig(), conf.MQ.HealthTarget)
+	if err != nil {
+		logger.Fatalw("MQ connection failed", "err", err)
+	}
+	return client
+}
+
+// setupRepos connects to postgres, unless articles and clusters are kept in memory in local mode
+// in which case no database is returned.
+func setupRepos(conf config, store *repository.MemoryStore) (*sql.DB, repository.ArticleRepo, repository.ClusterRepo) {
+	if conf.Local.Enabled && conf.Local.InMemoryStore {
+		logger.Infow("Storing articles and clusters in memory")
+		return nil, repository.NewMemoryArticleRepo(store), repository.NewMemoryClusterRepo(store)
+	}
+
+	db := connectDB(conf)
+	return db, repository.NewArticleRepo(db), repository.NewClusterRepo(db)
+}
+
+// connectDB connects to postgres and migrates the schema.
+func connectDB(conf config) *sql.DB {
+	db, err := conf.DB.ConnectPostgres()
+	if err != nil {
+		logger.Fatalw("DB connection failed", "err", err)
 	}
+	runMigrations(db)
+	return db
 }
 
 func runMigrations(db *sql.DB) {
@@ -53,14 +156,28 @@ func (e *env) close() {
 		logger.Errorw("MQ close failed", "err", err)
 	}
 
-	err = e.db.Close()
+	if e.db != nil {
+		closeDB(e.db)
+	}
+}
+
+func closeDB(db *sql.DB) {
+	err := db.Close()
 	if err != nil {
 		logger.Errorw("DB close failed", "err", err)
 	}
 }
 
 func (e *env) newSubscriptionHandler(queue string, fn handlerFunc) handler {
-	return newHandler(queue, e.mqClient, fn)
+	h := newHandler(
+		queue, e.mqClient, fn, e.config.Retry, e.exchange(),
+		e.config.MQ.DeadLetterQueue, e.config.MQ.WorkersPerQueue)
+	h.status = e.subscriptions
+	if e.config.Ledger.TTL > 0 {
+		h.ledger = e.messageLedger
+		h.ledgerTTL = e.config.Ledger.TTL
+	}
+	return h
 }
 
 func (e *env) exchange() string {
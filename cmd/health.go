@@ -1,25 +1,135 @@
 package main
 
 import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
 	"time"
 
 	"github.com/CzarSimon/go-file-heartbeat/heartbeat"
 	"github.com/mimir-news/pkg/dbutil"
 	"github.com/mimir-news/pkg/id"
+	"github.com/pkg/errors"
 )
 
-func (e *env) healthCheck() {
-	for {
-		sleep(e.config.HearbeatInterval)
-		checkID := id.New()
-		err := dbutil.IsConnected(e.db)
+// Health statuses.
+const (
+	statusOK          = "ok"
+	statusUnavailable = "unavailable"
+)
+
+var (
+	errMQDisconnected       = errors.New("MQ disconnected")
+	errSubscriptionInactive = errors.New("subscription inactive")
+)
+
+type healthResponse struct {
+	Status string        `json:"status"`
+	Checks []checkResult `json:"checks,omitempty"`
+}
+
+type checkResult struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+}
+
+// dependencyCheck checks the health of a dependency, returning a description of its state
+// and an error if it is unhealthy.
+type dependencyCheck struct {
+	name  string
+	check func() (string, error)
+}
+
+func (e *env) handleLiveness(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, healthResponse{Status: statusOK})
+}
+
+func (e *env) handleReadiness(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, e.readinessChecks())
+}
+
+func writeHealth(w http.ResponseWriter, checks []dependencyCheck) {
+	res, ok := runChecks(checks)
+	if !ok {
+		writeJSON(w, http.StatusServiceUnavailable, res)
+		return
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+func runChecks(checks []dependencyCheck) (healthResponse, bool) {
+	res := healthResponse{
+		Status: statusOK,
+		Checks: make([]checkResult, 0, len(checks)),
+	}
+
+	for _, c := range checks {
+		result := checkResult{Name: c.name, Status: statusOK}
+		message, err := c.check()
+		result.Message = message
 		if err != nil {
-			logger.Errorw("health check failed", "reason", "DB not connected", "healthCheckId", checkID, "err", err)
-			continue
+			result.Status = statusUnavailable
+			result.Message = err.Error()
+			res.Status = statusUnavailable
 		}
+		res.Checks = append(res.Checks, result)
+	}
+
+	return res, res.Status == statusOK
+}
+
+// dependencyChecks returns checks of the services the ranker depends on.
+func (e *env) dependencyChecks() []dependencyCheck {
+	return []dependencyCheck{
+		{name: "db", check: e.checkDB},
+		{name: "mq", check: e.checkMQ},
+	}
+}
+
+// readinessChecks returns checks of dependencies and the subscriptions to each queue.
+func (e *env) readinessChecks() []dependencyCheck {
+	checks := e.dependencyChecks()
+	for _, queue := range []string{e.rankQueue(), e.scrapedQueue()} {
+		checks = append(checks, e.subscriptionCheck(queue))
+	}
+	return checks
+}
+
+func (e *env) checkDB() (string, error) {
+	if e.db == nil {
+		return "in-memory store", nil
+	}
+	return "", dbutil.IsConnected(e.db)
+}
+
+func (e *env) checkMQ() (string, error) {
+	if !e.mqClient.Connected() {
+		return "", errMQDisconnected
+	}
+	return "", nil
+}
+
+func (e *env) subscriptionCheck(queue string) dependencyCheck {
+	return dependencyCheck{
+		name: "subscription:" + queue,
+		check: func() (string, error) {
+			return e.subscriptions.check(queue, e.config.Health.MaxMessageAge, time.Now())
+		},
+	}
+}
 
-		if !e.mqClient.Connected() {
-			logger.Errorw("health check failed", "reason", "MQ disconnected", "healthCheckId", checkID)
+// healthCheck emits a heartbeat to the configured file for as long as the dependencies are healthy.
+func (e *env) healthCheck(ctx context.Context) {
+	for {
+		if !sleep(ctx, time.Duration(e.config.HearbeatInterval)*time.Second) {
+			return
+		}
+		checkID := id.New()
+		res, ok := runChecks(e.dependencyChecks())
+		if !ok {
+			logger.Errorw("health check failed", "healthCheckId", checkID, "checks", res.Checks)
 			continue
 		}
 
@@ -29,7 +139,70 @@ func (e *env) healthCheck() {
 
 }
 
-func sleep(seconds int) {
-	sleepSeconds := time.Duration(seconds) * time.Second
-	time.Sleep(sleepSeconds)
+// subscriptionStatus tracks which subscriptions are active and when they last processed a message.
+// A nil subscriptionStatus tracks nothing.
+type subscriptionStatus struct {
+	mu            sync.RWMutex
+	active        map[string]bool
+	lastProcessed map[string]time.Time
+}
+
+func newSubscriptionStatus() *subscriptionStatus {
+	return &subscriptionStatus{
+		active:        make(map[string]bool),
+		lastProcessed: make(map[string]time.Time),
+	}
+}
+
+func (s *subscriptionStatus) setActive(queue string, active bool) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.active[queue] = active
+}
+
+func (s *subscriptionStatus) messageProcessed(queue string, at time.Time) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastProcessed[queue] = at
+}
+
+// check fails if the subscription to the queue is inactive or, when maxAge is positive,
+// if the last message processed is older than maxAge.
+func (s *subscriptionStatus) check(queue string, maxAge time.Duration, now time.Time) (string, error) {
+	if s == nil {
+		return "", errSubscriptionInactive
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if !s.active[queue] {
+		return "", errSubscriptionInactive
+	}
+
+	lastProcessed, ok := s.lastProcessed[queue]
+	if !ok {
+		return "no message processed", nil
+	}
+
+	age := now.Sub(lastProcessed).Round(time.Second)
+	if maxAge > 0 && age > maxAge {
+		return "", errors.Errorf("last message processed %s ago, max age %s", age, maxAge)
+	}
+	return fmt.Sprintf("last message processed %s ago", age), nil
+}
+
+// sleep waits for the given duration, returns false if the context was cancelled before.
+func sleep(ctx context.Context, duration time.Duration) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(duration):
+		return true
+	}
 }
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteHealth(t *testing.T) {
+	assert := assert.New(t)
+
+	healthy := dependencyCheck{name: "healthy", check: func() (string, error) { return "fine", nil }}
+	unhealthy := dependencyCheck{name: "unhealthy", check: func() (string, error) { return "", errMock }}
+
+	res := httptest.NewRecorder()
+	writeHealth(res, []dependencyCheck{healthy})
+	assert.Equal(http.StatusOK, res.Code)
+
+	var body healthResponse
+	err := json.NewDecoder(res.Body).Decode(&body)
+	assert.Nil(err)
+	assert.Equal(statusOK, body.Status)
+	assert.Equal([]checkResult{{Name: "healthy", Status: statusOK, Message: "fine"}}, body.Checks)
+
+	res = httptest.NewRecorder()
+	writeHealth(res, []dependencyCheck{healthy, unhealthy})
+	assert.Equal(http.StatusServiceUnavailable, res.Code)
+
+	err = json.NewDecoder(res.Body).Decode(&body)
+	assert.Nil(err)
+	assert.Equal(statusUnavailable, body.Status)
+	assert.Equal(2, len(body.Checks))
+	assert.Equal(checkResult{Name: "unhealthy", Status: statusUnavailable, Message: errMock.Error()}, body.Checks[1])
+}
+
+func TestLivenessRoute(t *testing.T) {
+	assert := assert.New(t)
+
+	server := newMockEnv(nil, nil, nil).newServer()
+	res := performRequest(server.Handler, "GET", livenessRoute)
+	assert.Equal(http.StatusOK, res.Code)
+}
+
+func TestSubscriptionStatusCheck(t *testing.T) {
+	assert := assert.New(t)
+
+	now := time.Now()
+	status := newSubscriptionStatus()
+	_, err := status.check("q-0", time.Minute, now)
+	assert.Equal(errSubscriptionInactive, err)
+
+	status.setActive("q-0", true)
+	message, err := status.check("q-0", time.Minute, now)
+	assert.Nil(err)
+	assert.Equal("no message processed", message)
+
+	status.messageProcessed("q-0", now.Add(-30*time.Second))
+	message, err = status.check("q-0", time.Minute, now)
+	assert.Nil(err)
+	assert.Equal("last message processed 30s ago", message)
+
+	_, err = status.check("q-0", 10*time.Second, now)
+	assert.NotNil(err)
+
+	_, err = status.check("q-0", 0, now)
+	assert.Nil(err)
+
+	status.setActive("q-0", false)
+	_, err = status.check("q-0", time.Minute, now)
+	assert.Equal(errSubscriptionInactive, err)
+
+	var untracked *subscriptionStatus
+	untracked.setActive("q-0", true)
+	untracked.messageProcessed("q-0", now)
+	_, err = untracked.check("q-0", time.Minute, now)
+	assert.Equal(errSubscriptionInactive, err)
+}
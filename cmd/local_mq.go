@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+
+	"github.com/mimir-news/pkg/mq"
+	"github.com/pkg/errors"
+)
+
+// stdio is the path that refers to stdin for inputs and stdout for the output in local mode.
+const stdio = "-"
+
+// maxLocalMessageSize is the longest line accepted as a message, scraped articles include their body.
+const maxLocalMessageSize = 10 * 1024 * 1024
+
+var errMultipleStdinInputs = errors.New("only one local input can be read from stdin")
+
+// localMQClient is an in-process mq.Client used to run the ranker without a broker.
+// Messages are read as JSON lines from the input of each queue and sent messages are
+// written as JSON lines to the output. A subscription ends when its input is exhausted.
+type localMQClient struct {
+	inputs        map[string]io.Reader
+	output        io.Writer
+	closers       []io.Closer
+	mu            sync.Mutex
+	subscriptions map[string]chan mq.Message
+}
+
+// localMessage is a message read from a line of a local input.
+type localMessage struct {
+	queue string
+	line  int
+	body  []byte
+}
+
+// localSentMessage is the line written to the local output for each message sent.
+type localSentMessage struct {
+	Exchange   string          `json:"exchange"`
+	RoutingKey string          `json:"routingKey"`
+	Body       json.RawMessage `json:"body"`
+}
+
+func newLocalMQClient(inputs map[string]io.Reader, output io.Writer) *localMQClient {
+	return &localMQClient{
+		inputs:        inputs,
+		output:        output,
+		closers:       make([]io.Closer, 0),
+		subscriptions: make(map[string]chan mq.Message),
+	}
+}
+
+// openLocalMQClient creates a localMQClient reading the rank and scraped queues from the
+// configured inputs, queues without an input receive no messages.
+func openLocalMQClient(conf localConfig, queues mqConfig) (*localMQClient, error) {
+	if conf.RankObjectsInput == stdio && conf.ScrapedArticlesInput == stdio {
+		return nil, errMultipleStdinInputs
+	}
+
+	output, err := openLocalOutput(conf.Output)
+	if err != nil {
+		return nil, err
+	}
+
+	client := newLocalMQClient(make(map[string]io.Reader), output)
+	client.addCloser(output)
+	inputPaths := map[string]string{
+		queues.RankQueue:    conf.RankObjectsInput,
+		queues.ScrapedQueue: conf.ScrapedArticlesInput,
+	}
+	for queue, path := range inputPaths {
+		if path == "" {
+			continue
+		}
+
+		input, err := openLocalInput(path)
+		if err != nil {
+			client.Close()
+			return nil, err
+		}
+		client.inputs[queue] = input
+		client.addCloser(input)
+	}
+
+	return client, nil
+}
+
+func openLocalInput(path string) (io.ReadCloser, error) {
+	if path == stdio {
+		return ioutil.NopCloser(os.Stdin), nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "opening local input failed")
+	}
+	return file, nil
+}
+
+func openLocalOutput(path string) (io.WriteCloser, error) {
+	if path == stdio {
+		return nopWriteCloser{Writer: os.Stdout}, nil
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, errors.Wrap(err, "opening local output failed")
+	}
+	return file, nil
+}
+
+func (c *localMQClient) addCloser(closer io.Closer) {
+	c.closers = append(c.closers, closer)
+}
+
+// Send writes the message to the output along with the exchange and routing key it was sent to.
+func (c *localMQClient) Send(msg interface{}, exchange, routingKey string) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return errors.Wrap(err, "localMQClient.Send failed")
+	}
+
+	line, err := json.Marshal(localSentMessage{
+		Exchange:   exchange,
+		RoutingKey: routingKey,
+		Body:       body,
+	})
+	if err != nil {
+		return errors.Wrap(err, "localMQClient.Send failed")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, err = c.output.Write(append(line, '\n'))
+	if err != nil {
+		return errors.Wrap(err, "localMQClient.Send failed")
+	}
+	return nil
+}
+
+// Subscribe returns a channel of the messages read from the input of the queue, the channel
+// is closed once the input is exhausted. Subscriptions to the same queue share a channel.
+func (c *localMQClient) Subscribe(queue, consumerID string) (chan mq.Message, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	messages, ok := c.subscriptions[queue]
+	if ok {
+		return messages, nil
+	}
+
+	messages = make(chan mq.Message)
+	c.subscriptions[queue] = messages
+	input, ok := c.inputs[queue]
+	if !ok {
+		logger.Warnw("No local input for queue", "queue", queue)
+		close(messages)
+		return messages, nil
+	}
+
+	go readLocalMessages(queue, input, messages)
+	return messages, nil
+}
+
+func readLocalMessages(queue string, input io.Reader, messages chan<- mq.Message) {
+	defer close(messages)
+	scanner := bufio.NewScanner(input)
+	scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), maxLocalMessageSize)
+
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		body := make([]byte, len(scanner.Bytes()))
+		copy(body, scanner.Bytes())
+		messages <- &localMessage{queue: queue, line: line, body: body}
+	}
+
+	err := scanner.Err()
+	if err != nil {
+		logger.Errorw("Reading local input failed", "queue", queue, "line", line+1, "err", err)
+		return
+	}
+	logger.Infow("Local input exhausted", "queue", queue, "lines", line)
+}
+
+// Connected always returns true since there is no connection to lose.
+func (c *localMQClient) Connected() bool {
+	return true
+}
+
+// Close closes the inputs and output.
+func (c *localMQClient) Close() error {
+	var firstErr error
+	for _, closer := range c.closers {
+		err := closer.Close()
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (m *localMessage) Decode(v interface{}) error {
+	return json.Unmarshal(m.body, v)
+}
+
+func (m *localMessage) Ack() error {
+	return nil
+}
+
+// Reject logs the rejected message since there is no broker to hand it back to.
+func (m *localMessage) Reject() error {
+	logger.Warnw("Local message rejected", "queue", m.queue, "line", m.line)
+	return nil
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/mimir-news/news-ranker/pkg/domain"
+	"github.com/mimir-news/news-ranker/pkg/repository"
+	"github.com/mimir-news/pkg/schema/news"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocalMQClient_Subscribe(t *testing.T) {
+	assert := assert.New(t)
+
+	ro := getTestRankObject()
+	line, err := json.Marshal(ro)
+	assert.Nil(err)
+	input := strings.NewReader(string(line) + "\n\n" + string(line) + "\n")
+	client := newLocalMQClient(map[string]io.Reader{"q-rank-objects": input}, &bytes.Buffer{})
+
+	messages, err := client.Subscribe("q-rank-objects", "c-0")
+	assert.Nil(err)
+	sameMessages, err := client.Subscribe("q-rank-objects", "c-1")
+	assert.Nil(err)
+	assert.Equal(messages, sameMessages)
+
+	received := 0
+	for msg := range messages {
+		var decoded news.RankObject
+		err = msg.Decode(&decoded)
+		assert.Nil(err)
+		assert.Equal(ro.URLs, decoded.URLs)
+		assert.Nil(msg.Ack())
+		received++
+	}
+	assert.Equal(2, received)
+
+	messages, err = client.Subscribe("q-scraped-articles", "c-2")
+	assert.Nil(err)
+	_, ok := <-messages
+	assert.False(ok)
+}
+
+func TestLocalMQClient_Send(t *testing.T) {
+	assert := assert.New(t)
+
+	output := &bytes.Buffer{}
+	client := newLocalMQClient(nil, output)
+	assert.True(client.Connected())
+
+	target := news.ScrapeTarget{URL: "http://url.com", ArticleID: "a-0"}
+	err := client.Send(target, "x-news", "q-scrape-targets")
+	assert.Nil(err)
+	err = client.Send(target, "x-news", "q-scrape-targets")
+	assert.Nil(err)
+
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	assert.Equal(2, len(lines))
+
+	var sent localSentMessage
+	err = json.Unmarshal([]byte(lines[0]), &sent)
+	assert.Nil(err)
+	assert.Equal("x-news", sent.Exchange)
+	assert.Equal("q-scrape-targets", sent.RoutingKey)
+
+	var sentTarget news.ScrapeTarget
+	err = json.Unmarshal(sent.Body, &sentTarget)
+	assert.Nil(err)
+	assert.Equal(target, sentTarget)
+}
+
+func TestLocalMode_ReplayScrapedArticles(t *testing.T) {
+	assert := assert.New(t)
+
+	scrapedArticle := getTestScrapedArticle()
+	line, err := json.Marshal(scrapedArticle)
+	assert.Nil(err)
+	input := strings.NewReader(string(line) + "\nwill not parse\n")
+	output := &bytes.Buffer{}
+	client := newLocalMQClient(map[string]io.Reader{"q-scraped-articles": input}, output)
+
+	store := repository.NewMemoryStore()
+	mockEnv := newMockEnv(repository.NewMemoryArticleRepo(store), repository.NewMemoryClusterRepo(store), client)
+	mockEnv.config.MQ.DeadLetterQueue = "q-dead-letters"
+	mockEnv.config.Retry = testRetryPolicy(1)
+	mockEnv.config.MQ.WorkersPerQueue = 1
+
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	h := mockEnv.newSubscriptionHandler(mockEnv.scrapedQueue(), mockEnv.handleScrapedArticleMessage)
+	handleSubscription(context.Background(), h, wg)
+	wg.Wait()
+
+	article, err := mockEnv.articleRepo.FindByID(scrapedArticle.Article.ID)
+	assert.Nil(err)
+	clusterHash := domain.CalcClusterHash(article.Title, "S0", article.ArticleDate)
+	_, err = mockEnv.clusterRepo.FindByHash(clusterHash)
+	assert.Nil(err)
+
+	var sent localSentMessage
+	err = json.Unmarshal(output.Bytes(), &sent)
+	assert.Nil(err)
+	assert.Equal("q-dead-letters", sent.RoutingKey)
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyedMutex(t *testing.T) {
+	assert := assert.New(t)
+
+	var locks keyedMutex
+	counters := map[string]*int{"k-0": new(int), "k-1": new(int)}
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 100; i++ {
+		key := "k-0"
+		if i%2 == 1 {
+			key = "k-1"
+		}
+		wg.Add(1)
+		go func(key string) {
+			defer wg.Done()
+			unlock := locks.lock(key)
+			defer unlock()
+			*counters[key]++
+		}(key)
+	}
+	wg.Wait()
+
+	assert.Equal(50, *counters["k-0"])
+	assert.Equal(50, *counters["k-1"])
+	assert.Equal(0, len(locks.locks))
+}
@@ -1,8 +1,12 @@
 package main
 
 import (
+	"context"
 	"log"
+	"os"
+	"os/signal"
 	"sync"
+	"syscall"
 	"time"
 
 	_ "github.com/lib/pq"
@@ -22,18 +26,76 @@ func init() {
 
 func main() {
 	defer logger.Sync()
+	if len(os.Args) > 1 {
+		runCommand(os.Args[1], os.Args[2:])
+		return
+	}
+
 	conf := getConfig()
 	e := setupEnv(conf)
 	defer e.close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 	wg := &sync.WaitGroup{}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+	defer signal.Stop(signals)
 
 	rankObjectHandler := e.newSubscriptionHandler(e.rankQueue(), e.handleRankObjectMessage)
 	articlesHandler := e.newSubscriptionHandler(e.scrapedQueue(), e.handleScrapedArticleMessage)
-	go e.healthCheck()
-	go handleSubscription(rankObjectHandler, wg)
-	go handleSubscription(articlesHandler, wg)
+	server := e.newServer()
+	if conf.HearbeatFile != "" {
+		go e.healthCheck(ctx)
+	}
+	if conf.Decay.enabled() {
+		go e.decayClusterScores(ctx)
+	}
+	if conf.Ledger.TTL > 0 {
+		go e.pruneMessageLedger(ctx)
+	}
+	if conf.Scrape.PendingTimeout > 0 {
+		go e.resendPendingScrapes(ctx)
+	}
+	if conf.Publishers.RefreshInterval > 0 {
+		go e.refreshPublishers(ctx)
+	}
+	if conf.RefererQuality.RefreshInterval > 0 {
+		go e.refreshRefererQualities(ctx)
+	}
+	go e.serveHTTP(server)
+	wg.Add(2)
+	go handleSubscription(ctx, rankObjectHandler, wg)
+	go handleSubscription(ctx, articlesHandler, wg)
 
 	time.Sleep(initalWaitingTime)
 	logger.Infow("Application started", "name", ServiceName) // log.Println("Started", ServiceName)
-	wg.Wait()
+	waitForShutdown(signals, cancel, wg, conf.ShutdownTimeout)
+	shutdownServer(server, conf.ShutdownTimeout)
+}
+
+// waitForShutdown blocks until a termination signal is received or all subscriptions have stopped.
+// On termination the subscriptions are stopped and given until the timeout to finish in-flight messages.
+// Signals are registered by the caller before consuming starts so that none are missed.
+func waitForShutdown(signals <-chan os.Signal, cancel context.CancelFunc, wg *sync.WaitGroup, timeout time.Duration) {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		logger.Warnw("All subscriptions stopped, shutting down")
+		return
+	case sig := <-signals:
+		logger.Infow("Received signal, shutting down", "signal", sig.String(), "timeout", timeout.String())
+	}
+
+	cancel()
+	select {
+	case <-done:
+		logger.Infow("In-flight messages handled")
+	case <-time.After(timeout):
+		logger.Errorw("Shutdown timeout exceeded before in-flight messages were handled", "timeout", timeout.String())
+	}
 }
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"sort"
+	"time"
+
+	"github.com/mimir-news/news-ranker/pkg/domain"
+	"github.com/mimir-news/news-ranker/pkg/repository"
+	"github.com/mimir-news/pkg/schema/news"
+)
+
+// allArticles matches every article with an article date.
+var allArticles = repository.Filter{
+	From: time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC),
+	To:   time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC),
+}
+
+type mergeOptions struct {
+	batchSize int
+	dryRun    bool
+}
+
+type mergeStats struct {
+	Processed int
+	Updated   int
+	Merged    int
+	Failed    int
+}
+
+// articleGroup is the stored articles whose URLs resolve to the same canonical URL.
+type articleGroup struct {
+	URL      string
+	articles []news.Article
+}
+
+// mergeDuplicateArticles resolves the URLs of all stored articles and merges the articles whose
+// URLs resolve to the same canonical URL into one article stored under the canonical URL.
+func (e *env) mergeDuplicateArticles(opts mergeOptions) mergeStats {
+	groups, stats := e.groupArticlesByURL(opts.batchSize)
+	for _, group := range groups {
+		e.mergeArticleGroup(group, opts, &stats)
+	}
+	return stats
+}
+
+// groupArticlesByURL reads all articles and groups them by resolved URL, ordered by URL.
+func (e *env) groupArticlesByURL(batchSize int) ([]articleGroup, mergeStats) {
+	var stats mergeStats
+	groupsByURL := make(map[string]*articleGroup)
+	afterID := ""
+	for {
+		articles, err := e.articleRepo.FindArticles(allArticles, afterID, batchSize)
+		if err != nil {
+			logger.Errorw("Failed to retrieve articles to merge", "afterId", afterID, "err", err)
+			stats.Failed++
+			break
+		}
+		if len(articles) == 0 {
+			break
+		}
+
+		for _, article := range articles {
+			stats.Processed++
+			URL := e.resolveURL(article.URL)
+			group, ok := groupsByURL[URL]
+			if !ok {
+				group = &articleGroup{URL: URL}
+				groupsByURL[URL] = group
+			}
+			// Only what is needed to pick the article to keep.
+			group.articles = append(group.articles, news.Article{
+				ID:        article.ID,
+				URL:       article.URL,
+				CreatedAt: article.CreatedAt,
+			})
+		}
+		afterID = articles[len(articles)-1].ID
+	}
+
+	groups := make([]articleGroup, 0, len(groupsByURL))
+	for _, group := range groupsByURL {
+		groups = append(groups, *group)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].URL < groups[j].URL
+	})
+	return groups, stats
+}
+
+func (e *env) mergeArticleGroup(group articleGroup, opts mergeOptions, stats *mergeStats) {
+	article, duplicateIDs := pickArticleToKeep(group)
+	if len(duplicateIDs) == 0 && article.URL == group.URL {
+		return
+	}
+	if opts.dryRun {
+		logger.Infow("Would merge articles",
+			"articleId", article.ID, "url", group.URL, "from", article.URL, "duplicates", duplicateIDs)
+		stats.Updated++
+		stats.Merged += len(duplicateIDs)
+		return
+	}
+
+	article.URL = group.URL
+	score, err := e.mergedReferenceScore(append([]string{article.ID}, duplicateIDs...))
+	if err != nil {
+		logger.Errorw("Failed to get referers of articles to merge", "articleId", article.ID, "err", err)
+		stats.Failed++
+		return
+	}
+	article.ReferenceScore = score
+
+	err = retryConcurrentClusterUpdate(func() error {
+		return e.articleRepo.MergeArticles(article, duplicateIDs, e.mergedClusterRescorer(article))
+	}, "articleId", article.ID)
+	if err != nil {
+		logger.Errorw("Failed to merge articles", "articleId", article.ID, "duplicates", duplicateIDs, "err", err)
+		stats.Failed++
+		return
+	}
+	stats.Updated++
+	stats.Merged += len(duplicateIDs)
+}
+
+// mergedReferenceScore scores an article by the referers of the articles merged into it,
+// referers by the same author are counted once as when the articles are merged.
+func (e *env) mergedReferenceScore(articleIDs []string) (float64, error) {
+	referers, err := e.articleRepo.FindReferersByArticleIDs(articleIDs)
+	if err != nil {
+		return 0, err
+	}
+
+	merged := make([]domain.Referer, 0)
+	for _, articleID := range articleIDs {
+		for _, referer := range referers[articleID] {
+			merged = mergeReferers(merged, referer)
+		}
+	}
+	return e.scorer.Score(merged...), nil
+}
+
+// mergedClusterRescorer rescores the clusters of a merged article with the reference score of the
+// merged article. Referers deduplicated across clusters are rescored, and reference scores decayed
+// per referer, by the next update or rescore.
+func (e *env) mergedClusterRescorer(article news.Article) repository.ClusterRescorer {
+	referenceScore := article.ReferenceScore * e.authority(article.URL)
+	now := time.Now()
+	return func(cluster *domain.ArticleCluster) {
+		for i, member := range cluster.Members {
+			if member.ArticleID == article.ID {
+				cluster.Members[i].ReferenceScore = referenceScore
+			}
+		}
+		cluster.ElectLeaderAndScore(e.leaderPolicy)
+		cluster.ApplyDecay(e.decayer, now)
+	}
+}
+
+// pickArticleToKeep picks the article already stored under the canonical URL, or else the
+// first created article, to merge the rest of the group into.
+func pickArticleToKeep(group articleGroup) (news.Article, []string) {
+	articles := make([]news.Article, len(group.articles))
+	copy(articles, group.articles)
+	sort.Slice(articles, func(i, j int) bool {
+		iCanonical, jCanonical := articles[i].URL == group.URL, articles[j].URL == group.URL
+		if iCanonical != jCanonical {
+			return iCanonical
+		}
+		if !articles[i].CreatedAt.Equal(articles[j].CreatedAt) {
+			return articles[i].CreatedAt.Before(articles[j].CreatedAt)
+		}
+		return articles[i].ID < articles[j].ID
+	})
+
+	duplicateIDs := make([]string, 0, len(articles)-1)
+	for _, duplicate := range articles[1:] {
+		duplicateIDs = append(duplicateIDs, duplicate.ID)
+	}
+	return articles[0], duplicateIDs
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/mimir-news/news-ranker/pkg/repository"
+	"github.com/mimir-news/pkg/id"
+	"github.com/mimir-news/pkg/schema/news"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergeDuplicateArticles(t *testing.T) {
+	assert := assert.New(t)
+
+	store := repository.NewMemoryStore()
+	mockEnv := newMockEnv(repository.NewMemoryArticleRepo(store), repository.NewMemoryClusterRepo(store), nil)
+
+	URLs := []string{
+		"http://example.com/article?utm_source=twitter",
+		"https://www.example.com/article",
+		"https://example.com/other/",
+	}
+	articleIDs := make([]string, 0, len(URLs))
+	for i, URL := range URLs {
+		scrapedArticle := getTestScrapedArticle()
+		scrapedArticle.Article.ID = id.New()
+		scrapedArticle.Article.URL = URL
+		scrapedArticle.Subjects = []news.Subject{{ID: id.New(), Symbol: "S0", ArticleID: scrapedArticle.Article.ID}}
+		scrapedArticle.Referer = news.Referer{
+			ID:            id.New(),
+			ExternalID:    "e-id-" + strconv.Itoa(i),
+			FollowerCount: 1000,
+			ArticleID:     scrapedArticle.Article.ID,
+		}
+		assert.Nil(mockEnv.articleRepo.SaveScrapedArticle(scrapedArticle, domainReferers([]news.Referer{scrapedArticle.Referer})))
+		articleIDs = append(articleIDs, scrapedArticle.Article.ID)
+		time.Sleep(time.Millisecond)
+	}
+
+	stats := mockEnv.mergeDuplicateArticles(mergeOptions{batchSize: 2, dryRun: true})
+	assert.Equal(mergeStats{Processed: 3, Updated: 2, Merged: 1}, stats)
+	_, err := mockEnv.articleRepo.FindByID(articleIDs[1])
+	assert.Nil(err)
+
+	stats = mockEnv.mergeDuplicateArticles(mergeOptions{batchSize: 2})
+	assert.Equal(mergeStats{Processed: 3, Updated: 2, Merged: 1}, stats)
+
+	article, err := mockEnv.articleRepo.FindByURL("https://example.com/article")
+	assert.Nil(err)
+	assert.Equal(articleIDs[0], article.ID)
+	_, err = mockEnv.articleRepo.FindByID(articleIDs[1])
+	assert.Equal(repository.ErrNoSuchArticle, err)
+	referers, err := mockEnv.articleRepo.FindArticleReferers(article.ID)
+	assert.Nil(err)
+	assert.Equal(2, len(referers))
+	assertScore(mockEnv.scorer.Score(referers...), article.ReferenceScore, t)
+
+	other, err := mockEnv.articleRepo.FindByURL("https://example.com/other")
+	assert.Nil(err)
+	assert.Equal(articleIDs[2], other.ID)
+
+	stats = mockEnv.mergeDuplicateArticles(mergeOptions{batchSize: 2})
+	assert.Equal(mergeStats{Processed: 2}, stats)
+}
+
+func TestPickArticleToKeep(t *testing.T) {
+	assert := assert.New(t)
+
+	now := time.Now()
+	group := articleGroup{
+		URL: "https://example.com/article",
+		articles: []news.Article{
+			{ID: "a-0", URL: "http://example.com/article", CreatedAt: now},
+			{ID: "a-1", URL: "https://example.com/article", CreatedAt: now.Add(time.Hour)},
+			{ID: "a-2", URL: "https://example.com/article/", CreatedAt: now.Add(-time.Hour)},
+		},
+	}
+
+	article, duplicateIDs := pickArticleToKeep(group)
+	assert.Equal("a-1", article.ID)
+	assert.Equal([]string{"a-2", "a-0"}, duplicateIDs)
+
+	group.articles = group.articles[:1]
+	article, duplicateIDs = pickArticleToKeep(group)
+	assert.Equal("a-0", article.ID)
+	assert.Len(duplicateIDs, 0)
+}
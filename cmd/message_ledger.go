@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/mimir-news/pkg/id"
+	"github.com/mimir-news/pkg/mq"
+)
+
+const messageLedgerPruneInterval = time.Hour
+
+// messageClaimTimeout is for how long a message claimed by a consumer is held before another
+// consumer may handle it, in case the consumer stopped without marking it processed or releasing it.
+// The claim is renewed every messageClaimRenewInterval while the message is being handled.
+const (
+	messageClaimTimeout       = time.Minute
+	messageClaimRenewInterval = messageClaimTimeout / 3
+)
+
+// identifiedMessage is implemented by messages that carry the message id set by the broker.
+type identifiedMessage interface {
+	MessageID() string
+}
+
+// messageID returns a stable identity of a message, the broker message id if set and otherwise
+// a hash of the routing key and message body. If neither is available a random id is returned along with false.
+func messageID(msg mq.Message, routingKey string) (string, bool) {
+	if m, ok := msg.(identifiedMessage); ok && m.MessageID() != "" {
+		return m.MessageID(), true
+	}
+
+	var body json.RawMessage
+	err := msg.Decode(&body)
+	if err != nil || len(body) == 0 {
+		return id.New(), false
+	}
+
+	hash := sha256.New()
+	hash.Write([]byte(routingKey))
+	hash.Write([]byte{0})
+	hash.Write(body)
+	msgID := fmt.Sprintf("sha256-%x", hash.Sum(nil))
+	logger.Debugw("Message has no broker id, using body hash", "routingKey", routingKey, "msgID", msgID)
+	return msgID, true
+}
+
+// handleDelivery claims a consumed message in the ledger before handling it so that it is handled
+// once even if delivered more than once. Redelivered messages that have been processed are acked
+// without being handled again and messages claimed by another consumer are requeued.
+// The claim is released if handling fails so that retried and dead lettered messages can be replayed.
+// Messages republished for retry are unwrapped first and keep the id of their first delivery.
+func (h handler) handleDelivery(msg mq.Message) {
+	msg = unwrapRetry(msg)
+	msgID, stable := messageID(msg, h.queue)
+	if h.ledger == nil || !stable {
+		h.handleMessage(msg, msgID)
+		return
+	}
+
+	now := time.Now()
+	claimed, err := h.ledger.Claim(h.queue, msgID, now, now.Add(messageClaimTimeout))
+	if err != nil {
+		logger.Errorw("Message ledger claim failed", "queue", h.queue, "msgID", msgID, "err", err)
+		h.handleMessage(msg, msgID)
+		return
+	}
+	if !claimed {
+		h.handleUnclaimed(msg, msgID)
+		return
+	}
+
+	stopRenewing := h.renewClaim(msgID)
+	handled := h.handleMessage(msg, msgID)
+	stopRenewing()
+	if handled {
+		h.markProcessed(msgID)
+	} else {
+		h.releaseClaim(msgID)
+	}
+}
+
+// handleUnclaimed acks a message that has already been processed, a message claimed by another
+// consumer that has not finished handling it is requeued without counting an attempt. If that consumer
+// stopped the claim is no longer renewed and expires, so the requeued message is then handled.
+func (h handler) handleUnclaimed(msg mq.Message, msgID string) {
+	processed, err := h.ledger.IsProcessed(h.queue, msgID, time.Now())
+	if err != nil {
+		logger.Errorw("Message ledger lookup failed", "queue", h.queue, "msgID", msgID, "err", err)
+	}
+
+	if processed {
+		logger.Infow("Skipping already processed message", "queue", h.queue, "msgID", msgID)
+		messagesDuplicate.WithLabelValues(h.queue).Inc()
+		wrapMessageHandlingResult(msg, nil, h.queue)
+		return
+	}
+	logger.Infow("Message is being handled by another consumer", "queue", h.queue, "msgID", msgID)
+	h.requeue(msg, msgID, retryCount(msg))
+}
+
+// renewClaim renews the claim of a message until the returned function is called.
+func (h handler) renewClaim(msgID string) func() {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(messageClaimRenewInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				err := h.ledger.Renew(h.queue, msgID, time.Now().Add(messageClaimTimeout))
+				if err != nil {
+					logger.Errorw("Failed to renew message claim", "queue", h.queue, "msgID", msgID, "err", err)
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+func (h handler) markProcessed(msgID string) {
+	err := h.ledger.MarkProcessed(h.queue, msgID, time.Now().Add(h.ledgerTTL))
+	if err != nil {
+		logger.Errorw("Failed to record processed message", "queue", h.queue, "msgID", msgID, "err", err)
+	}
+}
+
+func (h handler) releaseClaim(msgID string) {
+	err := h.ledger.Release(h.queue, msgID)
+	if err != nil {
+		logger.Errorw("Failed to release message claim", "queue", h.queue, "msgID", msgID, "err", err)
+	}
+}
+
+// pruneMessageLedger periodically deletes expired entries from the message ledger.
+func (e *env) pruneMessageLedger(ctx context.Context) {
+	for sleep(ctx, messageLedgerPruneInterval) {
+		deleted, err := e.messageLedger.DeleteExpired(time.Now())
+		if err != nil {
+			logger.Errorw("Message ledger pruning failed", "err", err)
+			continue
+		}
+		logger.Infow("Message ledger pruned", "deleted", deleted)
+	}
+}
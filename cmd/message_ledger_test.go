@@ -0,0 +1,144 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mimir-news/news-ranker/pkg/repository"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMessageID(t *testing.T) {
+	assert := assert.New(t)
+
+	first, stable := messageID(newRecordingMessage(getTestRankObject()), "q-rank-objects")
+	assert.True(stable)
+	second, stable := messageID(newRecordingMessage(getTestRankObject()), "q-rank-objects")
+	assert.True(stable)
+	assert.Equal(first, second)
+
+	ro := getTestRankObject()
+	ro.Referer.ID = "other-referer"
+	other, stable := messageID(newRecordingMessage(ro), "q-rank-objects")
+	assert.True(stable)
+	assert.NotEqual(first, other)
+
+	otherQueue, stable := messageID(newRecordingMessage(getTestRankObject()), "q-scraped-articles")
+	assert.True(stable)
+	assert.NotEqual(first, otherQueue)
+
+	brokerID, stable := messageID(&identifiedRecordingMessage{
+		recordingMessage: newRecordingMessage(getTestRankObject()),
+		id:               "broker-msg-0",
+	}, "q-rank-objects")
+	assert.True(stable)
+	assert.Equal("broker-msg-0", brokerID)
+
+	_, stable = messageID(newRecordingMessage([]byte("will not parse")), "q-rank-objects")
+	assert.False(stable)
+}
+
+func TestHandleDelivery_Redelivered(t *testing.T) {
+	assert := assert.New(t)
+
+	ledger := repository.NewMemoryMessageLedger()
+	fn := &countingHandlerFunc{errs: []error{nil}}
+	h := newHandler("q-rank-objects", newRecordingMQClient(nil), fn.handle, testRetryPolicy(1), "x-news", "", 1)
+	h.ledger = ledger
+	h.ledgerTTL = time.Hour
+
+	first := newRecordingMessage(getTestRankObject())
+	h.handleDelivery(first)
+	redelivered := newRecordingMessage(getTestRankObject())
+	h.handleDelivery(redelivered)
+
+	assert.Equal(1, fn.calls)
+	assert.True(first.acked)
+	assert.True(redelivered.acked)
+
+	msgID, _ := messageID(first, "q-rank-objects")
+	processed, err := ledger.IsProcessed("q-rank-objects", msgID, time.Now())
+	assert.Nil(err)
+	assert.True(processed)
+
+	h.ledger = nil
+	h.handleDelivery(newRecordingMessage(getTestRankObject()))
+	assert.Equal(2, fn.calls)
+}
+
+func TestHandleDelivery_FailedNotRecorded(t *testing.T) {
+	assert := assert.New(t)
+
+	client := newRecordingMQClient(nil)
+	fn := &countingHandlerFunc{errs: []error{errMock, nil}}
+	h := newHandler("q-rank-objects", client, fn.handle, testRetryPolicy(1), "x-news", "q-dead-letters", 1)
+	h.ledger = repository.NewMemoryMessageLedger()
+	h.ledgerTTL = time.Hour
+
+	failed := newRecordingMessage(getTestRankObject())
+	h.handleDelivery(failed)
+	assert.True(failed.acked)
+	assert.Equal(1, len(client.sent))
+	msgID, _ := messageID(failed, "q-rank-objects")
+	assert.Equal(msgID, client.sent[0].msg.(deadLetter).MessageID)
+
+	replayed := newRecordingMessage(getTestRankObject())
+	h.handleDelivery(replayed)
+	assert.Equal(2, fn.calls)
+	assert.True(replayed.acked)
+}
+
+func TestHandleDelivery_InFlight(t *testing.T) {
+	assert := assert.New(t)
+
+	client := newRecordingMQClient(nil)
+	fn := &countingHandlerFunc{errs: []error{nil}}
+	h := newHandler("q-rank-objects", client, fn.handle, testRetryPolicy(2), "x-news", "q-dead-letters", 1)
+	h.ledger = repository.NewMemoryMessageLedger()
+	h.ledgerTTL = time.Hour
+
+	msg := newRecordingMessage(getTestRankObject())
+	msgID, _ := messageID(msg, "q-rank-objects")
+	now := time.Now()
+	claimed, err := h.ledger.Claim("q-rank-objects", msgID, now, now.Add(time.Minute))
+	assert.Nil(err)
+	assert.True(claimed)
+
+	h.handleDelivery(msg)
+	assert.Equal(0, fn.calls)
+	assert.True(msg.acked)
+	assert.Equal(1, len(client.sent))
+	envelope := client.sent[0].msg.(retryEnvelope)
+	assert.Equal(0, envelope.RetryCount)
+	assert.Equal(msgID, envelope.MessageID)
+
+	// Requeued while in flight any number of times without being dead lettered.
+	for i := 0; i < 3; i++ {
+		requeued := newRecordingMessage(client.sent[len(client.sent)-1].msg)
+		h.handleDelivery(requeued)
+		assert.True(requeued.acked)
+	}
+	assert.Equal(4, len(client.sent))
+	envelope = client.sent[3].msg.(retryEnvelope)
+	assert.Equal(0, envelope.RetryCount)
+	assert.Equal(msgID, envelope.MessageID)
+
+	err = h.ledger.Release("q-rank-objects", msgID)
+	assert.Nil(err)
+	retried := newRecordingMessage(envelope)
+	h.handleDelivery(retried)
+	assert.Equal(1, fn.calls)
+	assert.True(retried.acked)
+	processed, err := h.ledger.IsProcessed("q-rank-objects", msgID, time.Now())
+	assert.Nil(err)
+	assert.True(processed)
+}
+
+type identifiedRecordingMessage struct {
+	*recordingMessage
+	id string
+}
+
+func (m *identifiedRecordingMessage) MessageID() string {
+	return m.id
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const metricsNamespace = "news_ranker"
+
+// Cluster write operations.
+const (
+	clusterCreated  = "created"
+	clusterUpdated  = "updated"
+	clusterConflict = "conflict"
+)
+
+var (
+	messagesConsumed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "messages_consumed_total",
+		Help:      "Number of messages consumed per queue.",
+	}, []string{"queue"})
+
+	messagesAcked = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "messages_acked_total",
+		Help:      "Number of messages acked per queue.",
+	}, []string{"queue"})
+
+	messagesRejected = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "messages_rejected_total",
+		Help:      "Number of messages rejected per queue.",
+	}, []string{"queue"})
+
+	messagesDeadLettered = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "messages_dead_lettered_total",
+		Help:      "Number of messages sent to the dead letter queue per queue they failed in.",
+	}, []string{"queue"})
+
+	messagesRetried = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "messages_retried_total",
+		Help:      "Number of failed messages republished for another attempt per queue.",
+	}, []string{"queue"})
+
+	messagesRequeued = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "messages_requeued_total",
+		Help:      "Number of messages claimed by another consumer sent back without counting an attempt per queue.",
+	}, []string{"queue"})
+
+	messagesDuplicate = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "messages_duplicate_total",
+		Help:      "Number of redelivered messages skipped as already processed per queue.",
+	}, []string{"queue"})
+
+	messageHandlingDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "message_handling_duration_seconds",
+		Help:      "Time spent on each attempt at handling a message per queue.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"queue"})
+
+	articleUpdates = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "article_updates_total",
+		Help:      "Number of ranked existing articles per update type.",
+	}, []string{"type"})
+
+	clusterWrites = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "cluster_writes_total",
+		Help:      "Number of cluster writes per operation, conflicts are writes lost to concurrent updates.",
+	}, []string{"operation"})
+
+	clusterMembersRefreshed = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "cluster_members_refreshed_total",
+		Help:      "Number of existing cluster members whose scores were refreshed.",
+	})
+
+	scrapeTargetsPublished = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "scrape_targets_published_total",
+		Help:      "Number of scrape targets sent for scraping.",
+	})
+
+	scrapeRequestsCoalesced = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "scrape_requests_coalesced_total",
+		Help:      "Number of scrape requests merged into a pending scrape of the same URL instead of being sent.",
+	})
+
+	pendingScrapesAbandoned = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "pending_scrapes_abandoned_total",
+		Help:      "Number of pending scrapes given up on after the max number of attempts.",
+	})
+
+	clusterEventsPublished = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "cluster_events_published_total",
+		Help:      "Number of cluster updated events published per change reason.",
+	}, []string{"reason"})
+
+	refererSignals = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "referer_signals_total",
+		Help:      "Number of spam or bot signals raised by referers per signal.",
+	}, []string{"signal"})
+
+	repositoryQueryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "repository_query_duration_seconds",
+		Help:      "Latency of repository calls per repository and method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"repository", "method"})
+)
+
+func init() {
+	prometheus.MustRegister(
+		messagesConsumed,
+		messagesAcked,
+		messagesRejected,
+		messagesDeadLettered,
+		messagesRetried,
+		messagesRequeued,
+		messagesDuplicate,
+		messageHandlingDuration,
+		articleUpdates,
+		clusterWrites,
+		clusterMembersRefreshed,
+		scrapeTargetsPublished,
+		scrapeRequestsCoalesced,
+		pendingScrapesAbandoned,
+		clusterEventsPublished,
+		refererSignals,
+		repositoryQueryDuration,
+	)
+}
+
+func observeDuration(observer prometheus.Observer, start time.Time) {
+	observer.Observe(time.Since(start).Seconds())
+}
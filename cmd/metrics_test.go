@@ -0,0 +1,69 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mimir-news/news-ranker/pkg/domain"
+	"github.com/mimir-news/news-ranker/pkg/repository"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandleMessageMetrics(t *testing.T) {
+	assert := assert.New(t)
+
+	queue := "q-metrics"
+	client := newRecordingMQClient(nil)
+	fn := &countingHandlerFunc{errs: []error{nil, errMock}}
+	h := newHandler(queue, client, fn.handle, testRetryPolicy(1), "x-news", "", 1)
+
+	consumed := testutil.ToFloat64(messagesConsumed.WithLabelValues(queue))
+	acked := testutil.ToFloat64(messagesAcked.WithLabelValues(queue))
+	rejected := testutil.ToFloat64(messagesRejected.WithLabelValues(queue))
+	deadLettered := testutil.ToFloat64(messagesDeadLettered.WithLabelValues(queue))
+
+	h.handleMessage(newRecordingMessage(getTestRankObject()), "msg-0")
+	h.handleMessage(newRecordingMessage(getTestRankObject()), "msg-1")
+
+	assert.Equal(consumed+2, testutil.ToFloat64(messagesConsumed.WithLabelValues(queue)))
+	assert.Equal(acked+1, testutil.ToFloat64(messagesAcked.WithLabelValues(queue)))
+	assert.Equal(rejected+1, testutil.ToFloat64(messagesRejected.WithLabelValues(queue)))
+	assert.Equal(deadLettered, testutil.ToFloat64(messagesDeadLettered.WithLabelValues(queue)))
+}
+
+func TestRecordClusterWrite(t *testing.T) {
+	assert := assert.New(t)
+
+	created := testutil.ToFloat64(clusterWrites.WithLabelValues(clusterCreated))
+	updated := testutil.ToFloat64(clusterWrites.WithLabelValues(clusterUpdated))
+	conflicts := testutil.ToFloat64(clusterWrites.WithLabelValues(clusterConflict))
+
+	recordClusterWrite(clusterCreated, nil)
+	recordClusterWrite(clusterUpdated, repository.ErrConcurrentUpdate)
+	recordClusterWrite(clusterUpdated, errMock)
+
+	assert.Equal(created+1, testutil.ToFloat64(clusterWrites.WithLabelValues(clusterCreated)))
+	assert.Equal(updated, testutil.ToFloat64(clusterWrites.WithLabelValues(clusterUpdated)))
+	assert.Equal(conflicts+1, testutil.ToFloat64(clusterWrites.WithLabelValues(clusterConflict)))
+}
+
+func TestInstrumentedClusterRepo(t *testing.T) {
+	assert := assert.New(t)
+
+	cluster := *domain.NewArticleCluster("title-0", "AAPL", time.Now(), "a-0", 0.4, nil)
+	mockRepo := &mockClusterRepo{
+		findByHashCluster: cluster,
+		updateReturn:      errMock,
+	}
+	repo := newInstrumentedClusterRepo(mockRepo)
+
+	c, err := repo.FindByHash(cluster.Hash)
+	assert.Nil(err)
+	assert.Equal(cluster.Hash, c.Hash)
+	assert.Equal(cluster.Hash, mockRepo.findByHashArg)
+
+	err = repo.Update(cluster)
+	assert.Equal(errMock, err)
+	assert.Equal(1, mockRepo.updateCalls)
+}
@@ -0,0 +1,12 @@
+-- +migrate Up
+CREATE TABLE publisher (
+  host VARCHAR(253) PRIMARY KEY,
+  weight DOUBLE PRECISION NOT NULL,
+  paywalled BOOLEAN NOT NULL DEFAULT FALSE,
+  blocked BOOLEAN NOT NULL DEFAULT FALSE,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- +migrate Down
+DROP TABLE IF EXISTS publisher;
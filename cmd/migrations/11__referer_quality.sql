@@ -0,0 +1,16 @@
+-- +migrate Up
+CREATE TABLE referer_quality (
+  author VARCHAR(100) PRIMARY KEY,
+  quality DOUBLE PRECISION NOT NULL,
+  follower_count BIGINT NOT NULL,
+  signals INTEGER NOT NULL DEFAULT 0,
+  last_signal VARCHAR(20) NOT NULL DEFAULT '',
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX referer_quality_flagged_idx ON referer_quality(quality) WHERE quality < 1;
+
+-- +migrate Down
+DROP INDEX IF EXISTS referer_quality_flagged_idx;
+DROP TABLE IF EXISTS referer_quality;
@@ -0,0 +1,12 @@
+-- +migrate Up
+ALTER TABLE processed_message ADD COLUMN claimed_at TIMESTAMP;
+
+UPDATE processed_message SET claimed_at = processed_at;
+
+ALTER TABLE processed_message ALTER COLUMN claimed_at SET NOT NULL;
+ALTER TABLE processed_message ALTER COLUMN processed_at DROP NOT NULL;
+
+-- +migrate Down
+DELETE FROM processed_message WHERE processed_at IS NULL;
+ALTER TABLE processed_message ALTER COLUMN processed_at SET NOT NULL;
+ALTER TABLE processed_message DROP COLUMN IF EXISTS claimed_at;
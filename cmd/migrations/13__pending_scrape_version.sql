@@ -0,0 +1,5 @@
+-- +migrate Up
+ALTER TABLE pending_scrape ADD COLUMN version BIGINT NOT NULL DEFAULT 0;
+
+-- +migrate Down
+ALTER TABLE pending_scrape DROP COLUMN IF EXISTS version;
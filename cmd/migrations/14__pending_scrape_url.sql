@@ -0,0 +1,17 @@
+-- +migrate Up
+ALTER TABLE pending_scrape ADD COLUMN scrape_url VARCHAR(350);
+
+UPDATE pending_scrape SET scrape_url = url;
+
+ALTER TABLE pending_scrape ALTER COLUMN scrape_url SET NOT NULL;
+
+DELETE FROM pending_scrape p USING pending_scrape o
+  WHERE p.article_id = o.article_id AND p.url > o.url;
+
+DROP INDEX IF EXISTS pending_scrape_article_id_idx;
+CREATE UNIQUE INDEX pending_scrape_article_id_idx ON pending_scrape(article_id);
+
+-- +migrate Down
+DROP INDEX IF EXISTS pending_scrape_article_id_idx;
+CREATE INDEX pending_scrape_article_id_idx ON pending_scrape(article_id);
+ALTER TABLE pending_scrape DROP COLUMN IF EXISTS scrape_url;
@@ -0,0 +1,13 @@
+-- +migrate Up
+ALTER TABLE twitter_references ADD COLUMN created_at TIMESTAMP;
+
+UPDATE twitter_references r SET created_at = a.created_at
+  FROM article a WHERE a.id = r.article_id;
+
+ALTER TABLE twitter_references ALTER COLUMN created_at SET DEFAULT NOW();
+
+CREATE INDEX article_cluster_article_date_idx ON article_cluster(article_date);
+
+-- +migrate Down
+DROP INDEX IF EXISTS article_cluster_article_date_idx;
+ALTER TABLE twitter_references DROP COLUMN IF EXISTS created_at;
@@ -0,0 +1,8 @@
+-- +migrate Up
+ALTER TABLE article_cluster ADD COLUMN fingerprint BIGINT;
+
+CREATE INDEX article_cluster_symbol_article_date_idx ON article_cluster(symbol, article_date);
+
+-- +migrate Down
+DROP INDEX IF EXISTS article_cluster_symbol_article_date_idx;
+ALTER TABLE article_cluster DROP COLUMN IF EXISTS fingerprint;
@@ -0,0 +1,5 @@
+-- +migrate Up
+ALTER TABLE article_cluster ADD COLUMN version BIGINT NOT NULL DEFAULT 0;
+
+-- +migrate Down
+ALTER TABLE article_cluster DROP COLUMN IF EXISTS version;
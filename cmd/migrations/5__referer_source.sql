@@ -0,0 +1,9 @@
+-- +migrate Up
+ALTER TABLE twitter_references ADD COLUMN source VARCHAR(20) NOT NULL DEFAULT 'twitter';
+ALTER TABLE twitter_references ALTER COLUMN twitter_author TYPE VARCHAR(100);
+
+CREATE INDEX twitter_references_source_idx ON twitter_references(source);
+
+-- +migrate Down
+DROP INDEX IF EXISTS twitter_references_source_idx;
+ALTER TABLE twitter_references DROP COLUMN IF EXISTS source;
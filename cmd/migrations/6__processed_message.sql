@@ -0,0 +1,14 @@
+-- +migrate Up
+CREATE TABLE processed_message (
+  queue VARCHAR(100),
+  message_id VARCHAR(100),
+  processed_at TIMESTAMP NOT NULL,
+  expires_at TIMESTAMP NOT NULL,
+  PRIMARY KEY (queue, message_id)
+);
+
+CREATE INDEX processed_message_expires_at_idx ON processed_message(expires_at);
+
+-- +migrate Down
+DROP INDEX IF EXISTS processed_message_expires_at_idx;
+DROP TABLE IF EXISTS processed_message;
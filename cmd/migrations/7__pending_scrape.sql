@@ -0,0 +1,15 @@
+-- +migrate Up
+CREATE TABLE pending_scrape (
+  url VARCHAR(350) PRIMARY KEY,
+  article_id VARCHAR(50) NOT NULL,
+  subjects TEXT NOT NULL,
+  referers TEXT NOT NULL,
+  attempts INT NOT NULL,
+  requested_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX pending_scrape_requested_at_idx ON pending_scrape(requested_at);
+
+-- +migrate Down
+DROP INDEX IF EXISTS pending_scrape_requested_at_idx;
+DROP TABLE IF EXISTS pending_scrape;
@@ -0,0 +1,12 @@
+-- +migrate Up
+CREATE TABLE url_redirect (
+  source_url VARCHAR(350) PRIMARY KEY,
+  target_url VARCHAR(350) NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX pending_scrape_article_id_idx ON pending_scrape(article_id);
+
+-- +migrate Down
+DROP INDEX IF EXISTS pending_scrape_article_id_idx;
+DROP TABLE IF EXISTS url_redirect;
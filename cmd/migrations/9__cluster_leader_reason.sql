@@ -0,0 +1,5 @@
+-- +migrate Up
+ALTER TABLE article_cluster ADD COLUMN lead_reason VARCHAR(100);
+
+-- +migrate Down
+ALTER TABLE article_cluster DROP COLUMN IF EXISTS lead_reason;
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/mimir-news/news-ranker/pkg/domain"
+	"github.com/mimir-news/news-ranker/pkg/repository"
+	"github.com/mimir-news/pkg/schema/news"
+)
+
+// pendingScrapeBatchSize is the max number of timed out pending scrapes resent per sweep.
+const pendingScrapeBatchSize = 500
+
+// coalescesScrapes checks if scrape requests for a URL already sent for scraping are coalesced.
+func (e *env) coalescesScrapes() bool {
+	return e.config.Scrape.PendingTimeout > 0
+}
+
+// maxPendingScrapeUpdateAttempts is the number of times merging a rank object into a pending scrape
+// is attempted when the pending scrape is concurrently changed by another instance.
+const maxPendingScrapeUpdateAttempts = 5
+
+// requestScrape sends the scrape target for scraping unless the article URL is already pending,
+// in which case the rank object is merged into the pending scrape instead.
+func (e *env) requestScrape(URL string, target news.ScrapeTarget, rankObject news.RankObject) {
+	unlock := e.pendingScrapeLocks.lock(URL)
+	defer unlock()
+
+	for attempt := 1; attempt <= maxPendingScrapeUpdateAttempts; attempt++ {
+		err := e.tryRequestScrape(URL, target, rankObject)
+		if err != repository.ErrPendingScrapeChanged {
+			return
+		}
+		logger.Infow("Pending scrape concurrently changed, retrying",
+			"articleId", target.ArticleID,
+			"attempt", attempt)
+	}
+
+	logger.Errorw("Giving up merging into pending scrape after repeated concurrent changes",
+		"articleId", target.ArticleID,
+		"attempts", maxPendingScrapeUpdateAttempts)
+}
+
+// tryRequestScrape reads the pending scrape of the article URL and merges the rank object into it,
+// or registers a new pending scrape if there is none. Returns repository.ErrPendingScrapeChanged
+// if the pending scrape was changed after it was read. If the pending scrape cannot be read or
+// updated the scrape target is sent for scraping, so that the rank object is not lost.
+func (e *env) tryRequestScrape(URL string, target news.ScrapeTarget, rankObject news.RankObject) error {
+	pending, err := e.pendingScrapeRepo.FindByURL(URL)
+	if err == repository.ErrNoSuchPendingScrape {
+		err = e.registerPendingScrape(URL, target)
+		if err != repository.ErrPendingScrapeExists {
+			return nil
+		}
+		pending, err = e.pendingScrapeRepo.FindByURL(URL)
+	}
+	if err != nil {
+		logger.Errorw("Getting pending scrape failed", "articleId", target.ArticleID, "err", err)
+		e.queueScrapeTarget(target)
+		return err
+	}
+
+	pending.Merge(rankObject)
+	err = e.pendingScrapeRepo.Update(pending)
+	if err == repository.ErrPendingScrapeChanged {
+		return err
+	} else if err != nil {
+		logger.Errorw("Updating pending scrape failed", "articleId", pending.ArticleID, "err", err)
+		e.queueScrapeTarget(target)
+		return err
+	}
+	scrapeRequestsCoalesced.Inc()
+	logger.Infow("Scrape request merged into pending scrape", "articleId", pending.ArticleID)
+	return nil
+}
+
+// registerPendingScrape stores the scrape target as pending under the article URL and sends it for scraping,
+// unless the URL was registered concurrently by another instance in which case ErrPendingScrapeExists is returned.
+func (e *env) registerPendingScrape(URL string, target news.ScrapeTarget) error {
+	err := e.pendingScrapeRepo.Save(domain.NewPendingScrape(URL, target, time.Now()))
+	if err == repository.ErrPendingScrapeExists {
+		return err
+	} else if err != nil {
+		logger.Errorw("Storing pending scrape failed", "articleId", target.ArticleID, "err", err)
+	}
+
+	// If sending fails the stored pending scrape is resent once it times out.
+	e.queueScrapeTarget(target)
+	return nil
+}
+
+// lockPendingScrape returns the pending scrape of an article if there is one, its URL stays
+// locked until the returned unlock function is called.
+func (e *env) lockPendingScrape(articleID string) (domain.PendingScrape, bool, func()) {
+	if !e.coalescesScrapes() {
+		return domain.PendingScrape{}, false, func() {}
+	}
+
+	pending, err := e.pendingScrapeRepo.FindByArticleID(articleID)
+	if err != nil {
+		if err != repository.ErrNoSuchPendingScrape {
+			logger.Errorw("Getting pending scrape failed", "articleId", articleID, "err", err)
+		}
+		return domain.PendingScrape{}, false, func() {}
+	}
+
+	// Read again under the lock, since the pending scrape may have been given up on since.
+	unlock := e.pendingScrapeLocks.lock(pending.URL)
+	pending, err = e.pendingScrapeRepo.FindByURL(pending.URL)
+	if err != nil || pending.ArticleID != articleID {
+		unlock()
+		return domain.PendingScrape{}, false, func() {}
+	}
+	return pending, true, unlock
+}
+
+func (e *env) deletePendingScrape(pending domain.PendingScrape) {
+	err := e.pendingScrapeRepo.Delete(pending.URL)
+	if err != nil {
+		logger.Errorw("Deleting pending scrape failed", "articleId", pending.ArticleID, "err", err)
+	}
+}
+
+// resendPendingScrapes periodically resends pending scrapes for which no scraped article
+// has arrived within the timeout.
+func (e *env) resendPendingScrapes(ctx context.Context) {
+	for sleep(ctx, e.config.Scrape.PendingTimeout/2) {
+		e.resendTimedOutScrapes(time.Now())
+	}
+}
+
+func (e *env) resendTimedOutScrapes(now time.Time) {
+	requestedBefore := now.Add(-e.config.Scrape.PendingTimeout)
+	pendingScrapes, err := e.pendingScrapeRepo.FindRequestedBefore(requestedBefore, pendingScrapeBatchSize)
+	if err != nil {
+		logger.Errorw("Getting timed out pending scrapes failed", "err", err)
+		return
+	}
+
+	for _, pending := range pendingScrapes {
+		e.resendPendingScrape(pending.URL, now)
+	}
+}
+
+// resendPendingScrape sends a timed out pending scrape again, or gives up on it once
+// it has been sent the max number of times.
+func (e *env) resendPendingScrape(URL string, now time.Time) {
+	unlock := e.pendingScrapeLocks.lock(URL)
+	defer unlock()
+
+	// Read again under the lock, since the scraped article may have arrived since.
+	pending, err := e.pendingScrapeRepo.FindByURL(URL)
+	if err == repository.ErrNoSuchPendingScrape {
+		return
+	} else if err != nil {
+		logger.Errorw("Getting pending scrape failed", "url", URL, "err", err)
+		return
+	}
+	if !pending.IsExpired(now, e.config.Scrape.PendingTimeout) {
+		return
+	}
+
+	if pending.Attempts >= e.config.Scrape.MaxAttempts {
+		logger.Warnw("Giving up on pending scrape", "articleId", pending.ArticleID, "attempts", pending.Attempts)
+		pendingScrapesAbandoned.Inc()
+		e.deletePendingScrape(pending)
+		return
+	}
+
+	pending.Attempts++
+	pending.RequestedAt = now
+	err = e.pendingScrapeRepo.Update(pending)
+	if err == repository.ErrPendingScrapeChanged {
+		// Changed by another instance since read, it is resent by the next sweep if still timed out.
+		logger.Infow("Pending scrape concurrently changed, skipping resend", "articleId", pending.ArticleID)
+		return
+	} else if err != nil {
+		logger.Errorw("Updating pending scrape failed", "articleId", pending.ArticleID, "err", err)
+		return
+	}
+	e.queueScrapeTarget(pending.ScrapeTarget())
+}
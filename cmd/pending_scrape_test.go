@@ -0,0 +1,182 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mimir-news/news-ranker/pkg/domain"
+	"github.com/mimir-news/news-ranker/pkg/repository"
+	"github.com/mimir-news/pkg/schema/news"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRankNewArticle_CoalescesPendingScrapes(t *testing.T) {
+	assert := assert.New(t)
+
+	mqClient := newRecordingMQClient(nil)
+	store := repository.NewMemoryStore()
+	mockEnv := newMockEnv(repository.NewMemoryArticleRepo(store), repository.NewMemoryClusterRepo(store), mqClient)
+	mockEnv.config.Scrape = scrapeConfig{PendingTimeout: time.Hour, MaxAttempts: 2}
+
+	first := getTestRankObject()
+	mockEnv.rankNewArticle(news.NewArticle("https://url.0"), "https://url.0/?utm_source=twitter", first)
+	second := getTestRankObject()
+	second.Referer.ExternalID = "e-id-1"
+	second.Subjects = []news.Subject{{Symbol: "S2", Name: "subject-2"}}
+	mockEnv.rankNewArticle(news.NewArticle("https://url.0"), "https://url.0", second)
+	mockEnv.rankNewArticle(news.NewArticle("https://url.0"), "http://url.0", first)
+
+	assert.Equal(1, len(mqClient.sent))
+	target := mqClient.sent[0].msg.(news.ScrapeTarget)
+	assert.Equal("https://url.0/?utm_source=twitter", target.URL)
+
+	pending, err := mockEnv.pendingScrapeRepo.FindByURL("https://url.0")
+	assert.Nil(err)
+	assert.Equal(target.ArticleID, pending.ArticleID)
+	assert.Equal(target.URL, pending.ScrapeTarget().URL)
+	assert.Equal(1, pending.Attempts)
+	assert.Equal(3, len(pending.Subjects))
+	assert.Equal(2, len(pending.Referers))
+	assert.Equal("e-id-1", pending.Referers[1].ExternalID)
+	assert.Equal(target.ArticleID, pending.Referers[1].ArticleID)
+}
+
+func TestRequestScrape_ConcurrentlyChanged(t *testing.T) {
+	assert := assert.New(t)
+
+	mqClient := newRecordingMQClient(nil)
+	mockEnv := newMockEnv(nil, nil, mqClient)
+	mockEnv.config.Scrape = scrapeConfig{PendingTimeout: time.Hour, MaxAttempts: 2}
+	pendingRepo := &concurrentPendingScrapeRepo{PendingScrapeRepo: mockEnv.pendingScrapeRepo, changes: 1}
+	mockEnv.pendingScrapeRepo = pendingRepo
+
+	first := getTestRankObject()
+	target := news.ScrapeTarget{URL: "https://url.0", ArticleID: "a-0", Referer: first.Referer}
+	err := pendingRepo.Save(domain.NewPendingScrape(target.URL, target, time.Now()))
+	assert.Nil(err)
+
+	second := getTestRankObject()
+	second.Referer.ExternalID = "e-id-1"
+	mockEnv.requestScrape(target.URL, target, second)
+
+	pending, err := pendingRepo.FindByURL("https://url.0")
+	assert.Nil(err)
+	assert.Equal(0, len(mqClient.sent))
+	assert.Equal(3, len(pending.Referers))
+	assert.Equal("e-id-concurrent", pending.Referers[1].ExternalID)
+	assert.Equal("e-id-1", pending.Referers[2].ExternalID)
+}
+
+func TestRequestScrape_UpdateFailed(t *testing.T) {
+	assert := assert.New(t)
+
+	mqClient := newRecordingMQClient(nil)
+	mockEnv := newMockEnv(nil, nil, mqClient)
+	mockEnv.config.Scrape = scrapeConfig{PendingTimeout: time.Hour, MaxAttempts: 2}
+	pendingRepo := &failingPendingScrapeRepo{PendingScrapeRepo: mockEnv.pendingScrapeRepo}
+	mockEnv.pendingScrapeRepo = pendingRepo
+
+	first := getTestRankObject()
+	target := news.ScrapeTarget{URL: "https://url.0", ArticleID: "a-0", Referer: first.Referer}
+	err := pendingRepo.Save(domain.NewPendingScrape(target.URL, target, time.Now()))
+	assert.Nil(err)
+
+	mockEnv.requestScrape(target.URL, target, getTestRankObject())
+	assert.Equal(1, len(mqClient.sent))
+	assert.Equal(target, mqClient.sent[0].msg.(news.ScrapeTarget))
+}
+
+// failingPendingScrapeRepo fails to update pending scrapes.
+type failingPendingScrapeRepo struct {
+	repository.PendingScrapeRepo
+}
+
+func (r *failingPendingScrapeRepo) Update(pending domain.PendingScrape) error {
+	return errMock
+}
+
+// concurrentPendingScrapeRepo merges a rank object into the pending scrape after it is read
+// the configured number of times, as if by another instance.
+type concurrentPendingScrapeRepo struct {
+	repository.PendingScrapeRepo
+	changes int
+}
+
+func (r *concurrentPendingScrapeRepo) FindByURL(url string) (domain.PendingScrape, error) {
+	pending, err := r.PendingScrapeRepo.FindByURL(url)
+	if err != nil || r.changes == 0 {
+		return pending, err
+	}
+
+	r.changes--
+	concurrent := pending
+	concurrent.Merge(news.RankObject{Referer: news.Referer{ExternalID: "e-id-concurrent", FollowerCount: 10}})
+	return pending, r.PendingScrapeRepo.Update(concurrent)
+}
+
+func TestResendTimedOutScrapes(t *testing.T) {
+	assert := assert.New(t)
+
+	mqClient := newRecordingMQClient(nil)
+	mockEnv := newMockEnv(nil, nil, mqClient)
+	mockEnv.config.Scrape = scrapeConfig{PendingTimeout: time.Hour, MaxAttempts: 2}
+
+	now := time.Now()
+	target := newScrapeTarget(news.NewArticle("https://url.0"), getTestRankObject())
+	err := mockEnv.pendingScrapeRepo.Save(domain.NewPendingScrape(target.URL, target, now.Add(-30*time.Minute)))
+	assert.Nil(err)
+
+	mockEnv.resendTimedOutScrapes(now)
+	assert.Equal(0, len(mqClient.sent))
+
+	mockEnv.resendTimedOutScrapes(now.Add(time.Hour))
+	assert.Equal(1, len(mqClient.sent))
+	assert.Equal(target.ArticleID, mqClient.sent[0].msg.(news.ScrapeTarget).ArticleID)
+	pending, err := mockEnv.pendingScrapeRepo.FindByURL("https://url.0")
+	assert.Nil(err)
+	assert.Equal(2, pending.Attempts)
+
+	mockEnv.resendTimedOutScrapes(now.Add(3 * time.Hour))
+	assert.Equal(1, len(mqClient.sent))
+	_, err = mockEnv.pendingScrapeRepo.FindByURL("https://url.0")
+	assert.Equal(repository.ErrNoSuchPendingScrape, err)
+}
+
+func TestUpdateAndStoreScrapedArticle_PendingScrape(t *testing.T) {
+	assert := assert.New(t)
+
+	store := repository.NewMemoryStore()
+	mockEnv := newMockEnv(repository.NewMemoryArticleRepo(store), repository.NewMemoryClusterRepo(store), newRecordingMQClient(nil))
+	mockEnv.config.Scrape = scrapeConfig{PendingTimeout: time.Hour, MaxAttempts: 2}
+
+	scrapedArticle := getTestScrapedArticle()
+	pending := domain.NewPendingScrape(scrapedArticle.Article.URL, news.ScrapeTarget{
+		URL:       scrapedArticle.Article.URL,
+		ArticleID: scrapedArticle.Article.ID,
+		Subjects:  scrapedArticle.Subjects,
+		Referer:   scrapedArticle.Referer,
+	}, time.Now())
+	pending.Merge(news.RankObject{
+		Subjects: []news.Subject{{Symbol: "S2", Name: "subject-2"}},
+		Referer:  news.Referer{ExternalID: "e-id-pending", FollowerCount: 1000},
+	})
+	err := mockEnv.pendingScrapeRepo.Save(pending)
+	assert.Nil(err)
+
+	article, err := mockEnv.updateAndStoreScrapedArticle(scrapedArticle)
+	assert.Nil(err)
+
+	referers, err := mockEnv.articleRepo.FindArticleReferers(article.ID)
+	assert.Nil(err)
+	assert.Equal(2, len(referers))
+	subjects, err := mockEnv.articleRepo.FindArticleSubjects(article.ID)
+	assert.Nil(err)
+	assert.Equal(3, len(subjects))
+
+	stored, err := mockEnv.articleRepo.FindByID(article.ID)
+	assert.Nil(err)
+	assertScore(mockEnv.scorer.Score(referers...), stored.ReferenceScore, t)
+
+	_, err = mockEnv.pendingScrapeRepo.FindByURL(scrapedArticle.Article.URL)
+	assert.Equal(repository.ErrNoSuchPendingScrape, err)
+}
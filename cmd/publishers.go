@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/mimir-news/news-ranker/pkg/domain"
+	"github.com/mimir-news/news-ranker/pkg/repository"
+)
+
+// publisherCache keeps the publisher registry in memory between refreshes from the repository.
+type publisherCache struct {
+	repo     repository.PublisherRepo
+	mu       sync.RWMutex
+	registry domain.PublisherRegistry
+}
+
+func newPublisherCache(repo repository.PublisherRepo) *publisherCache {
+	return &publisherCache{
+		repo:     repo,
+		registry: domain.NewPublisherRegistry(nil),
+	}
+}
+
+// Find returns the publisher of the source from the cached registry.
+func (c *publisherCache) Find(source string) domain.Publisher {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.registry.Find(source)
+}
+
+// refresh replaces the cached registry with the publishers in the repository,
+// the cached registry is kept if they cannot be read.
+func (c *publisherCache) refresh() error {
+	publishers, err := c.repo.FindAll()
+	if err != nil {
+		return err
+	}
+
+	registry := domain.NewPublisherRegistry(publishers)
+	c.mu.Lock()
+	c.registry = registry
+	c.mu.Unlock()
+	return nil
+}
+
+// refreshPublishers periodically reloads the publisher registry so that changes made with
+// the publishers command are applied without a restart.
+func (e *env) refreshPublishers(ctx context.Context) {
+	for sleep(ctx, e.config.Publishers.RefreshInterval) {
+		err := e.publishers.refresh()
+		if err != nil {
+			logger.Errorw("Refreshing publishers failed", "err", err)
+		}
+	}
+}
+
+// authority returns the multiplier of the scores of articles at the URL.
+func (e *env) authority(articleURL string) float64 {
+	return e.publishers.Find(domain.ArticleSource(articleURL)).Authority()
+}
+
+// listPublishers writes the registered publishers as a table.
+func listPublishers(repo repository.PublisherRepo, w io.Writer) error {
+	publishers, err := repo.FindAll()
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "HOST\tWEIGHT\tPAYWALLED\tBLOCKED")
+	for _, p := range publishers {
+		fmt.Fprintf(tw, "%s\t%s\t%t\t%t\n", p.Host, formatFloat(p.Weight), p.Paywalled, p.Blocked)
+	}
+	return tw.Flush()
+}
+
+// addPublisher validates and stores a new publisher.
+func addPublisher(repo repository.PublisherRepo, p domain.Publisher) (domain.Publisher, error) {
+	publisher, err := domain.NewPublisher(p.Host, p.Weight, p.Paywalled, p.Blocked)
+	if err != nil {
+		return domain.Publisher{}, err
+	}
+
+	err = repo.Save(publisher)
+	if err != nil {
+		return domain.Publisher{}, err
+	}
+	return publisher, nil
+}
+
+// updatePublisher changes the settings of a stored publisher that are named in set.
+func updatePublisher(repo repository.PublisherRepo, p domain.Publisher, set map[string]bool) (domain.Publisher, error) {
+	host, err := domain.NewPublisher(p.Host, domain.DefaultAuthority, false, false)
+	if err != nil {
+		return domain.Publisher{}, err
+	}
+
+	publisher, err := repo.Find(host.Host)
+	if err != nil {
+		return domain.Publisher{}, err
+	}
+	if set["weight"] {
+		publisher.Weight = p.Weight
+	}
+	if set["paywalled"] {
+		publisher.Paywalled = p.Paywalled
+	}
+	if set["blocked"] {
+		publisher.Blocked = p.Blocked
+	}
+
+	publisher, err = domain.NewPublisher(
+		publisher.Host, publisher.Weight, publisher.Paywalled, publisher.Blocked)
+	if err != nil {
+		return domain.Publisher{}, err
+	}
+
+	err = repo.Update(publisher)
+	if err != nil {
+		return domain.Publisher{}, err
+	}
+	return publisher, nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/mimir-news/news-ranker/pkg/domain"
+	"github.com/mimir-news/news-ranker/pkg/repository"
+	"github.com/mimir-news/pkg/schema/news"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateNewCluster_PublisherAuthority(t *testing.T) {
+	assert := assert.New(t)
+
+	articleDate, err := time.Parse("2006-01-02", "2018-10-25")
+	assert.Nil(err)
+	article := news.Article{
+		ID:             "a-0",
+		URL:            "https://www.reuters.com/article/a-0",
+		Title:          "t-0",
+		ReferenceScore: 0.5,
+		ArticleDate:    articleDate,
+	}
+	subject := news.Subject{
+		Symbol:    "smbl",
+		Score:     0.3,
+		ArticleID: "a-0",
+	}
+
+	clusterRepo := &mockClusterRepo{
+		findByHashCluster: emptyCluster,
+		findByHashErr:     repository.ErrNoSuchCluster,
+	}
+	mockEnv := newMockEnv(nil, clusterRepo, nil)
+	_, err = addPublisher(mockEnv.publishers.repo, domain.Publisher{Host: "reuters.com", Weight: 2.0})
+	assert.Nil(err)
+
+	// Added publishers are not applied before the cache is refreshed.
+	mockEnv.clusterArticleWithSubject(article, subject)
+	assertScore(0.8, clusterRepo.saveArg.Score, t)
+
+	assert.Nil(mockEnv.publishers.refresh())
+	mockEnv.clusterArticleWithSubject(article, subject)
+	member := clusterRepo.saveArg.Members[0]
+	assertScore(1.0, member.ReferenceScore, t)
+	assertScore(0.6, member.SubjectScore, t)
+	assertScore(1.6, clusterRepo.saveArg.Score, t)
+
+	_, err = updatePublisher(mockEnv.publishers.repo, domain.Publisher{Host: "reuters.com", Blocked: true}, map[string]bool{"blocked": true})
+	assert.Nil(err)
+	assert.Nil(mockEnv.publishers.refresh())
+	mockEnv.clusterArticleWithSubject(article, subject)
+	assertScore(0.0, clusterRepo.saveArg.Score, t)
+}
+
+func TestPublishersCommand(t *testing.T) {
+	assert := assert.New(t)
+	mockEnv := newMockEnv(nil, nil, nil)
+
+	publisher, err := addPublisher(mockEnv.publishers.repo, domain.Publisher{Host: "WWW.FT.com", Weight: 1.2, Paywalled: true})
+	assert.Nil(err)
+	assert.Equal("ft.com", publisher.Host)
+	_, err = addPublisher(mockEnv.publishers.repo, domain.Publisher{Host: "ft.com", Weight: 1.0})
+	assert.Equal(repository.ErrPublisherExists, err)
+	_, err = addPublisher(mockEnv.publishers.repo, domain.Publisher{Host: "", Weight: 1.0})
+	assert.NotNil(err)
+
+	publisher, err = updatePublisher(mockEnv.publishers.repo, domain.Publisher{Host: "ft.com", Weight: 0.8}, map[string]bool{"weight": true})
+	assert.Nil(err)
+	assert.Equal(domain.Publisher{Host: "ft.com", Weight: 0.8, Paywalled: true}, publisher)
+	_, err = updatePublisher(mockEnv.publishers.repo, domain.Publisher{Host: "wsj.com", Weight: 0.8}, map[string]bool{"weight": true})
+	assert.Equal(repository.ErrNoSuchPublisher, err)
+
+	var out bytes.Buffer
+	assert.Nil(listPublishers(mockEnv.publishers.repo, &out))
+	assert.Equal("HOST    WEIGHT  PAYWALLED  BLOCKED\nft.com  0.8     true       false\n", out.String())
+}
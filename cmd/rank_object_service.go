@@ -6,6 +6,7 @@ import (
 	"github.com/mimir-news/pkg/id"
 	"github.com/mimir-news/pkg/mq"
 	"github.com/mimir-news/pkg/schema/news"
+	"github.com/pkg/errors"
 )
 
 func (e *env) handleRankObjectMessage(msg mq.Message, msgID string) error {
@@ -13,74 +14,97 @@ func (e *env) handleRankObjectMessage(msg mq.Message, msgID string) error {
 	ro, err := parseRankObject(msg)
 	if err != nil {
 		logger.Errorw("Parsing RankObject failed", "msgID", msgID, "err", err)
-		return err
+		return permanent(err)
 	}
 
 	failed := 0
-	for _, URL := range ro.URLs {
-		article, err := e.articleRepo.FindByURL(URL)
+	referer := domain.NewReferer(ro.Referer)
+	URLs := e.resolveURLs(ro.URLs)
+	e.observeReferer(ro.Referer, URLs)
+	for _, URL := range URLs {
+		article, err := e.articleRepo.FindByURL(URL.URL)
 		if err == repository.ErrNoSuchArticle {
-			e.rankNewArticle(news.NewArticle(URL), ro)
+			e.rankNewArticle(news.NewArticle(URL.URL), URL.RequestedURL, ro)
 			continue
 		} else if err != nil {
 			logger.Errorw("Getting article from repository failed", "msgID", msgID, "err", err)
 			failed++
 			continue
 		}
-		e.rankExistingArticle(article, ro)
+		err = e.rankExistingArticle(article, URL.RequestedURL, ro.Subjects, referer)
+		if err != nil {
+			failed++
+		}
 	}
 
 	logger.Infow("RankObject handling done",
 		"msgID", msgID,
-		"succeded", len(ro.URLs)-failed,
+		"succeded", len(URLs)-failed,
 		"failed", failed)
+	if failed > 0 {
+		return errors.Errorf("ranking %d of %d URLs failed", failed, len(URLs))
+	}
 	return nil
 }
 
-func (e *env) rankNewArticle(article news.Article, rankObject news.RankObject) {
+// rankNewArticle sends an article not yet stored for scraping from the URL it was requested at,
+// scrapes are coalesced by the URL of the article if configured.
+func (e *env) rankNewArticle(article news.Article, requestedURL string, rankObject news.RankObject) {
 	scrapeTarget := newScrapeTarget(article, rankObject)
+	scrapeTarget.URL = requestedURL
+	if e.coalescesScrapes() {
+		e.requestScrape(article.URL, scrapeTarget, rankObject)
+		return
+	}
 	e.queueScrapeTarget(scrapeTarget)
 }
 
-func (e *env) rankExistingArticle(article news.Article, rankObject news.RankObject) {
-	update, err := e.getArticleUpdate(article, rankObject)
+// rankExistingArticle ranks a stored article with new subjects or referers, an article with new
+// subjects is scraped again from the URL it was requested at.
+func (e *env) rankExistingArticle(article news.Article, requestedURL string, subjects []news.Subject, referer domain.Referer) error {
+	unlock := e.articleLocks.lock(article.ID)
+	defer unlock()
+
+	update, err := e.getArticleUpdate(article, subjects, referer)
 	if err != nil {
 		logger.Errorw("Getting article from repository failed", "err", err)
-		return
+		return err
 	}
 
+	articleUpdates.WithLabelValues(update.Type.String()).Inc()
 	switch update.Type {
 	case domain.NewSubjectsAndReferences, domain.NewSubjects:
-		e.queueScrapeTarget(update.ToScapeTarget())
+		scrapeTarget := update.ToScapeTarget()
+		scrapeTarget.URL = requestedURL
+		e.queueScrapeTarget(scrapeTarget)
 	case domain.NewReferences:
-		e.rankWithNewReferences(update)
+		return e.rankWithNewReferences(update)
 	default:
 		logger.Infow("Taking no action article",
 			"updateType", update.Type,
 			"articleId", article.ID)
 	}
+	return nil
 }
 
-func (e *env) rankWithNewReferences(update domain.ArticleUpdate) {
-	newRefScore := calcReferenceScore(e.config.TwitterUsers, e.config.ReferenceWeight, update.Referers...)
+func (e *env) rankWithNewReferences(update domain.ArticleUpdate) error {
+	newRefScore := e.scorer.Score(update.Referers...)
 	update.Article.ReferenceScore = newRefScore
 
-	err := e.articleRepo.Update(update.Article)
+	err := e.articleRepo.UpdateWithReferer(update.Article, update.NewReferer)
 	if err != nil {
-		logger.Errorw("Article update failed", "err", err)
-		return
+		logger.Errorw("Article update with new referer failed", "articleId", update.Article.ID, "err", err)
+		return err
 	}
 
-	err = e.articleRepo.SaveReferer(update.NewReferer)
+	err = e.clusterArticle(update.Article)
 	if err != nil {
-		logger.Errorw("Saving referer failed", "err", err)
-		return
+		logger.Errorw("Clustering article with new referer failed", "articleId", update.Article.ID, "err", err)
 	}
-
-	e.clusterArticle(update.Article)
+	return err
 }
 
-func (e *env) getArticleUpdate(article news.Article, rankObject news.RankObject) (domain.ArticleUpdate, error) {
+func (e *env) getArticleUpdate(article news.Article, newSubjects []news.Subject, newReferer domain.Referer) (domain.ArticleUpdate, error) {
 	subjects, err := e.articleRepo.FindArticleSubjects(article.ID)
 	if err != nil {
 		return domain.ArticleUpdate{}, err
@@ -92,7 +116,7 @@ func (e *env) getArticleUpdate(article news.Article, rankObject news.RankObject)
 	}
 
 	articleUpdate := domain.CreateArticleUpdate(
-		article, subjects, rankObject.Subjects, referers, rankObject.Referer)
+		article, subjects, newSubjects, referers, newReferer)
 	return articleUpdate, nil
 }
 
@@ -101,7 +125,9 @@ func (e *env) queueScrapeTarget(scrapeTarget news.ScrapeTarget) {
 	err := e.mqClient.Send(scrapeTarget, e.exchange(), e.scrapeQueue())
 	if err != nil {
 		logger.Errorw("Sending scrape target failed", "articleId", scrapeTarget.ArticleID, "err", err)
+		return
 	}
+	scrapeTargetsPublished.Inc()
 }
 
 func parseRankObject(msg mq.Message) (news.RankObject, error) {
@@ -110,14 +136,6 @@ func parseRankObject(msg mq.Message) (news.RankObject, error) {
 	return ro, err
 }
 
-func calcReferenceScore(twitterUsers, referenceWeight float64, references ...news.Referer) float64 {
-	var totalReferences int64
-	for _, reference := range references {
-		totalReferences += reference.FollowerCount
-	}
-	return float64(totalReferences) * referenceWeight / twitterUsers
-}
-
 func newScrapeTarget(article news.Article, ro news.RankObject) news.ScrapeTarget {
 	target := news.ScrapeTarget{
 		URL:       article.URL,
@@ -1,11 +1,13 @@
 package main
 
 import (
+	"strconv"
 	"testing"
 	"time"
 
 	"github.com/mimir-news/pkg/id"
 
+	"github.com/mimir-news/news-ranker/pkg/domain"
 	"github.com/mimir-news/news-ranker/pkg/repository"
 	"github.com/mimir-news/pkg/mq/mqtest"
 	"github.com/mimir-news/pkg/schema/news"
@@ -33,7 +35,7 @@ func TestNewScrapeTarget(t *testing.T) {
 
 	article := news.Article{
 		ID:    "a-0",
-		URL:   "http://url.0",
+		URL:   "https://url.0",
 		Title: "title",
 		Body:  "body",
 	}
@@ -69,8 +71,9 @@ func TestHandleRankObjectMessage_NewArticle(t *testing.T) {
 				ScrapeQueue: "scrape-queue",
 			},
 		},
-		mqClient:    mqtest.NewSuccessMockClient(nil),
-		articleRepo: articleRepo,
+		mqClient:       mqtest.NewSuccessMockClient(nil),
+		articleRepo:    articleRepo,
+		refererQuality: newTestRefererQuality(),
 	}
 
 	err := mockEnv.handleRankObjectMessage(message, id.New())
@@ -98,7 +101,7 @@ func TestHandleRankObjectMessage_NewArticle(t *testing.T) {
 	mockEnv.mqClient = mqtest.NewSuccessMockClient(nil)
 
 	err = mockEnv.handleRankObjectMessage(message, id.New())
-	assert.Nil(err)
+	assert.NotNil(err)
 	assert.Equal(articleURL, articleRepo.findByURLArg)
 
 	// Checks that no attempt was made to update an article.
@@ -147,7 +150,7 @@ func TestHandleRankObjectMessage_ExistingArticleNewSubjects(t *testing.T) {
 		articleSubjects:        oldSubjects,
 		findArticleSubjectsErr: nil,
 
-		articleReferers:        oldReferers,
+		articleReferers:        domainReferers(oldReferers),
 		findArticleReferersErr: nil,
 	}
 
@@ -158,8 +161,9 @@ func TestHandleRankObjectMessage_ExistingArticleNewSubjects(t *testing.T) {
 				ScrapeQueue: "scrape-queue",
 			},
 		},
-		mqClient:    mqtest.NewSuccessMockClient(nil),
-		articleRepo: articleRepo,
+		mqClient:       mqtest.NewSuccessMockClient(nil),
+		articleRepo:    articleRepo,
+		refererQuality: newTestRefererQuality(),
 	}
 
 	err := mockEnv.handleRankObjectMessage(message, id.New())
@@ -169,8 +173,8 @@ func TestHandleRankObjectMessage_ExistingArticleNewSubjects(t *testing.T) {
 	assert.Equal(article.ID, articleRepo.findArticleReferersArg)
 
 	// Checks that an only new reference update was not initated.
-	assert.Equal((news.Article{}).String(), articleRepo.updateArg.String())
-	assert.Equal((news.Referer{}).String(), articleRepo.saveRefererArg.String())
+	assert.Equal((news.Article{}).String(), articleRepo.updateWithRefererArticleArg.String())
+	assert.Equal((news.Referer{}).String(), articleRepo.updateWithRefererRefererArg.String())
 
 	articleRepo = &mockArticleRepo{
 		findByURLArticle: article,
@@ -179,20 +183,20 @@ func TestHandleRankObjectMessage_ExistingArticleNewSubjects(t *testing.T) {
 		articleSubjects:        nil,
 		findArticleSubjectsErr: errMock,
 
-		articleReferers:        oldReferers,
+		articleReferers:        domainReferers(oldReferers),
 		findArticleReferersErr: nil,
 	}
 	mockEnv.articleRepo = articleRepo
 
 	err = mockEnv.handleRankObjectMessage(message, id.New())
-	assert.Nil(err)
+	assert.NotNil(err)
 	assert.Equal(articleURL, articleRepo.findByURLArg)
 	assert.Equal(article.ID, articleRepo.findArticleSubjectsArg)
 	assert.Equal("", articleRepo.findArticleReferersArg)
 
 	// Checks that an only new reference update was not initated.
-	assert.Equal((news.Article{}).String(), articleRepo.updateArg.String())
-	assert.Equal((news.Referer{}).String(), articleRepo.saveRefererArg.String())
+	assert.Equal((news.Article{}).String(), articleRepo.updateWithRefererArticleArg.String())
+	assert.Equal((news.Referer{}).String(), articleRepo.updateWithRefererRefererArg.String())
 
 	articleRepo = &mockArticleRepo{
 		findByURLArticle: article,
@@ -201,20 +205,20 @@ func TestHandleRankObjectMessage_ExistingArticleNewSubjects(t *testing.T) {
 		articleSubjects:        oldSubjects,
 		findArticleSubjectsErr: nil,
 
-		articleReferers:        oldReferers,
+		articleReferers:        domainReferers(oldReferers),
 		findArticleReferersErr: errMock,
 	}
 	mockEnv.articleRepo = articleRepo
 
 	err = mockEnv.handleRankObjectMessage(message, id.New())
-	assert.Nil(err)
+	assert.NotNil(err)
 	assert.Equal(articleURL, articleRepo.findByURLArg)
 	assert.Equal(article.ID, articleRepo.findArticleSubjectsArg)
 	assert.Equal(article.ID, articleRepo.findArticleReferersArg)
 
 	// Checks that an only new reference update was not initated.
-	assert.Equal((news.Article{}).String(), articleRepo.updateArg.String())
-	assert.Equal((news.Referer{}).String(), articleRepo.saveRefererArg.String())
+	assert.Equal((news.Article{}).String(), articleRepo.updateWithRefererArticleArg.String())
+	assert.Equal((news.Referer{}).String(), articleRepo.updateWithRefererRefererArg.String())
 }
 
 func TestHandleRankObjectMessage_ExistingArticleNewReferers(t *testing.T) {
@@ -265,7 +269,7 @@ func TestHandleRankObjectMessage_ExistingArticleNewReferers(t *testing.T) {
 		articleSubjects:        oldSubjects,
 		findArticleSubjectsErr: nil,
 
-		articleReferers:        oldReferers,
+		articleReferers:        domainReferers(oldReferers),
 		findArticleReferersErr: nil,
 	}
 
@@ -275,12 +279,16 @@ func TestHandleRankObjectMessage_ExistingArticleNewReferers(t *testing.T) {
 				Exchange:    "mq-exchange",
 				ScrapeQueue: "scrape-queue",
 			},
-			TwitterUsers:    2000,
-			ReferenceWeight: 1.0,
 		},
-		mqClient:    mqtest.NewSuccessMockClient(nil),
-		articleRepo: articleRepo,
-		clusterRepo: &mockClusterRepo{},
+		mqClient:     mqtest.NewSuccessMockClient(nil),
+		articleRepo:  articleRepo,
+		clusterRepo:  &mockClusterRepo{},
+		scorer:       domain.NewLinearScorer(2000, 1.0),
+		decayer:      newTestDecayer(domain.NoDecay),
+		leaderPolicy: newTestLeaderPolicy(domain.HighestScoreLeader),
+		publishers:   newPublisherCache(repository.NewMemoryPublisherRepo()),
+
+		refererQuality: newTestRefererQuality(),
 	}
 
 	err := mockEnv.handleRankObjectMessage(message, id.New())
@@ -289,10 +297,11 @@ func TestHandleRankObjectMessage_ExistingArticleNewReferers(t *testing.T) {
 	assert.Equal(article.ID, articleRepo.findArticleSubjectsArg)
 	assert.Equal(article.ID, articleRepo.findArticleReferersArg)
 
-	// Checks that an only new reference update was not initated.
-	assertScore(1.0, articleRepo.updateArg.ReferenceScore, t)
-	assert.Equal(ro.Referer.ExternalID, articleRepo.saveRefererArg.ExternalID)
-	assert.Equal(ro.Referer.FollowerCount, articleRepo.saveRefererArg.FollowerCount)
+	// Checks that the article was updated together with the new referer.
+	assertScore(1.0, articleRepo.updateWithRefererArticleArg.ReferenceScore, t)
+	assert.Equal(ro.Referer.ExternalID, articleRepo.updateWithRefererRefererArg.ExternalID)
+	assert.Equal(ro.Referer.FollowerCount, articleRepo.updateWithRefererRefererArg.FollowerCount)
+	assert.Equal((news.Article{}).String(), articleRepo.updateArg.String())
 
 	articleRepo = &mockArticleRepo{
 		findByURLArticle: article,
@@ -301,47 +310,68 @@ func TestHandleRankObjectMessage_ExistingArticleNewReferers(t *testing.T) {
 		articleSubjects:        oldSubjects,
 		findArticleSubjectsErr: nil,
 
-		articleReferers:        oldReferers,
+		articleReferers:        domainReferers(oldReferers),
 		findArticleReferersErr: nil,
 
-		updateErr: errMock,
+		updateWithRefererErr: errMock,
 	}
+	clusterRepo := &mockClusterRepo{}
 	mockEnv.articleRepo = articleRepo
+	mockEnv.clusterRepo = clusterRepo
 
 	err = mockEnv.handleRankObjectMessage(message, id.New())
-	assert.Nil(err)
-
-	// Checks that an only new reference update was not initated.
-	assertScore(1.0, articleRepo.updateArg.ReferenceScore, t)
-	assert.Equal((news.Referer{}).String(), articleRepo.saveRefererArg.String())
-
-	articleRepo = &mockArticleRepo{
-		findByURLArticle: article,
-		findByURLErr:     nil,
-
-		articleSubjects:        oldSubjects,
-		findArticleSubjectsErr: nil,
+	assert.NotNil(err)
 
-		articleReferers:        oldReferers,
-		findArticleReferersErr: nil,
+	// Checks that clustering was not attempted after a failed update.
+	assertScore(1.0, articleRepo.updateWithRefererArticleArg.ReferenceScore, t)
+	assert.Equal("", clusterRepo.findByHashArg)
+}
 
-		saveRefererErr: errMock,
-	}
-	mockEnv.articleRepo = articleRepo
+func TestHandleRankObjectMessage_RepeatReferersRefreshClusters(t *testing.T) {
+	assert := assert.New(t)
 
-	err = mockEnv.handleRankObjectMessage(message, id.New())
+	store := repository.NewMemoryStore()
+	mockEnv := newMockEnv(repository.NewMemoryArticleRepo(store), repository.NewMemoryClusterRepo(store), nil)
+	scrapedArticle := getTestScrapedArticle()
+	scrapedArticle.Article.URL = "https://url.com"
+	scrapedArticle.Article.ReferenceScore = 1.0
+	err := mockEnv.articleRepo.SaveScrapedArticle(scrapedArticle, domainReferers([]news.Referer{scrapedArticle.Referer}))
 	assert.Nil(err)
+	article := scrapedArticle.Article
+	mockEnv.clusterArticle(article)
+
+	assertClusterScores := func(referenceScore float64) {
+		for _, subject := range scrapedArticle.Subjects {
+			clusterHash := domain.CalcClusterHash(article.Title, subject.Symbol, article.ArticleDate)
+			cluster, err := mockEnv.clusterRepo.FindByHash(clusterHash)
+			assert.Nil(err)
+			assert.Equal(1, len(cluster.Members))
+			assertScore(referenceScore, cluster.Members[0].ReferenceScore, t)
+			assertScore(referenceScore+subject.Score, cluster.Score, t)
+		}
+	}
+	assertClusterScores(1.0)
 
-	// Checks that an only new reference update was not initated.
-	assertScore(1.0, articleRepo.updateArg.ReferenceScore, t)
-	assert.Equal(ro.Referer.ExternalID, articleRepo.saveRefererArg.ExternalID)
-	assert.Equal(ro.Referer.FollowerCount, articleRepo.saveRefererArg.FollowerCount)
+	ro := getTestRankObject()
+	ro.URLs = []string{article.URL}
+	for i, expectedScore := range []float64{2.0, 3.0, 3.0} {
+		if i < 2 {
+			ro.Referer.ExternalID = "e-id-" + strconv.Itoa(i)
+		}
+		err = mockEnv.handleRankObjectMessage(mqtest.NewMessage(ro, false, false), id.New())
+		assert.Nil(err)
+
+		stored, err := mockEnv.articleRepo.FindByID(article.ID)
+		assert.Nil(err)
+		assertScore(expectedScore, stored.ReferenceScore, t)
+		assertClusterScores(expectedScore)
+	}
 }
 
 func getTestRankObject() news.RankObject {
 	return news.RankObject{
 		URLs: []string{
-			"http://url.0",
+			"https://url.0",
 		},
 		Subjects: []news.Subject{
 			news.Subject{
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/mimir-news/news-ranker/pkg/domain"
+	"github.com/mimir-news/news-ranker/pkg/repository"
+	"github.com/mimir-news/pkg/schema/news"
+)
+
+// refererQuality detects suspicious referers and keeps the quality of flagged authors
+// in memory, where the scorer looks it up for every referer it scores. The recent activity
+// used to detect bursts and spreads is only kept in memory, so the ranker is deployed as a
+// single instance: with several instances each would only detect the signals within the
+// share of rank objects it consumes, and the activity is lost on restart.
+type refererQuality struct {
+	repo        repository.RefererQualityRepo
+	conf        domain.RefererQualityConfig
+	authorLocks keyedMutex
+
+	activityMu sync.Mutex
+	activity   *domain.RefererActivity
+
+	mu      sync.RWMutex
+	flagged map[string]float64
+}
+
+func newRefererQuality(repo repository.RefererQualityRepo, conf domain.RefererQualityConfig) *refererQuality {
+	return &refererQuality{
+		repo:     repo,
+		conf:     conf,
+		activity: domain.NewRefererActivity(conf),
+		flagged:  make(map[string]float64),
+	}
+}
+
+// Quality returns the quality of the author, authors that are not flagged have the default quality.
+func (q *refererQuality) Quality(author string) float64 {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	quality, ok := q.flagged[author]
+	if !ok {
+		return domain.DefaultRefererQuality
+	}
+	return quality
+}
+
+// observe detects the signals raised by the referer to the articles at the URLs and stores
+// the resulting quality of its author once. Each signal is counted once however many of
+// the articles raised it. The quality is only stored if it changed, so clean referers of
+// authors that are not flagged are not written.
+func (q *refererQuality) observe(referer news.Referer, articleURLs []string, at time.Time) error {
+	author := referer.ExternalID
+	unlock := q.authorLocks.lock(author)
+	defer unlock()
+
+	previous, err := q.repo.Find(author)
+	if err == repository.ErrNoSuchAuthorQuality {
+		previous = domain.NewAuthorQuality(author)
+	} else if err != nil {
+		return err
+	}
+
+	signals := make([]string, 0)
+	raised := make(map[string]bool)
+	q.activityMu.Lock()
+	for _, articleURL := range articleURLs {
+		for _, signal := range q.activity.Detect(author, articleURL, referer.FollowerCount, previous, at) {
+			if !raised[signal] {
+				raised[signal] = true
+				signals = append(signals, signal)
+			}
+		}
+	}
+	q.activityMu.Unlock()
+
+	quality := previous.Observe(referer.FollowerCount, signals, q.conf, at)
+	for _, signal := range signals {
+		refererSignals.WithLabelValues(signal).Inc()
+	}
+	if len(signals) > 0 {
+		logger.Infow("Suspicious referer",
+			"author", author,
+			"urls", articleURLs,
+			"signals", signals,
+			"quality", quality.Quality)
+	}
+
+	if !q.changed(previous, quality, signals) {
+		return nil
+	}
+	err = q.repo.Save(quality)
+	if err != nil {
+		return err
+	}
+	q.cache(quality)
+	return nil
+}
+
+// changed returns true if the observed quality has to be stored. The follower count is
+// only stored if follower jumps are detected, as it is the baseline for the next jump.
+func (q *refererQuality) changed(previous, quality domain.AuthorQuality, signals []string) bool {
+	if len(signals) > 0 || quality.Quality != previous.Quality {
+		return true
+	}
+	return q.conf.FollowerJumpRatio > 0 && quality.FollowerCount != previous.FollowerCount
+}
+
+func (q *refererQuality) cache(quality domain.AuthorQuality) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if quality.Flagged() {
+		q.flagged[quality.Author] = quality.Quality
+	} else {
+		delete(q.flagged, quality.Author)
+	}
+}
+
+// refresh replaces the cached qualities with the flagged authors in the repository,
+// the cached qualities are kept if they cannot be read.
+func (q *refererQuality) refresh() error {
+	qualities, err := q.repo.FindFlagged()
+	if err != nil {
+		return err
+	}
+
+	flagged := make(map[string]float64, len(qualities))
+	for _, quality := range qualities {
+		flagged[quality.Author] = quality.Quality
+	}
+	q.mu.Lock()
+	q.flagged = flagged
+	q.mu.Unlock()
+	return nil
+}
+
+// observeReferer records the referer of a rank object before the articles it refers to are scored,
+// so that the referer is discounted if it raises signals.
+func (e *env) observeReferer(referer news.Referer, URLs []articleURL) {
+	if referer.ExternalID == "" || len(URLs) == 0 {
+		return
+	}
+
+	articleURLs := make([]string, 0, len(URLs))
+	for _, URL := range URLs {
+		articleURLs = append(articleURLs, URL.URL)
+	}
+	err := e.refererQuality.observe(referer, articleURLs, time.Now())
+	if err != nil {
+		logger.Errorw("Observing referer failed", "author", referer.ExternalID, "urls", articleURLs, "err", err)
+	}
+}
+
+// refreshRefererQualities periodically reloads the flagged authors so that qualities
+// changed by other instances are applied.
+func (e *env) refreshRefererQualities(ctx context.Context) {
+	for sleep(ctx, e.config.RefererQuality.RefreshInterval) {
+		err := e.refererQuality.refresh()
+		if err != nil {
+			logger.Errorw("Refreshing referer qualities failed", "err", err)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mimir-news/news-ranker/pkg/domain"
+	"github.com/mimir-news/news-ranker/pkg/repository"
+	"github.com/mimir-news/pkg/schema/news"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRefererQuality_DiscountsScores(t *testing.T) {
+	assert := assert.New(t)
+
+	repo := repository.NewMemoryRefererQualityRepo()
+	quality := newRefererQuality(repo, domain.RefererQualityConfig{
+		FollowerJumpRatio: 10,
+		Penalty:           0.5,
+		Recovery:          0.5,
+	})
+	scorer, err := domain.NewScorer(domain.ScorerConfig{
+		Strategy:        domain.LinearScoring,
+		TwitterUsers:    1000,
+		ReferenceWeight: 1.0,
+		Qualities:       quality,
+	})
+	assert.Nil(err)
+
+	now := time.Now()
+	referer := news.Referer{ExternalID: "author-0", FollowerCount: 100}
+	assert.Nil(quality.observe(referer, []string{"http://url.com/a"}, now))
+	assertScore(0.1, scorer.Score(domain.NewReferer(referer)), t)
+
+	referer.FollowerCount = 1000
+	assert.Nil(quality.observe(referer, []string{"http://url.com/b", "http://url.com/c"}, now.Add(time.Minute)))
+	assertScore(0.5, quality.Quality("author-0"), t)
+	assertScore(0.5, scorer.Score(domain.NewReferer(referer)), t)
+	assertScore(1.0, scorer.Score(domain.NewReferer(news.Referer{ExternalID: "author-1", FollowerCount: 1000})), t)
+
+	stored, err := repo.Find("author-0")
+	assert.Nil(err)
+	assert.Equal(int64(1000), stored.FollowerCount)
+	assert.Equal(1, stored.Signals)
+	assert.Equal(domain.FollowerJumpSignal, stored.LastSignal)
+
+	// Qualities are reloaded from the repository and recover with referers without signals.
+	fresh := newRefererQuality(repo, domain.RefererQualityConfig{Penalty: 0.5, Recovery: 0.5})
+	assertScore(1.0, fresh.Quality("author-0"), t)
+	assert.Nil(fresh.refresh())
+	assertScore(0.5, fresh.Quality("author-0"), t)
+	assert.Nil(fresh.observe(referer, []string{"http://url.com/d"}, now.Add(2*time.Minute)))
+	assertScore(0.75, fresh.Quality("author-0"), t)
+}
+
+func TestRefererQuality_SavesChanges(t *testing.T) {
+	assert := assert.New(t)
+
+	repo := repository.NewMemoryRefererQualityRepo()
+	quality := newRefererQuality(repo, domain.RefererQualityConfig{Penalty: 0.5, Recovery: 0.5})
+
+	now := time.Now()
+	referer := news.Referer{ExternalID: "author-0", FollowerCount: 100}
+	assert.Nil(quality.observe(referer, []string{"http://url.com/a"}, now))
+	_, err := repo.Find("author-0")
+	assert.Equal(repository.ErrNoSuchAuthorQuality, err)
+
+	flagged := domain.NewAuthorQuality("author-0")
+	flagged.Quality = 0.5
+	assert.Nil(repo.Save(flagged))
+	assert.Nil(quality.observe(referer, []string{"http://url.com/b"}, now.Add(time.Minute)))
+	stored, err := repo.Find("author-0")
+	assert.Nil(err)
+	assertScore(0.75, stored.Quality, t)
+	assert.Equal(int64(100), stored.FollowerCount)
+
+	// Follower counts are stored as the baseline of follower jumps.
+	jumps := newRefererQuality(repo, domain.RefererQualityConfig{FollowerJumpRatio: 10, Penalty: 0.5})
+	assert.Nil(jumps.observe(news.Referer{ExternalID: "author-1", FollowerCount: 100}, []string{"http://url.com/c"}, now))
+	stored, err = repo.Find("author-1")
+	assert.Nil(err)
+	assert.Equal(int64(100), stored.FollowerCount)
+	assertScore(1.0, stored.Quality, t)
+}
+
+func newTestRefererQuality() *refererQuality {
+	return newRefererQuality(repository.NewMemoryRefererQualityRepo(), domain.RefererQualityConfig{})
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"time"
+
+	"github.com/mimir-news/news-ranker/pkg/domain"
+	"github.com/mimir-news/news-ranker/pkg/repository"
+	"github.com/mimir-news/pkg/schema/news"
+)
+
+// instrumentedArticleRepo records the latency of every call to the wrapped ArticleRepo.
+type instrumentedArticleRepo struct {
+	repo repository.ArticleRepo
+}
+
+func newInstrumentedArticleRepo(repo repository.ArticleRepo) repository.ArticleRepo {
+	return &instrumentedArticleRepo{
+		repo: repo,
+	}
+}
+
+func (r *instrumentedArticleRepo) FindByID(id string) (news.Article, error) {
+	defer observeQuery("article", "FindByID", time.Now())
+	return r.repo.FindByID(id)
+}
+
+func (r *instrumentedArticleRepo) FindByIDs(ids []string) (map[string]news.Article, error) {
+	defer observeQuery("article", "FindByIDs", time.Now())
+	return r.repo.FindByIDs(ids)
+}
+
+func (r *instrumentedArticleRepo) FindByURL(url string) (news.Article, error) {
+	defer observeQuery("article", "FindByURL", time.Now())
+	return r.repo.FindByURL(url)
+}
+
+func (r *instrumentedArticleRepo) FindArticles(filter repository.Filter, afterID string, limit int) ([]news.Article, error) {
+	defer observeQuery("article", "FindArticles", time.Now())
+	return r.repo.FindArticles(filter, afterID, limit)
+}
+
+func (r *instrumentedArticleRepo) FindArticleSubjects(articleID string) ([]news.Subject, error) {
+	defer observeQuery("article", "FindArticleSubjects", time.Now())
+	return r.repo.FindArticleSubjects(articleID)
+}
+
+func (r *instrumentedArticleRepo) FindArticleReferers(articleID string) ([]domain.Referer, error) {
+	defer observeQuery("article", "FindArticleReferers", time.Now())
+	return r.repo.FindArticleReferers(articleID)
+}
+
+func (r *instrumentedArticleRepo) FindReferersByArticleIDs(articleIDs []string) (map[string][]domain.Referer, error) {
+	defer observeQuery("article", "FindReferersByArticleIDs", time.Now())
+	return r.repo.FindReferersByArticleIDs(articleIDs)
+}
+
+func (r *instrumentedArticleRepo) Update(article news.Article) error {
+	defer observeQuery("article", "Update", time.Now())
+	return r.repo.Update(article)
+}
+
+func (r *instrumentedArticleRepo) UpdateWithReferer(article news.Article, referer domain.Referer) error {
+	defer observeQuery("article", "UpdateWithReferer", time.Now())
+	return r.repo.UpdateWithReferer(article, referer)
+}
+
+func (r *instrumentedArticleRepo) SaveScrapedArticle(scrapedArticle news.ScrapedArticle, referers []domain.Referer) error {
+	defer observeQuery("article", "SaveScrapedArticle", time.Now())
+	return r.repo.SaveScrapedArticle(scrapedArticle, referers)
+}
+
+func (r *instrumentedArticleRepo) MergeArticles(article news.Article, duplicateIDs []string, rescore repository.ClusterRescorer) error {
+	defer observeQuery("article", "MergeArticles", time.Now())
+	return r.repo.MergeArticles(article, duplicateIDs, rescore)
+}
+
+// instrumentedClusterRepo records the latency of every call to the wrapped ClusterRepo.
+type instrumentedClusterRepo struct {
+	repo repository.ClusterRepo
+}
+
+func newInstrumentedClusterRepo(repo repository.ClusterRepo) repository.ClusterRepo {
+	return &instrumentedClusterRepo{
+		repo: repo,
+	}
+}
+
+func (r *instrumentedClusterRepo) FindByHash(clusterHash string) (domain.ArticleCluster, error) {
+	defer observeQuery("cluster", "FindByHash", time.Now())
+	return r.repo.FindByHash(clusterHash)
+}
+
+func (r *instrumentedClusterRepo) FindSince(date time.Time) ([]domain.ArticleCluster, error) {
+	defer observeQuery("cluster", "FindSince", time.Now())
+	return r.repo.FindSince(date)
+}
+
+func (r *instrumentedClusterRepo) FindCandidates(symbol string, since time.Time, limit int) ([]domain.ArticleCluster, error) {
+	defer observeQuery("cluster", "FindCandidates", time.Now())
+	return r.repo.FindCandidates(symbol, since, limit)
+}
+
+func (r *instrumentedClusterRepo) FindBySymbolAndDate(symbol string, date time.Time, limit int) ([]domain.ArticleCluster, error) {
+	defer observeQuery("cluster", "FindBySymbolAndDate", time.Now())
+	return r.repo.FindBySymbolAndDate(symbol, date, limit)
+}
+
+func (r *instrumentedClusterRepo) FindClusters(filter repository.Filter, afterHash string, limit int) ([]domain.ArticleCluster, error) {
+	defer observeQuery("cluster", "FindClusters", time.Now())
+	return r.repo.FindClusters(filter, afterHash, limit)
+}
+
+func (r *instrumentedClusterRepo) Save(cluster domain.ArticleCluster) error {
+	defer observeQuery("cluster", "Save", time.Now())
+	return r.repo.Save(cluster)
+}
+
+func (r *instrumentedClusterRepo) Update(cluster domain.ArticleCluster) error {
+	defer observeQuery("cluster", "Update", time.Now())
+	return r.repo.Update(cluster)
+}
+
+func (r *instrumentedClusterRepo) UpdateScore(cluster domain.ArticleCluster) error {
+	defer observeQuery("cluster", "UpdateScore", time.Now())
+	return r.repo.UpdateScore(cluster)
+}
+
+func observeQuery(repo, method string, start time.Time) {
+	observeDuration(repositoryQueryDuration.WithLabelValues(repo, method), start)
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"math"
+	"time"
+
+	"github.com/mimir-news/news-ranker/pkg/domain"
+	"github.com/mimir-news/news-ranker/pkg/repository"
+	"github.com/mimir-news/pkg/schema/news"
+)
+
+// scoreTolerance is the smallest score change worth storing, scores are stored with five decimals.
+const scoreTolerance = 1e-5
+
+type rescoreOptions struct {
+	filter    repository.Filter
+	batchSize int
+	dryRun    bool
+}
+
+type rescoreStats struct {
+	Processed int
+	Updated   int
+	Failed    int
+}
+
+// rescorer recomputes stored scores with the current scoring and decay parameters.
+// Reference scores are cached per article within a batch so that they are computed once
+// for the cluster members of the batch referring to it, the cache is cleared between
+// batches to bound its size.
+type rescorer struct {
+	env             *env
+	opts            rescoreOptions
+	referenceScores map[string]float64
+	now             time.Time
+}
+
+func (e *env) newRescorer(opts rescoreOptions) *rescorer {
+	return &rescorer{
+		env:             e,
+		opts:            opts,
+		referenceScores: make(map[string]float64),
+		now:             time.Now(),
+	}
+}
+
+// rescoreArticles recomputes the reference score of every article matching the filter.
+func (r *rescorer) rescoreArticles() rescoreStats {
+	var stats rescoreStats
+	afterID := ""
+	for {
+		articles, err := r.env.articleRepo.FindArticles(r.opts.filter, afterID, r.opts.batchSize)
+		if err != nil {
+			logger.Errorw("Failed to retrieve articles to rescore", "afterId", afterID, "err", err)
+			stats.Failed++
+			return stats
+		}
+		if len(articles) == 0 {
+			return stats
+		}
+
+		r.referenceScores = make(map[string]float64)
+		for _, article := range articles {
+			r.rescoreArticle(article, &stats)
+		}
+		afterID = articles[len(articles)-1].ID
+		logger.Infow("Rescore progress",
+			"phase", "articles",
+			"processed", stats.Processed,
+			"updated", stats.Updated,
+			"failed", stats.Failed,
+			"dryRun", r.opts.dryRun)
+	}
+}
+
+func (r *rescorer) rescoreArticle(article news.Article, stats *rescoreStats) {
+	stats.Processed++
+	score, err := r.referenceScore(article.ID)
+	if err != nil {
+		logger.Errorw("Failed to compute reference score", "articleId", article.ID, "err", err)
+		stats.Failed++
+		return
+	}
+
+	if !scoreChanged(article.ReferenceScore, score) {
+		return
+	}
+	if r.opts.dryRun {
+		logger.Infow("Would update article reference score",
+			"articleId", article.ID, "from", article.ReferenceScore, "to", score)
+		stats.Updated++
+		return
+	}
+
+	article.ReferenceScore = score
+	err = r.env.articleRepo.Update(article)
+	if err != nil {
+		logger.Errorw("Failed to update article reference score", "articleId", article.ID, "err", err)
+		stats.Failed++
+		return
+	}
+	stats.Updated++
+}
+
+// rescoreClusters updates the reference scores of the members of every cluster matching
+// the filter and elects a new leader and score.
+func (r *rescorer) rescoreClusters() rescoreStats {
+	var stats rescoreStats
+	afterHash := ""
+	for {
+		clusters, err := r.env.clusterRepo.FindClusters(r.opts.filter, afterHash, r.opts.batchSize)
+		if err != nil {
+			logger.Errorw("Failed to retrieve clusters to rescore", "afterHash", afterHash, "err", err)
+			stats.Failed++
+			return stats
+		}
+		if len(clusters) == 0 {
+			return stats
+		}
+
+		r.referenceScores = make(map[string]float64)
+		for _, cluster := range clusters {
+			r.rescoreCluster(cluster, &stats)
+		}
+		afterHash = clusters[len(clusters)-1].Hash
+		logger.Infow("Rescore progress",
+			"phase", "clusters",
+			"processed", stats.Processed,
+			"updated", stats.Updated,
+			"failed", stats.Failed,
+			"dryRun", r.opts.dryRun)
+	}
+}
+
+// rescoreCluster rescores the cluster and stores it, re-reading and rescoring it if it
+// was concurrently updated by the ranker.
+func (r *rescorer) rescoreCluster(cluster domain.ArticleCluster, stats *rescoreStats) {
+	stats.Processed++
+	clusterHash := cluster.Hash
+	reload := false
+	err := retryConcurrentClusterUpdate(func() error {
+		if reload {
+			var err error
+			cluster, err = r.env.clusterRepo.FindByHash(clusterHash)
+			if err != nil {
+				return err
+			}
+		}
+		reload = true
+
+		updated, err := r.tryRescoreCluster(cluster)
+		if updated {
+			stats.Updated++
+		}
+		return err
+	}, "clusterHash", clusterHash)
+	if err != nil {
+		logger.Errorw("Failed to rescore cluster", "clusterHash", clusterHash, "err", err)
+		stats.Failed++
+	}
+}
+
+// tryRescoreCluster rescores the cluster and stores it if its leader or score changed.
+// Returns repository.ErrConcurrentUpdate if the cluster was changed after it was read.
+func (r *rescorer) tryRescoreCluster(cluster domain.ArticleCluster) (bool, error) {
+	previousScore := cluster.Score
+	previousLeader := cluster.LeadArticleID
+	err := r.rescoreMembers(cluster.Symbol, cluster.Members)
+	if err != nil {
+		return false, err
+	}
+	cluster.ElectLeaderAndScore(r.env.leaderPolicy)
+	err = r.env.applyDecay(&cluster, r.now)
+	if err != nil {
+		return false, err
+	}
+
+	if !scoreChanged(previousScore, cluster.Score) && previousLeader == cluster.LeadArticleID {
+		return false, nil
+	}
+	if r.opts.dryRun {
+		logger.Infow("Would update cluster score",
+			"clusterHash", cluster.Hash, "from", previousScore, "to", cluster.Score)
+		return true, nil
+	}
+
+	err = r.env.clusterRepo.Update(cluster)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// rescoreMembers updates the reference and subject scores of the members, both weighted by the current
+// authority of their publishers, with referers deduplicated across the cluster if configured.
+func (r *rescorer) rescoreMembers(symbol string, members []domain.ClusterMember) error {
+	dedupe := r.env.config.Clustering.DedupeAuthors
+	if dedupe {
+		err := r.env.scoreClusterReferers(members)
+		if err != nil {
+			return err
+		}
+	}
+
+	for i, member := range members {
+		authority := r.env.publishers.Find(member.Source).Authority()
+		if !dedupe {
+			score, err := r.referenceScore(member.ArticleID)
+			if err != nil {
+				return err
+			}
+			members[i].ReferenceScore = score * authority
+		}
+
+		subjectScore, err := r.subjectScore(member.ArticleID, symbol)
+		if err == repository.ErrNoSubjects {
+			logger.Warnw("Keeping subject score of member without stored subject",
+				"articleId", member.ArticleID, "symbol", symbol)
+			continue
+		} else if err != nil {
+			return err
+		}
+		members[i].SubjectScore = subjectScore * authority
+	}
+	return nil
+}
+
+// subjectScore returns the stored score of the article for the subject with the symbol,
+// or repository.ErrNoSubjects if the article has no such subject.
+func (r *rescorer) subjectScore(articleID, symbol string) (float64, error) {
+	subjects, err := r.env.articleRepo.FindArticleSubjects(articleID)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, subject := range subjects {
+		if subject.Symbol == symbol {
+			return subject.Score, nil
+		}
+	}
+	return 0, repository.ErrNoSubjects
+}
+
+func (r *rescorer) referenceScore(articleID string) (float64, error) {
+	if score, ok := r.referenceScores[articleID]; ok {
+		return score, nil
+	}
+
+	referers, err := r.env.articleRepo.FindArticleReferers(articleID)
+	if err != nil && err != repository.ErrNoReferers {
+		return 0, err
+	}
+
+	score := r.env.scorer.Score(referers...)
+	r.referenceScores[articleID] = score
+	return score, nil
+}
+
+func scoreChanged(previous, current float64) bool {
+	return math.Abs(previous-current) > scoreTolerance
+}
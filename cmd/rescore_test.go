@@ -0,0 +1,167 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mimir-news/news-ranker/pkg/domain"
+	"github.com/mimir-news/news-ranker/pkg/repository"
+	"github.com/mimir-news/pkg/schema/news"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRescoreArticles(t *testing.T) {
+	assert := assert.New(t)
+
+	filter := repository.Filter{Symbol: "AAPL"}
+	articleRepo := &mockArticleRepo{
+		findArticlesBatches: [][]news.Article{
+			{
+				news.Article{ID: "a-0", ReferenceScore: 1.0},
+				news.Article{ID: "a-1", ReferenceScore: 0.5},
+			},
+			{
+				news.Article{ID: "a-2", ReferenceScore: 0.5},
+			},
+		},
+		articleReferersByID: map[string][]domain.Referer{
+			"a-0": {domain.NewReferer(news.Referer{ExternalID: "r-0", FollowerCount: 1000})},
+			"a-1": {domain.NewReferer(news.Referer{ExternalID: "r-0", FollowerCount: 1000})},
+			"a-2": {domain.NewReferer(news.Referer{ExternalID: "r-1", FollowerCount: 500})},
+		},
+	}
+	mockEnv := newMockEnv(articleRepo, nil, nil)
+
+	r := mockEnv.newRescorer(rescoreOptions{filter: filter, batchSize: 2})
+	stats := r.rescoreArticles()
+	assert.Equal(rescoreStats{Processed: 3, Updated: 1, Failed: 0}, stats)
+	assert.Equal(map[string]float64{"a-2": 0.5}, r.referenceScores)
+	assert.Equal(filter, articleRepo.findArticlesFilter)
+	assert.Equal(1, articleRepo.updateCalls)
+	assert.Equal("a-1", articleRepo.updateArg.ID)
+	assert.Equal(1.0, articleRepo.updateArg.ReferenceScore)
+
+	articleRepo.findArticlesBatches = [][]news.Article{{news.Article{ID: "a-1", ReferenceScore: 0.5}}}
+	articleRepo.updateCalls = 0
+	stats = mockEnv.newRescorer(rescoreOptions{filter: filter, batchSize: 2, dryRun: true}).rescoreArticles()
+	assert.Equal(rescoreStats{Processed: 1, Updated: 1, Failed: 0}, stats)
+	assert.Equal(0, articleRepo.updateCalls)
+
+	articleRepo.findArticlesBatches = [][]news.Article{{news.Article{ID: "a-1", ReferenceScore: 0.5}}}
+	articleRepo.updateErr = errMock
+	stats = mockEnv.newRescorer(rescoreOptions{filter: filter, batchSize: 2}).rescoreArticles()
+	assert.Equal(rescoreStats{Processed: 1, Updated: 0, Failed: 1}, stats)
+}
+
+func TestRescoreClusters(t *testing.T) {
+	assert := assert.New(t)
+
+	articleDate := time.Now()
+	members := []domain.ClusterMember{
+		*domain.NewClusterMember("hash-0", "a-0", 0.1, 0.5),
+		*domain.NewClusterMember("hash-0", "a-1", 0.2, 0.1),
+	}
+	cluster := *domain.NewArticleCluster("title-0", "AAPL", articleDate, "a-1", 0.9, members)
+	cluster.ElectLeaderAndScore(newTestLeaderPolicy(domain.HighestScoreLeader))
+	unchanged := *domain.NewArticleCluster("title-1", "AAPL", articleDate, "a-2", 0.0, []domain.ClusterMember{
+		*domain.NewClusterMember("hash-1", "a-2", 0.0, 0.0),
+	})
+	unchanged.ElectLeaderAndScore(newTestLeaderPolicy(domain.HighestScoreLeader))
+
+	articleRepo := &mockArticleRepo{
+		articleReferersByID: map[string][]domain.Referer{
+			"a-0": {domain.NewReferer(news.Referer{ExternalID: "r-0", FollowerCount: 2000})},
+			"a-1": {domain.NewReferer(news.Referer{ExternalID: "r-1", FollowerCount: 100})},
+		},
+	}
+	clusterRepo := &mockClusterRepo{
+		findClustersBatches: [][]domain.ArticleCluster{{cluster, unchanged}},
+	}
+	mockEnv := newMockEnv(articleRepo, clusterRepo, nil)
+
+	stats := mockEnv.newRescorer(rescoreOptions{batchSize: 2}).rescoreClusters()
+	assert.Equal(rescoreStats{Processed: 2, Updated: 1, Failed: 0}, stats)
+	assert.Equal(1, clusterRepo.updateCalls)
+
+	updated := clusterRepo.updateArg
+	assert.Equal(cluster.Hash, updated.Hash)
+	assert.Equal("a-0", updated.LeadArticleID)
+	assert.Equal(2.0, updated.Members[0].ReferenceScore)
+	assert.Equal(0.1, updated.Members[1].ReferenceScore)
+	assert.InDelta(2.0+0.5+0.1, updated.Score, 1e-9)
+}
+
+func TestRescoreClusters_PublisherAuthority(t *testing.T) {
+	assert := assert.New(t)
+
+	member := *domain.NewClusterMember("hash-0", "a-0", 0.2, 0.5)
+	member.Source = "reuters.com"
+	cluster := *domain.NewArticleCluster("title-0", "AAPL", time.Now(), "a-0", 0.7, []domain.ClusterMember{member})
+
+	articleRepo := &mockArticleRepo{
+		articleReferersByID: map[string][]domain.Referer{
+			"a-0": {domain.NewReferer(news.Referer{ExternalID: "r-0", FollowerCount: 200})},
+		},
+		articleSubjectsByID: map[string][]news.Subject{
+			"a-0": {{Symbol: "MSFT", Score: 0.9}, {Symbol: "AAPL", Score: 0.5}},
+		},
+	}
+	clusterRepo := &mockClusterRepo{
+		findClustersBatches: [][]domain.ArticleCluster{{cluster}},
+	}
+	mockEnv := newMockEnv(articleRepo, clusterRepo, nil)
+	_, err := addPublisher(mockEnv.publishers.repo, domain.Publisher{Host: "reuters.com", Weight: 2.0})
+	assert.Nil(err)
+	assert.Nil(mockEnv.publishers.refresh())
+
+	stats := mockEnv.newRescorer(rescoreOptions{batchSize: 2}).rescoreClusters()
+	assert.Equal(rescoreStats{Processed: 1, Updated: 1, Failed: 0}, stats)
+	updated := clusterRepo.updateArg.Members[0]
+	assert.InDelta(0.4, updated.ReferenceScore, 1e-9)
+	assert.InDelta(1.0, updated.SubjectScore, 1e-9)
+	assert.InDelta(1.4, clusterRepo.updateArg.Score, 1e-9)
+}
+
+func TestRescoreClusters_ConcurrentUpdate(t *testing.T) {
+	assert := assert.New(t)
+
+	articleDate := time.Now()
+	stale := *domain.NewArticleCluster("title-0", "AAPL", articleDate, "a-0", 0.0, []domain.ClusterMember{
+		*domain.NewClusterMember("hash-0", "a-0", 0.1, 0.5),
+	})
+	reloaded := *domain.NewArticleCluster("title-0", "AAPL", articleDate, "a-0", 0.0, []domain.ClusterMember{
+		*domain.NewClusterMember("hash-0", "a-0", 0.1, 0.5),
+		*domain.NewClusterMember("hash-0", "a-1", 0.2, 0.1),
+	})
+
+	articleRepo := &mockArticleRepo{
+		articleReferersByID: map[string][]domain.Referer{
+			"a-0": {domain.NewReferer(news.Referer{ExternalID: "r-0", FollowerCount: 2000})},
+			"a-1": {domain.NewReferer(news.Referer{ExternalID: "r-1", FollowerCount: 100})},
+		},
+	}
+	clusterRepo := &mockClusterRepo{
+		findClustersBatches: [][]domain.ArticleCluster{{stale}},
+		findByHashCluster:   reloaded,
+		updateConflicts:     1,
+	}
+	mockEnv := newMockEnv(articleRepo, clusterRepo, nil)
+
+	stats := mockEnv.newRescorer(rescoreOptions{batchSize: 2}).rescoreClusters()
+	assert.Equal(rescoreStats{Processed: 1, Updated: 1, Failed: 0}, stats)
+	assert.Equal(2, clusterRepo.updateCalls)
+	assert.Equal(stale.Hash, clusterRepo.findByHashArg)
+	assert.Len(clusterRepo.updateArg.Members, 2)
+	assert.InDelta(2.0+0.5+0.1, clusterRepo.updateArg.Score, 1e-9)
+
+	clusterRepo = &mockClusterRepo{
+		findClustersBatches: [][]domain.ArticleCluster{{stale}},
+		findByHashCluster:   reloaded,
+		updateConflicts:     maxClusterUpdateAttempts,
+	}
+	mockEnv = newMockEnv(articleRepo, clusterRepo, nil)
+
+	stats = mockEnv.newRescorer(rescoreOptions{batchSize: 2}).rescoreClusters()
+	assert.Equal(rescoreStats{Processed: 1, Updated: 0, Failed: 1}, stats)
+	assert.Equal(maxClusterUpdateAttempts, clusterRepo.updateCalls)
+}
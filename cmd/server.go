@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mimir-news/news-ranker/pkg/domain"
+	"github.com/mimir-news/news-ranker/pkg/repository"
+	"github.com/mimir-news/pkg/schema/news"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	dateFormat          = "2006-01-02"
+	defaultClusterLimit = 20
+	maxClusterLimit     = 100
+	clustersRoute       = "/v1/clusters"
+	metricsRoute        = "/metrics"
+	livenessRoute       = "/healthz"
+	readinessRoute      = "/readyz"
+)
+
+var errInvalidLimit = errors.New("invalid limit")
+
+type clusterResponse struct {
+	Hash         string           `json:"hash"`
+	Title        string           `json:"title"`
+	Symbol       string           `json:"symbol"`
+	ArticleDate  string           `json:"articleDate"`
+	Score        float64          `json:"score"`
+	LeadArticle  *news.Article    `json:"leadArticle"`
+	LeaderReason string           `json:"leaderReason"`
+	Members      []memberResponse `json:"members"`
+}
+
+type memberResponse struct {
+	ArticleID      string  `json:"articleId"`
+	ReferenceScore float64 `json:"referenceScore"`
+	SubjectScore   float64 `json:"subjectScore"`
+	Score          float64 `json:"score"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func (e *env) newServer() *http.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc(clustersRoute, e.handleGetClusters)
+	mux.HandleFunc(clustersRoute+"/", e.handleGetCluster)
+	mux.Handle(metricsRoute, promhttp.Handler())
+	mux.HandleFunc(livenessRoute, e.handleLiveness)
+	mux.HandleFunc(readinessRoute, e.handleReadiness)
+
+	return &http.Server{
+		Addr:    ":" + e.config.Server.Port,
+		Handler: mux,
+	}
+}
+
+func (e *env) serveHTTP(server *http.Server) {
+	logger.Infow("Starting HTTP server", "addr", server.Addr)
+	err := server.ListenAndServe()
+	if err != nil && err != http.ErrServerClosed {
+		logger.Fatalw("HTTP server failed", "err", err)
+	}
+}
+
+func shutdownServer(server *http.Server, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	err := server.Shutdown(ctx)
+	if err != nil {
+		logger.Errorw("HTTP server shutdown failed", "err", err)
+	}
+}
+
+func (e *env) handleGetClusters(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	query := r.URL.Query()
+	symbol := query.Get("symbol")
+	if symbol == "" {
+		writeError(w, http.StatusBadRequest, "Missing symbol")
+		return
+	}
+
+	date, err := parseDateParam(query.Get("date"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid date, expected format YYYY-MM-DD")
+		return
+	}
+
+	limit, err := parseLimitParam(query.Get("limit"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid limit")
+		return
+	}
+
+	clusters, err := e.clusterRepo.FindBySymbolAndDate(symbol, date, limit)
+	if err != nil {
+		logger.Errorw("Failed retrieving clusters", "symbol", symbol, "date", date, "err", err)
+		writeError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	leadArticles, err := e.findLeadArticles(clusters)
+	if err != nil {
+		logger.Errorw("Failed retrieving lead articles", "symbol", symbol, "date", date, "err", err)
+		writeError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	res := make([]clusterResponse, 0, len(clusters))
+	for _, cluster := range clusters {
+		res = append(res, newClusterResponse(cluster, leadArticles))
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+func (e *env) handleGetCluster(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	clusterHash := strings.TrimPrefix(r.URL.Path, clustersRoute+"/")
+	if clusterHash == "" || strings.Contains(clusterHash, "/") {
+		writeError(w, http.StatusNotFound, "Not found")
+		return
+	}
+
+	cluster, err := e.clusterRepo.FindByHash(clusterHash)
+	if err == repository.ErrNoSuchCluster {
+		writeError(w, http.StatusNotFound, "No such cluster")
+		return
+	} else if err != nil {
+		logger.Errorw("Failed retrieving cluster", "clusterHash", clusterHash, "err", err)
+		writeError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	leadArticles, err := e.findLeadArticles([]domain.ArticleCluster{cluster})
+	if err != nil {
+		logger.Errorw("Failed retrieving lead article", "clusterHash", clusterHash, "err", err)
+		writeError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newClusterResponse(cluster, leadArticles))
+}
+
+// newClusterResponse creates the response for the cluster, leaving out a lead article that was not found.
+func newClusterResponse(cluster domain.ArticleCluster, leadArticles map[string]news.Article) clusterResponse {
+	members := make([]memberResponse, 0, len(cluster.Members))
+	for _, member := range cluster.Members {
+		members = append(members, memberResponse{
+			ArticleID:      member.ArticleID,
+			ReferenceScore: member.ReferenceScore,
+			SubjectScore:   member.SubjectScore,
+			Score:          member.Score(),
+		})
+	}
+
+	return clusterResponse{
+		Hash:         cluster.Hash,
+		Title:        cluster.Title,
+		Symbol:       cluster.Symbol,
+		ArticleDate:  cluster.ArticleDate.Format(dateFormat),
+		Score:        cluster.Score,
+		LeadArticle:  findLeadArticle(cluster, leadArticles),
+		LeaderReason: cluster.LeaderReason,
+		Members:      members,
+	}
+}
+
+// findLeadArticles reads the lead articles of the clusters by article id in one query.
+func (e *env) findLeadArticles(clusters []domain.ArticleCluster) (map[string]news.Article, error) {
+	articleIDs := make([]string, 0, len(clusters))
+	for _, cluster := range clusters {
+		articleIDs = append(articleIDs, cluster.LeadArticleID)
+	}
+	return e.articleRepo.FindByIDs(articleIDs)
+}
+
+func findLeadArticle(cluster domain.ArticleCluster, leadArticles map[string]news.Article) *news.Article {
+	article, ok := leadArticles[cluster.LeadArticleID]
+	if !ok {
+		logger.Warnw("Lead article not found", "clusterHash", cluster.Hash, "articleId", cluster.LeadArticleID)
+		return nil
+	}
+	return &article
+}
+
+func parseDateParam(value string) (time.Time, error) {
+	if value == "" {
+		return time.Parse(dateFormat, time.Now().UTC().Format(dateFormat))
+	}
+	return time.Parse(dateFormat, value)
+}
+
+func parseLimitParam(value string) (int, error) {
+	if value == "" {
+		return defaultClusterLimit, nil
+	}
+
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 1 {
+		return 0, errInvalidLimit
+	}
+
+	if limit > maxClusterLimit {
+		return maxClusterLimit, nil
+	}
+	return limit, nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	err := json.NewEncoder(w).Encode(body)
+	if err != nil {
+		logger.Errorw("Failed to write response", "err", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, errorResponse{Error: message})
+}
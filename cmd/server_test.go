@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mimir-news/news-ranker/pkg/domain"
+	"github.com/mimir-news/news-ranker/pkg/repository"
+	"github.com/mimir-news/pkg/schema/news"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandleGetClusters(t *testing.T) {
+	assert := assert.New(t)
+
+	articleDate, err := time.Parse(dateFormat, "2018-10-25")
+	assert.Nil(err)
+	members := []domain.ClusterMember{
+		*domain.NewClusterMember("hash-0", "a-0", 0.3, 0.1),
+		*domain.NewClusterMember("hash-0", "a-1", 0.4, 0.2),
+	}
+	cluster := *domain.NewArticleCluster("title-0", "AAPL", articleDate, "a-1", 0.9, members)
+
+	clusterRepo := &mockClusterRepo{
+		findBySymbolAndDateClusters: []domain.ArticleCluster{cluster},
+	}
+	articleRepo := &mockArticleRepo{
+		findByIDsArticles: []news.Article{{ID: "a-1", Title: "title-0"}},
+	}
+	server := newMockEnv(articleRepo, clusterRepo, nil).newServer()
+
+	res := performRequest(server.Handler, "GET", "/v1/clusters?symbol=AAPL&date=2018-10-25&limit=5")
+	assert.Equal(http.StatusOK, res.Code)
+	assert.Equal([]interface{}{"AAPL", articleDate, 5}, clusterRepo.findBySymbolAndDateArgs)
+	assert.Equal([]string{"a-1"}, articleRepo.findByIDsArg)
+
+	var body []clusterResponse
+	err = json.NewDecoder(res.Body).Decode(&body)
+	assert.Nil(err)
+	assert.Equal(1, len(body))
+	assert.Equal(cluster.Hash, body[0].Hash)
+	assert.Equal("2018-10-25", body[0].ArticleDate)
+	assert.Equal("a-1", body[0].LeadArticle.ID)
+	assert.Equal(2, len(body[0].Members))
+
+	res = performRequest(server.Handler, "GET", "/v1/clusters?symbol=AAPL&date=2018-10-25&limit=500")
+	assert.Equal(http.StatusOK, res.Code)
+	assert.Equal(maxClusterLimit, clusterRepo.findBySymbolAndDateArgs[2])
+
+	res = performRequest(server.Handler, "GET", "/v1/clusters?date=2018-10-25")
+	assert.Equal(http.StatusBadRequest, res.Code)
+
+	res = performRequest(server.Handler, "GET", "/v1/clusters?symbol=AAPL&date=25-10-2018")
+	assert.Equal(http.StatusBadRequest, res.Code)
+
+	res = performRequest(server.Handler, "GET", "/v1/clusters?symbol=AAPL&limit=-1")
+	assert.Equal(http.StatusBadRequest, res.Code)
+
+	res = performRequest(server.Handler, "POST", "/v1/clusters?symbol=AAPL")
+	assert.Equal(http.StatusMethodNotAllowed, res.Code)
+
+	articleRepo.findByIDsErr = errMock
+	res = performRequest(server.Handler, "GET", "/v1/clusters?symbol=AAPL")
+	assert.Equal(http.StatusInternalServerError, res.Code)
+
+	clusterRepo.findBySymbolAndDateErr = errMock
+	res = performRequest(server.Handler, "GET", "/v1/clusters?symbol=AAPL")
+	assert.Equal(http.StatusInternalServerError, res.Code)
+}
+
+func TestHandleGetCluster(t *testing.T) {
+	assert := assert.New(t)
+
+	cluster := *domain.NewArticleCluster("title-0", "AAPL", time.Now(), "a-0", 0.4, []domain.ClusterMember{
+		*domain.NewClusterMember("hash-0", "a-0", 0.3, 0.1),
+	})
+	clusterRepo := &mockClusterRepo{
+		findByHashCluster: cluster,
+	}
+	articleRepo := &mockArticleRepo{}
+	server := newMockEnv(articleRepo, clusterRepo, nil).newServer()
+
+	res := performRequest(server.Handler, "GET", "/v1/clusters/"+cluster.Hash)
+	assert.Equal(http.StatusOK, res.Code)
+	assert.Equal(cluster.Hash, clusterRepo.findByHashArg)
+
+	var body clusterResponse
+	err := json.NewDecoder(res.Body).Decode(&body)
+	assert.Nil(err)
+	assert.Equal(cluster.Hash, body.Hash)
+	assert.Nil(body.LeadArticle)
+	assert.Equal(1, len(body.Members))
+
+	articleRepo.findByIDsErr = errMock
+	res = performRequest(server.Handler, "GET", "/v1/clusters/"+cluster.Hash)
+	assert.Equal(http.StatusInternalServerError, res.Code)
+
+	clusterRepo.findByHashErr = repository.ErrNoSuchCluster
+	res = performRequest(server.Handler, "GET", "/v1/clusters/missing-hash")
+	assert.Equal(http.StatusNotFound, res.Code)
+
+	clusterRepo.findByHashErr = errMock
+	res = performRequest(server.Handler, "GET", "/v1/clusters/"+cluster.Hash)
+	assert.Equal(http.StatusInternalServerError, res.Code)
+}
+
+func TestMetricsRoute(t *testing.T) {
+	assert := assert.New(t)
+
+	server := newMockEnv(nil, nil, nil).newServer()
+	scrapeTargetsPublished.Inc()
+
+	res := performRequest(server.Handler, "GET", metricsRoute)
+	assert.Equal(http.StatusOK, res.Code)
+	assert.Contains(res.Body.String(), "news_ranker_scrape_targets_published_total")
+}
+
+func performRequest(handler http.Handler, method, path string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, req)
+	return res
+}
@@ -2,6 +2,9 @@ SVC_NAME=$(jq '.name' -r ../appv.json)
 SVC_VERSION=$(jq '.version' -r ../appv.json)
 CONF_DIR="../integrationtest/conf"
 
+# Optional YAML or TOML file with settings grouped by section, e.g. 'host' under 'mq' for MQ_HOST.
+# Env vars take precedence. Check it with './cmd config validate' or './cmd config print'.
+# export CONFIG_FILE='news-ranker.yml'
 export DB_HOST='127.0.0.1'
 export DB_PORT='5432'
 export DB_NAME='newsranker'
@@ -9,16 +12,87 @@ export DB_USERNAME='newsranker'
 export DB_PASSWORD='newsranker'
 export TWITTER_USERS='320000000'
 export REFERENCE_WEIGHT='1000'
+export REFERER_REDDIT_USERS='330000000'
+export REFERER_REDDIT_WEIGHT='1000'
+export REFERER_STOCKTWITS_USERS='2000000'
+export REFERER_STOCKTWITS_WEIGHT='500'
+export SCORING_STRATEGY='linear'
+export DECAY_MODEL='exponential'
+export DECAY_HALF_LIFE='24h'
+export DECAY_INTERVAL='15m'
+export CLUSTERING_STRATEGY='exact'
+# With the 'similarity' strategy articles join the most similar of the latest CLUSTERING_CANDIDATE_LIMIT
+# clusters of a subject, if the share of matching fingerprint bits is at least the threshold.
+export CLUSTERING_SIMILARITY_THRESHOLD='0.9'
+export CLUSTERING_CANDIDATE_LIMIT='500'
+# Cluster leaders are elected by 'highest_score', 'first_seen' (ingested first), 'longest_body' or
+# 'preferred_source', which picks the first listed source. Ties are broken deterministically.
+export CLUSTERING_LEADER_POLICY='highest_score'
+# export CLUSTERING_PREFERRED_SOURCES='reuters.com,bloomberg.com'
+# Authors referring to several articles of a cluster count fully for the article seen first
+# and are weighted by CLUSTERING_REPEAT_AUTHOR_WEIGHT for each repeat, '0' counts them once.
+export CLUSTERING_DEDUPE_AUTHORS='true'
+export CLUSTERING_REPEAT_AUTHOR_WEIGHT='0'
 export MQ_EXCHANGE='x-news'
 export MQ_RANK_QUEUE='q-rank-objects'
 export MQ_SCRAPE_QUEUE='q-scrape-targets'
 export MQ_SCRAPED_QUEUE='q-scraped-articles'
+export MQ_DEAD_LETTER_QUEUE='q-news-ranker-dead-letters'
 export MQ_HEALTH_TARGET='q-health-newsranker'
 export MQ_HOST=$DB_HOST
 export MQ_PORT='5672'
 export MQ_USER='newsranker'
 export MQ_PASSWORD='password'
+export MQ_CLUSTER_UPDATES_ROUTING_KEY='cluster-updates'
 export MQ_PREFETCH_COUNT='5'
+export MQ_WORKERS_PER_QUEUE='4'
+export RETRY_MAX_ATTEMPTS='3'
+# Failed messages are retried through a delay queue per attempt named by the queue, this suffix and
+# the attempt, e.g. q-rank-objects-retry-1 and q-rank-objects-retry-2. Declare them with x-message-ttl
+# set to the backoff and x-dead-letter-exchange and x-dead-letter-routing-key routing expired messages
+# back to the queue. Empty retries right away.
+export RETRY_DELAY_QUEUE_SUFFIX='-retry'
+export SHUTDOWN_TIMEOUT='20s'
+export SERVER_PORT='8080'
+export READINESS_MAX_MESSAGE_AGE='0s'
+# Redelivered messages processed within the TTL are skipped, '0s' disables deduplication.
+export MESSAGE_LEDGER_TTL='24h'
+# Rank objects for a URL already sent for scraping are merged into the pending scrape, which is
+# resent if not scraped within the timeout, '0s' disables coalescing.
+export PENDING_SCRAPE_TIMEOUT='10m'
+export PENDING_SCRAPE_MAX_ATTEMPTS='3'
+# Article URLs are canonicalised before lookup, with optional rules per domain as a JSON object.
+export URL_RULES='{"youtube.com": {"keepParams": ["v"]}, "youtu.be": {"host": "youtube.com"}}'
+# Remembers the URLs that pending scrapes were redirected to, e.g. for shortened links.
+export URL_RESOLVE_REDIRECTS='true'
+# Article scores are weighted by the authority of their publisher, managed with
+# './cmd publishers list|add|update -host reuters.com -weight 1.5'. Reloaded at this interval.
+export PUBLISHER_REFRESH_INTERVAL='1m'
+# Referers raising spam or bot signals have their author quality multiplied by the penalty, which
+# discounts them in scoring and recovers with each clean referer. Signals are groups of authors
+# bursting together on the same articles, authors referring to many articles and follower count
+# jumps, '0' disables one and all are disabled by default. E.g. a burst size of 20 with 3 group
+# articles flags authors found in bursts of 20 authors on 3 articles together within the group window.
+# Run './cmd rescore' to apply changed qualities to stored scores.
+export REFERER_QUALITY_BURST_WINDOW='10s'
+export REFERER_QUALITY_BURST_SIZE='0'
+export REFERER_QUALITY_BURST_GROUP_WINDOW='24h'
+export REFERER_QUALITY_BURST_GROUP_ARTICLES='0'
+export REFERER_QUALITY_SPREAD_WINDOW='1h'
+export REFERER_QUALITY_SPREAD_ARTICLES='0'
+export REFERER_QUALITY_FOLLOWER_JUMP_RATIO='0'
+export REFERER_QUALITY_PENALTY='0.5'
+export REFERER_QUALITY_RECOVERY='0.05'
+export REFERER_QUALITY_REFRESH_INTERVAL='1m'
+# Local mode reads rank objects and scraped articles from JSON lines files, or stdin given '-',
+# instead of RabbitMQ and writes sent messages as JSON lines to LOCAL_OUTPUT, stdout by default.
+# MQ host and credentials are then not needed, nor DB settings if LOCAL_STORE is 'memory'.
+# export LOCAL_MODE='true'
+# export LOCAL_RANK_OBJECTS_INPUT='rank-objects.jsonl'
+# export LOCAL_SCRAPED_ARTICLES_INPUT='scraped-articles.jsonl'
+# export LOCAL_OUTPUT='-'
+# export LOCAL_STORE='memory'
+# Optional, emits a heartbeat to the file while DB and MQ are connected.
 export HEARTBEAT_FILE='/tmp/news-ranker-health.txt'
 export HEARTBEAT_INTERVAL='20'
 
@@ -1,9 +1,13 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"sync"
+	"time"
 
+	"github.com/mimir-news/news-ranker/pkg/repository"
 	"github.com/mimir-news/pkg/id"
 	"github.com/mimir-news/pkg/mq"
 )
@@ -11,44 +15,248 @@ import (
 type handlerFunc func(msg mq.Message, messageId string) error
 
 type handler struct {
-	queue  string
-	client mq.Client
-	fn     handlerFunc
+	queue           string
+	client          mq.Client
+	fn              handlerFunc
+	retry           retryPolicy
+	exchange        string
+	deadLetterQueue string
+	workers         int
+	status          *subscriptionStatus
+	// Messages processed are recorded in the ledger for ledgerTTL if set.
+	ledger    repository.MessageLedger
+	ledgerTTL time.Duration
 }
 
-func newHandler(queue string, client mq.Client, fn handlerFunc) handler {
+// retryPolicy limits the attempts at handling a message. Failed messages are republished to a delay
+// queue per attempt, named by the queue, DelayQueueSuffix and the attempt, e.g. q-rank-objects-retry-1.
+// Delay queues are declared in the broker with a message TTL, doubled for each attempt, and dead letter
+// expired messages back to the queue, so the broker holds them for the backoff.
+// Without a suffix failed messages are republished to the queue directly.
+type retryPolicy struct {
+	MaxAttempts      int
+	DelayQueueSuffix string
+}
+
+// retryRoutingKey returns the routing key that messages of the queue are republished to after a failed attempt.
+func (p retryPolicy) retryRoutingKey(queue string, attempt int) string {
+	if p.DelayQueueSuffix == "" {
+		return queue
+	}
+	return fmt.Sprintf("%s%s-%d", queue, p.DelayQueueSuffix, attempt)
+}
+
+// retryEnvelope wraps a message republished for another attempt. Messages are sent without
+// headers so the number of attempts made travels in the x-retry-count field of the envelope.
+type retryEnvelope struct {
+	RetryCount int             `json:"x-retry-count"`
+	MessageID  string          `json:"messageId"`
+	Body       json.RawMessage `json:"body"`
+}
+
+// retriedMessage is a message received in a retry envelope, it decodes to the original body
+// and keeps the message id of the first delivery.
+type retriedMessage struct {
+	mq.Message
+	envelope retryEnvelope
+}
+
+func (m retriedMessage) Decode(v interface{}) error {
+	return json.Unmarshal(m.envelope.Body, v)
+}
+
+func (m retriedMessage) MessageID() string {
+	return m.envelope.MessageID
+}
+
+// unwrapRetry returns the original message if msg is a retry envelope, otherwise msg as is.
+// Messages requeued without an attempt counted are enveloped too, with a retry count of 0.
+func unwrapRetry(msg mq.Message) mq.Message {
+	var envelope retryEnvelope
+	err := msg.Decode(&envelope)
+	if err != nil || envelope.RetryCount < 0 || envelope.MessageID == "" || len(envelope.Body) == 0 {
+		return msg
+	}
+	return retriedMessage{Message: msg, envelope: envelope}
+}
+
+// retryCount returns the number of attempts already made at handling the message.
+func retryCount(msg mq.Message) int {
+	if m, ok := msg.(retriedMessage); ok {
+		return m.envelope.RetryCount
+	}
+	return 0
+}
+
+// permanentError marks a message handling error that retrying will not resolve.
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string {
+	return e.err.Error()
+}
+
+func permanent(err error) error {
+	return permanentError{err: err}
+}
+
+func isPermanent(err error) bool {
+	_, ok := err.(permanentError)
+	return ok
+}
+
+func newHandler(queue string, client mq.Client, fn handlerFunc, retry retryPolicy, exchange, deadLetterQueue string, workers int) handler {
 	return handler{
-		queue:  queue,
-		client: client,
-		fn:     fn,
+		queue:           queue,
+		client:          client,
+		fn:              fn,
+		retry:           retry,
+		exchange:        exchange,
+		deadLetterQueue: deadLetterQueue,
+		workers:         workers,
 	}
 }
 
-func handleSubscription(h handler, wg *sync.WaitGroup) {
-	wg.Add(1)
+// handleSubscription consumes messages from the handlers queue with the configured number of
+// workers until the context is cancelled. Messages being handled when the context is cancelled
+// are finished before returning.
+func handleSubscription(ctx context.Context, h handler, wg *sync.WaitGroup) {
 	defer wg.Done()
 	consumerID := newConsumerID()
-	logger.Infow("Starting subscription", "queue", h.queue, "consumerId", consumerID)
+	logger.Infow("Starting subscription", "queue", h.queue, "consumerId", consumerID, "workers", h.workers)
 	messageChannel, err := h.client.Subscribe(h.queue, consumerID)
 	if err != nil {
 		logger.Errorw("Channel subscription failed", "queue", h.queue, "error", err)
 		return
 	}
 
-	for msg := range messageChannel {
-		err = h.fn(msg, id.New())
+	h.status.setActive(h.queue, true)
+	defer h.status.setActive(h.queue, false)
+
+	workers := &sync.WaitGroup{}
+	for i := 0; i < h.workers; i++ {
+		workers.Add(1)
+		go h.consume(ctx, messageChannel, consumerID, workers)
+	}
+	workers.Wait()
+}
+
+func (h handler) consume(ctx context.Context, messageChannel <-chan mq.Message, consumerID string, wg *sync.WaitGroup) {
+	defer wg.Done()
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Infow("Stopping subscription", "queue", h.queue, "consumerId", consumerID)
+			return
+		case msg, ok := <-messageChannel:
+			if !ok {
+				logger.Warnw("Subscription channel closed", "queue", h.queue, "consumerId", consumerID)
+				return
+			}
+			h.handleDelivery(msg)
+		}
+	}
+}
+
+// handleMessage makes one attempt at handling the message, returns true if it was handled successfully.
+// Failed messages are republished for another attempt after a delay until the attempts counted in
+// their retry envelope are exhausted, they are then dead lettered.
+func (h handler) handleMessage(msg mq.Message, msgID string) bool {
+	messagesConsumed.WithLabelValues(h.queue).Inc()
+	defer observeDuration(messageHandlingDuration.WithLabelValues(h.queue), time.Now())
+	defer func() {
+		h.status.messageProcessed(h.queue, time.Now())
+	}()
+
+	attempt := retryCount(msg) + 1
+	err := h.fn(msg, msgID)
+	if err == nil {
+		wrapMessageHandlingResult(msg, nil, h.queue)
+		return true
+	}
+
+	h.handleFailure(msg, msgID, attempt, err)
+	return false
+}
+
+// handleFailure schedules a retry of a failed attempt at handling the message, or sends it to the
+// dead letter queue if the error is permanent or the attempts are exhausted.
+func (h handler) handleFailure(msg mq.Message, msgID string, attempt int, err error) {
+	if !isPermanent(err) && attempt < h.retry.MaxAttempts {
+		h.scheduleRetry(msg, msgID, attempt, err)
+		return
+	}
+
+	if h.deadLetterQueue != "" {
+		err = h.sendToDeadLetterQueue(msg, msgID, attempt, err)
+	}
+	wrapMessageHandlingResult(msg, err, h.queue)
+}
+
+// scheduleRetry republishes a failed message with its attempt counted to the delay queue of the attempt
+// and acks it right away, so the consumer moves on to other messages while the broker holds it.
+// The message is rejected if it cannot be republished.
+func (h handler) scheduleRetry(msg mq.Message, msgID string, attempt int, reason error) {
+	logger.Warnw("Message handling failed, retrying",
+		"queue", h.queue,
+		"msgID", msgID,
+		"attempt", attempt,
+		"error", reason)
+
+	err := h.republish(msg, msgID, attempt, h.retry.retryRoutingKey(h.queue, attempt))
+	if err != nil {
+		logger.Errorw("Republishing message for retry failed", "queue", h.queue, "msgID", msgID, "err", err)
+		wrapMessageHandlingResult(msg, err, h.queue)
+		return
+	}
+
+	messagesRetried.WithLabelValues(h.queue).Inc()
+	wrapMessageHandlingResult(msg, nil, h.queue)
+}
+
+// requeue sends the message back to be handled again after the delay of the first retry without
+// counting an attempt, so the message is not dead lettered. The message is rejected if it cannot be republished.
+func (h handler) requeue(msg mq.Message, msgID string, attempts int) {
+	err := h.republish(msg, msgID, attempts, h.retry.retryRoutingKey(h.queue, 1))
+	if err != nil {
+		logger.Errorw("Requeuing message failed", "queue", h.queue, "msgID", msgID, "err", err)
 		wrapMessageHandlingResult(msg, err, h.queue)
+		return
+	}
+
+	messagesRequeued.WithLabelValues(h.queue).Inc()
+	logger.Infow("Message requeued", "queue", h.queue, "msgID", msgID, "attempts", attempts)
+	wrapMessageHandlingResult(msg, nil, h.queue)
+}
+
+// republish sends the message body with the routing key wrapped in a retry envelope,
+// so that the attempts made and the message id survive redelivery.
+func (h handler) republish(msg mq.Message, msgID string, attempts int, routingKey string) error {
+	var body json.RawMessage
+	err := msg.Decode(&body)
+	if err != nil {
+		return err
+	}
+
+	envelope := retryEnvelope{
+		RetryCount: attempts,
+		MessageID:  msgID,
+		Body:       body,
 	}
+	return h.client.Send(envelope, h.exchange, routingKey)
 }
 
 func wrapMessageHandlingResult(msg mq.Message, err error, queueName string) {
 	if err != nil {
 		logger.Errorw("Channel subscription failed", "queue", queueName, "error", err)
+		messagesRejected.WithLabelValues(queueName).Inc()
 		rejectErr := msg.Reject()
 		if rejectErr != nil {
 			logger.Errorw("Reject failed", "queue", queueName, "error", rejectErr)
 		}
 	} else {
+		messagesAcked.WithLabelValues(queueName).Inc()
 		ackErr := msg.Ack()
 		if ackErr != nil {
 			logger.Errorw("Ack failed", "queue", queueName, "error", ackErr)
@@ -0,0 +1,398 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mimir-news/pkg/id"
+	"github.com/mimir-news/pkg/mq"
+	"github.com/mimir-news/pkg/mq/mqtest"
+	"github.com/mimir-news/pkg/schema/news"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandleMessage_Success(t *testing.T) {
+	assert := assert.New(t)
+
+	client := newRecordingMQClient(nil)
+	fn := &countingHandlerFunc{errs: []error{nil}}
+	h := newHandler("q-rank-objects", client, fn.handle, testRetryPolicy(3), "x-news", "q-dead-letters", 1)
+
+	msg := newRecordingMessage(getTestRankObject())
+	handled := h.handleMessage(msg, id.New())
+
+	assert.True(handled)
+	assert.Equal(1, fn.calls)
+	assert.True(msg.acked)
+	assert.False(msg.rejected)
+	assert.Equal(0, len(client.sent))
+}
+
+func TestHandleMessage_Retry(t *testing.T) {
+	assert := assert.New(t)
+
+	client := newRecordingMQClient(nil)
+	fn := &countingHandlerFunc{errs: []error{errMock, nil}}
+	h := newHandler("q-rank-objects", client, fn.handle, testRetryPolicy(3), "x-news", "q-dead-letters", 1)
+
+	ro := getTestRankObject()
+	msg := newRecordingMessage(ro)
+	handled := h.handleMessage(msg, "msg-0")
+
+	assert.False(handled)
+	assert.Equal(1, fn.calls)
+	assert.True(msg.acked)
+	assert.False(msg.rejected)
+	assert.Equal(1, len(client.sent))
+
+	sent := client.sent[0]
+	assert.Equal("x-news", sent.exchange)
+	assert.Equal("q-rank-objects", sent.routingKey)
+	envelope, ok := sent.msg.(retryEnvelope)
+	assert.True(ok)
+	assert.Equal(1, envelope.RetryCount)
+	assert.Equal("msg-0", envelope.MessageID)
+
+	expectedBody, err := json.Marshal(ro)
+	assert.Nil(err)
+	assert.JSONEq(string(expectedBody), string(envelope.Body))
+
+	retried := newRecordingMessage(envelope)
+	h.handleDelivery(retried)
+
+	assert.Equal(2, fn.calls)
+	assert.Equal("msg-0", fn.lastMsgID)
+	assert.True(retried.acked)
+	assert.Equal(1, len(client.sent))
+
+	var decoded news.RankObject
+	err = unwrapRetry(retried).Decode(&decoded)
+	assert.Nil(err)
+	assert.Equal(ro.URLs, decoded.URLs)
+}
+
+func TestHandleMessage_DeadLetter(t *testing.T) {
+	assert := assert.New(t)
+
+	client := newRecordingMQClient(nil)
+	fn := &countingHandlerFunc{errs: []error{errMock}}
+	h := newHandler("q-rank-objects", client, fn.handle, testRetryPolicy(3), "x-news", "q-dead-letters", 1)
+
+	ro := getTestRankObject()
+	body, err := json.Marshal(ro)
+	assert.Nil(err)
+	msg := newRecordingMessage(retryEnvelope{RetryCount: 2, MessageID: "msg-0", Body: body})
+	h.handleDelivery(msg)
+
+	assert.Equal(1, fn.calls)
+	assert.True(msg.acked)
+	assert.False(msg.rejected)
+	assert.Equal(1, len(client.sent))
+
+	sent := client.sent[0]
+	assert.Equal("x-news", sent.exchange)
+	assert.Equal("q-dead-letters", sent.routingKey)
+	dl, ok := sent.msg.(deadLetter)
+	assert.True(ok)
+	assert.Equal("q-rank-objects", dl.Queue)
+	assert.Equal("msg-0", dl.MessageID)
+	assert.Equal(errMock.Error(), dl.Reason)
+	assert.Equal(3, dl.Attempts)
+	assert.JSONEq(string(body), string(dl.Body))
+}
+
+func TestHandleMessage_PermanentError(t *testing.T) {
+	assert := assert.New(t)
+
+	client := newRecordingMQClient(nil)
+	fn := &countingHandlerFunc{errs: []error{permanent(errMock)}}
+	h := newHandler("q-rank-objects", client, fn.handle, testRetryPolicy(3), "x-news", "q-dead-letters", 1)
+
+	body := json.RawMessage(`{"urls": "https://url.0"}`)
+	msg := newRecordingMessage(body)
+	h.handleMessage(msg, "msg-0")
+
+	assert.Equal(1, fn.calls)
+	assert.True(msg.acked)
+	assert.Equal(1, len(client.sent))
+	dl := client.sent[0].msg.(deadLetter)
+	assert.JSONEq(string(body), string(dl.Body))
+	assert.Equal(1, dl.Attempts)
+}
+
+func TestHandleMessage_NoDeadLetterQueue(t *testing.T) {
+	assert := assert.New(t)
+
+	client := newRecordingMQClient(nil)
+	fn := &countingHandlerFunc{errs: []error{errMock}}
+	h := newHandler("q-rank-objects", client, fn.handle, testRetryPolicy(1), "x-news", "", 1)
+
+	msg := newRecordingMessage(getTestRankObject())
+	h.handleMessage(msg, id.New())
+
+	assert.Equal(1, fn.calls)
+	assert.False(msg.acked)
+	assert.True(msg.rejected)
+	assert.Equal(0, len(client.sent))
+
+	failingClient := newRecordingMQClient(errMock)
+	fn = &countingHandlerFunc{errs: []error{errMock}}
+	h = newHandler("q-rank-objects", failingClient, fn.handle, testRetryPolicy(1), "x-news", "q-dead-letters", 1)
+
+	msg = newRecordingMessage(getTestRankObject())
+	h.handleMessage(msg, id.New())
+	assert.False(msg.acked)
+	assert.True(msg.rejected)
+
+	fn = &countingHandlerFunc{errs: []error{errMock}}
+	h = newHandler("q-rank-objects", failingClient, fn.handle, testRetryPolicy(3), "x-news", "q-dead-letters", 1)
+
+	msg = newRecordingMessage(getTestRankObject())
+	h.handleMessage(msg, id.New())
+	assert.False(msg.acked)
+	assert.True(msg.rejected)
+}
+
+func TestHandleMessage_DelayQueue(t *testing.T) {
+	assert := assert.New(t)
+
+	client := newRecordingMQClient(nil)
+	fn := &countingHandlerFunc{errs: []error{errMock}}
+	retry := retryPolicy{
+		MaxAttempts:      3,
+		DelayQueueSuffix: "-retry",
+	}
+	h := newHandler("q-rank-objects", client, fn.handle, retry, "x-news", "q-dead-letters", 1)
+
+	msg := newRecordingMessage(getTestRankObject())
+	handled := h.handleMessage(msg, "msg-0")
+
+	assert.False(handled)
+	assert.True(msg.acked)
+	assert.Equal(1, len(client.sent))
+	assert.Equal("x-news", client.sent[0].exchange)
+	assert.Equal("q-rank-objects-retry-1", client.sent[0].routingKey)
+	envelope, ok := client.sent[0].msg.(retryEnvelope)
+	assert.True(ok)
+	assert.Equal(1, envelope.RetryCount)
+	assert.Equal("msg-0", envelope.MessageID)
+
+	retried := newRecordingMessage(envelope)
+	h.handleDelivery(retried)
+	assert.True(retried.acked)
+	assert.Equal(2, len(client.sent))
+	assert.Equal("q-rank-objects-retry-2", client.sent[1].routingKey)
+}
+
+func TestHandleSubscription_Cancelled(t *testing.T) {
+	assert := assert.New(t)
+
+	messages := make(chan mq.Message)
+	client := mqtest.NewSuccessMockClient(messages)
+	fn := &countingHandlerFunc{errs: []error{nil}}
+	h := newHandler("q-rank-objects", client, fn.handle, testRetryPolicy(1), "x-news", "", 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	go handleSubscription(ctx, h, wg)
+
+	msg := newRecordingMessage(getTestRankObject())
+	messages <- msg
+	cancel()
+	wg.Wait()
+
+	assert.Equal(1, fn.calls)
+	assert.True(msg.acked)
+}
+
+func TestHandleSubscription_Workers(t *testing.T) {
+	assert := assert.New(t)
+
+	workers := 4
+	started := make(chan struct{})
+	release := make(chan struct{})
+	fn := func(msg mq.Message, msgID string) error {
+		started <- struct{}{}
+		<-release
+		return nil
+	}
+
+	messages := make(chan mq.Message, workers)
+	client := mqtest.NewSuccessMockClient(messages)
+	h := newHandler("q-rank-objects", client, fn, testRetryPolicy(1), "x-news", "", workers)
+
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	go handleSubscription(context.Background(), h, wg)
+
+	sent := make([]*recordingMessage, workers)
+	for i := range sent {
+		sent[i] = newRecordingMessage(getTestRankObject())
+		messages <- sent[i]
+	}
+
+	for i := 0; i < workers; i++ {
+		select {
+		case <-started:
+		case <-time.After(time.Second):
+			t.Fatalf("Only %d of %d workers handled messages concurrently", i, workers)
+		}
+	}
+	close(release)
+	close(messages)
+	wg.Wait()
+
+	for _, msg := range sent {
+		assert.True(msg.acked)
+	}
+}
+
+// BenchmarkHandleSubscription measures message throughput for different numbers of workers
+// when handling a message takes about as long as a few Postgres round trips.
+// Messages per second for a run is 1e9 divided by its ns/op.
+func BenchmarkHandleSubscription(b *testing.B) {
+	for _, workers := range []int{1, 2, 4, 8, 16} {
+		b.Run(fmt.Sprintf("workers-%d", workers), func(b *testing.B) {
+			benchmarkHandleSubscription(b, workers)
+		})
+	}
+}
+
+func benchmarkHandleSubscription(b *testing.B, workers int) {
+	fn := func(msg mq.Message, msgID string) error {
+		time.Sleep(2 * time.Millisecond)
+		return nil
+	}
+
+	messages := make(chan mq.Message, workers)
+	client := mqtest.NewSuccessMockClient(messages)
+	h := newHandler("q-rank-objects", client, fn, testRetryPolicy(1), "x-news", "", workers)
+
+	batch := make([]mq.Message, b.N)
+	for i := range batch {
+		batch[i] = mqtest.NewMessage(getTestRankObject(), false, false)
+	}
+
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	b.ResetTimer()
+	go handleSubscription(context.Background(), h, wg)
+	for _, msg := range batch {
+		messages <- msg
+	}
+	close(messages)
+	wg.Wait()
+}
+
+func TestReplayDeadLetter(t *testing.T) {
+	assert := assert.New(t)
+
+	client := newRecordingMQClient(nil)
+	mockEnv := newMockEnv(nil, nil, client)
+
+	body, err := json.Marshal(getTestRankObject())
+	assert.Nil(err)
+	dl := deadLetter{
+		Queue:     mockEnv.rankQueue(),
+		MessageID: "msg-0",
+		Reason:    "mock error",
+		Attempts:  3,
+		Body:      body,
+	}
+
+	err = mockEnv.replayDeadLetter(mqtest.NewMessage(dl, false, false))
+	assert.Nil(err)
+	assert.Equal(1, len(client.sent))
+	assert.Equal(mockEnv.exchange(), client.sent[0].exchange)
+	assert.Equal(mockEnv.rankQueue(), client.sent[0].routingKey)
+
+	dl.Queue = "q-unknown"
+	err = mockEnv.replayDeadLetter(mqtest.NewMessage(dl, false, false))
+	assert.NotNil(err)
+
+	dl.Queue = mockEnv.scrapedQueue()
+	dl.Body = nil
+	err = mockEnv.replayDeadLetter(mqtest.NewMessage(dl, false, false))
+	assert.NotNil(err)
+	assert.Equal(1, len(client.sent))
+}
+
+func testRetryPolicy(maxAttempts int) retryPolicy {
+	return retryPolicy{
+		MaxAttempts: maxAttempts,
+	}
+}
+
+// countingHandlerFunc returns the configured errors in order, repeating the last one.
+type countingHandlerFunc struct {
+	calls     int
+	lastMsgID string
+	errs      []error
+}
+
+func (f *countingHandlerFunc) handle(msg mq.Message, msgID string) error {
+	f.calls++
+	f.lastMsgID = msgID
+	if f.calls > len(f.errs) {
+		return f.errs[len(f.errs)-1]
+	}
+	return f.errs[f.calls-1]
+}
+
+type sentMessage struct {
+	msg        interface{}
+	exchange   string
+	routingKey string
+}
+
+type recordingMQClient struct {
+	mq.Client
+	mu      sync.Mutex
+	sent    []sentMessage
+	sendErr error
+}
+
+func newRecordingMQClient(sendErr error) *recordingMQClient {
+	return &recordingMQClient{
+		Client:  mqtest.NewSuccessMockClient(nil),
+		sent:    make([]sentMessage, 0),
+		sendErr: sendErr,
+	}
+}
+
+func (c *recordingMQClient) Send(msg interface{}, exchange, routingKey string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.sendErr != nil {
+		return c.sendErr
+	}
+	c.sent = append(c.sent, sentMessage{msg: msg, exchange: exchange, routingKey: routingKey})
+	return nil
+}
+
+type recordingMessage struct {
+	mq.Message
+	acked    bool
+	rejected bool
+}
+
+func newRecordingMessage(body interface{}) *recordingMessage {
+	return &recordingMessage{
+		Message: mqtest.NewMessage(body, false, false),
+	}
+}
+
+func (m *recordingMessage) Ack() error {
+	m.acked = true
+	return nil
+}
+
+func (m *recordingMessage) Reject() error {
+	m.rejected = true
+	return nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"github.com/mimir-news/news-ranker/pkg/domain"
+	"github.com/mimir-news/news-ranker/pkg/repository"
+	"github.com/mimir-news/pkg/schema/news"
+)
+
+// canonicalURL returns the canonical form of a URL, URLs that cannot be canonicalised are used as is.
+func (e *env) canonicalURL(rawURL string) string {
+	canonical, err := e.urlCanonicalizer.Canonicalize(rawURL)
+	if err != nil {
+		logger.Warnw("URL canonicalisation failed", "url", rawURL, "err", err)
+		return rawURL
+	}
+	return canonical
+}
+
+// resolveURL returns the canonical form of a URL, or of the URL it is known to redirect to.
+func (e *env) resolveURL(rawURL string) string {
+	canonical := e.canonicalURL(rawURL)
+	if !e.config.URLs.ResolveRedirects {
+		return canonical
+	}
+
+	target, err := e.urlRedirectRepo.FindTarget(canonical)
+	if err == repository.ErrNoSuchRedirect {
+		return canonical
+	} else if err != nil {
+		logger.Errorw("Getting URL redirect failed", "url", canonical, "err", err)
+		return canonical
+	}
+	return target
+}
+
+// articleURL is a URL as requested in a rank object together with the URL it resolves to.
+// Articles are looked up and stored by the resolved URL but scraped from the requested URL,
+// which the canonical form may no longer be valid for.
+type articleURL struct {
+	URL          string
+	RequestedURL string
+}
+
+// resolveURLs resolves the URLs of a rank object, dropping URLs that resolve to the same article.
+func (e *env) resolveURLs(rawURLs []string) []articleURL {
+	resolved := make([]articleURL, 0, len(rawURLs))
+	seen := make(map[string]bool, len(rawURLs))
+	for _, rawURL := range rawURLs {
+		URL := e.resolveURL(rawURL)
+		if !seen[URL] {
+			seen[URL] = true
+			resolved = append(resolved, articleURL{URL: URL, RequestedURL: rawURL})
+		}
+	}
+	return resolved
+}
+
+// recordRedirect remembers that an article requested at one URL was scraped at another.
+func (e *env) recordRedirect(requestedURL, articleURL string) {
+	if !e.config.URLs.ResolveRedirects || requestedURL == articleURL {
+		return
+	}
+
+	err := e.urlRedirectRepo.Save(requestedURL, articleURL)
+	if err != nil {
+		logger.Errorw("Storing URL redirect failed", "url", requestedURL, "target", articleURL, "err", err)
+		return
+	}
+	logger.Infow("Stored URL redirect", "url", requestedURL, "target", articleURL)
+}
+
+// rankDuplicateScrape ranks a scraped article that turned out to be stored already under
+// another id, e.g. because the scraper followed a redirect, as references to the stored article.
+func (e *env) rankDuplicateScrape(article news.Article, scrapedArticle news.ScrapedArticle) {
+	logger.Infow("Scraped article already stored",
+		"articleId", scrapedArticle.Article.ID, "storedArticleId", article.ID)
+
+	subjects := scrapedArticle.Subjects
+	referers := []domain.Referer{domain.NewReferer(scrapedArticle.Referer)}
+	pending, isPending, unlockPending := e.lockPendingScrape(scrapedArticle.Article.ID)
+	if isPending {
+		e.recordRedirect(pending.URL, article.URL)
+		subjects = pending.MergeSubjects(subjects, article.ID)
+		referers = pending.MergeReferers(referers, article.ID)
+		e.deletePendingScrape(pending)
+	}
+	unlockPending()
+
+	for i, referer := range referers {
+		var newSubjects []news.Subject
+		if i == 0 {
+			newSubjects = subjects
+		}
+		e.rankExistingArticle(article, article.URL, newSubjects, referer)
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mimir-news/news-ranker/pkg/repository"
+	"github.com/mimir-news/pkg/id"
+	"github.com/mimir-news/pkg/mq/mqtest"
+	"github.com/mimir-news/pkg/schema/news"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandleRankObjectMessage_CanonicalURLs(t *testing.T) {
+	assert := assert.New(t)
+
+	mqClient := newRecordingMQClient(nil)
+	store := repository.NewMemoryStore()
+	mockEnv := newMockEnv(repository.NewMemoryArticleRepo(store), repository.NewMemoryClusterRepo(store), mqClient)
+	mockEnv.config.Scrape = scrapeConfig{PendingTimeout: time.Hour, MaxAttempts: 2}
+
+	ro := getTestRankObject()
+	ro.URLs = []string{
+		"http://www.example.com/article?utm_source=twitter",
+		"https://example.com/article/#comments",
+	}
+	err := mockEnv.handleRankObjectMessage(mqtest.NewMessage(ro, false, false), id.New())
+	assert.Nil(err)
+
+	assert.Equal(1, len(mqClient.sent))
+	target := mqClient.sent[0].msg.(news.ScrapeTarget)
+	assert.Equal("http://www.example.com/article?utm_source=twitter", target.URL)
+
+	pending, err := mockEnv.pendingScrapeRepo.FindByURL("https://example.com/article")
+	assert.Nil(err)
+	assert.Equal(target.ArticleID, pending.ArticleID)
+}
+
+func TestHandleScrapedArticleMessage_Redirect(t *testing.T) {
+	assert := assert.New(t)
+
+	mqClient := newRecordingMQClient(nil)
+	store := repository.NewMemoryStore()
+	mockEnv := newMockEnv(repository.NewMemoryArticleRepo(store), repository.NewMemoryClusterRepo(store), mqClient)
+	mockEnv.config.Scrape = scrapeConfig{PendingTimeout: time.Hour, MaxAttempts: 2}
+	mockEnv.config.URLs.ResolveRedirects = true
+	mockEnv.urlRedirectRepo = repository.NewMemoryURLRedirectRepo()
+
+	ro := getTestRankObject()
+	ro.URLs = []string{"https://t.co/abc"}
+	err := mockEnv.handleRankObjectMessage(mqtest.NewMessage(ro, false, false), id.New())
+	assert.Nil(err)
+	assert.Equal(1, len(mqClient.sent))
+	target := mqClient.sent[0].msg.(news.ScrapeTarget)
+
+	scrapedArticle := getTestScrapedArticle()
+	scrapedArticle.Article.ID = target.ArticleID
+	scrapedArticle.Article.URL = "https://www.example.com/article?utm_medium=social"
+	scrapedArticle.Subjects = target.Subjects
+	scrapedArticle.Referer = target.Referer
+	err = mockEnv.handleScrapedArticleMessage(mqtest.NewMessage(scrapedArticle, false, false), id.New())
+	assert.Nil(err)
+
+	article, err := mockEnv.articleRepo.FindByURL("https://example.com/article")
+	assert.Nil(err)
+	assert.Equal(target.ArticleID, article.ID)
+	redirectTarget, err := mockEnv.urlRedirectRepo.FindTarget("https://t.co/abc")
+	assert.Nil(err)
+	assert.Equal("https://example.com/article", redirectTarget)
+
+	ro.Referer.ExternalID = "e-id-1"
+	err = mockEnv.handleRankObjectMessage(mqtest.NewMessage(ro, false, false), id.New())
+	assert.Nil(err)
+	assert.Equal(1, len(mqClient.sent))
+	referers, err := mockEnv.articleRepo.FindArticleReferers(article.ID)
+	assert.Nil(err)
+	assert.Equal(2, len(referers))
+}
+
+func TestHandleScrapedArticleMessage_AlreadyStored(t *testing.T) {
+	assert := assert.New(t)
+
+	store := repository.NewMemoryStore()
+	mockEnv := newMockEnv(repository.NewMemoryArticleRepo(store), repository.NewMemoryClusterRepo(store), newRecordingMQClient(nil))
+
+	stored := getTestScrapedArticle()
+	stored.Article.URL = "https://example.com/article"
+	err := mockEnv.articleRepo.SaveScrapedArticle(stored, domainReferers([]news.Referer{stored.Referer}))
+	assert.Nil(err)
+
+	scrapedArticle := getTestScrapedArticle()
+	scrapedArticle.Article.ID = "a-1"
+	scrapedArticle.Article.URL = "https://example.com/article/amp"
+	scrapedArticle.Subjects = []news.Subject{{ID: "s-2", Symbol: "S0", ArticleID: "a-1"}}
+	scrapedArticle.Referer = news.Referer{ID: "r-1", ExternalID: "e-id-1", FollowerCount: 1000, ArticleID: "a-1"}
+	err = mockEnv.handleScrapedArticleMessage(mqtest.NewMessage(scrapedArticle, false, false), id.New())
+	assert.Nil(err)
+
+	_, err = mockEnv.articleRepo.FindByID("a-1")
+	assert.Equal(repository.ErrNoSuchArticle, err)
+	referers, err := mockEnv.articleRepo.FindArticleReferers(stored.Article.ID)
+	assert.Nil(err)
+	assert.Equal(2, len(referers))
+	article, err := mockEnv.articleRepo.FindByID(stored.Article.ID)
+	assert.Nil(err)
+	assertScore(mockEnv.scorer.Score(referers...), article.ReferenceScore, t)
+}
@@ -17,13 +17,29 @@ const (
 // UpdateType describes distinct type of update.
 type UpdateType int
 
+// String returns a string representation of an update type.
+func (t UpdateType) String() string {
+	switch t {
+	case NoUpdate:
+		return "none"
+	case NewSubjects:
+		return "new_subjects"
+	case NewReferences:
+		return "new_references"
+	case NewSubjectsAndReferences:
+		return "new_subjects_and_references"
+	default:
+		return "unknown"
+	}
+}
+
 // ArticleUpdate bundles an update instruction with the data needed to perform it.
 type ArticleUpdate struct {
 	Type       UpdateType
 	Article    news.Article
 	Subjects   []news.Subject
-	Referers   []news.Referer
-	NewReferer news.Referer
+	Referers   []Referer
+	NewReferer Referer
 }
 
 // ToScapeTarget creatas a scrape target from ana article update.
@@ -32,7 +48,7 @@ func (u ArticleUpdate) ToScapeTarget() news.ScrapeTarget {
 	return news.ScrapeTarget{
 		URL:       article.URL,
 		Subjects:  u.Subjects,
-		Referer:   u.NewReferer,
+		Referer:   u.NewReferer.Referer,
 		Title:     article.Title,
 		Body:      article.Body,
 		ArticleID: article.ID,
@@ -41,7 +57,7 @@ func (u ArticleUpdate) ToScapeTarget() news.ScrapeTarget {
 
 // CreateArticleUpdate dicerns how an article has been updated
 // and assembles the data needed to rank it again.
-func CreateArticleUpdate(article news.Article, oldSub, newSub []news.Subject, referers []news.Referer, newReferer news.Referer) ArticleUpdate {
+func CreateArticleUpdate(article news.Article, oldSub, newSub []news.Subject, referers []Referer, newReferer Referer) ArticleUpdate {
 	mergedSubjects := mergeSubjects(oldSub, newSub, article.ID)
 	mergedReferers := mergeReferers(referers, newReferer, article.ID)
 
@@ -86,8 +102,8 @@ func createSubjectSet(subjects []news.Subject) map[string]bool {
 	return subjectSet
 }
 
-func mergeReferers(referers []news.Referer, newReferer news.Referer, articleID string) []news.Referer {
-	merged := make([]news.Referer, len(referers))
+func mergeReferers(referers []Referer, newReferer Referer, articleID string) []Referer {
+	merged := make([]Referer, len(referers))
 	copy(merged, referers)
 
 	for _, referer := range referers {
@@ -99,7 +115,7 @@ func mergeReferers(referers []news.Referer, newReferer news.Referer, articleID s
 	return merged
 }
 
-func copyRefererWithIDs(referer news.Referer, articleID string) news.Referer {
+func copyRefererWithIDs(referer Referer, articleID string) Referer {
 	referer.ArticleID = articleID
 	if referer.ID == "" {
 		referer.ID = id.New()
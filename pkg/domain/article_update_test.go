@@ -45,36 +45,36 @@ func TestCreateArticleUpdate(t *testing.T) {
 		},
 	}
 
-	oldRefs := []news.Referer{
-		news.Referer{
+	oldRefs := []Referer{
+		NewReferer(news.Referer{
 			ID:         "some-id-0",
 			ExternalID: "r-0",
 			ArticleID:  "article-0",
-		},
-		news.Referer{
+		}),
+		NewReferer(news.Referer{
 			ID:         "some-id-0",
 			ExternalID: "r-1",
 			ArticleID:  "article-0",
-		},
+		}),
 	}
-	newRef := news.Referer{ExternalID: "r-2"}
-	repeatedRef := news.Referer{ExternalID: "r-1"}
-	mergedRefs := []news.Referer{
-		news.Referer{
+	newRef := NewReferer(news.Referer{ExternalID: "r-2"})
+	repeatedRef := NewReferer(news.Referer{ExternalID: "r-1"})
+	mergedRefs := []Referer{
+		NewReferer(news.Referer{
 			ID:         "some-id-0",
 			ExternalID: "r-0",
 			ArticleID:  "article-0",
-		},
-		news.Referer{
+		}),
+		NewReferer(news.Referer{
 			ID:         "some-id-0",
 			ExternalID: "r-1",
 			ArticleID:  "article-0",
-		},
-		news.Referer{
+		}),
+		NewReferer(news.Referer{
 			ID:         "some-id-will-not-be-this-but-must-be-set",
 			ExternalID: "r-2",
 			ArticleID:  "article-0",
-		},
+		}),
 	}
 
 	u1 := CreateArticleUpdate(a, oldSubj, repeatedSubjects, oldRefs, repeatedRef)
@@ -106,7 +106,7 @@ func TestCreateArticleUpdate(t *testing.T) {
 	}
 }
 
-func assertArticleUpdate(t *testing.T, u ArticleUpdate, eA news.Article, eS []news.Subject, eR []news.Referer) {
+func assertArticleUpdate(t *testing.T, u ArticleUpdate, eA news.Article, eS []news.Subject, eR []Referer) {
 	if u.Article.ID != eA.ID {
 		t.Errorf("Article.ID wrong. Expected: %s Got: %s", u.Article.ID, eA.ID)
 	}
@@ -170,11 +170,11 @@ func TestToScrapeTarget(t *testing.T) {
 			news.Subject{Symbol: "s-0"},
 			news.Subject{Symbol: "s-1"},
 		},
-		Referers: []news.Referer{
-			news.Referer{ExternalID: "r-0"},
-			news.Referer{ExternalID: "r-1"},
+		Referers: []Referer{
+			NewReferer(news.Referer{ExternalID: "r-0"}),
+			NewReferer(news.Referer{ExternalID: "r-1"}),
 		},
-		NewReferer: news.Referer{ExternalID: "r-1"},
+		NewReferer: NewReferer(news.Referer{ExternalID: "r-1"}),
 	}
 
 	exptectedTarget := news.ScrapeTarget{
@@ -197,3 +197,19 @@ func TestToScrapeTarget(t *testing.T) {
 			exptectedTarget, actualTarget)
 	}
 }
+
+func TestUpdateTypeString(t *testing.T) {
+	expected := map[UpdateType]string{
+		NoUpdate:                 "none",
+		NewSubjects:              "new_subjects",
+		NewReferences:            "new_references",
+		NewSubjectsAndReferences: "new_subjects_and_references",
+		UpdateType(0):            "unknown",
+	}
+
+	for updateType, name := range expected {
+		if updateType.String() != name {
+			t.Errorf("UpdateType(%d).String() wrong. Expected=%s Got=%s", int(updateType), name, updateType.String())
+		}
+	}
+}
@@ -31,7 +31,10 @@ type ArticleCluster struct {
 	Symbol        string
 	ArticleDate   time.Time
 	LeadArticleID string
+	LeaderReason  string
 	Score         float64
+	Fingerprint   uint64
+	Version       int64
 	Members       []ClusterMember
 }
 
@@ -46,24 +49,94 @@ func (a *ArticleCluster) AddMember(newMember ClusterMember) {
 	a.Members = append(a.Members, newMember)
 }
 
-// ElectLeaderAndScore finds highes scoring member and sums up the total cluster score.
-func (a *ArticleCluster) ElectLeaderAndScore() {
-	leader := selectHighestScoreMember(a.Members)
+// MemberDelta is the change to a cluster made by upserting a member.
+type MemberDelta struct {
+	ArticleID      string
+	Added          bool
+	ReferenceScore float64
+	SubjectScore   float64
+	PreviousLeader string
+	Leader         string
+}
+
+// Changed returns true if the member was added or had its scores changed.
+func (d MemberDelta) Changed() bool {
+	return d.Added || d.ReferenceScore != 0 || d.SubjectScore != 0
+}
+
+// LeaderChanged returns true if another member was elected leader.
+func (d MemberDelta) LeaderChanged() bool {
+	return d.PreviousLeader != d.Leader
+}
+
+// UpsertMember adds the member to the cluster, or refreshes the reference and subject scores
+// of the existing member for the same article. The leader is then re-elected and the cluster
+// rescored. Returns the changes made to the member scores and leadership.
+func (a *ArticleCluster) UpsertMember(member ClusterMember, policy LeaderPolicy) MemberDelta {
+	delta := MemberDelta{
+		ArticleID:      member.ArticleID,
+		PreviousLeader: a.LeadArticleID,
+	}
+
+	existing := a.findMember(member.ArticleID)
+	if existing == nil {
+		a.Members = append(a.Members, member)
+		delta.Added = true
+		delta.ReferenceScore = member.ReferenceScore
+		delta.SubjectScore = member.SubjectScore
+	} else {
+		delta.ReferenceScore = member.ReferenceScore - existing.ReferenceScore
+		delta.SubjectScore = member.SubjectScore - existing.SubjectScore
+		existing.ReferenceScore = member.ReferenceScore
+		existing.SubjectScore = member.SubjectScore
+		if member.ReferencedAt.After(existing.ReferencedAt) {
+			existing.ReferencedAt = member.ReferencedAt
+		}
+	}
+
+	a.ElectLeaderAndScore(policy)
+	delta.Leader = a.LeadArticleID
+	return delta
+}
+
+func (a *ArticleCluster) findMember(articleID string) *ClusterMember {
+	for i := range a.Members {
+		if a.Members[i].ArticleID == articleID {
+			return &a.Members[i]
+		}
+	}
+	return nil
+}
+
+// ElectLeaderAndScore elects the leader by the policy and sums up the total cluster score,
+// which is the subject score of the leader and the reference scores of all members.
+func (a *ArticleCluster) ElectLeaderAndScore(policy LeaderPolicy) {
+	leader, reason := policy.Elect(a.Members)
 	referenceSum := sumReferenceScore(a.Members)
 	a.LeadArticleID = leader.ArticleID
+	a.LeaderReason = reason
 	a.Score = leader.SubjectScore + referenceSum
 }
 
-func selectHighestScoreMember(members []ClusterMember) ClusterMember {
-	var highScoreMember ClusterMember
-	highScore := 0.0
-	for _, member := range members {
-		if member.Score() >= highScore {
-			highScore = member.Score()
-			highScoreMember = member
+// ApplyDecay discounts the cluster score by age. The subject score of the leader
+// decays with the article date while the share of each referer in the reference score
+// of a member decays with the time the referer was observed. Members without referer
+// shares decay their whole reference score with the time their latest referer was observed.
+func (a *ArticleCluster) ApplyDecay(decayer Decayer, now time.Time) {
+	var score float64
+	for _, member := range a.Members {
+		if member.ArticleID == a.LeadArticleID {
+			score += decayer.Decay(member.SubjectScore, now.Sub(a.ArticleDate))
+		}
+		if len(member.References) == 0 {
+			score += decayer.Decay(member.ReferenceScore, now.Sub(member.referenceTime(a.ArticleDate)))
+			continue
+		}
+		for _, reference := range member.References {
+			score += decayer.Decay(reference.Score, now.Sub(reference.time(a.ArticleDate)))
 		}
 	}
-	return highScoreMember
+	a.Score = score
 }
 
 func sumReferenceScore(members []ClusterMember) float64 {
@@ -100,17 +173,38 @@ func CalcClusterHash(title, symbol string, date time.Time) string {
 // String returns a string representation of an article cluster.
 func (a *ArticleCluster) String() string {
 	return fmt.Sprintf(
-		"ArticleCluster(hash=%s title=%s symbol=%s articleDate=%s leadArticleId=%s score=%f)",
-		a.Hash, a.Title, a.Symbol, a.ArticleDate, a.LeadArticleID, a.Score)
+		"ArticleCluster(hash=%s title=%s symbol=%s articleDate=%s leadArticleId=%s leaderReason=%s score=%f)",
+		a.Hash, a.Title, a.Symbol, a.ArticleDate, a.LeadArticleID, a.LeaderReason, a.Score)
 }
 
-// ClusterMember is a scored article that is part of a cluster.
+// ClusterMember is a scored article that is part of a cluster. The time the article was
+// first seen, its source and body length are read from the article to elect leaders.
+// References split the reference score by referer to decay it, they are never stored.
 type ClusterMember struct {
 	ID             string
 	ClusterHash    string
 	ArticleID      string
 	ReferenceScore float64
 	SubjectScore   float64
+	ReferencedAt   time.Time
+	FirstSeenAt    time.Time
+	Source         string
+	BodyLength     int
+	References     []Reference
+}
+
+// Reference is the share of a member reference score contributed by a referer observed at a time.
+type Reference struct {
+	Score      float64
+	ObservedAt time.Time
+}
+
+// time returns the time the referer was observed, falling back to the provided default if unknown.
+func (r Reference) time(defaultTime time.Time) time.Time {
+	if r.ObservedAt.IsZero() {
+		return defaultTime
+	}
+	return r.ObservedAt
 }
 
 // NewClusterMember creates a new ClusterMemeber
@@ -121,6 +215,7 @@ func NewClusterMember(clusterHash, articleID string, referenceScore, subjectScor
 		ArticleID:      articleID,
 		ReferenceScore: referenceScore,
 		SubjectScore:   subjectScore,
+		ReferencedAt:   time.Now(),
 	}
 }
 
@@ -129,6 +224,39 @@ func (m *ClusterMember) Score() float64 {
 	return m.ReferenceScore + m.SubjectScore
 }
 
+// SplitReferences splits the reference score of the member between its referers in proportion
+// to the score of each referer on its own, so that the shares sum up to the reference score.
+// The references are cleared if the referers score nothing.
+func (m *ClusterMember) SplitReferences(scorer Scorer, referers []Referer) {
+	scores := make([]float64, len(referers))
+	var total float64
+	for i, referer := range referers {
+		scores[i] = scorer.Score(referer)
+		total += scores[i]
+	}
+
+	m.References = nil
+	if total <= 0 {
+		return
+	}
+	m.References = make([]Reference, len(referers))
+	for i, referer := range referers {
+		m.References[i] = Reference{
+			Score:      m.ReferenceScore * scores[i] / total,
+			ObservedAt: referer.CreatedAt,
+		}
+	}
+}
+
+// referenceTime returns the time the member was last referenced,
+// falling back to the provided default if unknown.
+func (m *ClusterMember) referenceTime(defaultTime time.Time) time.Time {
+	if m.ReferencedAt.IsZero() {
+		return defaultTime
+	}
+	return m.ReferencedAt
+}
+
 func (m *ClusterMember) String() string {
 	return fmt.Sprintf(
 		"ClusterMember(id=%s clusterHash=%s articleId=%s referenceScore=%f subjectScore=%f)",
@@ -0,0 +1,42 @@
+package domain
+
+import (
+	"time"
+)
+
+// Reasons for a cluster to change.
+const (
+	ClusterCreatedReason = "created"
+	MemberAddedReason    = "member_added"
+	MemberUpdatedReason  = "member_updated"
+)
+
+// ClusterUpdated event describing a change to an article cluster.
+type ClusterUpdated struct {
+	Hash          string    `json:"hash"`
+	Symbol        string    `json:"symbol"`
+	ArticleDate   string    `json:"articleDate"`
+	Score         float64   `json:"score"`
+	PreviousScore float64   `json:"previousScore"`
+	LeadArticleID string    `json:"leadArticleId"`
+	LeaderReason  string    `json:"leaderReason"`
+	MemberCount   int       `json:"memberCount"`
+	Reason        string    `json:"reason"`
+	UpdatedAt     time.Time `json:"updatedAt"`
+}
+
+// NewClusterUpdated creates a ClusterUpdated event from the changed cluster.
+func NewClusterUpdated(cluster ArticleCluster, previousScore float64, reason string) ClusterUpdated {
+	return ClusterUpdated{
+		Hash:          cluster.Hash,
+		Symbol:        cluster.Symbol,
+		ArticleDate:   cluster.ArticleDate.Format(dateFormat),
+		Score:         cluster.Score,
+		PreviousScore: previousScore,
+		LeadArticleID: cluster.LeadArticleID,
+		LeaderReason:  cluster.LeaderReason,
+		MemberCount:   len(cluster.Members),
+		Reason:        reason,
+		UpdatedAt:     time.Now().UTC(),
+	}
+}
@@ -0,0 +1,28 @@
+package domain
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewClusterUpdated(t *testing.T) {
+	articleDate, _ := time.Parse(dateFormat, "2018-10-25")
+	members := []ClusterMember{
+		*NewClusterMember("hash-0", "a-0", 0.3, 0.1),
+		*NewClusterMember("hash-0", "a-1", 0.4, 0.2),
+	}
+	cluster := *NewArticleCluster("title-0", "AAPL", articleDate, "a-1", 0.9, members)
+
+	event := NewClusterUpdated(cluster, 0.5, MemberAddedReason)
+	if event.Hash != cluster.Hash {
+		t.Errorf("NewClusterUpdated Hash wrong. Expected=%s Got=%s", cluster.Hash, event.Hash)
+	}
+	if event.ArticleDate != "2018-10-25" {
+		t.Errorf("NewClusterUpdated ArticleDate wrong. Expected=2018-10-25 Got=%s", event.ArticleDate)
+	}
+	assertFloat(t, 0.9, event.Score, "NewClusterUpdated Score")
+	assertFloat(t, 0.5, event.PreviousScore, "NewClusterUpdated PreviousScore")
+	if event.LeadArticleID != "a-1" || event.MemberCount != 2 || event.Reason != MemberAddedReason {
+		t.Errorf("NewClusterUpdated wrong. Got=%+v", event)
+	}
+}
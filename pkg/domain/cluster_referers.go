@@ -0,0 +1,41 @@
+package domain
+
+import (
+	"math"
+	"sort"
+)
+
+// DedupeClusterReferers weights the referers of the member articles of a cluster so that an author
+// referring to several members counts fully once, for the member first seen by the ranker, and is
+// weighted by the repeat weight raised to the number of earlier references for each repeat. Members
+// are ordered by when they were first seen as articles are only dated by day. Repeated referers are
+// discounted in the contribution they are scored with and dropped when weighted to zero, so a repeat
+// weight of 0 lets each author contribute once to a cluster and 1 keeps every referer.
+// The referers are given and returned by article id.
+func DedupeClusterReferers(members []ClusterMember, referers map[string][]Referer, repeatWeight float64) map[string][]Referer {
+	ordered := make([]ClusterMember, len(members))
+	copy(ordered, members)
+	sort.Slice(ordered, func(i, j int) bool {
+		a, b := ordered[i], ordered[j]
+		if !a.FirstSeenAt.Equal(b.FirstSeenAt) {
+			return a.FirstSeenAt.Before(b.FirstSeenAt)
+		}
+		return a.ArticleID < b.ArticleID
+	})
+
+	references := make(map[string]int)
+	deduped := make(map[string][]Referer, len(members))
+	for _, member := range ordered {
+		memberReferers := make([]Referer, 0, len(referers[member.ArticleID]))
+		for _, referer := range referers[member.ArticleID] {
+			weight := math.Pow(repeatWeight, float64(references[referer.ExternalID]))
+			references[referer.ExternalID]++
+			if weight > 0 {
+				referer.RepeatDiscount = 1 - weight
+				memberReferers = append(memberReferers, referer)
+			}
+		}
+		deduped[member.ArticleID] = memberReferers
+	}
+	return deduped
+}
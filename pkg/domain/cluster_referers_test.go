@@ -0,0 +1,70 @@
+package domain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mimir-news/pkg/schema/news"
+)
+
+func TestDedupeClusterReferers(t *testing.T) {
+	now := time.Now()
+	members := []ClusterMember{
+		newTestLeaderMember("a-0", 0, now, "", 0),
+		newTestLeaderMember("a-1", 0, now.Add(-time.Hour), "", 0),
+		newTestLeaderMember("a-2", 0, now, "", 0),
+	}
+	referers := map[string][]Referer{
+		"a-0": []Referer{
+			NewReferer(news.Referer{ExternalID: "author-0", FollowerCount: 1000}),
+			NewReferer(news.Referer{ExternalID: "author-1", FollowerCount: 1000}),
+		},
+		"a-1": []Referer{
+			NewReferer(news.Referer{ExternalID: "author-0", FollowerCount: 1000}),
+		},
+		"a-2": []Referer{
+			NewReferer(news.Referer{ExternalID: "author-0", FollowerCount: 1000}),
+			NewReferer(news.Referer{ExternalID: "author-2", FollowerCount: 1000}),
+		},
+	}
+
+	cases := []struct {
+		repeatWeight float64
+		weights      map[string][]float64
+	}{
+		{repeatWeight: 0, weights: map[string][]float64{
+			"a-0": {1}, "a-1": {1}, "a-2": {1},
+		}},
+		{repeatWeight: 0.5, weights: map[string][]float64{
+			"a-0": {0.5, 1}, "a-1": {1}, "a-2": {0.25, 1},
+		}},
+		{repeatWeight: 1, weights: map[string][]float64{
+			"a-0": {1, 1}, "a-1": {1}, "a-2": {1, 1},
+		}},
+	}
+	for _, c := range cases {
+		deduped := DedupeClusterReferers(members, referers, c.repeatWeight)
+		for articleID, expected := range c.weights {
+			actual := make([]float64, 0, len(deduped[articleID]))
+			for _, referer := range deduped[articleID] {
+				actual = append(actual, referer.weight())
+			}
+			if len(expected) != len(actual) {
+				t.Errorf("DedupeClusterReferers with repeatWeight=%f wrong referers of %s. Expected=%v Actual=%v",
+					c.repeatWeight, articleID, expected, actual)
+				continue
+			}
+			for i := range expected {
+				if expected[i] != actual[i] {
+					t.Errorf("DedupeClusterReferers with repeatWeight=%f wrong referers of %s. Expected=%v Actual=%v",
+						c.repeatWeight, articleID, expected, actual)
+					break
+				}
+			}
+		}
+	}
+
+	if referers["a-0"][0].RepeatDiscount != 0 {
+		t.Errorf("DedupeClusterReferers modified the given referers")
+	}
+}
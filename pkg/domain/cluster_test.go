@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/mimir-news/pkg/id"
+	"github.com/mimir-news/pkg/schema/news"
 )
 
 func TestAddMember(t *testing.T) {
@@ -36,6 +37,45 @@ func TestAddMember(t *testing.T) {
 	}
 }
 
+func TestUpsertMember(t *testing.T) {
+	title := "title"
+	symbol := "symbol"
+	articleDate := time.Now()
+	clusterHash := CalcClusterHash(title, symbol, articleDate)
+	members := []ClusterMember{
+		*NewClusterMember(clusterHash, "member-1", 1.0, 1.0),
+		*NewClusterMember(clusterHash, "member-2", 2.0, 2.0),
+	}
+	cluster := NewArticleCluster(title, symbol, articleDate, "", 0, members)
+	cluster.ElectLeaderAndScore(newTestLeaderPolicy(HighestScoreLeader))
+	memberID := cluster.Members[0].ID
+
+	delta := cluster.UpsertMember(*NewClusterMember(clusterHash, "member-1", 4.0, 1.0), newTestLeaderPolicy(HighestScoreLeader))
+	if len(cluster.Members) != 2 || cluster.Members[0].ID != memberID {
+		t.Fatalf("ArticleCluster.UpsertMember failed, existing member not updated in place. Members=%v", cluster.Members)
+	}
+	if cluster.Members[0].ReferenceScore != 4.0 || cluster.Score != 7.0 || cluster.LeadArticleID != "member-1" {
+		t.Errorf("ArticleCluster.UpsertMember wrong result. Expected score=7.0 leader=member-1 Actual score=%f leader=%s",
+			cluster.Score, cluster.LeadArticleID)
+	}
+	if delta.Added || delta.ReferenceScore != 3.0 || delta.SubjectScore != 0 || !delta.Changed() {
+		t.Errorf("ArticleCluster.UpsertMember wrong delta. Expected referenceScore=3.0 Actual=%+v", delta)
+	}
+	if delta.PreviousLeader != "member-2" || delta.Leader != "member-1" || !delta.LeaderChanged() {
+		t.Errorf("ArticleCluster.UpsertMember wrong leader change. Expected member-2 -> member-1 Actual=%+v", delta)
+	}
+
+	delta = cluster.UpsertMember(*NewClusterMember(clusterHash, "member-1", 4.0, 1.0), newTestLeaderPolicy(HighestScoreLeader))
+	if delta.Changed() || delta.LeaderChanged() {
+		t.Errorf("ArticleCluster.UpsertMember unchanged member reported as changed. Delta=%+v", delta)
+	}
+
+	delta = cluster.UpsertMember(*NewClusterMember(clusterHash, "member-3", 0.5, 0.5), newTestLeaderPolicy(HighestScoreLeader))
+	if len(cluster.Members) != 3 || !delta.Added || delta.ReferenceScore != 0.5 || cluster.Score != 7.5 {
+		t.Errorf("ArticleCluster.UpsertMember failed to add new member. Delta=%+v Score=%f", delta, cluster.Score)
+	}
+}
+
 func TestElectLeaderAndScore(t *testing.T) {
 	title := "title"
 	symbol := "symbol"
@@ -48,7 +88,7 @@ func TestElectLeaderAndScore(t *testing.T) {
 	}
 	cluster1 := NewArticleCluster(title, symbol, articleDate, "", 0, members)
 
-	cluster1.ElectLeaderAndScore()
+	cluster1.ElectLeaderAndScore(newTestLeaderPolicy(HighestScoreLeader))
 	if cluster1.Score != 6.0 {
 		t.Errorf("ArticleCluster.ElectLeaderAndScore wrong score. Expected=6.0 Actual=%f",
 			cluster1.Score)
@@ -65,7 +105,7 @@ func TestElectLeaderAndScore(t *testing.T) {
 	}
 	cluster2 := NewArticleCluster(title, symbol, articleDate, "", 0, members2)
 
-	cluster2.ElectLeaderAndScore()
+	cluster2.ElectLeaderAndScore(newTestLeaderPolicy(HighestScoreLeader))
 	if cluster2.Score != 6.0 {
 		t.Errorf("ArticleCluster.ElectLeaderAndScore wrong score. Expected=6.0 Actual=%f",
 			cluster2.Score)
@@ -76,6 +116,70 @@ func TestElectLeaderAndScore(t *testing.T) {
 	}
 }
 
+func TestApplyDecay(t *testing.T) {
+	now := time.Now()
+	articleDate := now.Add(-48 * time.Hour)
+	clusterHash := CalcClusterHash("title", "symbol", articleDate)
+	members := []ClusterMember{
+		*NewClusterMember(clusterHash, "member-1", 1.0, 1.0),
+		*NewClusterMember(clusterHash, "member-2", 2.0, 4.0),
+		*NewClusterMember(clusterHash, "member-3", 4.0, 1.0),
+	}
+	members[0].ReferencedAt = now.Add(-24 * time.Hour)
+	members[1].ReferencedAt = now
+	members[2].ReferencedAt = time.Time{}
+
+	cluster := NewArticleCluster("title", "symbol", articleDate, "", 0, members)
+	cluster.ElectLeaderAndScore(newTestLeaderPolicy(HighestScoreLeader))
+	if cluster.LeadArticleID != "member-2" {
+		t.Fatalf("ArticleCluster.ElectLeaderAndScore wrong LeadArticleId. Expected=member-2 Actual=%s",
+			cluster.LeadArticleID)
+	}
+
+	decayer, _ := NewDecayer(DecayConfig{Model: ExponentialDecay, HalfLife: 24 * time.Hour})
+	cluster.ApplyDecay(decayer, now)
+	// Leader subject score 4.0 decays 2 half lives, reference scores 1.0, 2.0, 4.0 decay 1, 0, 2 half lives.
+	assertFloat(t, 1.0+0.5+2.0+1.0, cluster.Score, "ArticleCluster.ApplyDecay")
+
+	noDecay, _ := NewDecayer(DecayConfig{Model: NoDecay})
+	cluster.ApplyDecay(noDecay, now)
+	assertFloat(t, 11.0, cluster.Score, "ArticleCluster.ApplyDecay")
+}
+
+func TestApplyDecay_PerReferer(t *testing.T) {
+	now := time.Now()
+	articleDate := now.Add(-48 * time.Hour)
+	clusterHash := CalcClusterHash("title", "symbol", articleDate)
+	members := []ClusterMember{
+		*NewClusterMember(clusterHash, "member-1", 3.0, 1.0),
+	}
+	members[0].ReferencedAt = now
+
+	referers := []Referer{
+		{Referer: news.Referer{ID: "r-0", FollowerCount: 2000}, CreatedAt: now.Add(-48 * time.Hour)},
+		{Referer: news.Referer{ID: "r-1", FollowerCount: 1000}, CreatedAt: now},
+	}
+	members[0].SplitReferences(NewLinearScorer(1000, 1.0), referers)
+	if len(members[0].References) != 2 {
+		t.Fatalf("ClusterMember.SplitReferences wrong number of references. Expected=2 Actual=%d",
+			len(members[0].References))
+	}
+	assertFloat(t, 2.0, members[0].References[0].Score, "ClusterMember.SplitReferences")
+	assertFloat(t, 1.0, members[0].References[1].Score, "ClusterMember.SplitReferences")
+
+	cluster := NewArticleCluster("title", "symbol", articleDate, "", 0, members)
+	cluster.ElectLeaderAndScore(newTestLeaderPolicy(HighestScoreLeader))
+	decayer, _ := NewDecayer(DecayConfig{Model: ExponentialDecay, HalfLife: 24 * time.Hour})
+	cluster.ApplyDecay(decayer, now)
+	// Subject score 1.0 and the share 2.0 of the first referer decay 2 half lives, while the share 1.0
+	// of the latest referer is not decayed even though the member was referenced just now.
+	assertFloat(t, 0.25+0.5+1.0, cluster.Score, "ArticleCluster.ApplyDecay")
+
+	cluster.Members[0].SplitReferences(NewLinearScorer(1000, 1.0), nil)
+	cluster.ApplyDecay(decayer, now)
+	assertFloat(t, 0.25+3.0, cluster.Score, "ArticleCluster.ApplyDecay")
+}
+
 func TestCalcClusterHash(t *testing.T) {
 	title := "title"
 	symbol := "symbol"
@@ -0,0 +1,94 @@
+package domain
+
+import (
+	"math"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Decay models.
+const (
+	NoDecay          = "none"
+	ExponentialDecay = "exponential"
+	GravityDecay     = "gravity"
+)
+
+// gravityOffsetHours offsets the age of an item in the gravity model
+// so that brand new items are not scored infinitely high.
+const gravityOffsetHours = 2.0
+
+// Common decay errors.
+var (
+	ErrUnknownDecayModel = errors.New("Unknown decay model")
+	ErrInvalidHalfLife   = errors.New("Half life must be positive")
+	ErrInvalidGravity    = errors.New("Gravity must be positive")
+)
+
+// Decayer discounts a score based on the age of what is scored.
+type Decayer interface {
+	Decay(score float64, age time.Duration) float64
+}
+
+// DecayConfig parameters needed to create a Decayer.
+type DecayConfig struct {
+	Model    string
+	HalfLife time.Duration
+	Gravity  float64
+}
+
+// NewDecayer creates a Decayer using the model specified in the config.
+func NewDecayer(conf DecayConfig) (Decayer, error) {
+	switch conf.Model {
+	case NoDecay:
+		return noDecayer{}, nil
+	case ExponentialDecay:
+		if conf.HalfLife <= 0 {
+			return nil, ErrInvalidHalfLife
+		}
+		return &exponentialDecayer{halfLife: conf.HalfLife}, nil
+	case GravityDecay:
+		if conf.Gravity <= 0 {
+			return nil, ErrInvalidGravity
+		}
+		return &gravityDecayer{gravity: conf.Gravity}, nil
+	default:
+		return nil, errors.Wrap(ErrUnknownDecayModel, conf.Model)
+	}
+}
+
+// noDecayer leaves scores untouched regardless of age.
+type noDecayer struct{}
+
+func (d noDecayer) Decay(score float64, age time.Duration) float64 {
+	return score
+}
+
+// exponentialDecayer halves a score every half life.
+type exponentialDecayer struct {
+	halfLife time.Duration
+}
+
+func (d *exponentialDecayer) Decay(score float64, age time.Duration) float64 {
+	halfLives := nonNegativeAge(age).Hours() / d.halfLife.Hours()
+	return score * math.Pow(0.5, halfLives)
+}
+
+// gravityDecayer discounts a score by the age in hours raised to a gravity exponent,
+// normalized so that a score of age zero is left untouched.
+type gravityDecayer struct {
+	gravity float64
+}
+
+func (d *gravityDecayer) Decay(score float64, age time.Duration) float64 {
+	ageHours := nonNegativeAge(age).Hours()
+	factor := gravityOffsetHours / (ageHours + gravityOffsetHours)
+	return score * math.Pow(factor, d.gravity)
+}
+
+func nonNegativeAge(age time.Duration) time.Duration {
+	if age < 0 {
+		return 0
+	}
+	return age
+}
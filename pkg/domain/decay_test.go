@@ -0,0 +1,55 @@
+package domain
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewDecayer(t *testing.T) {
+	for _, model := range []string{NoDecay, ExponentialDecay, GravityDecay} {
+		decayer, err := NewDecayer(DecayConfig{Model: model, HalfLife: time.Hour, Gravity: 1.8})
+		if err != nil {
+			t.Errorf("NewDecayer failed for model=%s. Unexpected error: %s", model, err)
+		}
+		if decayer == nil {
+			t.Errorf("NewDecayer failed for model=%s. Got nil Decayer", model)
+		}
+	}
+
+	_, err := NewDecayer(DecayConfig{Model: "unknown"})
+	if err == nil {
+		t.Errorf("NewDecayer should fail for unknown model")
+	}
+	for _, halfLife := range []time.Duration{0, -time.Hour} {
+		_, err = NewDecayer(DecayConfig{Model: ExponentialDecay, HalfLife: halfLife})
+		if err != ErrInvalidHalfLife {
+			t.Errorf("NewDecayer wrong error for halfLife=%s. Expected=%s Actual=%v", halfLife, ErrInvalidHalfLife, err)
+		}
+	}
+	for _, gravity := range []float64{0, -1.8} {
+		_, err = NewDecayer(DecayConfig{Model: GravityDecay, Gravity: gravity})
+		if err != ErrInvalidGravity {
+			t.Errorf("NewDecayer wrong error for gravity=%f. Expected=%s Actual=%v", gravity, ErrInvalidGravity, err)
+		}
+	}
+}
+
+func TestNoDecayer(t *testing.T) {
+	decayer, _ := NewDecayer(DecayConfig{Model: NoDecay})
+	assertFloat(t, 4.0, decayer.Decay(4.0, 72*time.Hour), "noDecayer.Decay")
+}
+
+func TestExponentialDecayer(t *testing.T) {
+	decayer, _ := NewDecayer(DecayConfig{Model: ExponentialDecay, HalfLife: 24 * time.Hour})
+	assertFloat(t, 4.0, decayer.Decay(4.0, 0), "exponentialDecayer.Decay")
+	assertFloat(t, 2.0, decayer.Decay(4.0, 24*time.Hour), "exponentialDecayer.Decay")
+	assertFloat(t, 0.5, decayer.Decay(4.0, 72*time.Hour), "exponentialDecayer.Decay")
+	assertFloat(t, 4.0, decayer.Decay(4.0, -time.Hour), "exponentialDecayer.Decay")
+}
+
+func TestGravityDecayer(t *testing.T) {
+	decayer, _ := NewDecayer(DecayConfig{Model: GravityDecay, Gravity: 2.0})
+	assertFloat(t, 4.0, decayer.Decay(4.0, 0), "gravityDecayer.Decay")
+	assertFloat(t, 1.0, decayer.Decay(4.0, 2*time.Hour), "gravityDecayer.Decay")
+	assertFloat(t, 0.25, decayer.Decay(4.0, 6*time.Hour), "gravityDecayer.Decay")
+}
@@ -0,0 +1,209 @@
+package domain
+
+import (
+	"math"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Leader election policies.
+const (
+	HighestScoreLeader    = "highest_score"
+	FirstSeenLeader       = "first_seen"
+	PreferredSourceLeader = "preferred_source"
+	LongestBodyLeader     = "longest_body"
+)
+
+// Reasons for electing a leader that are not the criterion of a policy.
+const (
+	OnlyMemberReason         = "only_member"
+	OnlyEligibleMemberReason = "only_eligible_member"
+	lowestIDCriterion        = "lowest_article_id"
+)
+
+// Errors returned when creating leader policies.
+var (
+	ErrUnknownLeaderPolicy = errors.New("Unknown leader policy")
+	ErrNoPreferredSources  = errors.New("No preferred sources")
+)
+
+// LeaderConfig parameters needed to create a LeaderPolicy.
+// Without publishers every member is eligible to lead.
+type LeaderConfig struct {
+	Policy           string
+	PreferredSources []string
+	Publishers       Publishers
+}
+
+// LeaderPolicy elects the member leading a cluster.
+type LeaderPolicy interface {
+	// Elect returns the leading member and the reason it was chosen.
+	Elect(members []ClusterMember) (ClusterMember, string)
+}
+
+// NewLeaderPolicy creates a LeaderPolicy using the policy specified in the config.
+// Members of paywalled or blocked publishers only lead if no other member can. Every
+// policy breaks ties by score, then by the time the articles were first seen and lastly
+// by article id so that the same members always elect the same leader.
+func NewLeaderPolicy(conf LeaderConfig) (LeaderPolicy, error) {
+	publishers := conf.Publishers
+	if publishers == nil {
+		publishers = NewPublisherRegistry(nil)
+	}
+
+	switch conf.Policy {
+	case HighestScoreLeader:
+		return newCriteriaPolicy(publishers, highestScore, firstSeen), nil
+	case FirstSeenLeader:
+		return newCriteriaPolicy(publishers, firstSeen, highestScore), nil
+	case PreferredSourceLeader:
+		if len(conf.PreferredSources) == 0 {
+			return nil, ErrNoPreferredSources
+		}
+		preferred := preferredSource(conf.PreferredSources)
+		return newCriteriaPolicy(publishers, preferred, highestScore, firstSeen), nil
+	case LongestBodyLeader:
+		return newCriteriaPolicy(publishers, longestBody, highestScore, firstSeen), nil
+	default:
+		return nil, errors.Wrap(ErrUnknownLeaderPolicy, conf.Policy)
+	}
+}
+
+// criterion ranks members by a value where lower is better.
+type criterion struct {
+	name string
+	rank func(member ClusterMember) float64
+}
+
+// criteriaPolicy elects the best of the most eligible members by the first criterion, using
+// the following criteria in order to break ties and lastly the lowest article id, which is unique.
+type criteriaPolicy struct {
+	eligibility criterion
+	criteria    []criterion
+}
+
+func newCriteriaPolicy(publishers Publishers, criteria ...criterion) criteriaPolicy {
+	return criteriaPolicy{
+		eligibility: publisherEligibility(publishers),
+		criteria:    criteria,
+	}
+}
+
+func (p criteriaPolicy) Elect(members []ClusterMember) (ClusterMember, string) {
+	if len(members) == 0 {
+		return ClusterMember{}, ""
+	}
+	if len(members) == 1 {
+		return members[0], OnlyMemberReason
+	}
+
+	candidates := bestBy(p.eligibility, members)
+	if len(candidates) == 1 {
+		return candidates[0], OnlyEligibleMemberReason
+	}
+
+	primary := p.criteria[0]
+	for i, c := range p.criteria {
+		candidates = bestBy(c, candidates)
+		if len(candidates) == 1 {
+			return candidates[0], explainElection(primary, c, i)
+		}
+	}
+	return lowestIDMember(candidates), primary.name + ", tie broken by " + lowestIDCriterion
+}
+
+func bestBy(c criterion, members []ClusterMember) []ClusterMember {
+	best := make([]ClusterMember, 0, 1)
+	var bestRank float64
+	for _, member := range members {
+		rank := c.rank(member)
+		if len(best) == 0 || rank < bestRank {
+			best = append(best[:0], member)
+			bestRank = rank
+		} else if rank == bestRank {
+			best = append(best, member)
+		}
+	}
+	return best
+}
+
+func lowestIDMember(members []ClusterMember) ClusterMember {
+	lowest := members[0]
+	for _, member := range members[1:] {
+		if member.ArticleID < lowest.ArticleID {
+			lowest = member
+		}
+	}
+	return lowest
+}
+
+func explainElection(primary, decisive criterion, index int) string {
+	if index == 0 {
+		return primary.name
+	}
+	return primary.name + ", tie broken by " + decisive.name
+}
+
+// publisherEligibility ranks members of paywalled publishers after other members
+// and members of blocked publishers last.
+func publisherEligibility(publishers Publishers) criterion {
+	return criterion{
+		name: "publisher_eligibility",
+		rank: func(member ClusterMember) float64 {
+			publisher := publishers.Find(member.Source)
+			if publisher.Blocked {
+				return 2
+			}
+			if publisher.Paywalled {
+				return 1
+			}
+			return 0
+		},
+	}
+}
+
+var (
+	highestScore = criterion{
+		name: HighestScoreLeader,
+		rank: func(member ClusterMember) float64 {
+			return -member.Score()
+		},
+	}
+
+	// firstSeen ranks members by when their article was ingested, members without a known time last.
+	// Articles are only dated by day when published, so ingestion time stands in for publication time.
+	firstSeen = criterion{
+		name: FirstSeenLeader,
+		rank: func(member ClusterMember) float64 {
+			if member.FirstSeenAt.IsZero() {
+				return math.Inf(1)
+			}
+			return float64(member.FirstSeenAt.UnixNano())
+		},
+	}
+
+	longestBody = criterion{
+		name: LongestBodyLeader,
+		rank: func(member ClusterMember) float64 {
+			return -float64(member.BodyLength)
+		},
+	}
+)
+
+// preferredSource ranks members by the position of their source in the list of sources,
+// sources match their subdomains. Members from other sources are ranked last.
+func preferredSource(sources []string) criterion {
+	return criterion{
+		name: PreferredSourceLeader,
+		rank: func(member ClusterMember) float64 {
+			for i, source := range sources {
+				source = strings.ToLower(source)
+				if member.Source == source || strings.HasSuffix(member.Source, "."+source) {
+					return float64(i)
+				}
+			}
+			return float64(len(sources))
+		},
+	}
+}
@@ -0,0 +1,115 @@
+package domain
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewLeaderPolicy(t *testing.T) {
+	for _, policy := range []string{HighestScoreLeader, FirstSeenLeader, PreferredSourceLeader, LongestBodyLeader} {
+		leaderPolicy, err := NewLeaderPolicy(LeaderConfig{Policy: policy, PreferredSources: []string{"reuters.com"}})
+		if err != nil {
+			t.Errorf("NewLeaderPolicy failed for policy=%s. Unexpected error: %s", policy, err)
+		}
+		if leaderPolicy == nil {
+			t.Errorf("NewLeaderPolicy failed for policy=%s. Got nil LeaderPolicy", policy)
+		}
+	}
+
+	_, err := NewLeaderPolicy(LeaderConfig{Policy: "unknown"})
+	if err == nil {
+		t.Errorf("NewLeaderPolicy should fail for unknown policy")
+	}
+	_, err = NewLeaderPolicy(LeaderConfig{Policy: PreferredSourceLeader})
+	if err != ErrNoPreferredSources {
+		t.Errorf("NewLeaderPolicy wrong error without preferred sources. Expected=%s Actual=%v", ErrNoPreferredSources, err)
+	}
+}
+
+func TestLeaderPolicies(t *testing.T) {
+	now := time.Now()
+	members := []ClusterMember{
+		newTestLeaderMember("a-2", 2.0, now.Add(-time.Hour), "uk.reuters.com", 100),
+		newTestLeaderMember("a-1", 2.0, now, "bloomberg.com", 300),
+		newTestLeaderMember("a-3", 1.0, now.Add(-2*time.Hour), "ft.com", 300),
+		newTestLeaderMember("a-0", 0.5, now.Add(-2*time.Hour), "example.com", 50),
+	}
+
+	cases := []struct {
+		policy  string
+		sources []string
+		leader  string
+		reason  string
+	}{
+		{policy: HighestScoreLeader, leader: "a-2", reason: "highest_score, tie broken by first_seen"},
+		{policy: FirstSeenLeader, leader: "a-3", reason: "first_seen, tie broken by highest_score"},
+		{policy: PreferredSourceLeader, sources: []string{"bloomberg.com", "reuters.com"}, leader: "a-1", reason: "preferred_source"},
+		{policy: PreferredSourceLeader, sources: []string{"reuters.com", "bloomberg.com"}, leader: "a-2", reason: "preferred_source"},
+		{policy: PreferredSourceLeader, sources: []string{"wsj.com"}, leader: "a-2", reason: "preferred_source, tie broken by first_seen"},
+		{policy: LongestBodyLeader, leader: "a-1", reason: "longest_body, tie broken by highest_score"},
+	}
+	for _, c := range cases {
+		leaderPolicy, err := NewLeaderPolicy(LeaderConfig{Policy: c.policy, PreferredSources: c.sources})
+		if err != nil {
+			t.Fatalf("NewLeaderPolicy failed for policy=%s. Unexpected error: %s", c.policy, err)
+		}
+
+		leader, reason := leaderPolicy.Elect(members)
+		if leader.ArticleID != c.leader || reason != c.reason {
+			t.Errorf("%s policy with sources=%v elected wrong leader. Expected=%s (%s) Actual=%s (%s)",
+				c.policy, c.sources, c.leader, c.reason, leader.ArticleID, reason)
+		}
+	}
+}
+
+func TestLeaderPolicy_DeterministicTiebreak(t *testing.T) {
+	leaderPolicy := newTestLeaderPolicy(HighestScoreLeader)
+	members := []ClusterMember{
+		newTestLeaderMember("a-1", 1.0, time.Time{}, "", 0),
+		newTestLeaderMember("a-0", 1.0, time.Time{}, "", 0),
+		newTestLeaderMember("a-2", 1.0, time.Time{}, "", 0),
+	}
+
+	for i := 0; i < len(members); i++ {
+		rotated := append(append([]ClusterMember{}, members[i:]...), members[:i]...)
+		leader, reason := leaderPolicy.Elect(rotated)
+		if leader.ArticleID != "a-0" || reason != "highest_score, tie broken by lowest_article_id" {
+			t.Errorf("highest_score policy depends on member order. Expected=a-0 Actual=%s (%s)", leader.ArticleID, reason)
+		}
+	}
+
+	leader, reason := leaderPolicy.Elect(members[:1])
+	if leader.ArticleID != "a-1" || reason != OnlyMemberReason {
+		t.Errorf("highest_score policy wrong single member election. Expected=a-1 (%s) Actual=%s (%s)",
+			OnlyMemberReason, leader.ArticleID, reason)
+	}
+}
+
+func TestArticleSource(t *testing.T) {
+	cases := map[string]string{
+		"https://www.Reuters.com/article/x": "reuters.com",
+		"http://uk.reuters.com:443/a":       "uk.reuters.com",
+		"not a url\x7f":                     "",
+	}
+	for rawURL, expected := range cases {
+		if source := ArticleSource(rawURL); source != expected {
+			t.Errorf("ArticleSource(%q) wrong. Expected=%s Actual=%s", rawURL, expected, source)
+		}
+	}
+}
+
+func newTestLeaderMember(articleID string, score float64, firstSeenAt time.Time, source string, bodyLength int) ClusterMember {
+	member := NewClusterMember("hash", articleID, score, 0)
+	member.FirstSeenAt = firstSeenAt
+	member.Source = source
+	member.BodyLength = bodyLength
+	return *member
+}
+
+func newTestLeaderPolicy(policy string) LeaderPolicy {
+	leaderPolicy, err := NewLeaderPolicy(LeaderConfig{Policy: policy})
+	if err != nil {
+		panic(err)
+	}
+	return leaderPolicy
+}
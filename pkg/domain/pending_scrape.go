@@ -0,0 +1,75 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/mimir-news/pkg/schema/news"
+)
+
+// PendingScrape is an article sent for scraping that has not been scraped yet, together with
+// the subjects and referers of the rank objects that referred to it while waiting.
+// Pending scrapes are keyed by the canonical URL of the article, while the article
+// is scraped from the URL it was first requested at.
+type PendingScrape struct {
+	URL         string
+	ScrapeURL   string
+	ArticleID   string
+	Subjects    []news.Subject
+	Referers    []Referer
+	Attempts    int
+	RequestedAt time.Time
+	Version     int64
+}
+
+// NewPendingScrape creates a pending scrape of the article at the canonical URL for a scrape target
+// requested at the given time.
+func NewPendingScrape(URL string, target news.ScrapeTarget, requestedAt time.Time) PendingScrape {
+	return PendingScrape{
+		URL:         URL,
+		ScrapeURL:   target.URL,
+		ArticleID:   target.ArticleID,
+		Subjects:    mergeSubjects(nil, target.Subjects, target.ArticleID),
+		Referers:    mergeReferers(nil, NewReferer(target.Referer), target.ArticleID),
+		Attempts:    1,
+		RequestedAt: requestedAt,
+	}
+}
+
+// Merge adds the subjects and referers of a rank object not already known to the pending scrape.
+func (p *PendingScrape) Merge(rankObject news.RankObject) {
+	p.Subjects = mergeSubjects(p.Subjects, rankObject.Subjects, p.ArticleID)
+	p.Referers = mergeReferers(p.Referers, NewReferer(rankObject.Referer), p.ArticleID)
+}
+
+// MergeSubjects adds the subjects of the pending scrape for other symbols to the given subjects.
+func (p PendingScrape) MergeSubjects(subjects []news.Subject, articleID string) []news.Subject {
+	return mergeSubjects(subjects, p.Subjects, articleID)
+}
+
+// MergeReferers adds the referers of the pending scrape by other authors after the given referers.
+func (p PendingScrape) MergeReferers(referers []Referer, articleID string) []Referer {
+	merged := referers
+	for _, referer := range p.Referers {
+		merged = mergeReferers(merged, referer, articleID)
+	}
+	return merged
+}
+
+// IsExpired checks if the scrape was requested longer than the timeout ago.
+func (p PendingScrape) IsExpired(now time.Time, timeout time.Duration) bool {
+	return now.Sub(p.RequestedAt) > timeout
+}
+
+// ScrapeTarget creates a scrape target for the pending scrape with all known subjects,
+// referred by the referer that first requested the scrape.
+func (p PendingScrape) ScrapeTarget() news.ScrapeTarget {
+	target := news.ScrapeTarget{
+		URL:       p.ScrapeURL,
+		Subjects:  p.Subjects,
+		ArticleID: p.ArticleID,
+	}
+	if len(p.Referers) > 0 {
+		target.Referer = p.Referers[0].Referer
+	}
+	return target
+}
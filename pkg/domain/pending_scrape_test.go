@@ -0,0 +1,64 @@
+package domain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mimir-news/pkg/schema/news"
+)
+
+func TestPendingScrapeMerge(t *testing.T) {
+	now := time.Now()
+	pending := NewPendingScrape("https://url.com", news.ScrapeTarget{
+		URL:       "http://url.com?utm_source=twitter",
+		ArticleID: "a-0",
+		Subjects:  []news.Subject{{ID: "s-0", Symbol: "S0"}},
+		Referer:   news.Referer{ID: "r-0", ExternalID: "author-0", FollowerCount: 100},
+	}, now)
+
+	pending.Merge(news.RankObject{
+		URLs:     []string{"http://url.com"},
+		Subjects: []news.Subject{{Symbol: "S0"}, {Symbol: "S1"}},
+		Referer:  news.Referer{ExternalID: "author-1", FollowerCount: 200},
+	})
+	pending.Merge(news.RankObject{
+		URLs:    []string{"http://url.com"},
+		Referer: news.Referer{ExternalID: "author-0", FollowerCount: 300},
+	})
+
+	if len(pending.Subjects) != 2 {
+		t.Fatalf("Wrong number of subjects. Expected=2 Got=%d", len(pending.Subjects))
+	}
+	if pending.Subjects[1].Symbol != "S1" || pending.Subjects[1].ArticleID != "a-0" || pending.Subjects[1].ID == "" {
+		t.Errorf("Merged subject wrong. Got=%+v", pending.Subjects[1])
+	}
+	if len(pending.Referers) != 2 {
+		t.Fatalf("Wrong number of referers. Expected=2 Got=%d", len(pending.Referers))
+	}
+	if pending.Referers[1].ExternalID != "author-1" || pending.Referers[1].ArticleID != "a-0" || pending.Referers[1].ID == "" {
+		t.Errorf("Merged referer wrong. Got=%+v", pending.Referers[1])
+	}
+
+	target := pending.ScrapeTarget()
+	if pending.URL != "https://url.com" || target.URL != "http://url.com?utm_source=twitter" {
+		t.Errorf("Scrape target URL wrong. Expected=http://url.com?utm_source=twitter Got=%s", target.URL)
+	}
+	if target.Referer.ID != "r-0" {
+		t.Errorf("Scrape target referer wrong. Expected=r-0 Got=%s", target.Referer.ID)
+	}
+	if len(target.Subjects) != 2 {
+		t.Errorf("Wrong number of scrape target subjects. Expected=2 Got=%d", len(target.Subjects))
+	}
+
+	referers := pending.MergeReferers([]Referer{NewReferer(news.Referer{ID: "r-0", ExternalID: "author-0"})}, "a-1")
+	if len(referers) != 2 || referers[1].ExternalID != "author-1" || referers[1].ArticleID != "a-1" {
+		t.Errorf("MergeReferers wrong. Got=%+v", referers)
+	}
+
+	if pending.IsExpired(now.Add(time.Minute), time.Hour) {
+		t.Error("Pending scrape should not be expired")
+	}
+	if !pending.IsExpired(now.Add(2*time.Hour), time.Hour) {
+		t.Error("Pending scrape should be expired")
+	}
+}
@@ -0,0 +1,101 @@
+package domain
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultAuthority is the authority weight of publishers that are not registered.
+const DefaultAuthority = 1.0
+
+// ErrInvalidPublisher is returned when creating a publisher without a host or with a negative weight.
+var ErrInvalidPublisher = errors.New("invalid publisher")
+
+// Publisher is a registered source of articles. The scores of its articles are multiplied
+// by its authority weight, articles of blocked publishers are scored zero and paywalled
+// or blocked publishers only lead clusters without other leaders.
+type Publisher struct {
+	Host      string
+	Weight    float64
+	Paywalled bool
+	Blocked   bool
+}
+
+// NewPublisher creates a new publisher for the canonical form of a host.
+func NewPublisher(host string, weight float64, paywalled, blocked bool) (Publisher, error) {
+	host = canonicalHost(host)
+	if host == "" || strings.ContainsAny(host, "/:") {
+		return Publisher{}, errors.Wrap(ErrInvalidPublisher, "host must be a domain name")
+	}
+	if weight < 0 {
+		return Publisher{}, errors.Wrap(ErrInvalidPublisher, "weight must not be negative")
+	}
+
+	return Publisher{
+		Host:      host,
+		Weight:    weight,
+		Paywalled: paywalled,
+		Blocked:   blocked,
+	}, nil
+}
+
+// Authority returns the multiplier of the scores of articles from the publisher.
+func (p Publisher) Authority() float64 {
+	if p.Blocked {
+		return 0
+	}
+	return p.Weight
+}
+
+// Publishers finds the publisher of an article source.
+type Publishers interface {
+	Find(source string) Publisher
+}
+
+// PublisherRegistry finds publishers by host, a publisher is also the publisher of its subdomains.
+type PublisherRegistry struct {
+	publishers map[string]Publisher
+}
+
+// NewPublisherRegistry creates a registry of the publishers.
+func NewPublisherRegistry(publishers []Publisher) PublisherRegistry {
+	registry := PublisherRegistry{
+		publishers: make(map[string]Publisher, len(publishers)),
+	}
+	for _, publisher := range publishers {
+		registry.publishers[publisher.Host] = publisher
+	}
+	return registry
+}
+
+// Find returns the publisher of the source or of its closest parent domain,
+// sources without a registered publisher have the default authority.
+func (r PublisherRegistry) Find(source string) Publisher {
+	for _, domain := range parentDomains(source) {
+		publisher, ok := r.publishers[domain]
+		if ok {
+			return publisher
+		}
+	}
+	return Publisher{Host: source, Weight: DefaultAuthority}
+}
+
+// Len returns the number of registered publishers.
+func (r PublisherRegistry) Len() int {
+	return len(r.publishers)
+}
+
+// parentDomains returns the host followed by its parent domains, closest first.
+func parentDomains(host string) []string {
+	domains := make([]string, 0, strings.Count(host, ".")+1)
+	for domain := host; domain != ""; {
+		domains = append(domains, domain)
+		dot := strings.Index(domain, ".")
+		if dot == -1 {
+			break
+		}
+		domain = domain[dot+1:]
+	}
+	return domains
+}
@@ -0,0 +1,93 @@
+package domain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestNewPublisher(t *testing.T) {
+	publisher, err := NewPublisher("WWW.Reuters.com", 1.5, true, false)
+	if err != nil {
+		t.Fatalf("NewPublisher failed. Unexpected error: %s", err)
+	}
+	expected := Publisher{Host: "reuters.com", Weight: 1.5, Paywalled: true}
+	if publisher != expected {
+		t.Errorf("NewPublisher wrong publisher. Expected=%+v Actual=%+v", expected, publisher)
+	}
+
+	for _, host := range []string{"", "https://reuters.com/news"} {
+		_, err := NewPublisher(host, 1.0, false, false)
+		if errors.Cause(err) != ErrInvalidPublisher {
+			t.Errorf("NewPublisher(%q) wrong error. Expected=%s Actual=%v", host, ErrInvalidPublisher, err)
+		}
+	}
+	_, err = NewPublisher("reuters.com", -1.0, false, false)
+	if errors.Cause(err) != ErrInvalidPublisher {
+		t.Errorf("NewPublisher with negative weight wrong error. Expected=%s Actual=%v", ErrInvalidPublisher, err)
+	}
+}
+
+func TestPublisherRegistry(t *testing.T) {
+	registry := NewPublisherRegistry([]Publisher{
+		{Host: "reuters.com", Weight: 1.5},
+		{Host: "blogs.reuters.com", Weight: 0.5},
+		{Host: "spam.com", Weight: 2.0, Blocked: true},
+	})
+	if registry.Len() != 3 {
+		t.Errorf("PublisherRegistry.Len wrong. Expected=3 Actual=%d", registry.Len())
+	}
+
+	cases := map[string]float64{
+		"reuters.com":          1.5,
+		"uk.reuters.com":       1.5,
+		"blogs.reuters.com":    0.5,
+		"my.blogs.reuters.com": 0.5,
+		"notreuters.com":       DefaultAuthority,
+		"spam.com":             0,
+		"":                     DefaultAuthority,
+	}
+	for source, expected := range cases {
+		if authority := registry.Find(source).Authority(); authority != expected {
+			t.Errorf("PublisherRegistry.Find(%q) wrong authority. Expected=%f Actual=%f", source, expected, authority)
+		}
+	}
+}
+
+func TestLeaderPolicy_PublisherEligibility(t *testing.T) {
+	publishers := NewPublisherRegistry([]Publisher{
+		{Host: "ft.com", Weight: 1.0, Paywalled: true},
+		{Host: "spam.com", Weight: 1.0, Blocked: true},
+	})
+	leaderPolicy, err := NewLeaderPolicy(LeaderConfig{Policy: HighestScoreLeader, Publishers: publishers})
+	if err != nil {
+		t.Fatalf("NewLeaderPolicy failed. Unexpected error: %s", err)
+	}
+
+	now := time.Now()
+	members := []ClusterMember{
+		newTestLeaderMember("a-0", 3.0, now, "spam.com", 100),
+		newTestLeaderMember("a-1", 2.0, now, "ft.com", 100),
+		newTestLeaderMember("a-2", 1.0, now, "reuters.com", 100),
+		newTestLeaderMember("a-3", 0.5, now, "bloomberg.com", 100),
+	}
+
+	cases := []struct {
+		members []ClusterMember
+		leader  string
+		reason  string
+	}{
+		{members: members, leader: "a-2", reason: "highest_score"},
+		{members: members[:3], leader: "a-2", reason: OnlyEligibleMemberReason},
+		{members: members[:2], leader: "a-1", reason: OnlyEligibleMemberReason},
+		{members: members[:1], leader: "a-0", reason: OnlyMemberReason},
+	}
+	for i, c := range cases {
+		leader, reason := leaderPolicy.Elect(c.members)
+		if leader.ArticleID != c.leader || reason != c.reason {
+			t.Errorf("%d - Elect with publishers wrong leader. Expected=%s (%s) Actual=%s (%s)",
+				i, c.leader, c.reason, leader.ArticleID, reason)
+		}
+	}
+}
@@ -0,0 +1,271 @@
+package domain
+
+import (
+	"time"
+)
+
+// Referer quality signals, raised by referers that look like spam or bots.
+const (
+	BurstSignal        = "burst"
+	SpreadSignal       = "spread"
+	FollowerJumpSignal = "follower_jump"
+)
+
+// DefaultRefererQuality is the quality of authors that have not raised any signals.
+const DefaultRefererQuality = 1.0
+
+// RefererQualityConfig configures when referers raise signals and how signals change the
+// quality of their authors. A zero window, size, number of articles or ratio disables the signal.
+//
+// An article bursts when it is referred to by BurstSize different authors within the BurstWindow.
+// A burst is raised by a referer to a bursting article whose author has been in bursts on
+// BurstGroupArticles different articles together with the same other author within the
+// BurstGroupWindow, so that a group of authors that keeps referring to the same articles at once
+// is flagged while the many authors of a single viral article are not. A spread is raised by an
+// author referring to more than SpreadArticles different articles within the SpreadWindow.
+// A follower jump is raised when the follower count of an author has grown FollowerJumpRatio
+// times since its previous referer.
+type RefererQualityConfig struct {
+	BurstWindow        time.Duration
+	BurstSize          int
+	BurstGroupWindow   time.Duration
+	BurstGroupArticles int
+	SpreadWindow       time.Duration
+	SpreadArticles     int
+	FollowerJumpRatio  float64
+	Penalty            float64
+	Recovery           float64
+}
+
+func (c RefererQualityConfig) detectsBursts() bool {
+	return c.BurstWindow > 0 && c.BurstSize > 0 && c.BurstGroupWindow > 0 && c.BurstGroupArticles > 0
+}
+
+// RefererQualities finds the quality of referer authors, by which their contributions to
+// reference scores are discounted.
+type RefererQualities interface {
+	Quality(author string) float64
+}
+
+// AuthorQuality is the quality of a referer author, between 0 and 1, together with the
+// follower count of its latest referer used to detect follower jumps.
+type AuthorQuality struct {
+	Author        string
+	Quality       float64
+	FollowerCount int64
+	Signals       int
+	LastSignal    string
+	UpdatedAt     time.Time
+}
+
+// NewAuthorQuality creates the quality of an author without any referers.
+func NewAuthorQuality(author string) AuthorQuality {
+	return AuthorQuality{
+		Author:  author,
+		Quality: DefaultRefererQuality,
+	}
+}
+
+// Observe returns the quality of the author after a referer with the follower count that raised
+// the signals. Each signal multiplies the quality by the penalty, a referer without signals
+// recovers the quality towards the default by the recovery share of the difference.
+func (q AuthorQuality) Observe(followerCount int64, signals []string, conf RefererQualityConfig, at time.Time) AuthorQuality {
+	next := q
+	next.FollowerCount = followerCount
+	next.UpdatedAt = at
+	for _, signal := range signals {
+		next.Quality *= conf.Penalty
+		next.Signals++
+		next.LastSignal = signal
+	}
+	if len(signals) == 0 {
+		next.Quality += (DefaultRefererQuality - next.Quality) * conf.Recovery
+	}
+	return next
+}
+
+// Flagged returns true if the author is discounted in scoring.
+func (q AuthorQuality) Flagged() bool {
+	return q.Quality < DefaultRefererQuality
+}
+
+// RefererActivity keeps the recent referers needed to detect bursts and spreads. Activity older
+// than the windows is pruned as time passes. RefererActivity is not safe for concurrent use.
+type RefererActivity struct {
+	conf     RefererQualityConfig
+	articles map[string][]recentReferer
+	// bursts holds the bursting articles each author referred to.
+	bursts    map[string]map[string]time.Time
+	authors   map[string]map[string]time.Time
+	lastPrune time.Time
+}
+
+// recentReferer is an author that referred to an article at a time.
+type recentReferer struct {
+	author string
+	at     time.Time
+}
+
+// NewRefererActivity creates an empty RefererActivity.
+func NewRefererActivity(conf RefererQualityConfig) *RefererActivity {
+	return &RefererActivity{
+		conf:     conf,
+		articles: make(map[string][]recentReferer),
+		bursts:   make(map[string]map[string]time.Time),
+		authors:  make(map[string]map[string]time.Time),
+	}
+}
+
+// Detect records that the author referred to the article at the time and returns the signals
+// raised by the referer. Follower jumps are detected against the previous quality of the author.
+func (a *RefererActivity) Detect(author, article string, followerCount int64, previous AuthorQuality, at time.Time) []string {
+	a.prune(at)
+	signals := make([]string, 0)
+	if a.detectBurst(author, article, at) {
+		signals = append(signals, BurstSignal)
+	}
+	if a.detectSpread(author, article, at) {
+		signals = append(signals, SpreadSignal)
+	}
+	if a.detectFollowerJump(followerCount, previous) {
+		signals = append(signals, FollowerJumpSignal)
+	}
+	return signals
+}
+
+// detectBurst records the referer and, if the article is bursting, that the author and the other
+// recent authors of the article were in the burst. Returns true if the author has been in bursts
+// on enough articles together with one of the other authors to be part of a group.
+func (a *RefererActivity) detectBurst(author, article string, at time.Time) bool {
+	if !a.conf.detectsBursts() {
+		return false
+	}
+
+	recent := referersWithinWindow(a.articles[article], at, a.conf.BurstWindow)
+	recent = append(recent, recentReferer{author: author, at: at})
+	a.articles[article] = recent
+
+	others := make(map[string]bool)
+	for _, referer := range recent {
+		if referer.author != author {
+			others[referer.author] = true
+		}
+	}
+	if len(others)+1 < a.conf.BurstSize {
+		return false
+	}
+
+	a.addBurst(author, article, at)
+	group := false
+	for other := range others {
+		a.addBurst(other, article, at)
+		if sharedArticles(a.bursts[author], a.bursts[other]) >= a.conf.BurstGroupArticles {
+			group = true
+		}
+	}
+	return group
+}
+
+// addBurst records that the author was in a burst on the article, bursts outside the group window are dropped.
+func (a *RefererActivity) addBurst(author, article string, at time.Time) {
+	articles, ok := a.bursts[author]
+	if !ok {
+		articles = make(map[string]time.Time)
+		a.bursts[author] = articles
+	}
+	if _, ok := articles[article]; !ok {
+		articles[article] = at
+	}
+	pruneArticles(articles, at, a.conf.BurstGroupWindow)
+}
+
+// sharedArticles returns the number of articles in both sets.
+func sharedArticles(first, second map[string]time.Time) int {
+	if len(second) < len(first) {
+		first, second = second, first
+	}
+	shared := 0
+	for article := range first {
+		if _, ok := second[article]; ok {
+			shared++
+		}
+	}
+	return shared
+}
+
+func (a *RefererActivity) detectSpread(author, article string, at time.Time) bool {
+	if a.conf.SpreadWindow <= 0 || a.conf.SpreadArticles <= 0 {
+		return false
+	}
+
+	articles, ok := a.authors[author]
+	if !ok {
+		articles = make(map[string]time.Time)
+		a.authors[author] = articles
+	}
+	articles[article] = at
+	pruneArticles(articles, at, a.conf.SpreadWindow)
+	return len(articles) > a.conf.SpreadArticles
+}
+
+func (a *RefererActivity) detectFollowerJump(followerCount int64, previous AuthorQuality) bool {
+	if a.conf.FollowerJumpRatio <= 0 || previous.FollowerCount <= 0 {
+		return false
+	}
+	return float64(followerCount) >= float64(previous.FollowerCount)*a.conf.FollowerJumpRatio
+}
+
+// prune drops activity outside the windows once per the longest window.
+func (a *RefererActivity) prune(now time.Time) {
+	window := a.conf.BurstWindow
+	if a.conf.BurstGroupWindow > window {
+		window = a.conf.BurstGroupWindow
+	}
+	if a.conf.SpreadWindow > window {
+		window = a.conf.SpreadWindow
+	}
+	if now.Sub(a.lastPrune) < window {
+		return
+	}
+	a.lastPrune = now
+
+	for article, referers := range a.articles {
+		recent := referersWithinWindow(referers, now, a.conf.BurstWindow)
+		if len(recent) == 0 {
+			delete(a.articles, article)
+		} else {
+			a.articles[article] = recent
+		}
+	}
+	for author, articles := range a.bursts {
+		pruneArticles(articles, now, a.conf.BurstGroupWindow)
+		if len(articles) == 0 {
+			delete(a.bursts, author)
+		}
+	}
+	for author, articles := range a.authors {
+		pruneArticles(articles, now, a.conf.SpreadWindow)
+		if len(articles) == 0 {
+			delete(a.authors, author)
+		}
+	}
+}
+
+// pruneArticles deletes the articles referred to before the window.
+func pruneArticles(articles map[string]time.Time, now time.Time, window time.Duration) {
+	for article, referredAt := range articles {
+		if now.Sub(referredAt) > window {
+			delete(articles, article)
+		}
+	}
+}
+
+// referersWithinWindow returns the referers, in the order they were recorded, that are within the window before now.
+func referersWithinWindow(referers []recentReferer, now time.Time, window time.Duration) []recentReferer {
+	for i, referer := range referers {
+		if now.Sub(referer.at) <= window {
+			return referers[i:]
+		}
+	}
+	return referers[:0]
+}
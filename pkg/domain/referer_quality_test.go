@@ -0,0 +1,124 @@
+package domain
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRefererActivity_Burst(t *testing.T) {
+	activity := NewRefererActivity(RefererQualityConfig{
+		BurstWindow:        10 * time.Second,
+		BurstSize:          3,
+		BurstGroupWindow:   time.Hour,
+		BurstGroupArticles: 2,
+	})
+	now := time.Now()
+	detect := func(author, article string, at time.Duration) []string {
+		return activity.Detect(author, article, 100, AuthorQuality{}, now.Add(at))
+	}
+
+	// A single viral article does not flag its authors however many there are.
+	assertSignals(t, nil, detect("a", "article-0", 0), "burst below size")
+	assertSignals(t, nil, detect("b", "article-0", time.Second), "burst below size")
+	assertSignals(t, nil, detect("b", "article-0", 2*time.Second), "burst by repeated author")
+	assertSignals(t, nil, detect("c", "article-0", 3*time.Second), "burst on one article")
+	assertSignals(t, nil, detect("d", "article-0", 4*time.Second), "burst on one article")
+
+	// Authors bursting together on a second article are a group.
+	assertSignals(t, nil, detect("a", "article-1", time.Minute), "group burst below size")
+	assertSignals(t, nil, detect("e", "article-1", time.Minute), "group burst below size")
+	assertSignals(t, []string{BurstSignal}, detect("b", "article-1", time.Minute), "group burst")
+	assertSignals(t, []string{BurstSignal}, detect("c", "article-1", time.Minute), "group burst")
+	assertSignals(t, nil, detect("f", "article-1", time.Minute), "burst by author outside the group")
+	assertSignals(t, nil, detect("g", "article-2", time.Minute), "burst on other article")
+
+	// Bursts outside the group window are forgotten.
+	assertSignals(t, nil, detect("a", "article-3", 2*time.Hour), "group burst after window")
+	assertSignals(t, nil, detect("b", "article-3", 2*time.Hour), "group burst after window")
+	assertSignals(t, nil, detect("c", "article-3", 2*time.Hour), "group burst after window")
+	if len(activity.bursts) != 3 {
+		t.Errorf("RefererActivity bursts not pruned. Expected 3 authors Actual=%d", len(activity.bursts))
+	}
+}
+
+func TestRefererActivity_Spread(t *testing.T) {
+	activity := NewRefererActivity(RefererQualityConfig{SpreadWindow: time.Hour, SpreadArticles: 2})
+	now := time.Now()
+
+	assertSignals(t, nil, activity.Detect("a", "article-0", 100, AuthorQuality{}, now), "spread")
+	assertSignals(t, nil, activity.Detect("a", "article-1", 100, AuthorQuality{}, now), "spread")
+	assertSignals(t, nil, activity.Detect("a", "article-1", 100, AuthorQuality{}, now), "spread on same article")
+	assertSignals(t, nil, activity.Detect("b", "article-2", 100, AuthorQuality{}, now), "spread of other author")
+	assertSignals(t, []string{SpreadSignal}, activity.Detect("a", "article-2", 100, AuthorQuality{}, now), "spread")
+	assertSignals(t, nil, activity.Detect("a", "article-3", 100, AuthorQuality{}, now.Add(2*time.Hour)), "spread after window")
+
+	if len(activity.authors) != 1 || len(activity.articles) != 0 {
+		t.Errorf("RefererActivity not pruned. Expected 1 author and 0 articles Actual=%d and %d",
+			len(activity.authors), len(activity.articles))
+	}
+}
+
+func TestRefererActivity_FollowerJump(t *testing.T) {
+	activity := NewRefererActivity(RefererQualityConfig{FollowerJumpRatio: 10})
+	now := time.Now()
+	previous := AuthorQuality{Author: "a", Quality: 1.0, FollowerCount: 100}
+
+	assertSignals(t, nil, activity.Detect("a", "article-0", 999, previous, now), "follower jump below ratio")
+	assertSignals(t, []string{FollowerJumpSignal}, activity.Detect("a", "article-0", 1000, previous, now), "follower jump")
+	assertSignals(t, nil, activity.Detect("a", "article-0", 1000000, NewAuthorQuality("a"), now), "follower jump of new author")
+}
+
+func TestAuthorQuality_Observe(t *testing.T) {
+	conf := RefererQualityConfig{Penalty: 0.5, Recovery: 0.5}
+	now := time.Now()
+
+	quality := NewAuthorQuality("a").Observe(100, []string{BurstSignal, SpreadSignal}, conf, now)
+	assertFloat(t, 0.25, quality.Quality, "AuthorQuality.Observe with signals")
+	if !quality.Flagged() || quality.Signals != 2 || quality.LastSignal != SpreadSignal || quality.FollowerCount != 100 {
+		t.Errorf("AuthorQuality.Observe wrong quality: %+v", quality)
+	}
+
+	quality = quality.Observe(100, nil, conf, now)
+	assertFloat(t, 0.625, quality.Quality, "AuthorQuality.Observe without signals")
+	if quality.Signals != 2 || !quality.UpdatedAt.Equal(now) {
+		t.Errorf("AuthorQuality.Observe wrong quality: %+v", quality)
+	}
+}
+
+func TestScorer_RefererQualities(t *testing.T) {
+	qualities := testRefererQualities{"a": 0.5, "c": 0}
+	for _, strategy := range []string{LinearScoring, DiminishingScoring, CappedScoring} {
+		scorer, _ := NewScorer(ScorerConfig{
+			Strategy:          strategy,
+			TwitterUsers:      1000,
+			ReferenceWeight:   1.0,
+			DiminishingFactor: 1.0,
+			AuthorCap:         10.0,
+			Qualities:         qualities,
+		})
+		score := scorer.Score(testReferers(2000, 1000, 4000)...)
+		assertFloat(t, 1.0+1.0, score, strategy+" scorer with referer qualities")
+	}
+}
+
+type testRefererQualities map[string]float64
+
+func (q testRefererQualities) Quality(author string) float64 {
+	quality, ok := q[author]
+	if !ok {
+		return DefaultRefererQuality
+	}
+	return quality
+}
+
+func assertSignals(t *testing.T, expected, actual []string, name string) {
+	if len(expected) != len(actual) {
+		t.Errorf("%s wrong signals. Expected=%v Actual=%v", name, expected, actual)
+		return
+	}
+	for i := range expected {
+		if expected[i] != actual[i] {
+			t.Errorf("%s wrong signals. Expected=%v Actual=%v", name, expected, actual)
+		}
+	}
+}
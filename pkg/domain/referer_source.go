@@ -0,0 +1,79 @@
+package domain
+
+import (
+	"strings"
+	"time"
+
+	"github.com/mimir-news/pkg/schema/news"
+)
+
+// Referer sources.
+const (
+	TwitterSource    = "twitter"
+	RedditSource     = "reddit"
+	StockTwitsSource = "stocktwits"
+	RSSSource        = "rss"
+	InternalSource   = "internal"
+)
+
+// RefererSources all known referer sources.
+var RefererSources = []string{
+	TwitterSource,
+	RedditSource,
+	StockTwitsSource,
+	RSSSource,
+	InternalSource,
+}
+
+const sourceSeparator = ":"
+
+// SourceNormalization describes how follower counts from a referer source are scored.
+// Follower counts are taken as a share of the sources users and scaled by the weight.
+type SourceNormalization struct {
+	Users  float64
+	Weight float64
+}
+
+// Referer is a referer of an article together with the source it was observed on.
+// The contribution of a referer repeating an author of a cluster is discounted by
+// the repeat discount, which is never stored. Referers read back from a repository
+// carry the time they were stored as the time they were observed.
+type Referer struct {
+	news.Referer
+	Source         string    `json:"source"`
+	RepeatDiscount float64   `json:"-"`
+	CreatedAt      time.Time `json:"-"`
+}
+
+// weight returns the share of its scored contribution the referer counts with.
+func (r Referer) weight() float64 {
+	return 1 - r.RepeatDiscount
+}
+
+// NewReferer creates a referer received in a message from another service. Messages carry
+// the source of non twitter referers as a prefix of the external id, e.g. "reddit:some-user",
+// any other external id is considered a twitter author. Stored referers keep the source
+// they were stored with.
+func NewReferer(referer news.Referer) Referer {
+	return Referer{
+		Referer: referer,
+		Source:  sourceOfExternalID(referer.ExternalID),
+	}
+}
+
+func sourceOfExternalID(externalID string) string {
+	parts := strings.SplitN(externalID, sourceSeparator, 2)
+	if len(parts) == 2 && validRefererSource(parts[0]) {
+		return parts[0]
+	}
+	return TwitterSource
+}
+
+func validRefererSource(source string) bool {
+	for _, s := range RefererSources {
+		if s == source {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,27 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/mimir-news/pkg/schema/news"
+)
+
+func TestNewReferer(t *testing.T) {
+	expected := map[string]string{
+		"some-author":            TwitterSource,
+		"reddit:some-user":       RedditSource,
+		"stocktwits:some-user":   StockTwitsSource,
+		"rss:feed.example.com":   RSSSource,
+		"internal:user-id":       InternalSource,
+		"unknown:some-user":      TwitterSource,
+		"reddit":                 TwitterSource,
+		"reddit:user:with:colon": RedditSource,
+	}
+
+	for externalID, source := range expected {
+		actual := NewReferer(news.Referer{ExternalID: externalID})
+		if actual.Source != source {
+			t.Errorf("NewReferer(%s) wrong source. Expected=%s Got=%s", externalID, source, actual.Source)
+		}
+	}
+}
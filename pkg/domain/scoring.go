@@ -0,0 +1,170 @@
+package domain
+
+import (
+	"math"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// Scoring strategies.
+const (
+	LinearScoring      = "linear"
+	LogScoring         = "log"
+	DiminishingScoring = "diminishing"
+	CappedScoring      = "capped"
+)
+
+// Common scoring errors.
+var (
+	ErrUnknownScoringStrategy = errors.New("Unknown scoring strategy")
+	ErrInvalidUsers           = errors.New("Users must be positive")
+)
+
+// Scorer calculates the reference score of an article based on its referers.
+type Scorer interface {
+	Score(referers ...Referer) float64
+}
+
+// ScorerConfig parameters needed to create a Scorer.
+type ScorerConfig struct {
+	Strategy          string
+	TwitterUsers      float64
+	ReferenceWeight   float64
+	DiminishingFactor float64
+	AuthorCap         float64
+	Sources           map[string]SourceNormalization
+	Qualities         RefererQualities
+}
+
+// NewScorer creates a Scorer using the strategy specified in the config.
+// The users of twitter and of every source must be positive, ErrInvalidUsers is
+// returned wrapped with the source otherwise.
+func NewScorer(conf ScorerConfig) (Scorer, error) {
+	linear := NewLinearScorer(conf.TwitterUsers, conf.ReferenceWeight)
+	linear.Sources = conf.Sources
+	linear.Qualities = conf.Qualities
+
+	var scorer Scorer
+	switch conf.Strategy {
+	case LinearScoring:
+		scorer = linear
+	case LogScoring:
+		scorer = &logScorer{linear: linear}
+	case DiminishingScoring:
+		scorer = &diminishingScorer{linear: linear, factor: conf.DiminishingFactor}
+	case CappedScoring:
+		scorer = &cappedScorer{linear: linear, authorCap: conf.AuthorCap}
+	default:
+		return nil, errors.Wrap(ErrUnknownScoringStrategy, conf.Strategy)
+	}
+
+	if conf.TwitterUsers <= 0 {
+		return nil, errors.Wrap(ErrInvalidUsers, TwitterSource)
+	}
+	for source, norm := range conf.Sources {
+		if norm.Users <= 0 {
+			return nil, errors.Wrap(ErrInvalidUsers, source)
+		}
+	}
+	return scorer, nil
+}
+
+// LinearScorer scores referers as the share of users reached in their source times a weight,
+// discounted by the quality of their authors if qualities are given.
+// Referers from sources without a normalization are scored as twitter referers.
+type LinearScorer struct {
+	TwitterUsers    float64
+	ReferenceWeight float64
+	Sources         map[string]SourceNormalization
+	Qualities       RefererQualities
+}
+
+// NewLinearScorer creates a new LinearScorer.
+func NewLinearScorer(twitterUsers, referenceWeight float64) *LinearScorer {
+	return &LinearScorer{
+		TwitterUsers:    twitterUsers,
+		ReferenceWeight: referenceWeight,
+	}
+}
+
+// Score sums the follower counts of the referers scaled by the reach in their source.
+func (s *LinearScorer) Score(referers ...Referer) float64 {
+	var score float64
+	for _, referer := range referers {
+		score += s.scoreReferer(referer) * referer.weight()
+	}
+	return score
+}
+
+func (s *LinearScorer) scoreReferer(referer Referer) float64 {
+	norm, ok := s.Sources[referer.Source]
+	if !ok {
+		norm = SourceNormalization{Users: s.TwitterUsers, Weight: s.ReferenceWeight}
+	}
+	return float64(referer.FollowerCount) * norm.Weight / norm.Users * s.quality(referer)
+}
+
+func (s *LinearScorer) quality(referer Referer) float64 {
+	if s.Qualities == nil {
+		return DefaultRefererQuality
+	}
+	return s.Qualities.Quality(referer.ExternalID)
+}
+
+// logScorer dampens the contribution of referers with large follower counts.
+type logScorer struct {
+	linear *LinearScorer
+}
+
+func (s *logScorer) Score(referers ...Referer) float64 {
+	var score float64
+	for _, referer := range referers {
+		score += math.Log1p(s.linear.scoreReferer(referer)) * referer.weight()
+	}
+	return score
+}
+
+// diminishingScorer lets each additional referer contribute less than the previous,
+// starting with the most influential referer.
+type diminishingScorer struct {
+	linear *LinearScorer
+	factor float64
+}
+
+func (s *diminishingScorer) Score(referers ...Referer) float64 {
+	contributions := make([]float64, 0, len(referers))
+	for _, referer := range referers {
+		contributions = append(contributions, s.linear.scoreReferer(referer)*referer.weight())
+	}
+	sort.Sort(sort.Reverse(sort.Float64Slice(contributions)))
+
+	var score float64
+	weight := 1.0
+	for _, contribution := range contributions {
+		score += contribution * weight
+		weight *= s.factor
+	}
+	return score
+}
+
+// cappedScorer limits how much a single author can contribute to a score.
+type cappedScorer struct {
+	linear    *LinearScorer
+	authorCap float64
+}
+
+func (s *cappedScorer) Score(referers ...Referer) float64 {
+	authorReferers := make(map[string]Referer)
+	for _, referer := range referers {
+		if referer.FollowerCount > authorReferers[referer.ExternalID].FollowerCount {
+			authorReferers[referer.ExternalID] = referer
+		}
+	}
+
+	var score float64
+	for _, referer := range authorReferers {
+		score += math.Min(s.linear.scoreReferer(referer), s.authorCap) * referer.weight()
+	}
+	return score
+}
@@ -0,0 +1,116 @@
+package domain
+
+import (
+	"math"
+	"testing"
+
+	"github.com/mimir-news/pkg/schema/news"
+	"github.com/pkg/errors"
+)
+
+func TestNewScorer(t *testing.T) {
+	for _, strategy := range []string{LinearScoring, LogScoring, DiminishingScoring, CappedScoring} {
+		scorer, err := NewScorer(ScorerConfig{Strategy: strategy, TwitterUsers: 1000, ReferenceWeight: 1.0})
+		if err != nil {
+			t.Errorf("NewScorer failed for strategy=%s. Unexpected error: %s", strategy, err)
+		}
+		if scorer == nil {
+			t.Errorf("NewScorer failed for strategy=%s. Got nil Scorer", strategy)
+		}
+	}
+
+	_, err := NewScorer(ScorerConfig{Strategy: "unknown"})
+	if err == nil {
+		t.Errorf("NewScorer should fail for unknown strategy")
+	}
+	_, err = NewScorer(ScorerConfig{Strategy: LinearScoring, TwitterUsers: 0, ReferenceWeight: 1.0})
+	if errors.Cause(err) != ErrInvalidUsers {
+		t.Errorf("NewScorer wrong error for zero twitter users. Expected=%s Actual=%v", ErrInvalidUsers, err)
+	}
+	_, err = NewScorer(ScorerConfig{
+		Strategy:     LinearScoring,
+		TwitterUsers: 1000,
+		Sources:      map[string]SourceNormalization{RedditSource: SourceNormalization{Users: -1, Weight: 1.0}},
+	})
+	if errors.Cause(err) != ErrInvalidUsers {
+		t.Errorf("NewScorer wrong error for negative reddit users. Expected=%s Actual=%v", ErrInvalidUsers, err)
+	}
+}
+
+func TestLinearScorer(t *testing.T) {
+	scorer := NewLinearScorer(12000, 2.0)
+	score := scorer.Score(testReferers(1000, 1000, 1000)...)
+	assertFloat(t, 0.5, score, "LinearScorer.Score")
+
+	score = scorer.Score()
+	assertFloat(t, 0.0, score, "LinearScorer.Score")
+}
+
+func TestLinearScorer_Sources(t *testing.T) {
+	scorer, _ := NewScorer(ScorerConfig{
+		Strategy:        LinearScoring,
+		TwitterUsers:    1000,
+		ReferenceWeight: 1.0,
+		Sources: map[string]SourceNormalization{
+			RedditSource: SourceNormalization{Users: 100, Weight: 2.0},
+		},
+	})
+	referers := []Referer{
+		Referer{Referer: news.Referer{ExternalID: "author-0", FollowerCount: 500}, Source: TwitterSource},
+		Referer{Referer: news.Referer{ExternalID: "user-0", FollowerCount: 10}, Source: RedditSource},
+		Referer{Referer: news.Referer{ExternalID: "feed-0", FollowerCount: 1000}, Source: RSSSource},
+	}
+	score := scorer.Score(referers...)
+	assertFloat(t, 0.5+0.2+1.0, score, "LinearScorer.Score")
+}
+
+func TestLogScorer(t *testing.T) {
+	scorer, _ := NewScorer(ScorerConfig{Strategy: LogScoring, TwitterUsers: 1000, ReferenceWeight: 1.0})
+	score := scorer.Score(testReferers(1000, 3000)...)
+	assertFloat(t, math.Log(2)+math.Log(4), score, "logScorer.Score")
+
+	// Repeated referers are discounted in their contribution rather than their follower count.
+	referers := testReferers(1000, 3000)
+	referers[1].RepeatDiscount = 0.5
+	score = scorer.Score(referers...)
+	assertFloat(t, math.Log(2)+0.5*math.Log(4), score, "logScorer.Score")
+}
+
+func TestDiminishingScorer(t *testing.T) {
+	scorer, _ := NewScorer(ScorerConfig{
+		Strategy:          DiminishingScoring,
+		TwitterUsers:      1000,
+		ReferenceWeight:   1.0,
+		DiminishingFactor: 0.5,
+	})
+	score := scorer.Score(testReferers(1000, 4000, 2000)...)
+	assertFloat(t, 4.0+1.0+0.25, score, "diminishingScorer.Score")
+}
+
+func TestCappedScorer(t *testing.T) {
+	scorer, _ := NewScorer(ScorerConfig{
+		Strategy:        CappedScoring,
+		TwitterUsers:    1000,
+		ReferenceWeight: 1.0,
+		AuthorCap:       2.0,
+	})
+	score := scorer.Score(testReferers(1000, 4000, 500)...)
+	assertFloat(t, 1.0+2.0+0.5, score, "cappedScorer.Score")
+}
+
+func testReferers(followerCounts ...int64) []Referer {
+	referers := make([]Referer, 0, len(followerCounts))
+	for i, followers := range followerCounts {
+		referers = append(referers, NewReferer(news.Referer{
+			ExternalID:    string(rune('a' + i)),
+			FollowerCount: followers,
+		}))
+	}
+	return referers
+}
+
+func assertFloat(t *testing.T, expected, actual float64, name string) {
+	if math.Abs(expected-actual) > 1e-9 {
+		t.Errorf("%s failed. Expected=%f Actual=%f", name, expected, actual)
+	}
+}
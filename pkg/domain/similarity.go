@@ -0,0 +1,106 @@
+package domain
+
+import (
+	"hash/fnv"
+	"math/bits"
+	"strings"
+	"unicode"
+)
+
+// Clustering strategies.
+const (
+	ExactClustering      = "exact"
+	SimilarityClustering = "similarity"
+)
+
+// bodyWeight is the total weight of the body text in a fingerprint
+// relative to the total weight of the title.
+const bodyWeight = 0.3
+
+const fingerprintBits = 64
+
+// ValidClusteringStrategy checks if a clustering strategy is known.
+func ValidClusteringStrategy(strategy string) bool {
+	return strategy == ExactClustering || strategy == SimilarityClustering
+}
+
+// CalcFingerprint calculates a SimHash fingerprint of an article based on
+// the word shingles of its title and body. The title dominates the fingerprint
+// since bodies of articles covering the same news tend to differ more.
+func CalcFingerprint(title, body string) uint64 {
+	var vector [fingerprintBits]float64
+	titleShingles := createShingles(title)
+	addShingles(&vector, titleShingles, 1.0)
+
+	bodyShingles := createShingles(body)
+	if len(bodyShingles) > 0 {
+		weight := bodyWeight * float64(len(titleShingles)) / float64(len(bodyShingles))
+		addShingles(&vector, bodyShingles, weight)
+	}
+
+	var fingerprint uint64
+	for i, value := range vector {
+		if value > 0 {
+			fingerprint |= 1 << uint(i)
+		}
+	}
+	return fingerprint
+}
+
+// Similarity calculates the share of matching bits between two fingerprints.
+func Similarity(a, b uint64) float64 {
+	differentBits := bits.OnesCount64(a ^ b)
+	return 1.0 - float64(differentBits)/fingerprintBits
+}
+
+// MostSimilarCluster finds the candidate cluster with the fingerprint most similar to the
+// provided one, only considering candidates with a similarity of at least the threshold.
+func MostSimilarCluster(fingerprint uint64, candidates []ArticleCluster, threshold float64) (ArticleCluster, bool) {
+	var bestMatch ArticleCluster
+	found := false
+	bestSimilarity := threshold
+	for _, candidate := range candidates {
+		similarity := Similarity(fingerprint, candidate.Fingerprint)
+		if similarity >= bestSimilarity {
+			bestSimilarity = similarity
+			bestMatch = candidate
+			found = true
+		}
+	}
+	return bestMatch, found
+}
+
+func addShingles(vector *[fingerprintBits]float64, shingles []string, weight float64) {
+	for _, shingle := range shingles {
+		hash := hashShingle(shingle)
+		for i := 0; i < fingerprintBits; i++ {
+			if hash&(1<<uint(i)) != 0 {
+				vector[i] += weight
+			} else {
+				vector[i] -= weight
+			}
+		}
+	}
+}
+
+// createShingles splits text into word unigrams and bigrams.
+func createShingles(text string) []string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	shingles := make([]string, 0, 2*len(words))
+	for i, word := range words {
+		shingles = append(shingles, word)
+		if i > 0 {
+			shingles = append(shingles, words[i-1]+" "+word)
+		}
+	}
+	return shingles
+}
+
+func hashShingle(shingle string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(shingle))
+	return h.Sum64()
+}
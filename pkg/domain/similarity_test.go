@@ -0,0 +1,60 @@
+package domain
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCalcFingerprint(t *testing.T) {
+	fingerprint := CalcFingerprint("Apple beats Q3 estimates", "")
+	if fingerprint != CalcFingerprint("APPLE BEATS Q3 ESTIMATES!", "") {
+		t.Errorf("CalcFingerprint should ignore case and punctuation")
+	}
+
+	similar := CalcFingerprint("Apple Beats Q3 Estimates, Shares Rise", "")
+	unrelated := CalcFingerprint("Tesla recalls 100,000 vehicles over faulty brakes", "")
+	if Similarity(fingerprint, similar) <= Similarity(fingerprint, unrelated) {
+		t.Errorf("CalcFingerprint failed. Similar titles should be more similar than unrelated ones. Similar=%f Unrelated=%f",
+			Similarity(fingerprint, similar), Similarity(fingerprint, unrelated))
+	}
+
+	withBody := CalcFingerprint("Apple beats Q3 estimates", "Apple reported quarterly revenue ahead of expectations.")
+	if Similarity(fingerprint, withBody) < 0.9 {
+		t.Errorf("CalcFingerprint failed. The title should dominate the fingerprint. Similarity=%f",
+			Similarity(fingerprint, withBody))
+	}
+}
+
+func TestSimilarity(t *testing.T) {
+	assertFloat(t, 1.0, Similarity(0, 0), "Similarity")
+	assertFloat(t, 0.0, Similarity(0, ^uint64(0)), "Similarity")
+	assertFloat(t, 0.5, Similarity(0, 0xffffffff), "Similarity")
+}
+
+func TestMostSimilarCluster(t *testing.T) {
+	articleDate := time.Now()
+	fingerprint := CalcFingerprint("Apple beats Q3 estimates", "")
+
+	similar := NewArticleCluster("Apple Beats Q3 Estimates, Shares Rise", "AAPL", articleDate, "", 0, nil)
+	similar.Fingerprint = CalcFingerprint(similar.Title, "")
+	unrelated := NewArticleCluster("Tesla recalls 100,000 vehicles over faulty brakes", "AAPL", articleDate, "", 0, nil)
+	unrelated.Fingerprint = CalcFingerprint(unrelated.Title, "")
+
+	match, ok := MostSimilarCluster(fingerprint, []ArticleCluster{*unrelated, *similar}, 0.7)
+	if !ok {
+		t.Fatalf("MostSimilarCluster failed. Expected to find a match")
+	}
+	if match.Hash != similar.Hash {
+		t.Errorf("MostSimilarCluster failed. Expected=%s Actual=%s", similar.Hash, match.Hash)
+	}
+
+	_, ok = MostSimilarCluster(fingerprint, []ArticleCluster{*unrelated}, 0.7)
+	if ok {
+		t.Errorf("MostSimilarCluster failed. Should not match clusters below threshold")
+	}
+
+	_, ok = MostSimilarCluster(fingerprint, nil, 0.7)
+	if ok {
+		t.Errorf("MostSimilarCluster failed. Should not match without candidates")
+	}
+}
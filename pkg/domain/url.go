@@ -0,0 +1,157 @@
+package domain
+
+import (
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ErrInvalidURL is returned for URLs that cannot be canonicalised.
+var ErrInvalidURL = errors.New("invalid url")
+
+// trackingParams are query parameters that only identify where a link was shared.
+var trackingParams = map[string]bool{
+	"fbclid":   true,
+	"gclid":    true,
+	"dclid":    true,
+	"msclkid":  true,
+	"mc_cid":   true,
+	"mc_eid":   true,
+	"igshid":   true,
+	"ref_src":  true,
+	"ref_url":  true,
+	"cmpid":    true,
+	"ncid":     true,
+	"smid":     true,
+	"sr_share": true,
+	"amp":      true,
+}
+
+const trackingParamPrefix = "utm_"
+
+// hostPrefixes are subdomains serving mobile and AMP variants of the same pages.
+var hostPrefixes = []string{"www.", "m.", "amp."}
+
+// ampPathSuffixes are path endings used for AMP variants of pages.
+var ampPathSuffixes = []string{"/amp", "/amp.html", ".amp"}
+
+// URLRule describes how the URLs of a domain are canonicalised on top of the default rules.
+type URLRule struct {
+	Host        string   `json:"host"`        // Canonical host replacing the domain, if set.
+	KeepParams  []string `json:"keepParams"`  // Only these query parameters are kept, if set.
+	StripParams []string `json:"stripParams"` // Query parameters removed in addition to tracking parameters.
+}
+
+// URLCanonicalizer maps variants of an article URL to the same canonical URL.
+type URLCanonicalizer struct {
+	rules map[string]URLRule
+}
+
+// NewURLCanonicalizer creates a canonicalizer using rules per domain, a rule for a
+// domain also applies to its subdomains.
+func NewURLCanonicalizer(rules map[string]URLRule) URLCanonicalizer {
+	normalized := make(map[string]URLRule, len(rules))
+	for domain, rule := range rules {
+		normalized[strings.ToLower(domain)] = rule
+	}
+	return URLCanonicalizer{rules: normalized}
+}
+
+// Canonicalize returns the canonical form of a URL. The scheme is set to https, the host is
+// lower cased and stripped of www, mobile and AMP subdomains and default ports, while
+// tracking parameters, fragments, AMP suffixes and trailing slashes are removed from the rest.
+func (c URLCanonicalizer) Canonicalize(rawURL string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return "", errors.Wrap(ErrInvalidURL, err.Error())
+	}
+	scheme := strings.ToLower(u.Scheme)
+	if (scheme != "http" && scheme != "https") || u.Host == "" {
+		return "", ErrInvalidURL
+	}
+
+	host := canonicalHost(u.Host)
+	rule, hasRule := c.findRule(host)
+	if hasRule && rule.Host != "" {
+		host = strings.ToLower(rule.Host)
+	}
+
+	canonical := url.URL{
+		Scheme:   "https",
+		Host:     host,
+		Path:     canonicalPath(u.Path),
+		RawQuery: canonicalQuery(u.Query(), rule).Encode(),
+	}
+	return canonical.String(), nil
+}
+
+// ArticleSource returns the host an article URL is published on without prefixes such as www.,
+// or an empty string if the URL is invalid.
+func ArticleSource(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return canonicalHost(u.Host)
+}
+
+func (c URLCanonicalizer) findRule(host string) (URLRule, bool) {
+	for _, domain := range parentDomains(host) {
+		rule, ok := c.rules[domain]
+		if ok {
+			return rule, true
+		}
+	}
+	return URLRule{}, false
+}
+
+func canonicalHost(rawHost string) string {
+	host := strings.ToLower(rawHost)
+	if h, port, err := net.SplitHostPort(host); err == nil && (port == "80" || port == "443") {
+		host = h
+	}
+	host = strings.TrimSuffix(host, ".")
+
+	for _, prefix := range hostPrefixes {
+		if strings.HasPrefix(host, prefix) && strings.Count(host, ".") > 1 {
+			host = strings.TrimPrefix(host, prefix)
+		}
+	}
+	return host
+}
+
+func canonicalPath(path string) string {
+	path = strings.TrimRight(path, "/")
+	for _, suffix := range ampPathSuffixes {
+		if strings.HasSuffix(path, suffix) {
+			path = strings.TrimRight(strings.TrimSuffix(path, suffix), "/")
+		}
+	}
+	return path
+}
+
+func canonicalQuery(query url.Values, rule URLRule) url.Values {
+	keep := make(map[string]bool, len(rule.KeepParams))
+	for _, param := range rule.KeepParams {
+		keep[param] = true
+	}
+	strip := make(map[string]bool, len(rule.StripParams))
+	for _, param := range rule.StripParams {
+		strip[param] = true
+	}
+
+	canonical := make(url.Values)
+	for param, values := range query {
+		lower := strings.ToLower(param)
+		if trackingParams[lower] || strings.HasPrefix(lower, trackingParamPrefix) || strip[param] {
+			continue
+		}
+		if len(keep) > 0 && !keep[param] {
+			continue
+		}
+		canonical[param] = values
+	}
+	return canonical
+}
@@ -0,0 +1,51 @@
+package domain
+
+import (
+	"testing"
+)
+
+func TestURLCanonicalizer(t *testing.T) {
+	c := NewURLCanonicalizer(map[string]URLRule{
+		"youtube.com":    URLRule{KeepParams: []string{"v"}},
+		"youtu.be":       URLRule{Host: "youtube.com"},
+		"news.site.com":  URLRule{StripParams: []string{"src"}},
+		"Mobile.SITE.IO": URLRule{Host: "site.io"},
+	})
+
+	expected := map[string]string{
+		"http://example.com/article":                             "https://example.com/article",
+		"https://www.Example.com/article/":                       "https://example.com/article",
+		"https://example.com:443/article?utm_source=twitter":     "https://example.com/article",
+		"http://example.com:80/article?UTM_Medium=x&fbclid=abc":  "https://example.com/article",
+		"https://example.com/article?id=2&page=1#comments":       "https://example.com/article?id=2&page=1",
+		"https://example.com/article?page=1&id=2":                "https://example.com/article?id=2&page=1",
+		"https://amp.example.com/article/amp/":                   "https://example.com/article",
+		"https://m.example.com/article.amp":                      "https://example.com/article",
+		"https://example.com/article/amp.html?amp=1":             "https://example.com/article",
+		"https://example.com":                                    "https://example.com",
+		"https://example.com/":                                   "https://example.com",
+		"https://www.youtube.com/watch?v=abc&feature=share&t=10": "https://youtube.com/watch?v=abc",
+		"https://sub.youtube.com/watch?v=abc":                    "https://sub.youtube.com/watch?v=abc",
+		"https://news.site.com/a?src=rss&id=1":                   "https://news.site.com/a?id=1",
+		"https://mobile.site.io/a":                               "https://site.io/a",
+		"https://www.com/a":                                      "https://www.com/a",
+		"  https://example.com/article  ":                        "https://example.com/article",
+	}
+
+	for rawURL, canonical := range expected {
+		actual, err := c.Canonicalize(rawURL)
+		if err != nil {
+			t.Errorf("Canonicalize(%s) unexpected error: %s", rawURL, err)
+		}
+		if actual != canonical {
+			t.Errorf("Canonicalize(%s) wrong. Expected=%s Got=%s", rawURL, canonical, actual)
+		}
+	}
+
+	for _, invalid := range []string{"", "example.com/article", "ftp://example.com/file", "http://%zz"} {
+		_, err := c.Canonicalize(invalid)
+		if err == nil {
+			t.Errorf("Canonicalize(%s) should fail", invalid)
+		}
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"database/sql"
 	"strings"
 
+	"github.com/lib/pq"
+	"github.com/mimir-news/news-ranker/pkg/domain"
 	"github.com/mimir-news/pkg/dbutil"
 	"github.com/mimir-news/pkg/schema/news"
 	"github.com/pkg/errors"
@@ -23,14 +25,23 @@ var (
 
 // ArticleRepo data access interface for articles.
 type ArticleRepo interface {
+	FindByID(id string) (news.Article, error)
+	FindByIDs(ids []string) (map[string]news.Article, error)
 	FindByURL(url string) (news.Article, error)
+	FindArticles(filter Filter, afterID string, limit int) ([]news.Article, error)
 	FindArticleSubjects(articleID string) ([]news.Subject, error)
-	FindArticleReferers(articleID string) ([]news.Referer, error)
+	FindArticleReferers(articleID string) ([]domain.Referer, error)
+	FindReferersByArticleIDs(articleIDs []string) (map[string][]domain.Referer, error)
 	Update(article news.Article) error
-	SaveReferer(referer news.Referer) error
-	SaveScrapedArticle(scrapedArticle news.ScrapedArticle) error
+	UpdateWithReferer(article news.Article, referer domain.Referer) error
+	SaveScrapedArticle(scrapedArticle news.ScrapedArticle, referers []domain.Referer) error
+	MergeArticles(article news.Article, duplicateIDs []string, rescore ClusterRescorer) error
 }
 
+// ClusterRescorer recomputes the member scores, leader and score of a cluster
+// whose members changed when articles were merged.
+type ClusterRescorer func(cluster *domain.ArticleCluster)
+
 type pgArticleRepo struct {
 	db *sql.DB
 }
@@ -42,20 +53,96 @@ func NewArticleRepo(db *sql.DB) ArticleRepo {
 	}
 }
 
+const findArticleByIDQuery = `SELECT
+  id, url, title, body, keywords, reference_score, article_date, created_at
+  FROM article WHERE id = $1`
+
+func (r *pgArticleRepo) FindByID(id string) (news.Article, error) {
+	a, err := r.findArticle(findArticleByIDQuery, id)
+	if err != nil && err != ErrNoSuchArticle {
+		return a, errors.Wrap(err, "pgArticleRepo.FindByID failed")
+	}
+	return a, err
+}
+
+const findArticlesByIDsQuery = `SELECT
+  id, url, title, body, keywords, reference_score, article_date, created_at
+  FROM article WHERE id = ANY($1)`
+
+// FindByIDs returns the articles by id, missing articles are left out.
+func (r *pgArticleRepo) FindByIDs(ids []string) (map[string]news.Article, error) {
+	rows, err := r.db.Query(findArticlesByIDsQuery, pq.Array(ids))
+	if err != nil {
+		return nil, errors.Wrap(err, "pgArticleRepo.FindByIDs failed")
+	}
+	defer rows.Close()
+
+	articles := make(map[string]news.Article, len(ids))
+	for rows.Next() {
+		a, err := scanArticle(rows)
+		if err != nil {
+			return nil, errors.Wrap(err, "pgArticleRepo.FindByIDs failed")
+		}
+		articles[a.ID] = a
+	}
+	return articles, rows.Err()
+}
+
 const findArticleByURLQuery = `SELECT
   id, url, title, body, keywords, reference_score, article_date, created_at
   FROM article WHERE url = $1`
 
 func (r *pgArticleRepo) FindByURL(url string) (news.Article, error) {
+	a, err := r.findArticle(findArticleByURLQuery, url)
+	if err != nil && err != ErrNoSuchArticle {
+		return a, errors.Wrap(err, "pgArticleRepo.FindByURL failed")
+	}
+	return a, err
+}
+
+func (r *pgArticleRepo) findArticle(query string, arg string) (news.Article, error) {
+	a, err := scanArticle(r.db.QueryRow(query, arg))
+	if err == sql.ErrNoRows {
+		return a, ErrNoSuchArticle
+	}
+	return a, err
+}
+
+const findArticlesQuery = `SELECT
+  a.id, a.url, a.title, a.body, a.keywords, a.reference_score, a.article_date, a.created_at
+  FROM article a
+  WHERE a.id > $1 AND a.article_date >= $2 AND a.article_date <= $3
+  AND ($4 = '' OR EXISTS (SELECT 1 FROM subject s WHERE s.article_id = a.id AND s.symbol = $4))
+  ORDER BY a.id LIMIT $5`
+
+// FindArticles returns up to limit articles matching the filter ordered by id,
+// starting after afterID so that all articles can be iterated over in batches.
+func (r *pgArticleRepo) FindArticles(filter Filter, afterID string, limit int) ([]news.Article, error) {
+	rows, err := r.db.Query(findArticlesQuery, afterID, filter.From, filter.To, filter.Symbol, limit)
+	if err != nil {
+		return nil, errors.Wrap(err, "pgArticleRepo.FindArticles failed")
+	}
+	defer rows.Close()
+
+	articles := make([]news.Article, 0, limit)
+	for rows.Next() {
+		a, err := scanArticle(rows)
+		if err != nil {
+			return nil, errors.Wrap(err, "pgArticleRepo.FindArticles failed")
+		}
+		articles = append(articles, a)
+	}
+	return articles, rows.Err()
+}
+
+func scanArticle(row scanner) (news.Article, error) {
 	var a news.Article
 	var joinedKeywords sql.NullString
-	err := r.db.QueryRow(findArticleByURLQuery, url).Scan(
+	err := row.Scan(
 		&a.ID, &a.URL, &a.Title, &a.Body, &joinedKeywords,
 		&a.ReferenceScore, &a.ArticleDate, &a.CreatedAt)
-	if err == sql.ErrNoRows {
-		return a, ErrNoSuchArticle
-	} else if err != nil {
-		return a, errors.Wrap(err, "pgArticleRepo.FindByURL failed")
+	if err != nil {
+		return a, err
 	}
 	a.Keywords = splitKeywords(joinedKeywords)
 	return a, nil
@@ -95,13 +182,13 @@ func mapRowsToSubjects(rows *sql.Rows) ([]news.Subject, error) {
 }
 
 const findArticleReferersQuery = `
-  SELECT id, twitter_author, follower_count, article_id FROM twitter_references
+  SELECT id, twitter_author, follower_count, article_id, source, created_at FROM twitter_references
   WHERE article_id = $1`
 
-func (r *pgArticleRepo) FindArticleReferers(articleID string) ([]news.Referer, error) {
+func (r *pgArticleRepo) FindArticleReferers(articleID string) ([]domain.Referer, error) {
 	rows, err := r.db.Query(findArticleReferersQuery, articleID)
 	if err == sql.ErrNoRows {
-		return make([]news.Referer, 0), ErrNoReferers
+		return make([]domain.Referer, 0), ErrNoReferers
 	} else if err != nil {
 		return nil, errors.Wrap(err, "pgArticleRepo.FindArticleReferers failed")
 	}
@@ -114,14 +201,44 @@ func (r *pgArticleRepo) FindArticleReferers(articleID string) ([]news.Referer, e
 	return referers, rows.Err()
 }
 
-func mapRowsToReferers(rows *sql.Rows) ([]news.Referer, error) {
-	referers := make([]news.Referer, 0)
+const findReferersByArticleIDsQuery = `
+  SELECT id, twitter_author, follower_count, article_id, source, created_at FROM twitter_references
+  WHERE article_id = ANY($1) ORDER BY id`
+
+// FindReferersByArticleIDs returns the referers of the articles by article id,
+// articles without referers are left out.
+func (r *pgArticleRepo) FindReferersByArticleIDs(articleIDs []string) (map[string][]domain.Referer, error) {
+	rows, err := r.db.Query(findReferersByArticleIDsQuery, pq.Array(articleIDs))
+	if err != nil {
+		return nil, errors.Wrap(err, "pgArticleRepo.FindReferersByArticleIDs failed")
+	}
+	defer rows.Close()
+
+	referers, err := mapRowsToReferers(rows)
+	if err != nil {
+		return nil, errors.Wrap(err, "pgArticleRepo.FindReferersByArticleIDs failed")
+	}
+	return referersByArticleID(referers), rows.Err()
+}
+
+func referersByArticleID(referers []domain.Referer) map[string][]domain.Referer {
+	byArticleID := make(map[string][]domain.Referer)
+	for _, referer := range referers {
+		byArticleID[referer.ArticleID] = append(byArticleID[referer.ArticleID], referer)
+	}
+	return byArticleID
+}
+
+func mapRowsToReferers(rows *sql.Rows) ([]domain.Referer, error) {
+	referers := make([]domain.Referer, 0)
 	for rows.Next() {
-		var r news.Referer
-		err := rows.Scan(&r.ID, &r.ExternalID, &r.FollowerCount, &r.ArticleID)
+		var r domain.Referer
+		var createdAt pq.NullTime
+		err := rows.Scan(&r.ID, &r.ExternalID, &r.FollowerCount, &r.ArticleID, &r.Source, &createdAt)
 		if err != nil {
 			return nil, err
 		}
+		r.CreatedAt = createdAt.Time
 		referers = append(referers, r)
 	}
 	return referers, nil
@@ -141,40 +258,74 @@ func (r *pgArticleRepo) Update(article news.Article) error {
 	return dbutil.AssertRowsAffected(res, expectedUpdates, ErrNoSuchArticle)
 }
 
+func (r *pgArticleRepo) UpdateWithReferer(article news.Article, referer domain.Referer) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return errors.Wrap(err, "pgArticleRepo.UpdateWithReferer failed")
+	}
+
+	err = updateArticle(article, tx)
+	if err != nil {
+		dbutil.RollbackTx(tx)
+		return err
+	}
+
+	err = insertReferer(referer, tx)
+	if err != nil {
+		dbutil.RollbackTx(tx)
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func updateArticle(article news.Article, tx *sql.Tx) error {
+	res, err := tx.Exec(updateArticleQuery, article.ReferenceScore, article.ID)
+	if err != nil {
+		return errors.Wrap(err, "updateArticle failed")
+	}
+
+	return dbutil.AssertRowsAffected(res, 1, ErrNoSuchArticle)
+}
+
 const insertReferencesQuery = `
-  INSERT INTO twitter_references(id, twitter_author, follower_count, article_id)
-  VALUES ($1, $2, $3, $4)`
+  INSERT INTO twitter_references(id, twitter_author, follower_count, article_id, source)
+  VALUES ($1, $2, $3, $4, $5)`
 
-func (r *pgArticleRepo) SaveReferer(referer news.Referer) error {
-	res, err := r.db.Exec(
+func insertReferer(referer domain.Referer, tx *sql.Tx) error {
+	res, err := tx.Exec(
 		insertReferencesQuery, referer.ID, referer.ExternalID,
-		referer.FollowerCount, referer.ArticleID)
+		referer.FollowerCount, referer.ArticleID, referer.Source)
 	if err != nil {
-		return errors.Wrap(err, "pgArticleRepo.SaveReferer failed")
+		return errors.Wrap(err, "insertReferer failed")
 	}
 
 	return dbutil.AssertRowsAffected(res, 1, ErrFailedInsert)
 }
 
-func (r *pgArticleRepo) SaveScrapedArticle(scrapedArticle news.ScrapedArticle) error {
+// SaveScrapedArticle stores the scraped article and its subjects together with the given referers,
+// referers already stored are ignored.
+func (r *pgArticleRepo) SaveScrapedArticle(scrapedArticle news.ScrapedArticle, referers []domain.Referer) error {
 	tx, err := r.db.Begin()
 	if err != nil {
-		return err
+		return errors.Wrap(err, "pgArticleRepo.SaveScrapedArticle failed")
 	}
 
-	err = r.upsertArticle(scrapedArticle.Article, tx)
+	err = upsertArticle(scrapedArticle.Article, tx)
 	if err != nil {
 		dbutil.RollbackTx(tx)
 		return err
 	}
 
-	err = r.insertReferer(scrapedArticle.Referer, tx)
-	if err != nil {
-		dbutil.RollbackTx(tx)
-		return err
+	for _, referer := range referers {
+		err = insertRefererIgnoreConflicts(referer, tx)
+		if err != nil {
+			dbutil.RollbackTx(tx)
+			return err
+		}
 	}
 
-	err = r.upsertSubjects(scrapedArticle.Subjects, tx)
+	err = upsertSubjects(scrapedArticle.Subjects, tx)
 	if err != nil {
 		dbutil.RollbackTx(tx)
 		return err
@@ -190,30 +341,30 @@ const upsertArticleQuery = `
   ON CONFLICT ON CONSTRAINT article_pkey
   DO UPDATE SET reference_score = $6`
 
-func (r *pgArticleRepo) upsertArticle(article news.Article, tx *sql.Tx) error {
+func upsertArticle(article news.Article, tx *sql.Tx) error {
 	keywords := joinKeywords(article.Keywords)
-	res, err := r.db.Exec(
+	res, err := tx.Exec(
 		upsertArticleQuery,
 		article.ID, article.URL, article.Title, article.Body, keywords,
 		article.ReferenceScore, article.ArticleDate)
 	if err != nil {
-		return errors.Wrap(err, "pgArticleRepo.upsertArticle failed")
+		return errors.Wrap(err, "upsertArticle failed")
 	}
 
 	return dbutil.AssertRowsAffected(res, 1, ErrFailedInsert)
 }
 
 const insertReferencesIgnoreConflictsQuery = `
-  INSERT INTO twitter_references(id, twitter_author, follower_count, article_id)
-  VALUES ($1, $2, $3, $4)
+  INSERT INTO twitter_references(id, twitter_author, follower_count, article_id, source)
+  VALUES ($1, $2, $3, $4, $5)
   ON CONFLICT ON CONSTRAINT twitter_references_pkey DO NOTHING`
 
-func (r *pgArticleRepo) insertReferer(referer news.Referer, tx *sql.Tx) error {
-	_, err := r.db.Exec(
+func insertRefererIgnoreConflicts(referer domain.Referer, tx *sql.Tx) error {
+	_, err := tx.Exec(
 		insertReferencesIgnoreConflictsQuery,
-		referer.ID, referer.ExternalID, referer.FollowerCount, referer.ArticleID)
+		referer.ID, referer.ExternalID, referer.FollowerCount, referer.ArticleID, referer.Source)
 	if err != nil {
-		return errors.Wrap(err, "pgArticleRepo.insertReferer failed")
+		return errors.Wrap(err, "insertRefererIgnoreConflicts failed")
 	}
 
 	return nil
@@ -224,11 +375,138 @@ const upsertSubjectQuery = `
   VALUES ($1, $2, $3, $4, $5)
   ON CONFLICT ON CONSTRAINT subject_pkey DO UPDATE SET score = $4`
 
-func (r *pgArticleRepo) upsertSubjects(subjects []news.Subject, tx *sql.Tx) error {
+func upsertSubjects(subjects []news.Subject, tx *sql.Tx) error {
 	for _, s := range subjects {
 		_, err := tx.Exec(upsertSubjectQuery, s.ID, s.Symbol, s.Name, s.Score, s.ArticleID)
 		if err != nil {
-			return errors.Wrap(err, "pgArticleRepo.upsertSubjects failed")
+			return errors.Wrap(err, "upsertSubjects failed")
+		}
+	}
+	return nil
+}
+
+// Queries moving what refers to a duplicate article to the article it is merged into,
+// rows that would violate a unique constraint are deleted instead.
+const (
+	deleteDuplicateReferersQuery = `
+  DELETE FROM twitter_references d WHERE d.article_id = $2 AND EXISTS (
+    SELECT 1 FROM twitter_references r WHERE r.article_id = $1 AND r.twitter_author = d.twitter_author)`
+	moveReferersQuery            = `UPDATE twitter_references SET article_id = $1 WHERE article_id = $2`
+	deleteDuplicateSubjectsQuery = `
+  DELETE FROM subject d WHERE d.article_id = $2 AND EXISTS (
+    SELECT 1 FROM subject s WHERE s.article_id = $1 AND s.symbol = d.symbol)`
+	moveSubjectsQuery                  = `UPDATE subject SET article_id = $1 WHERE article_id = $2`
+	deleteDuplicateClusterMembersQuery = `
+  DELETE FROM cluster_member d WHERE d.article_id = $2 AND EXISTS (
+    SELECT 1 FROM cluster_member m WHERE m.article_id = $1 AND m.cluster_hash = d.cluster_hash)`
+	moveClusterMembersQuery = `UPDATE cluster_member SET article_id = $1 WHERE article_id = $2`
+	moveClusterLeadsQuery   = `
+  UPDATE article_cluster SET lead_article_id = $1, version = version + 1
+  WHERE lead_article_id = $2`
+	deleteDuplicatePendingScrapesQuery = `
+  DELETE FROM pending_scrape d WHERE d.article_id = $2 AND EXISTS (
+    SELECT 1 FROM pending_scrape p WHERE p.article_id = $1)`
+	movePendingScrapesQuery = `
+  UPDATE pending_scrape SET article_id = $1, version = version + 1 WHERE article_id = $2`
+	deleteArticleQuery = `DELETE FROM article WHERE id = $2 AND id <> $1`
+)
+
+var mergeArticleQueries = []string{
+	deleteDuplicateReferersQuery,
+	moveReferersQuery,
+	deleteDuplicateSubjectsQuery,
+	moveSubjectsQuery,
+	deleteDuplicateClusterMembersQuery,
+	moveClusterMembersQuery,
+	moveClusterLeadsQuery,
+	deleteDuplicatePendingScrapesQuery,
+	movePendingScrapesQuery,
+	deleteArticleQuery,
+}
+
+const updateMergedArticleQuery = `UPDATE article SET url = $1, reference_score = $2 WHERE id = $3`
+
+// MergeArticles moves the referers, subjects, cluster memberships and pending scrapes of the
+// duplicate articles to the article, deletes the duplicates and sets the URL and reference score
+// of the article. The clusters of the article are rescored with their merged members.
+// All in one transaction, ErrConcurrentUpdate is returned if a cluster is updated meanwhile.
+func (r *pgArticleRepo) MergeArticles(article news.Article, duplicateIDs []string, rescore ClusterRescorer) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return errors.Wrap(err, "pgArticleRepo.MergeArticles failed")
+	}
+
+	for _, duplicateID := range duplicateIDs {
+		for _, query := range mergeArticleQueries {
+			_, err = tx.Exec(query, article.ID, duplicateID)
+			if err != nil {
+				dbutil.RollbackTx(tx)
+				return errors.Wrap(err, "pgArticleRepo.MergeArticles failed")
+			}
+		}
+	}
+
+	res, err := tx.Exec(updateMergedArticleQuery, article.URL, article.ReferenceScore, article.ID)
+	if err != nil {
+		dbutil.RollbackTx(tx)
+		return errors.Wrap(err, "pgArticleRepo.MergeArticles failed")
+	}
+	err = dbutil.AssertRowsAffected(res, 1, ErrNoSuchArticle)
+	if err != nil {
+		dbutil.RollbackTx(tx)
+		return err
+	}
+
+	err = rescoreArticleClusters(article.ID, rescore, tx)
+	if err != nil {
+		dbutil.RollbackTx(tx)
+		return err
+	}
+
+	return tx.Commit()
+}
+
+const findArticleClusterHashesQuery = `SELECT DISTINCT cluster_hash FROM cluster_member WHERE article_id = $1`
+
+// rescoreArticleClusters rescores and stores the clusters the article is a member of.
+func rescoreArticleClusters(articleID string, rescore ClusterRescorer, tx *sql.Tx) error {
+	rows, err := tx.Query(findArticleClusterHashesQuery, articleID)
+	if err != nil {
+		return errors.Wrap(err, "rescoreArticleClusters failed")
+	}
+	clusterHashes := make([]string, 0)
+	for rows.Next() {
+		var clusterHash string
+		err = rows.Scan(&clusterHash)
+		if err != nil {
+			rows.Close()
+			return errors.Wrap(err, "rescoreArticleClusters failed")
+		}
+		clusterHashes = append(clusterHashes, clusterHash)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return errors.Wrap(err, "rescoreArticleClusters failed")
+	}
+
+	for _, clusterHash := range clusterHashes {
+		cluster, err := findCluster(clusterHash, tx)
+		if err != nil {
+			return err
+		}
+		cluster.Members, err = findClusterMembers(clusterHash, tx)
+		if err != nil {
+			return err
+		}
+
+		rescore(&cluster)
+		err = updateCluster(cluster, tx)
+		if err != nil {
+			return err
+		}
+		err = upsertClusterMembers(cluster.Members, tx)
+		if err != nil {
+			return err
 		}
 	}
 	return nil
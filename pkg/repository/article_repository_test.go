@@ -0,0 +1,200 @@
+package repository
+
+import (
+	"database/sql"
+	"os"
+	"testing"
+	"time"
+
+	_ "github.com/lib/pq"
+	"github.com/mimir-news/news-ranker/pkg/domain"
+	"github.com/mimir-news/pkg/dbutil"
+	"github.com/mimir-news/pkg/id"
+	"github.com/mimir-news/pkg/schema/news"
+	"github.com/stretchr/testify/assert"
+)
+
+// testDBEnvKey names the environment variable holding a connection string to a
+// local Postgres used for integration tests, these are skipped if it is unset.
+const testDBEnvKey = "TEST_DB_DSN"
+
+func TestSaveScrapedArticle(t *testing.T) {
+	assert := assert.New(t)
+	db := connectTestDB(t)
+	defer db.Close()
+
+	repo := NewArticleRepo(db)
+	scrapedArticle := newTestScrapedArticle()
+	defer deleteTestArticle(db, scrapedArticle.Article.ID)
+
+	err := repo.SaveScrapedArticle(scrapedArticle, scrapedReferers(scrapedArticle))
+	assert.Nil(err)
+	assert.Equal(1, countRows(t, db, "article", "id", scrapedArticle.Article.ID))
+	assert.Equal(1, countRows(t, db, "twitter_references", "article_id", scrapedArticle.Article.ID))
+	assert.Equal(2, countRows(t, db, "subject", "article_id", scrapedArticle.Article.ID))
+}
+
+func TestSaveScrapedArticle_Rollback(t *testing.T) {
+	assert := assert.New(t)
+	db := connectTestDB(t)
+	defer db.Close()
+
+	repo := NewArticleRepo(db)
+	scrapedArticle := newTestScrapedArticle()
+	defer deleteTestArticle(db, scrapedArticle.Article.ID)
+
+	// Violates the unique constraint on symbol and article_id in the subject table.
+	scrapedArticle.Subjects[1].Symbol = scrapedArticle.Subjects[0].Symbol
+
+	err := repo.SaveScrapedArticle(scrapedArticle, scrapedReferers(scrapedArticle))
+	assert.NotNil(err)
+	assert.Equal(0, countRows(t, db, "article", "id", scrapedArticle.Article.ID))
+	assert.Equal(0, countRows(t, db, "twitter_references", "article_id", scrapedArticle.Article.ID))
+	assert.Equal(0, countRows(t, db, "subject", "article_id", scrapedArticle.Article.ID))
+}
+
+func TestUpdateWithReferer(t *testing.T) {
+	assert := assert.New(t)
+	db := connectTestDB(t)
+	defer db.Close()
+
+	repo := NewArticleRepo(db)
+	scrapedArticle := newTestScrapedArticle()
+	defer deleteTestArticle(db, scrapedArticle.Article.ID)
+
+	err := repo.SaveScrapedArticle(scrapedArticle, scrapedReferers(scrapedArticle))
+	assert.Nil(err)
+
+	article := scrapedArticle.Article
+	article.ReferenceScore = 2.5
+	referer := domain.Referer{
+		Referer: news.Referer{
+			ID:            id.New(),
+			ExternalID:    "author-1",
+			FollowerCount: 2000,
+			ArticleID:     article.ID,
+		},
+		Source: domain.RedditSource,
+	}
+
+	err = repo.UpdateWithReferer(article, referer)
+	assert.Nil(err)
+
+	var source string
+	err = db.QueryRow("SELECT source FROM twitter_references WHERE id = $1", referer.ID).Scan(&source)
+	assert.Nil(err)
+	assert.Equal(domain.RedditSource, source)
+
+	referers, err := repo.FindArticleReferers(article.ID)
+	assert.Nil(err)
+	assert.Contains(refererIDs(referers), referer.ID)
+
+	storedArticle, err := repo.FindByID(article.ID)
+	assert.Nil(err)
+	assert.Equal(2.5, storedArticle.ReferenceScore)
+	assert.Equal(2, countRows(t, db, "twitter_references", "article_id", article.ID))
+}
+
+func TestUpdateWithReferer_Rollback(t *testing.T) {
+	assert := assert.New(t)
+	db := connectTestDB(t)
+	defer db.Close()
+
+	repo := NewArticleRepo(db)
+	scrapedArticle := newTestScrapedArticle()
+	defer deleteTestArticle(db, scrapedArticle.Article.ID)
+
+	err := repo.SaveScrapedArticle(scrapedArticle, scrapedReferers(scrapedArticle))
+	assert.Nil(err)
+
+	article := scrapedArticle.Article
+	article.ReferenceScore = 2.5
+	// Violates the unique constraint on twitter_author and article_id in the twitter_references table.
+	referer := domain.NewReferer(scrapedArticle.Referer)
+	referer.ID = id.New()
+
+	err = repo.UpdateWithReferer(article, referer)
+	assert.NotNil(err)
+
+	storedArticle, err := repo.FindByID(article.ID)
+	assert.Nil(err)
+	assert.Equal(scrapedArticle.Article.ReferenceScore, storedArticle.ReferenceScore)
+	assert.Equal(1, countRows(t, db, "twitter_references", "article_id", article.ID))
+}
+
+func connectTestDB(t *testing.T) *sql.DB {
+	dsn := os.Getenv(testDBEnvKey)
+	if dsn == "" {
+		t.Skipf("%s not set, skipping integration test", testDBEnvKey)
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatalf("Failed to connect to test database: %s", err)
+	}
+
+	err = dbutil.Migrate("../../cmd/migrations", "postgres", db)
+	if err != nil {
+		t.Fatalf("Failed to migrate test database: %s", err)
+	}
+	return db
+}
+
+func countRows(t *testing.T, db *sql.DB, table, column, value string) int {
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM "+table+" WHERE "+column+" = $1", value).Scan(&count)
+	if err != nil {
+		t.Fatalf("Failed to count rows in %s: %s", table, err)
+	}
+	return count
+}
+
+func deleteTestArticle(db *sql.DB, articleID string) {
+	db.Exec("DELETE FROM pending_scrape WHERE article_id = $1", articleID)
+	db.Exec("DELETE FROM cluster_member WHERE article_id = $1", articleID)
+	db.Exec("DELETE FROM article_cluster WHERE lead_article_id = $1", articleID)
+	db.Exec("DELETE FROM subject WHERE article_id = $1", articleID)
+	db.Exec("DELETE FROM twitter_references WHERE article_id = $1", articleID)
+	db.Exec("DELETE FROM article WHERE id = $1", articleID)
+}
+
+func newTestScrapedArticle() news.ScrapedArticle {
+	articleID := id.New()
+	return news.ScrapedArticle{
+		Article: news.Article{
+			ID:             articleID,
+			URL:            "http://url.com/" + articleID,
+			Title:          "title-0",
+			Body:           "body-0",
+			Keywords:       []string{"k-0", "k-1"},
+			ReferenceScore: 1.5,
+			ArticleDate:    time.Now(),
+		},
+		Subjects: []news.Subject{
+			news.Subject{
+				ID:        id.New(),
+				Symbol:    "S0",
+				Name:      "subject-0",
+				Score:     0.1,
+				ArticleID: articleID,
+			},
+			news.Subject{
+				ID:        id.New(),
+				Symbol:    "S1",
+				Name:      "subject-1",
+				Score:     0.2,
+				ArticleID: articleID,
+			},
+		},
+		Referer: news.Referer{
+			ID:            id.New(),
+			ExternalID:    "author-0",
+			FollowerCount: 1000,
+			ArticleID:     articleID,
+		},
+	}
+}
+
+func scrapedReferers(scrapedArticle news.ScrapedArticle) []domain.Referer {
+	return []domain.Referer{domain.NewReferer(scrapedArticle.Referer)}
+}
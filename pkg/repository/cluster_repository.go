@@ -2,7 +2,9 @@ package repository
 
 import (
 	"database/sql"
+	"time"
 
+	"github.com/lib/pq"
 	"github.com/mimir-news/news-ranker/pkg/domain"
 	"github.com/mimir-news/pkg/dbutil"
 	"github.com/pkg/errors"
@@ -12,13 +14,21 @@ import (
 var (
 	ErrNoSuchCluster = errors.New("no such cluster")
 	ErrUpdateFailed  = errors.New("Update failed")
+	// ErrConcurrentUpdate is returned when a cluster was changed or created by someone else
+	// since it was read. The cluster should be read again and the change reapplied.
+	ErrConcurrentUpdate = errors.New("cluster was concurrently updated")
 )
 
 // ClusterRepo data access interface for article clusters.
 type ClusterRepo interface {
 	FindByHash(clusterHash string) (domain.ArticleCluster, error)
+	FindSince(date time.Time) ([]domain.ArticleCluster, error)
+	FindCandidates(symbol string, since time.Time, limit int) ([]domain.ArticleCluster, error) // Members are not included.
+	FindBySymbolAndDate(symbol string, date time.Time, limit int) ([]domain.ArticleCluster, error)
+	FindClusters(filter Filter, afterHash string, limit int) ([]domain.ArticleCluster, error)
 	Save(cluster domain.ArticleCluster) error
 	Update(cluster domain.ArticleCluster) error
+	UpdateScore(cluster domain.ArticleCluster) error
 }
 
 type pgClusterRepo struct {
@@ -38,13 +48,14 @@ func (r *pgClusterRepo) FindByHash(clusterHash string) (domain.ArticleCluster, e
 		return domain.ArticleCluster{}, errors.Wrap(err, "pgClusterRepo.FindByHash failed")
 	}
 
-	members, err := r.findClusterMembers(clusterHash, tx)
+	// The cluster is read before its members so that the read version is never newer than the members.
+	cluster, err := findCluster(clusterHash, tx)
 	if err != nil {
 		dbutil.RollbackTx(tx)
 		return domain.ArticleCluster{}, err
 	}
 
-	cluster, err := r.findCluster(clusterHash, tx)
+	members, err := findClusterMembers(clusterHash, tx)
 	if err != nil {
 		dbutil.RollbackTx(tx)
 		return domain.ArticleCluster{}, err
@@ -55,21 +66,24 @@ func (r *pgClusterRepo) FindByHash(clusterHash string) (domain.ArticleCluster, e
 }
 
 const findClusterMembersQuery = `
-  SELECT id, reference_score, subject_score, cluster_hash, article_id
-  FROM cluster_member WHERE cluster_hash = $1`
+  SELECT m.id, m.reference_score, m.subject_score, m.cluster_hash, m.article_id,
+    (SELECT MAX(r.created_at) FROM twitter_references r WHERE r.article_id = m.article_id),
+    a.created_at, a.url, LENGTH(a.body)
+  FROM cluster_member m LEFT JOIN article a ON a.id = m.article_id
+  WHERE m.cluster_hash = $1`
 
-func (r *pgClusterRepo) findClusterMembers(clusterHash string, tx *sql.Tx) ([]domain.ClusterMember, error) {
+func findClusterMembers(clusterHash string, tx *sql.Tx) ([]domain.ClusterMember, error) {
 	rows, err := tx.Query(findClusterMembersQuery, clusterHash)
 	if err == sql.ErrNoRows {
 		return nil, ErrNoSuchCluster
 	} else if err != nil {
-		return nil, errors.Wrap(err, "pgClusterRepo.findClusterMembers failed")
+		return nil, errors.Wrap(err, "findClusterMembers failed")
 	}
 	defer rows.Close()
 
 	members, err := mapRowsToClusterMemebers(rows)
 	if err != nil {
-		return nil, errors.Wrap(err, "pgClusterRepo.findClusterMembers failed")
+		return nil, errors.Wrap(err, "findClusterMembers failed")
 	}
 	return members, rows.Err()
 }
@@ -78,31 +92,178 @@ func mapRowsToClusterMemebers(rows *sql.Rows) ([]domain.ClusterMember, error) {
 	members := make([]domain.ClusterMember, 0)
 	for rows.Next() {
 		var m domain.ClusterMember
-		err := rows.Scan(&m.ID, &m.ReferenceScore, &m.SubjectScore, &m.ClusterHash, &m.ArticleID)
+		var referencedAt, firstSeenAt pq.NullTime
+		var articleURL sql.NullString
+		var bodyLength sql.NullInt64
+		err := rows.Scan(&m.ID, &m.ReferenceScore, &m.SubjectScore, &m.ClusterHash, &m.ArticleID,
+			&referencedAt, &firstSeenAt, &articleURL, &bodyLength)
 		if err != nil {
 			return nil, err
 		}
+		m.ReferencedAt = referencedAt.Time
+		m.FirstSeenAt = firstSeenAt.Time
+		m.Source = domain.ArticleSource(articleURL.String)
+		m.BodyLength = int(bodyLength.Int64)
 		members = append(members, m)
 	}
 	return members, nil
 }
 
 const findClusterQuery = `
-  SELECT cluster_hash, title, symbol, article_date, score, lead_article_id
+  SELECT cluster_hash, title, symbol, article_date, score, lead_article_id, lead_reason, fingerprint, version
   FROM article_cluster WHERE cluster_hash = $1`
 
-func (r *pgClusterRepo) findCluster(clusterHash string, tx *sql.Tx) (domain.ArticleCluster, error) {
-	var c domain.ArticleCluster
-	err := tx.QueryRow(findClusterQuery, clusterHash).Scan(
-		&c.Hash, &c.Title, &c.Symbol, &c.ArticleDate, &c.Score, &c.LeadArticleID)
+func findCluster(clusterHash string, tx *sql.Tx) (domain.ArticleCluster, error) {
+	c, err := scanCluster(tx.QueryRow(findClusterQuery, clusterHash))
 	if err == sql.ErrNoRows {
 		return domain.ArticleCluster{}, ErrNoSuchCluster
 	} else if err != nil {
-		return domain.ArticleCluster{}, errors.Wrap(err, "pgClusterRepo.findCluster failed")
+		return domain.ArticleCluster{}, errors.Wrap(err, "findCluster failed")
+	}
+	return c, nil
+}
+
+const findClustersSinceQuery = `
+  SELECT cluster_hash, title, symbol, article_date, score, lead_article_id, lead_reason, fingerprint, version
+  FROM article_cluster WHERE article_date >= $1`
+
+func (r *pgClusterRepo) FindSince(date time.Time) ([]domain.ArticleCluster, error) {
+	clusters, err := r.findClustersWithMembers(findClustersSinceQuery, date)
+	if err != nil {
+		return nil, errors.Wrap(err, "pgClusterRepo.FindSince failed")
+	}
+	return clusters, nil
+}
+
+const findClustersBySymbolAndDateQuery = `
+  SELECT cluster_hash, title, symbol, article_date, score, lead_article_id, lead_reason, fingerprint, version
+  FROM article_cluster WHERE symbol = $1 AND article_date = $2
+  ORDER BY score DESC, cluster_hash LIMIT $3`
+
+func (r *pgClusterRepo) FindBySymbolAndDate(symbol string, date time.Time, limit int) ([]domain.ArticleCluster, error) {
+	clusters, err := r.findClustersWithMembers(findClustersBySymbolAndDateQuery, symbol, date, limit)
+	if err != nil {
+		return nil, errors.Wrap(err, "pgClusterRepo.FindBySymbolAndDate failed")
+	}
+	return clusters, nil
+}
+
+const findClustersQuery = `
+  SELECT cluster_hash, title, symbol, article_date, score, lead_article_id, lead_reason, fingerprint, version
+  FROM article_cluster
+  WHERE cluster_hash > $1 AND article_date >= $2 AND article_date <= $3 AND ($4 = '' OR symbol = $4)
+  ORDER BY cluster_hash LIMIT $5`
+
+// FindClusters returns up to limit clusters matching the filter ordered by hash,
+// starting after afterHash so that all clusters can be iterated over in batches.
+func (r *pgClusterRepo) FindClusters(filter Filter, afterHash string, limit int) ([]domain.ArticleCluster, error) {
+	clusters, err := r.findClustersWithMembers(
+		findClustersQuery, afterHash, filter.From, filter.To, filter.Symbol, limit)
+	if err != nil {
+		return nil, errors.Wrap(err, "pgClusterRepo.FindClusters failed")
+	}
+	return clusters, nil
+}
+
+func (r *pgClusterRepo) findClustersWithMembers(query string, args ...interface{}) ([]domain.ArticleCluster, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	clusters, err := queryClusters(tx, query, args...)
+	if err != nil {
+		dbutil.RollbackTx(tx)
+		return nil, err
+	}
+
+	for i, cluster := range clusters {
+		members, err := findClusterMembers(cluster.Hash, tx)
+		if err != nil {
+			dbutil.RollbackTx(tx)
+			return nil, err
+		}
+		clusters[i].Members = members
+	}
+
+	return clusters, tx.Commit()
+}
+
+const findCandidatesQuery = `
+  SELECT cluster_hash, title, symbol, article_date, score, lead_article_id, lead_reason, fingerprint, version
+  FROM article_cluster WHERE symbol = $1 AND article_date >= $2
+  ORDER BY article_date DESC, cluster_hash LIMIT $3`
+
+// FindCandidates returns the most recent clusters of the symbol since the date, at most limit of them.
+func (r *pgClusterRepo) FindCandidates(symbol string, since time.Time, limit int) ([]domain.ArticleCluster, error) {
+	clusters, err := queryClusters(r.db, findCandidatesQuery, symbol, since, limit)
+	if err != nil {
+		return nil, errors.Wrap(err, "pgClusterRepo.FindCandidates failed")
+	}
+	return clusters, nil
+}
+
+type queryer interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func queryClusters(q queryer, query string, args ...interface{}) ([]domain.ArticleCluster, error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	clusters := make([]domain.ArticleCluster, 0)
+	for rows.Next() {
+		c, err := scanCluster(rows)
+		if err != nil {
+			return nil, err
+		}
+		clusters = append(clusters, c)
+	}
+	return clusters, rows.Err()
+}
+
+func scanCluster(row scanner) (domain.ArticleCluster, error) {
+	var c domain.ArticleCluster
+	var leaderReason sql.NullString
+	var fingerprint sql.NullInt64
+	err := row.Scan(
+		&c.Hash, &c.Title, &c.Symbol, &c.ArticleDate, &c.Score,
+		&c.LeadArticleID, &leaderReason, &fingerprint, &c.Version)
+	if err != nil {
+		return domain.ArticleCluster{}, err
 	}
+
+	c.LeaderReason = leaderReason.String
+	c.Fingerprint = mapFingerprint(fingerprint, c.Title)
 	return c, nil
 }
 
+// uniqueViolationCode is the postgres error code for unique constraint violations.
+const uniqueViolationCode = "23505"
+
+func isUniqueViolation(err error) bool {
+	pqErr, ok := err.(*pq.Error)
+	return ok && pqErr.Code == uniqueViolationCode
+}
+
+// mapFingerprint converts a stored fingerprint, clusters stored before fingerprints
+// were introduced are fingerprinted on their title.
+func mapFingerprint(fingerprint sql.NullInt64, title string) uint64 {
+	if !fingerprint.Valid {
+		return domain.CalcFingerprint(title, "")
+	}
+	return uint64(fingerprint.Int64)
+}
+
+// Update stores the cluster and its members if it has not been updated since it was read,
+// otherwise ErrConcurrentUpdate is returned.
 func (r *pgClusterRepo) Update(cluster domain.ArticleCluster) error {
 	tx, err := r.db.Begin()
 	if err != nil {
@@ -126,17 +287,34 @@ func (r *pgClusterRepo) Update(cluster domain.ArticleCluster) error {
 
 const updateClusterQuery = `
   UPDATE article_cluster SET
-    score = $1, lead_article_id = $2
-    WHERE cluster_hash = $3`
+    score = $1, lead_article_id = $2, lead_reason = $3, version = version + 1
+    WHERE cluster_hash = $4 AND version = $5`
 
 func updateCluster(cluster domain.ArticleCluster, tx *sql.Tx) error {
-	res, err := tx.Exec(updateClusterQuery, cluster.Score, cluster.LeadArticleID, cluster.Hash)
+	res, err := tx.Exec(updateClusterQuery,
+		cluster.Score, cluster.LeadArticleID, cluster.LeaderReason, cluster.Hash, cluster.Version)
 	if err != nil {
 		return errors.Wrap(err, "updateCluster failed")
 	}
-	return dbutil.AssertRowsAffected(res, 1, ErrUpdateFailed)
+	return dbutil.AssertRowsAffected(res, 1, ErrConcurrentUpdate)
 }
 
+const updateClusterScoreQuery = `
+  UPDATE article_cluster SET score = $1, version = version + 1
+  WHERE cluster_hash = $2 AND version = $3`
+
+// UpdateScore stores the cluster score if the cluster has not been updated since it was read,
+// otherwise ErrConcurrentUpdate is returned.
+func (r *pgClusterRepo) UpdateScore(cluster domain.ArticleCluster) error {
+	res, err := r.db.Exec(updateClusterScoreQuery, cluster.Score, cluster.Hash, cluster.Version)
+	if err != nil {
+		return errors.Wrap(err, "pgClusterRepo.UpdateScore failed")
+	}
+	return dbutil.AssertRowsAffected(res, 1, ErrConcurrentUpdate)
+}
+
+// Save stores a new cluster and its members, returns ErrConcurrentUpdate if
+// the cluster has already been created by someone else.
 func (r *pgClusterRepo) Save(cluster domain.ArticleCluster) error {
 	tx, err := r.db.Begin()
 	if err != nil {
@@ -160,14 +338,16 @@ func (r *pgClusterRepo) Save(cluster domain.ArticleCluster) error {
 
 const saveClusterQuery = `
   INSERT INTO article_cluster(
-    cluster_hash, title, symbol, article_date, score, lead_article_id
-  ) VALUES ($1, $2, $3, $4, $5, $6)`
+    cluster_hash, title, symbol, article_date, score, lead_article_id, lead_reason, fingerprint, version
+  ) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
 
 func saveCluster(cluster domain.ArticleCluster, tx *sql.Tx) error {
 	res, err := tx.Exec(
-		saveClusterQuery, cluster.Hash, cluster.Title, cluster.Symbol,
-		cluster.ArticleDate, cluster.Score, cluster.LeadArticleID)
-	if err != nil {
+		saveClusterQuery, cluster.Hash, cluster.Title, cluster.Symbol, cluster.ArticleDate,
+		cluster.Score, cluster.LeadArticleID, cluster.LeaderReason, int64(cluster.Fingerprint), cluster.Version)
+	if isUniqueViolation(err) {
+		return ErrConcurrentUpdate
+	} else if err != nil {
 		return ErrFailedInsert
 	}
 
@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/mimir-news/news-ranker/pkg/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpdate_ConcurrentUpdate(t *testing.T) {
+	assert := assert.New(t)
+	db := connectTestDB(t)
+	defer db.Close()
+
+	articleRepo := NewArticleRepo(db)
+	clusterRepo := NewClusterRepo(db)
+	scrapedArticle := newTestScrapedArticle()
+	article := scrapedArticle.Article
+	defer deleteTestArticle(db, article.ID)
+
+	err := articleRepo.SaveScrapedArticle(scrapedArticle, scrapedReferers(scrapedArticle))
+	assert.Nil(err)
+
+	subject := scrapedArticle.Subjects[0]
+	clusterHash := domain.CalcClusterHash(article.Title, subject.Symbol, article.ArticleDate)
+	members := []domain.ClusterMember{
+		*domain.NewClusterMember(clusterHash, article.ID, article.ReferenceScore, subject.Score),
+	}
+	cluster := *domain.NewArticleCluster(
+		article.Title, subject.Symbol, article.ArticleDate, article.ID, members[0].Score(), members)
+
+	err = clusterRepo.Save(cluster)
+	assert.Nil(err)
+	err = clusterRepo.Save(cluster)
+	assert.Equal(ErrConcurrentUpdate, err)
+
+	first, err := clusterRepo.FindByHash(clusterHash)
+	assert.Nil(err)
+	second, err := clusterRepo.FindByHash(clusterHash)
+	assert.Nil(err)
+	assert.Equal(int64(0), first.Version)
+
+	first.Score = 2.0
+	err = clusterRepo.Update(first)
+	assert.Nil(err)
+
+	second.Score = 3.0
+	err = clusterRepo.Update(second)
+	assert.Equal(ErrConcurrentUpdate, err)
+	err = clusterRepo.UpdateScore(second)
+	assert.Equal(ErrConcurrentUpdate, err)
+
+	stored, err := clusterRepo.FindByHash(clusterHash)
+	assert.Nil(err)
+	assert.Equal(int64(1), stored.Version)
+	assert.Equal(2.0, stored.Score)
+
+	err = clusterRepo.UpdateScore(stored)
+	assert.Nil(err)
+}
@@ -0,0 +1,599 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mimir-news/news-ranker/pkg/domain"
+	"github.com/mimir-news/pkg/id"
+	"github.com/mimir-news/pkg/schema/news"
+	"github.com/stretchr/testify/assert"
+)
+
+// repoContract is a pair of repositories sharing storage that the contract tests run against.
+type repoContract struct {
+	articleRepo       ArticleRepo
+	clusterRepo       ClusterRepo
+	pendingScrapeRepo PendingScrapeRepo
+	// cleanup removes the articles created by a test, along with their clusters.
+	cleanup func(articleIDs ...string)
+}
+
+type contractTest struct {
+	name string
+	test func(t *testing.T, c repoContract)
+}
+
+var contractTests = []contractTest{
+	{name: "SaveScrapedArticle", test: testContractSaveScrapedArticle},
+	{name: "SaveScrapedArticle_Rollback", test: testContractSaveScrapedArticleRollback},
+	{name: "SaveScrapedArticle_DuplicateURL", test: testContractSaveScrapedArticleDuplicateURL},
+	{name: "UpdateWithReferer", test: testContractUpdateWithReferer},
+	{name: "FindReferersByArticleIDs", test: testContractFindReferersByArticleIDs},
+	{name: "FindByIDs", test: testContractFindByIDs},
+	{name: "FindArticles", test: testContractFindArticles},
+	{name: "MergeArticles", test: testContractMergeArticles},
+	{name: "SaveAndUpdateCluster", test: testContractSaveAndUpdateCluster},
+	{name: "UpdateCluster_Rollback", test: testContractUpdateClusterRollback},
+	{name: "FindClusters", test: testContractFindClusters},
+}
+
+func TestMemoryRepoContract(t *testing.T) {
+	for _, ct := range contractTests {
+		t.Run(ct.name, func(t *testing.T) {
+			store := NewMemoryStore()
+			ct.test(t, repoContract{
+				articleRepo:       NewMemoryArticleRepo(store),
+				clusterRepo:       NewMemoryClusterRepo(store),
+				pendingScrapeRepo: NewMemoryPendingScrapeRepo(store),
+				cleanup:           func(articleIDs ...string) {},
+			})
+		})
+	}
+}
+
+func TestPostgresRepoContract(t *testing.T) {
+	db := connectTestDB(t)
+	defer db.Close()
+
+	for _, ct := range contractTests {
+		t.Run(ct.name, func(t *testing.T) {
+			ct.test(t, repoContract{
+				articleRepo:       NewArticleRepo(db),
+				clusterRepo:       NewClusterRepo(db),
+				pendingScrapeRepo: NewPendingScrapeRepo(db),
+				cleanup: func(articleIDs ...string) {
+					for _, articleID := range articleIDs {
+						deleteTestArticle(db, articleID)
+					}
+				},
+			})
+		})
+	}
+}
+
+func testContractSaveScrapedArticle(t *testing.T, c repoContract) {
+	assert := assert.New(t)
+	scrapedArticle := newTestScrapedArticle()
+	article := scrapedArticle.Article
+	defer c.cleanup(article.ID)
+
+	_, err := c.articleRepo.FindByID(article.ID)
+	assert.Equal(ErrNoSuchArticle, err)
+	_, err = c.articleRepo.FindByURL(article.URL)
+	assert.Equal(ErrNoSuchArticle, err)
+
+	err = c.articleRepo.SaveScrapedArticle(scrapedArticle, scrapedReferers(scrapedArticle))
+	assert.Nil(err)
+
+	stored, err := c.articleRepo.FindByID(article.ID)
+	assert.Nil(err)
+	assertArticle(t, article, stored)
+	stored, err = c.articleRepo.FindByURL(article.URL)
+	assert.Nil(err)
+	assertArticle(t, article, stored)
+
+	subjects, err := c.articleRepo.FindArticleSubjects(article.ID)
+	assert.Nil(err)
+	assert.ElementsMatch(scrapedArticle.Subjects, subjects)
+	referers, err := c.articleRepo.FindArticleReferers(article.ID)
+	assert.Nil(err)
+	assert.Equal(scrapedReferers(scrapedArticle), withoutCreatedAt(t, referers))
+
+	// Saving again updates the reference score and subject scores but keeps the first referer.
+	scrapedArticle.Article.ReferenceScore = 2.5
+	scrapedArticle.Article.Title = "changed title"
+	scrapedArticle.Subjects[0].Score = 0.3
+	scrapedArticle.Referer.FollowerCount = 5000
+	err = c.articleRepo.SaveScrapedArticle(scrapedArticle, scrapedReferers(scrapedArticle))
+	assert.Nil(err)
+
+	stored, err = c.articleRepo.FindByID(article.ID)
+	assert.Nil(err)
+	assert.Equal(2.5, stored.ReferenceScore)
+	assert.Equal(article.Title, stored.Title)
+	subjects, err = c.articleRepo.FindArticleSubjects(article.ID)
+	assert.Nil(err)
+	assert.ElementsMatch(scrapedArticle.Subjects, subjects)
+	referers, err = c.articleRepo.FindArticleReferers(article.ID)
+	assert.Nil(err)
+	assert.Equal(int64(1000), referers[0].FollowerCount)
+
+	stored.ReferenceScore = 3.25
+	err = c.articleRepo.Update(stored)
+	assert.Nil(err)
+	stored, err = c.articleRepo.FindByID(article.ID)
+	assert.Nil(err)
+	assert.Equal(3.25, stored.ReferenceScore)
+
+	err = c.articleRepo.Update(news.Article{ID: id.New(), ReferenceScore: 1.0})
+	assert.Equal(ErrNoSuchArticle, err)
+}
+
+func testContractSaveScrapedArticleRollback(t *testing.T, c repoContract) {
+	assert := assert.New(t)
+	scrapedArticle := newTestScrapedArticle()
+	articleID := scrapedArticle.Article.ID
+	defer c.cleanup(articleID)
+
+	scrapedArticle.Subjects[1].Symbol = scrapedArticle.Subjects[0].Symbol
+	err := c.articleRepo.SaveScrapedArticle(scrapedArticle, scrapedReferers(scrapedArticle))
+	assert.NotNil(err)
+
+	_, err = c.articleRepo.FindByID(articleID)
+	assert.Equal(ErrNoSuchArticle, err)
+	subjects, err := c.articleRepo.FindArticleSubjects(articleID)
+	assert.Nil(err)
+	assert.Len(subjects, 0)
+	referers, err := c.articleRepo.FindArticleReferers(articleID)
+	assert.Nil(err)
+	assert.Len(referers, 0)
+}
+
+func testContractSaveScrapedArticleDuplicateURL(t *testing.T, c repoContract) {
+	assert := assert.New(t)
+	first := newTestScrapedArticle()
+	second := newTestScrapedArticle()
+	second.Article.URL = first.Article.URL
+	defer c.cleanup(first.Article.ID, second.Article.ID)
+
+	err := c.articleRepo.SaveScrapedArticle(first, scrapedReferers(first))
+	assert.Nil(err)
+	err = c.articleRepo.SaveScrapedArticle(second, scrapedReferers(second))
+	assert.NotNil(err)
+
+	_, err = c.articleRepo.FindByID(second.Article.ID)
+	assert.Equal(ErrNoSuchArticle, err)
+	stored, err := c.articleRepo.FindByURL(first.Article.URL)
+	assert.Nil(err)
+	assert.Equal(first.Article.ID, stored.ID)
+}
+
+func testContractUpdateWithReferer(t *testing.T, c repoContract) {
+	assert := assert.New(t)
+	scrapedArticle := newTestScrapedArticle()
+	article := scrapedArticle.Article
+	defer c.cleanup(article.ID)
+
+	err := c.articleRepo.SaveScrapedArticle(scrapedArticle, scrapedReferers(scrapedArticle))
+	assert.Nil(err)
+
+	article.ReferenceScore = 2.5
+	referer := domain.Referer{
+		Referer: news.Referer{
+			ID:            id.New(),
+			ExternalID:    "author-1",
+			FollowerCount: 2000,
+			ArticleID:     article.ID,
+		},
+		Source: domain.RedditSource,
+	}
+	err = c.articleRepo.UpdateWithReferer(article, referer)
+	assert.Nil(err)
+
+	// Violates the unique constraint on author and article.
+	article.ReferenceScore = 4.0
+	duplicate := referer
+	duplicate.ID = id.New()
+	err = c.articleRepo.UpdateWithReferer(article, duplicate)
+	assert.NotNil(err)
+
+	stored, err := c.articleRepo.FindByID(article.ID)
+	assert.Nil(err)
+	assert.Equal(2.5, stored.ReferenceScore)
+	referers, err := c.articleRepo.FindArticleReferers(article.ID)
+	assert.Nil(err)
+	assert.ElementsMatch([]domain.Referer{domain.NewReferer(scrapedArticle.Referer), referer}, withoutCreatedAt(t, referers))
+
+	err = c.articleRepo.UpdateWithReferer(news.Article{ID: id.New()}, domain.Referer{Referer: news.Referer{ID: id.New()}})
+	assert.Equal(ErrNoSuchArticle, err)
+}
+
+func testContractFindReferersByArticleIDs(t *testing.T, c repoContract) {
+	assert := assert.New(t)
+	first := newTestScrapedArticle()
+	second := newTestScrapedArticle()
+	second.Referer.ExternalID = "reddit:author-1"
+	defer c.cleanup(first.Article.ID, second.Article.ID)
+
+	for _, scrapedArticle := range []news.ScrapedArticle{first, second} {
+		err := c.articleRepo.SaveScrapedArticle(scrapedArticle, scrapedReferers(scrapedArticle))
+		assert.Nil(err)
+	}
+
+	withoutReferers := id.New()
+	referers, err := c.articleRepo.FindReferersByArticleIDs([]string{first.Article.ID, second.Article.ID, withoutReferers})
+	assert.Nil(err)
+	assert.Equal(2, len(referers))
+	assert.Equal(scrapedReferers(first), withoutCreatedAt(t, referers[first.Article.ID]))
+	assert.Equal(scrapedReferers(second), withoutCreatedAt(t, referers[second.Article.ID]))
+
+	referers, err = c.articleRepo.FindReferersByArticleIDs([]string{withoutReferers})
+	assert.Nil(err)
+	assert.Len(referers, 0)
+}
+
+func testContractFindByIDs(t *testing.T, c repoContract) {
+	assert := assert.New(t)
+	first := newTestScrapedArticle()
+	second := newTestScrapedArticle()
+	defer c.cleanup(first.Article.ID, second.Article.ID)
+
+	for _, scrapedArticle := range []news.ScrapedArticle{first, second} {
+		err := c.articleRepo.SaveScrapedArticle(scrapedArticle, scrapedReferers(scrapedArticle))
+		assert.Nil(err)
+	}
+
+	articles, err := c.articleRepo.FindByIDs([]string{first.Article.ID, second.Article.ID, id.New()})
+	assert.Nil(err)
+	assert.Len(articles, 2)
+	assert.Equal(first.Article.URL, articles[first.Article.ID].URL)
+	assert.Equal(second.Article.Title, articles[second.Article.ID].Title)
+
+	articles, err = c.articleRepo.FindByIDs([]string{})
+	assert.Nil(err)
+	assert.Len(articles, 0)
+}
+
+func testContractFindArticles(t *testing.T, c repoContract) {
+	assert := assert.New(t)
+	symbol := newTestSymbol()
+	articleDate := time.Date(2019, 3, 10, 0, 0, 0, 0, time.UTC)
+
+	articleIDs := make([]string, 0, 4)
+	for i := 0; i < 4; i++ {
+		scrapedArticle := newTestScrapedArticle()
+		scrapedArticle.Article.ArticleDate = articleDate.AddDate(0, 0, i)
+		scrapedArticle.Subjects[0].Symbol = symbol
+		articleIDs = append(articleIDs, scrapedArticle.Article.ID)
+		err := c.articleRepo.SaveScrapedArticle(scrapedArticle, scrapedReferers(scrapedArticle))
+		assert.Nil(err)
+	}
+	defer c.cleanup(articleIDs...)
+
+	filter := Filter{
+		From:   articleDate.AddDate(0, 0, 1),
+		To:     articleDate.AddDate(0, 0, 3),
+		Symbol: symbol,
+	}
+	first, err := c.articleRepo.FindArticles(filter, "", 2)
+	assert.Nil(err)
+	assert.Len(first, 2)
+	second, err := c.articleRepo.FindArticles(filter, first[1].ID, 2)
+	assert.Nil(err)
+	assert.Len(second, 1)
+	last, err := c.articleRepo.FindArticles(filter, second[0].ID, 2)
+	assert.Nil(err)
+	assert.Len(last, 0)
+
+	found := []string{first[0].ID, first[1].ID, second[0].ID}
+	assert.True(found[0] < found[1] && found[1] < found[2])
+	assert.ElementsMatch(articleIDs[1:], found)
+}
+
+func testContractMergeArticles(t *testing.T, c repoContract) {
+	assert := assert.New(t)
+	first := newTestScrapedArticle()
+	second := newTestScrapedArticle()
+	third := newTestScrapedArticle()
+	third.Referer.ExternalID = "author-2"
+	third.Subjects[1].Symbol = "S2"
+	defer c.cleanup(first.Article.ID, second.Article.ID, third.Article.ID)
+	for _, scrapedArticle := range []news.ScrapedArticle{first, second, third} {
+		assert.Nil(c.articleRepo.SaveScrapedArticle(scrapedArticle, scrapedReferers(scrapedArticle)))
+	}
+
+	symbol := newTestSymbol()
+	shared := newTestCluster(second, symbol)
+	shared.Members = append(shared.Members, *domain.NewClusterMember(shared.Hash, first.Article.ID, 1.0, 1.0))
+	assert.Nil(c.clusterRepo.Save(shared))
+	other := newTestCluster(third, symbol)
+	assert.Nil(c.clusterRepo.Save(other))
+	for _, scrapedArticle := range []news.ScrapedArticle{second, third} {
+		target := news.ScrapeTarget{URL: scrapedArticle.Article.URL, ArticleID: scrapedArticle.Article.ID}
+		assert.Nil(c.pendingScrapeRepo.Save(domain.NewPendingScrape(target.URL, target, time.Now())))
+	}
+
+	merged := first.Article
+	merged.URL = "https://url.com/" + first.Article.ID
+	merged.ReferenceScore = 2.5
+	policy, err := domain.NewLeaderPolicy(domain.LeaderConfig{Policy: domain.HighestScoreLeader})
+	assert.Nil(err)
+	rescored := make([]string, 0)
+	rescore := func(cluster *domain.ArticleCluster) {
+		rescored = append(rescored, cluster.Hash)
+		for i := range cluster.Members {
+			if cluster.Members[i].ArticleID == merged.ID {
+				cluster.Members[i].ReferenceScore = merged.ReferenceScore
+			}
+		}
+		cluster.ElectLeaderAndScore(policy)
+	}
+	err = c.articleRepo.MergeArticles(merged, []string{second.Article.ID, third.Article.ID}, rescore)
+	assert.Nil(err)
+	assert.ElementsMatch([]string{shared.Hash, other.Hash}, rescored)
+
+	stored, err := c.articleRepo.FindByURL(merged.URL)
+	assert.Nil(err)
+	assert.Equal(first.Article.ID, stored.ID)
+	assert.Equal(2.5, stored.ReferenceScore)
+	_, err = c.articleRepo.FindByURL(first.Article.URL)
+	assert.Equal(ErrNoSuchArticle, err)
+	_, err = c.articleRepo.FindByID(second.Article.ID)
+	assert.Equal(ErrNoSuchArticle, err)
+	_, err = c.articleRepo.FindByID(third.Article.ID)
+	assert.Equal(ErrNoSuchArticle, err)
+
+	referers, err := c.articleRepo.FindArticleReferers(first.Article.ID)
+	assert.Nil(err)
+	assert.ElementsMatch([]string{first.Referer.ID, third.Referer.ID}, refererIDs(referers))
+	subjects, err := c.articleRepo.FindArticleSubjects(first.Article.ID)
+	assert.Nil(err)
+	assert.ElementsMatch([]string{"S0", "S1", "S2"}, subjectSymbols(subjects))
+
+	updated, err := c.clusterRepo.FindByHash(shared.Hash)
+	assert.Nil(err)
+	assert.Equal(first.Article.ID, updated.LeadArticleID)
+	assert.Equal(int64(2), updated.Version)
+	assert.Len(updated.Members, 1)
+	assert.Equal(first.Article.ID, updated.Members[0].ArticleID)
+	assert.Equal(2.5, updated.Members[0].ReferenceScore)
+	assert.InDelta(2.5+updated.Members[0].SubjectScore, updated.Score, 1e-5)
+	updated, err = c.clusterRepo.FindByHash(other.Hash)
+	assert.Nil(err)
+	assert.Equal(first.Article.ID, updated.LeadArticleID)
+	assert.Equal(first.Article.ID, updated.Members[0].ArticleID)
+	assert.Equal(2.5, updated.Members[0].ReferenceScore)
+
+	pending, err := c.pendingScrapeRepo.FindByArticleID(first.Article.ID)
+	assert.Nil(err)
+	assert.Equal(second.Article.URL, pending.URL)
+	_, err = c.pendingScrapeRepo.FindByURL(third.Article.URL)
+	assert.Equal(ErrNoSuchPendingScrape, err)
+
+	err = c.articleRepo.MergeArticles(news.Article{ID: id.New(), URL: "https://url.com/" + id.New()}, nil, rescore)
+	assert.Equal(ErrNoSuchArticle, err)
+}
+
+func testContractSaveAndUpdateCluster(t *testing.T, c repoContract) {
+	assert := assert.New(t)
+	first := newTestScrapedArticle()
+	second := newTestScrapedArticle()
+	defer c.cleanup(first.Article.ID, second.Article.ID)
+	assert.Nil(c.articleRepo.SaveScrapedArticle(first, scrapedReferers(first)))
+	assert.Nil(c.articleRepo.SaveScrapedArticle(second, scrapedReferers(second)))
+
+	cluster := newTestCluster(first, newTestSymbol())
+	_, err := c.clusterRepo.FindByHash(cluster.Hash)
+	assert.Equal(ErrNoSuchCluster, err)
+
+	err = c.clusterRepo.Save(cluster)
+	assert.Nil(err)
+	err = c.clusterRepo.Save(cluster)
+	assert.Equal(ErrConcurrentUpdate, err)
+
+	stored, err := c.clusterRepo.FindByHash(cluster.Hash)
+	assert.Nil(err)
+	assert.Equal(cluster.Hash, stored.Hash)
+	assert.Equal(cluster.Title, stored.Title)
+	assert.Equal(cluster.Symbol, stored.Symbol)
+	assertSameDate(t, cluster.ArticleDate, stored.ArticleDate)
+	assert.Equal(cluster.Fingerprint, stored.Fingerprint)
+	assert.Equal(cluster.Score, stored.Score)
+	assert.Equal(int64(0), stored.Version)
+	assert.Len(stored.Members, 1)
+	assert.Equal(cluster.Members[0].ID, stored.Members[0].ID)
+	assert.False(stored.Members[0].ReferencedAt.IsZero())
+	assert.False(stored.Members[0].FirstSeenAt.IsZero())
+	assert.Equal("url.com", stored.Members[0].Source)
+	assert.Equal(len(first.Article.Body), stored.Members[0].BodyLength)
+
+	concurrent, err := c.clusterRepo.FindByHash(cluster.Hash)
+	assert.Nil(err)
+
+	member := domain.NewClusterMember(cluster.Hash, second.Article.ID, 2.0, 0.5)
+	stored.AddMember(*member)
+	leaderPolicy, err := domain.NewLeaderPolicy(domain.LeaderConfig{Policy: domain.HighestScoreLeader})
+	assert.Nil(err)
+	stored.ElectLeaderAndScore(leaderPolicy)
+	err = c.clusterRepo.Update(stored)
+	assert.Nil(err)
+
+	err = c.clusterRepo.Update(concurrent)
+	assert.Equal(ErrConcurrentUpdate, err)
+	err = c.clusterRepo.UpdateScore(concurrent)
+	assert.Equal(ErrConcurrentUpdate, err)
+
+	updated, err := c.clusterRepo.FindByHash(cluster.Hash)
+	assert.Nil(err)
+	assert.Equal(int64(1), updated.Version)
+	assert.Equal(second.Article.ID, updated.LeadArticleID)
+	assert.Equal(domain.HighestScoreLeader, updated.LeaderReason)
+	assert.Equal(stored.Score, updated.Score)
+	assert.Len(updated.Members, 2)
+
+	updated.Score = 1.25
+	err = c.clusterRepo.UpdateScore(updated)
+	assert.Nil(err)
+	updated, err = c.clusterRepo.FindByHash(cluster.Hash)
+	assert.Nil(err)
+	assert.Equal(int64(2), updated.Version)
+	assert.Equal(1.25, updated.Score)
+}
+
+func testContractUpdateClusterRollback(t *testing.T, c repoContract) {
+	assert := assert.New(t)
+	scrapedArticle := newTestScrapedArticle()
+	defer c.cleanup(scrapedArticle.Article.ID)
+	assert.Nil(c.articleRepo.SaveScrapedArticle(scrapedArticle, scrapedReferers(scrapedArticle)))
+
+	cluster := newTestCluster(scrapedArticle, newTestSymbol())
+	err := c.clusterRepo.Save(cluster)
+	assert.Nil(err)
+
+	// Violates the unique constraint on cluster and article among cluster members.
+	stored, err := c.clusterRepo.FindByHash(cluster.Hash)
+	assert.Nil(err)
+	stored.Score = 5.0
+	stored.Members = append(stored.Members,
+		*domain.NewClusterMember(cluster.Hash, scrapedArticle.Article.ID, 1.0, 1.0))
+	err = c.clusterRepo.Update(stored)
+	assert.NotNil(err)
+
+	unchanged, err := c.clusterRepo.FindByHash(cluster.Hash)
+	assert.Nil(err)
+	assert.Equal(int64(0), unchanged.Version)
+	assert.Equal(cluster.Score, unchanged.Score)
+	assert.Len(unchanged.Members, 1)
+}
+
+func testContractFindClusters(t *testing.T, c repoContract) {
+	assert := assert.New(t)
+	symbol := newTestSymbol()
+	articleDate := time.Date(2019, 3, 10, 0, 0, 0, 0, time.UTC)
+
+	articleIDs := make([]string, 0, 3)
+	hashes := make([]string, 0, 3)
+	for i := 0; i < 3; i++ {
+		scrapedArticle := newTestScrapedArticle()
+		scrapedArticle.Article.ArticleDate = articleDate
+		if i == 2 {
+			scrapedArticle.Article.ArticleDate = articleDate.AddDate(0, 0, 1)
+		}
+		articleIDs = append(articleIDs, scrapedArticle.Article.ID)
+		assert.Nil(c.articleRepo.SaveScrapedArticle(scrapedArticle, scrapedReferers(scrapedArticle)))
+
+		cluster := newTestCluster(scrapedArticle, symbol)
+		cluster.Score = float64(i)
+		hashes = append(hashes, cluster.Hash)
+		assert.Nil(c.clusterRepo.Save(cluster))
+	}
+	defer c.cleanup(articleIDs...)
+
+	clusters, err := c.clusterRepo.FindBySymbolAndDate(symbol, articleDate, 10)
+	assert.Nil(err)
+	assert.Len(clusters, 2)
+	assert.Equal(hashes[1], clusters[0].Hash)
+	assert.Equal(hashes[0], clusters[1].Hash)
+	assert.Len(clusters[0].Members, 1)
+
+	clusters, err = c.clusterRepo.FindBySymbolAndDate(symbol, articleDate, 1)
+	assert.Nil(err)
+	assert.Len(clusters, 1)
+
+	candidates, err := c.clusterRepo.FindCandidates(symbol, articleDate.AddDate(0, 0, 1), 10)
+	assert.Nil(err)
+	assert.Len(candidates, 1)
+	assert.Equal(hashes[2], candidates[0].Hash)
+	assert.Len(candidates[0].Members, 0)
+
+	candidates, err = c.clusterRepo.FindCandidates(symbol, articleDate, 1)
+	assert.Nil(err)
+	assert.Len(candidates, 1)
+	assert.Equal(hashes[2], candidates[0].Hash)
+
+	clusters, err = c.clusterRepo.FindSince(articleDate)
+	assert.Nil(err)
+	assert.Subset(clusterHashes(clusters), hashes)
+
+	filter := Filter{From: articleDate, To: articleDate.AddDate(0, 0, 1), Symbol: symbol}
+	first, err := c.clusterRepo.FindClusters(filter, "", 2)
+	assert.Nil(err)
+	assert.Len(first, 2)
+	rest, err := c.clusterRepo.FindClusters(filter, first[1].Hash, 2)
+	assert.Nil(err)
+	assert.Len(rest, 1)
+	found := append(clusterHashes(first), clusterHashes(rest)...)
+	assert.True(found[0] < found[1] && found[1] < found[2])
+	assert.ElementsMatch(hashes, found)
+}
+
+func newTestCluster(scrapedArticle news.ScrapedArticle, symbol string) domain.ArticleCluster {
+	article := scrapedArticle.Article
+	clusterHash := domain.CalcClusterHash(article.Title+article.ID, symbol, article.ArticleDate)
+	members := []domain.ClusterMember{
+		*domain.NewClusterMember(clusterHash, article.ID, article.ReferenceScore, scrapedArticle.Subjects[0].Score),
+	}
+	cluster := domain.NewArticleCluster(
+		article.Title+article.ID, symbol, article.ArticleDate, article.ID, members[0].Score(), members)
+	cluster.Fingerprint = domain.CalcFingerprint(cluster.Title, article.Body)
+	return *cluster
+}
+
+// withoutCreatedAt checks that the referers were read back with the time they were stored
+// and returns them without it, to compare them with the referers that were saved.
+func withoutCreatedAt(t *testing.T, referers []domain.Referer) []domain.Referer {
+	stripped := make([]domain.Referer, len(referers))
+	for i, referer := range referers {
+		assert.False(t, referer.CreatedAt.IsZero(), "referer %s read without created at", referer.ID)
+		referer.CreatedAt = time.Time{}
+		stripped[i] = referer
+	}
+	return stripped
+}
+
+func refererIDs(referers []domain.Referer) []string {
+	ids := make([]string, 0, len(referers))
+	for _, referer := range referers {
+		ids = append(ids, referer.ID)
+	}
+	return ids
+}
+
+func subjectSymbols(subjects []news.Subject) []string {
+	symbols := make([]string, 0, len(subjects))
+	for _, subject := range subjects {
+		symbols = append(symbols, subject.Symbol)
+	}
+	return symbols
+}
+
+// newTestSymbol returns a symbol unique to a test so that other stored data is not found.
+func newTestSymbol() string {
+	return "T" + id.New()[:8]
+}
+
+func assertArticle(t *testing.T, expected, actual news.Article) {
+	assert := assert.New(t)
+	assert.Equal(expected.ID, actual.ID)
+	assert.Equal(expected.URL, actual.URL)
+	assert.Equal(expected.Title, actual.Title)
+	assert.Equal(expected.Body, actual.Body)
+	assert.Equal(expected.Keywords, actual.Keywords)
+	assert.Equal(expected.ReferenceScore, actual.ReferenceScore)
+	assertSameDate(t, expected.ArticleDate, actual.ArticleDate)
+	assert.False(actual.CreatedAt.IsZero())
+}
+
+// assertSameDate checks that a date was stored, times are stored as dates.
+func assertSameDate(t *testing.T, expected, actual time.Time) {
+	assert.Equal(t, expected.Format("2006-01-02"), actual.Format("2006-01-02"))
+}
+
+func clusterHashes(clusters []domain.ArticleCluster) []string {
+	hashes := make([]string, 0, len(clusters))
+	for _, cluster := range clusters {
+		hashes = append(hashes, cluster.Hash)
+	}
+	return hashes
+}
@@ -0,0 +1,12 @@
+package repository
+
+import (
+	"time"
+)
+
+// Filter narrows down the articles or clusters to find by date and symbol.
+type Filter struct {
+	From   time.Time
+	To     time.Time
+	Symbol string // Any symbol matches if empty.
+}
@@ -0,0 +1,225 @@
+package repository
+
+import (
+	"sort"
+
+	"github.com/mimir-news/news-ranker/pkg/domain"
+	"github.com/mimir-news/pkg/schema/news"
+	"github.com/pkg/errors"
+)
+
+type memoryArticleRepo struct {
+	store *MemoryStore
+}
+
+// NewMemoryArticleRepo creates a new ArticleRepo keeping articles in the store.
+func NewMemoryArticleRepo(store *MemoryStore) ArticleRepo {
+	return &memoryArticleRepo{
+		store: store,
+	}
+}
+
+func (r *memoryArticleRepo) FindByID(id string) (news.Article, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	article, ok := r.store.articles[id]
+	if !ok {
+		return news.Article{}, ErrNoSuchArticle
+	}
+	return copyArticle(article), nil
+}
+
+func (r *memoryArticleRepo) FindByIDs(ids []string) (map[string]news.Article, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	articles := make(map[string]news.Article, len(ids))
+	for _, id := range ids {
+		if article, ok := r.store.articles[id]; ok {
+			articles[id] = copyArticle(article)
+		}
+	}
+	return articles, nil
+}
+
+func (r *memoryArticleRepo) FindByURL(url string) (news.Article, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	id, ok := r.store.articleIDsByURL[url]
+	if !ok {
+		return news.Article{}, ErrNoSuchArticle
+	}
+	return copyArticle(r.store.articles[id]), nil
+}
+
+func (r *memoryArticleRepo) FindArticles(filter Filter, afterID string, limit int) ([]news.Article, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	articles := make([]news.Article, 0)
+	for _, article := range r.store.articles {
+		if article.ID <= afterID || !inDateRange(filter, article.ArticleDate) {
+			continue
+		}
+		if filter.Symbol != "" && !r.store.hasSubject(article.ID, filter.Symbol) {
+			continue
+		}
+		articles = append(articles, copyArticle(article))
+	}
+
+	sort.Slice(articles, func(i, j int) bool {
+		return articles[i].ID < articles[j].ID
+	})
+	if len(articles) > limit {
+		articles = articles[:limit]
+	}
+	return articles, nil
+}
+
+func (r *memoryArticleRepo) FindArticleSubjects(articleID string) ([]news.Subject, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	subjects := make([]news.Subject, 0)
+	for _, subject := range r.store.subjects {
+		if subject.ArticleID == articleID {
+			subjects = append(subjects, subject)
+		}
+	}
+
+	sort.Slice(subjects, func(i, j int) bool {
+		return subjects[i].ID < subjects[j].ID
+	})
+	return subjects, nil
+}
+
+func (r *memoryArticleRepo) FindArticleReferers(articleID string) ([]domain.Referer, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	referers := make([]domain.Referer, 0)
+	for _, stored := range r.store.referers {
+		if stored.referer.ArticleID == articleID {
+			referers = append(referers, stored.read())
+		}
+	}
+
+	sort.Slice(referers, func(i, j int) bool {
+		return referers[i].ID < referers[j].ID
+	})
+	return referers, nil
+}
+
+func (r *memoryArticleRepo) FindReferersByArticleIDs(articleIDs []string) (map[string][]domain.Referer, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	wanted := make(map[string]bool, len(articleIDs))
+	for _, articleID := range articleIDs {
+		wanted[articleID] = true
+	}
+	referers := make([]domain.Referer, 0)
+	for _, stored := range r.store.referers {
+		if wanted[stored.referer.ArticleID] {
+			referers = append(referers, stored.read())
+		}
+	}
+
+	sort.Slice(referers, func(i, j int) bool {
+		return referers[i].ID < referers[j].ID
+	})
+	return referersByArticleID(referers), nil
+}
+
+func (r *memoryArticleRepo) Update(article news.Article) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	tx := &memoryTx{}
+	return r.store.updateArticle(tx, article)
+}
+
+func (r *memoryArticleRepo) UpdateWithReferer(article news.Article, referer domain.Referer) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	tx := &memoryTx{}
+	err := r.store.updateArticle(tx, article)
+	if err != nil {
+		tx.rollback()
+		return err
+	}
+
+	err = r.store.insertReferer(tx, referer)
+	if err != nil {
+		tx.rollback()
+		return err
+	}
+
+	return nil
+}
+
+func (r *memoryArticleRepo) SaveScrapedArticle(scrapedArticle news.ScrapedArticle, referers []domain.Referer) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	tx := &memoryTx{}
+	err := r.store.upsertArticle(tx, scrapedArticle.Article)
+	if err != nil {
+		tx.rollback()
+		return err
+	}
+
+	// Referers already stored under the same id are ignored, as in the postgres implementation.
+	for _, referer := range referers {
+		if _, exists := r.store.referers[referer.ID]; exists {
+			continue
+		}
+		err = r.store.insertReferer(tx, referer)
+		if err != nil {
+			tx.rollback()
+			return err
+		}
+	}
+
+	for _, subject := range scrapedArticle.Subjects {
+		err = r.store.upsertSubject(tx, subject)
+		if err != nil {
+			tx.rollback()
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *memoryArticleRepo) MergeArticles(article news.Article, duplicateIDs []string, rescore ClusterRescorer) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	survivor, exists := r.store.articles[article.ID]
+	if !exists {
+		return ErrNoSuchArticle
+	}
+	duplicates := make(map[string]bool, len(duplicateIDs))
+	for _, duplicateID := range duplicateIDs {
+		duplicates[duplicateID] = duplicateID != article.ID
+	}
+	if ownerID, taken := r.store.articleIDsByURL[article.URL]; taken && ownerID != article.ID && !duplicates[ownerID] {
+		return errors.Wrap(errUniqueViolation, "mergeArticles failed")
+	}
+
+	for _, duplicateID := range duplicateIDs {
+		if duplicates[duplicateID] {
+			r.store.mergeArticle(article.ID, duplicateID)
+		}
+	}
+
+	delete(r.store.articleIDsByURL, survivor.URL)
+	survivor.URL = article.URL
+	survivor.ReferenceScore = roundScore(article.ReferenceScore)
+	r.store.setArticle(&memoryTx{}, survivor)
+	return r.store.rescoreArticleClusters(article.ID, rescore)
+}
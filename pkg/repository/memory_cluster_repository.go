@@ -0,0 +1,177 @@
+package repository
+
+import (
+	"sort"
+	"time"
+
+	"github.com/mimir-news/news-ranker/pkg/domain"
+	"github.com/pkg/errors"
+)
+
+type memoryClusterRepo struct {
+	store *MemoryStore
+}
+
+// NewMemoryClusterRepo creates a new ClusterRepo keeping clusters in the store.
+func NewMemoryClusterRepo(store *MemoryStore) ClusterRepo {
+	return &memoryClusterRepo{
+		store: store,
+	}
+}
+
+func (r *memoryClusterRepo) FindByHash(clusterHash string) (domain.ArticleCluster, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	cluster, ok := r.store.clusters[clusterHash]
+	if !ok {
+		return domain.ArticleCluster{}, ErrNoSuchCluster
+	}
+	return r.store.clusterWithMembers(cluster), nil
+}
+
+func (r *memoryClusterRepo) FindSince(date time.Time) ([]domain.ArticleCluster, error) {
+	return r.findClusters(true, func(cluster domain.ArticleCluster) bool {
+		return !cluster.ArticleDate.Before(date)
+	}), nil
+}
+
+func (r *memoryClusterRepo) FindCandidates(symbol string, since time.Time, limit int) ([]domain.ArticleCluster, error) {
+	clusters := r.findClusters(false, func(cluster domain.ArticleCluster) bool {
+		return cluster.Symbol == symbol && !cluster.ArticleDate.Before(since)
+	})
+
+	sort.Slice(clusters, func(i, j int) bool {
+		if !clusters[i].ArticleDate.Equal(clusters[j].ArticleDate) {
+			return clusters[i].ArticleDate.After(clusters[j].ArticleDate)
+		}
+		return clusters[i].Hash < clusters[j].Hash
+	})
+	return limitClusters(clusters, limit), nil
+}
+
+func (r *memoryClusterRepo) FindBySymbolAndDate(symbol string, date time.Time, limit int) ([]domain.ArticleCluster, error) {
+	clusters := r.findClusters(true, func(cluster domain.ArticleCluster) bool {
+		return cluster.Symbol == symbol && cluster.ArticleDate.Equal(date)
+	})
+
+	sort.Slice(clusters, func(i, j int) bool {
+		if clusters[i].Score != clusters[j].Score {
+			return clusters[i].Score > clusters[j].Score
+		}
+		return clusters[i].Hash < clusters[j].Hash
+	})
+	return limitClusters(clusters, limit), nil
+}
+
+func (r *memoryClusterRepo) FindClusters(filter Filter, afterHash string, limit int) ([]domain.ArticleCluster, error) {
+	clusters := r.findClusters(true, func(cluster domain.ArticleCluster) bool {
+		return cluster.Hash > afterHash &&
+			inDateRange(filter, cluster.ArticleDate) &&
+			(filter.Symbol == "" || cluster.Symbol == filter.Symbol)
+	})
+	return limitClusters(clusters, limit), nil
+}
+
+// findClusters returns the clusters matching a predicate ordered by hash.
+func (r *memoryClusterRepo) findClusters(withMembers bool, match func(domain.ArticleCluster) bool) []domain.ArticleCluster {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	clusters := make([]domain.ArticleCluster, 0)
+	for _, cluster := range r.store.clusters {
+		if !match(cluster) {
+			continue
+		}
+		if withMembers {
+			cluster = r.store.clusterWithMembers(cluster)
+		}
+		clusters = append(clusters, cluster)
+	}
+
+	sort.Slice(clusters, func(i, j int) bool {
+		return clusters[i].Hash < clusters[j].Hash
+	})
+	return clusters
+}
+
+func limitClusters(clusters []domain.ArticleCluster, limit int) []domain.ArticleCluster {
+	if len(clusters) > limit {
+		return clusters[:limit]
+	}
+	return clusters
+}
+
+func (r *memoryClusterRepo) Save(cluster domain.ArticleCluster) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if _, exists := r.store.clusters[cluster.Hash]; exists {
+		return ErrConcurrentUpdate
+	}
+	if _, exists := r.store.articles[cluster.LeadArticleID]; !exists {
+		return ErrFailedInsert
+	}
+
+	cluster.Score = roundScore(cluster.Score)
+	cluster.ArticleDate = toDate(cluster.ArticleDate)
+	tx := &memoryTx{}
+	r.store.setCluster(tx, cluster)
+	return r.upsertMembers(tx, cluster.Members)
+}
+
+func (r *memoryClusterRepo) Update(cluster domain.ArticleCluster) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	stored, err := r.findForUpdate(cluster)
+	if err != nil {
+		return err
+	}
+	if _, exists := r.store.articles[cluster.LeadArticleID]; !exists {
+		return errors.Wrap(errForeignKeyViolation, "updateCluster failed")
+	}
+
+	stored.Score = roundScore(cluster.Score)
+	stored.LeadArticleID = cluster.LeadArticleID
+	stored.LeaderReason = cluster.LeaderReason
+	stored.Version++
+	tx := &memoryTx{}
+	r.store.setCluster(tx, stored)
+	return r.upsertMembers(tx, cluster.Members)
+}
+
+func (r *memoryClusterRepo) UpdateScore(cluster domain.ArticleCluster) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	stored, err := r.findForUpdate(cluster)
+	if err != nil {
+		return err
+	}
+
+	stored.Score = roundScore(cluster.Score)
+	stored.Version++
+	r.store.setCluster(&memoryTx{}, stored)
+	return nil
+}
+
+// findForUpdate returns the stored cluster if it has the same version as the cluster to update.
+func (r *memoryClusterRepo) findForUpdate(cluster domain.ArticleCluster) (domain.ArticleCluster, error) {
+	stored, exists := r.store.clusters[cluster.Hash]
+	if !exists || stored.Version != cluster.Version {
+		return domain.ArticleCluster{}, ErrConcurrentUpdate
+	}
+	return stored, nil
+}
+
+func (r *memoryClusterRepo) upsertMembers(tx *memoryTx, members []domain.ClusterMember) error {
+	for _, member := range members {
+		err := r.store.upsertClusterMember(tx, member)
+		if err != nil {
+			tx.rollback()
+			return err
+		}
+	}
+	return nil
+}
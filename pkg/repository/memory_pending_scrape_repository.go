@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"sort"
+	"time"
+
+	"github.com/mimir-news/news-ranker/pkg/domain"
+	"github.com/mimir-news/pkg/schema/news"
+)
+
+type memoryPendingScrapeRepo struct {
+	store *MemoryStore
+}
+
+// NewMemoryPendingScrapeRepo creates a new PendingScrapeRepo keeping pending scrapes in the store.
+func NewMemoryPendingScrapeRepo(store *MemoryStore) PendingScrapeRepo {
+	return &memoryPendingScrapeRepo{
+		store: store,
+	}
+}
+
+func (r *memoryPendingScrapeRepo) FindByURL(url string) (domain.PendingScrape, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	p, ok := r.store.pendingScrapes[url]
+	if !ok {
+		return domain.PendingScrape{}, ErrNoSuchPendingScrape
+	}
+	return copyPendingScrape(p), nil
+}
+
+func (r *memoryPendingScrapeRepo) FindByArticleID(articleID string) (domain.PendingScrape, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	for _, p := range r.store.pendingScrapes {
+		if p.ArticleID == articleID {
+			return copyPendingScrape(p), nil
+		}
+	}
+	return domain.PendingScrape{}, ErrNoSuchPendingScrape
+}
+
+func (r *memoryPendingScrapeRepo) FindRequestedBefore(requestedBefore time.Time, limit int) ([]domain.PendingScrape, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	pendingScrapes := make([]domain.PendingScrape, 0)
+	for _, p := range r.store.pendingScrapes {
+		if p.RequestedAt.Before(requestedBefore) {
+			pendingScrapes = append(pendingScrapes, copyPendingScrape(p))
+		}
+	}
+
+	sort.Slice(pendingScrapes, func(i, j int) bool {
+		return pendingScrapes[i].RequestedAt.Before(pendingScrapes[j].RequestedAt)
+	})
+	if len(pendingScrapes) > limit {
+		pendingScrapes = pendingScrapes[:limit]
+	}
+	return pendingScrapes, nil
+}
+
+func (r *memoryPendingScrapeRepo) Save(pending domain.PendingScrape) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if _, exists := r.store.pendingScrapes[pending.URL]; exists {
+		return ErrPendingScrapeExists
+	}
+	for _, stored := range r.store.pendingScrapes {
+		if stored.ArticleID == pending.ArticleID {
+			return ErrPendingScrapeExists
+		}
+	}
+	r.store.pendingScrapes[pending.URL] = copyPendingScrape(pending)
+	return nil
+}
+
+func (r *memoryPendingScrapeRepo) Update(pending domain.PendingScrape) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	stored, exists := r.store.pendingScrapes[pending.URL]
+	if !exists || stored.Version != pending.Version {
+		return ErrPendingScrapeChanged
+	}
+
+	updated := copyPendingScrape(pending)
+	updated.ArticleID = stored.ArticleID
+	updated.Version++
+	r.store.pendingScrapes[pending.URL] = updated
+	return nil
+}
+
+func (r *memoryPendingScrapeRepo) Delete(url string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	delete(r.store.pendingScrapes, url)
+	return nil
+}
+
+func copyPendingScrape(p domain.PendingScrape) domain.PendingScrape {
+	subjects := make([]news.Subject, len(p.Subjects))
+	copy(subjects, p.Subjects)
+	referers := make([]domain.Referer, len(p.Referers))
+	copy(referers, p.Referers)
+
+	p.Subjects = subjects
+	p.Referers = referers
+	return p
+}
@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/mimir-news/news-ranker/pkg/domain"
+)
+
+type memoryPublisherRepo struct {
+	mu         sync.RWMutex
+	publishers map[string]domain.Publisher
+}
+
+// NewMemoryPublisherRepo creates a new PublisherRepo keeping publishers in memory.
+func NewMemoryPublisherRepo() PublisherRepo {
+	return &memoryPublisherRepo{
+		publishers: make(map[string]domain.Publisher),
+	}
+}
+
+func (r *memoryPublisherRepo) FindAll() ([]domain.Publisher, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	publishers := make([]domain.Publisher, 0, len(r.publishers))
+	for _, publisher := range r.publishers {
+		publishers = append(publishers, publisher)
+	}
+	sort.Slice(publishers, func(i, j int) bool {
+		return publishers[i].Host < publishers[j].Host
+	})
+	return publishers, nil
+}
+
+func (r *memoryPublisherRepo) Find(host string) (domain.Publisher, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	publisher, ok := r.publishers[host]
+	if !ok {
+		return domain.Publisher{}, ErrNoSuchPublisher
+	}
+	return publisher, nil
+}
+
+func (r *memoryPublisherRepo) Save(publisher domain.Publisher) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.publishers[publisher.Host]; exists {
+		return ErrPublisherExists
+	}
+	r.publishers[publisher.Host] = publisher
+	return nil
+}
+
+func (r *memoryPublisherRepo) Update(publisher domain.Publisher) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.publishers[publisher.Host]; !exists {
+		return ErrNoSuchPublisher
+	}
+	r.publishers[publisher.Host] = publisher
+	return nil
+}
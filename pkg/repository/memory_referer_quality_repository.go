@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/mimir-news/news-ranker/pkg/domain"
+)
+
+type memoryRefererQualityRepo struct {
+	mu        sync.RWMutex
+	qualities map[string]domain.AuthorQuality
+}
+
+// NewMemoryRefererQualityRepo creates a new RefererQualityRepo keeping qualities in memory.
+func NewMemoryRefererQualityRepo() RefererQualityRepo {
+	return &memoryRefererQualityRepo{
+		qualities: make(map[string]domain.AuthorQuality),
+	}
+}
+
+func (r *memoryRefererQualityRepo) Find(author string) (domain.AuthorQuality, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	quality, ok := r.qualities[author]
+	if !ok {
+		return domain.AuthorQuality{}, ErrNoSuchAuthorQuality
+	}
+	return quality, nil
+}
+
+func (r *memoryRefererQualityRepo) FindFlagged() ([]domain.AuthorQuality, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	qualities := make([]domain.AuthorQuality, 0)
+	for _, quality := range r.qualities {
+		if quality.Flagged() {
+			qualities = append(qualities, quality)
+		}
+	}
+	sort.Slice(qualities, func(i, j int) bool {
+		return qualities[i].Author < qualities[j].Author
+	})
+	return qualities, nil
+}
+
+func (r *memoryRefererQualityRepo) Save(quality domain.AuthorQuality) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.qualities[quality.Author] = quality
+	return nil
+}
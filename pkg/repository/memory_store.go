@@ -0,0 +1,403 @@
+package repository
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mimir-news/news-ranker/pkg/domain"
+	"github.com/mimir-news/pkg/schema/news"
+	"github.com/pkg/errors"
+)
+
+// Constraint violations reported by the in-memory repositories where postgres would report its own errors.
+var (
+	errUniqueViolation     = errors.New("unique constraint violated")
+	errForeignKeyViolation = errors.New("foreign key constraint violated")
+)
+
+// scorePrecision is the number of decimals scores are stored with, as in the NUMERIC(9,5) columns.
+const scorePrecision = 1e5
+
+// MemoryStore holds articles, their subjects and referers, article clusters and pending scrapes in memory.
+// It is safe for concurrent use and enforces the same constraints as the database schema,
+// it is shared by the in-memory ArticleRepo, ClusterRepo and PendingScrapeRepo.
+type MemoryStore struct {
+	mu              sync.RWMutex
+	articles        map[string]news.Article
+	articleIDsByURL map[string]string
+	subjects        map[string]news.Subject
+	referers        map[string]storedReferer
+	clusters        map[string]domain.ArticleCluster // Members are stored separately.
+	members         map[string]domain.ClusterMember
+	pendingScrapes  map[string]domain.PendingScrape
+}
+
+type storedReferer struct {
+	referer   domain.Referer
+	createdAt time.Time
+}
+
+// read returns the referer with the time it was stored.
+func (s storedReferer) read() domain.Referer {
+	referer := s.referer
+	referer.CreatedAt = s.createdAt
+	return referer
+}
+
+// NewMemoryStore creates a new empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		articles:        make(map[string]news.Article),
+		articleIDsByURL: make(map[string]string),
+		subjects:        make(map[string]news.Subject),
+		referers:        make(map[string]storedReferer),
+		clusters:        make(map[string]domain.ArticleCluster),
+		members:         make(map[string]domain.ClusterMember),
+		pendingScrapes:  make(map[string]domain.PendingScrape),
+	}
+}
+
+// memoryTx records how to undo the changes made to a store so that an operation
+// which fails halfway can be rolled back like a database transaction.
+// The store must be locked for as long as the transaction is used.
+type memoryTx struct {
+	undo []func()
+}
+
+func (tx *memoryTx) onRollback(fn func()) {
+	tx.undo = append(tx.undo, fn)
+}
+
+func (tx *memoryTx) rollback() {
+	for i := len(tx.undo) - 1; i >= 0; i-- {
+		tx.undo[i]()
+	}
+}
+
+func (s *MemoryStore) setArticle(tx *memoryTx, article news.Article) {
+	previous, exists := s.articles[article.ID]
+	s.articles[article.ID] = article
+	s.articleIDsByURL[article.URL] = article.ID
+	tx.onRollback(func() {
+		if exists {
+			s.articles[article.ID] = previous
+			return
+		}
+		delete(s.articles, article.ID)
+		delete(s.articleIDsByURL, article.URL)
+	})
+}
+
+func (s *MemoryStore) upsertArticle(tx *memoryTx, article news.Article) error {
+	existing, exists := s.articles[article.ID]
+	if exists {
+		existing.ReferenceScore = roundScore(article.ReferenceScore)
+		s.setArticle(tx, existing)
+		return nil
+	}
+
+	if _, urlTaken := s.articleIDsByURL[article.URL]; urlTaken {
+		return errors.Wrap(errUniqueViolation, "upsertArticle failed")
+	}
+
+	article.Keywords = splitKeywords(joinKeywords(article.Keywords))
+	article.ReferenceScore = roundScore(article.ReferenceScore)
+	article.ArticleDate = toDate(article.ArticleDate)
+	article.CreatedAt = time.Now().UTC()
+	s.setArticle(tx, article)
+	return nil
+}
+
+func (s *MemoryStore) updateArticle(tx *memoryTx, article news.Article) error {
+	existing, exists := s.articles[article.ID]
+	if !exists {
+		return ErrNoSuchArticle
+	}
+
+	existing.ReferenceScore = roundScore(article.ReferenceScore)
+	s.setArticle(tx, existing)
+	return nil
+}
+
+func (s *MemoryStore) insertReferer(tx *memoryTx, referer domain.Referer) error {
+	if _, exists := s.articles[referer.ArticleID]; !exists {
+		return errors.Wrap(errForeignKeyViolation, "insertReferer failed")
+	}
+	for _, stored := range s.referers {
+		if stored.referer.ID == referer.ID {
+			return errors.Wrap(errUniqueViolation, "insertReferer failed")
+		}
+		if stored.referer.ExternalID == referer.ExternalID && stored.referer.ArticleID == referer.ArticleID {
+			return errors.Wrap(errUniqueViolation, "insertReferer failed")
+		}
+	}
+
+	s.referers[referer.ID] = storedReferer{referer: referer, createdAt: time.Now().UTC()}
+	tx.onRollback(func() {
+		delete(s.referers, referer.ID)
+	})
+	return nil
+}
+
+func (s *MemoryStore) upsertSubject(tx *memoryTx, subject news.Subject) error {
+	subject.Score = roundScore(subject.Score)
+	previous, exists := s.subjects[subject.ID]
+	if exists {
+		updated := previous
+		updated.Score = subject.Score
+		s.subjects[subject.ID] = updated
+		tx.onRollback(func() {
+			s.subjects[subject.ID] = previous
+		})
+		return nil
+	}
+
+	if _, ok := s.articles[subject.ArticleID]; !ok {
+		return errors.Wrap(errForeignKeyViolation, "upsertSubjects failed")
+	}
+	for _, stored := range s.subjects {
+		if stored.Symbol == subject.Symbol && stored.ArticleID == subject.ArticleID {
+			return errors.Wrap(errUniqueViolation, "upsertSubjects failed")
+		}
+	}
+
+	s.subjects[subject.ID] = subject
+	tx.onRollback(func() {
+		delete(s.subjects, subject.ID)
+	})
+	return nil
+}
+
+func (s *MemoryStore) setCluster(tx *memoryTx, cluster domain.ArticleCluster) {
+	previous, exists := s.clusters[cluster.Hash]
+	cluster.Members = nil
+	s.clusters[cluster.Hash] = cluster
+	tx.onRollback(func() {
+		if exists {
+			s.clusters[cluster.Hash] = previous
+			return
+		}
+		delete(s.clusters, cluster.Hash)
+	})
+}
+
+func (s *MemoryStore) upsertClusterMember(tx *memoryTx, member domain.ClusterMember) error {
+	member.ReferenceScore = roundScore(member.ReferenceScore)
+	member.SubjectScore = roundScore(member.SubjectScore)
+	member.ReferencedAt = time.Time{}
+
+	previous, exists := s.members[member.ID]
+	if exists {
+		updated := previous
+		updated.ReferenceScore = member.ReferenceScore
+		updated.SubjectScore = member.SubjectScore
+		s.members[member.ID] = updated
+		tx.onRollback(func() {
+			s.members[member.ID] = previous
+		})
+		return nil
+	}
+
+	_, clusterExists := s.clusters[member.ClusterHash]
+	_, articleExists := s.articles[member.ArticleID]
+	if !clusterExists || !articleExists {
+		return errors.Wrap(errForeignKeyViolation, "upsertClusterMembers failed")
+	}
+	for _, stored := range s.members {
+		if stored.ClusterHash == member.ClusterHash && stored.ArticleID == member.ArticleID {
+			return errors.Wrap(errUniqueViolation, "upsertClusterMembers failed")
+		}
+	}
+
+	s.members[member.ID] = member
+	tx.onRollback(func() {
+		delete(s.members, member.ID)
+	})
+	return nil
+}
+
+// mergeArticle moves what refers to the duplicate article to the article and deletes the duplicate,
+// rows that would violate a unique constraint are deleted instead of moved.
+func (s *MemoryStore) mergeArticle(articleID, duplicateID string) {
+	duplicate, exists := s.articles[duplicateID]
+	if !exists {
+		return
+	}
+
+	for refererID, stored := range s.referers {
+		if stored.referer.ArticleID != duplicateID {
+			continue
+		}
+		if s.hasReferer(articleID, stored.referer.ExternalID) {
+			delete(s.referers, refererID)
+			continue
+		}
+		stored.referer.ArticleID = articleID
+		s.referers[refererID] = stored
+	}
+
+	for subjectID, subject := range s.subjects {
+		if subject.ArticleID != duplicateID {
+			continue
+		}
+		if s.hasSubject(articleID, subject.Symbol) {
+			delete(s.subjects, subjectID)
+			continue
+		}
+		subject.ArticleID = articleID
+		s.subjects[subjectID] = subject
+	}
+
+	for memberID, member := range s.members {
+		if member.ArticleID != duplicateID {
+			continue
+		}
+		if s.hasClusterMember(member.ClusterHash, articleID) {
+			delete(s.members, memberID)
+			continue
+		}
+		member.ArticleID = articleID
+		s.members[memberID] = member
+	}
+
+	for hash, cluster := range s.clusters {
+		if cluster.LeadArticleID == duplicateID {
+			cluster.LeadArticleID = articleID
+			cluster.Version++
+			s.clusters[hash] = cluster
+		}
+	}
+
+	hasPendingScrape := false
+	for _, pending := range s.pendingScrapes {
+		hasPendingScrape = hasPendingScrape || pending.ArticleID == articleID
+	}
+	for URL, pending := range s.pendingScrapes {
+		if pending.ArticleID != duplicateID {
+			continue
+		}
+		if hasPendingScrape {
+			delete(s.pendingScrapes, URL)
+			continue
+		}
+		pending.ArticleID = articleID
+		pending.Version++
+		s.pendingScrapes[URL] = pending
+		hasPendingScrape = true
+	}
+
+	delete(s.articles, duplicateID)
+	if s.articleIDsByURL[duplicate.URL] == duplicateID {
+		delete(s.articleIDsByURL, duplicate.URL)
+	}
+}
+
+// rescoreArticleClusters rescores and stores the clusters the article is a member of.
+func (s *MemoryStore) rescoreArticleClusters(articleID string, rescore ClusterRescorer) error {
+	tx := &memoryTx{}
+	for hash, stored := range s.clusters {
+		if !s.hasClusterMember(hash, articleID) {
+			continue
+		}
+
+		cluster := s.clusterWithMembers(stored)
+		rescore(&cluster)
+		stored.Score = roundScore(cluster.Score)
+		stored.LeadArticleID = cluster.LeadArticleID
+		stored.LeaderReason = cluster.LeaderReason
+		stored.Version++
+		s.setCluster(tx, stored)
+		for _, member := range cluster.Members {
+			err := s.upsertClusterMember(tx, member)
+			if err != nil {
+				tx.rollback()
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) hasReferer(articleID, externalID string) bool {
+	for _, stored := range s.referers {
+		if stored.referer.ArticleID == articleID && stored.referer.ExternalID == externalID {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *MemoryStore) hasClusterMember(clusterHash, articleID string) bool {
+	for _, member := range s.members {
+		if member.ClusterHash == clusterHash && member.ArticleID == articleID {
+			return true
+		}
+	}
+	return false
+}
+
+// clusterWithMembers returns a copy of the cluster with its members, each member is
+// referenced at the time its article was last referenced and has the attributes of its article.
+func (s *MemoryStore) clusterWithMembers(cluster domain.ArticleCluster) domain.ArticleCluster {
+	members := make([]domain.ClusterMember, 0)
+	for _, member := range s.members {
+		if member.ClusterHash != cluster.Hash {
+			continue
+		}
+		member.ReferencedAt = s.lastReferencedAt(member.ArticleID)
+		article := s.articles[member.ArticleID]
+		member.FirstSeenAt = article.CreatedAt
+		member.Source = domain.ArticleSource(article.URL)
+		member.BodyLength = len(article.Body)
+		members = append(members, member)
+	}
+	sort.Slice(members, func(i, j int) bool {
+		return members[i].ID < members[j].ID
+	})
+
+	cluster.Members = members
+	return cluster
+}
+
+func (s *MemoryStore) lastReferencedAt(articleID string) time.Time {
+	var last time.Time
+	for _, stored := range s.referers {
+		if stored.referer.ArticleID == articleID && stored.createdAt.After(last) {
+			last = stored.createdAt
+		}
+	}
+	return last
+}
+
+func (s *MemoryStore) hasSubject(articleID, symbol string) bool {
+	for _, subject := range s.subjects {
+		if subject.ArticleID == articleID && subject.Symbol == symbol {
+			return true
+		}
+	}
+	return false
+}
+
+// inDateRange checks if a stored date is within the date range of a filter.
+func inDateRange(filter Filter, date time.Time) bool {
+	return !date.Before(filter.From) && !date.After(filter.To)
+}
+
+func copyArticle(article news.Article) news.Article {
+	keywords := make([]string, len(article.Keywords))
+	copy(keywords, article.Keywords)
+	article.Keywords = keywords
+	return article
+}
+
+// toDate truncates a time to its date, as when stored in a DATE column.
+// Stored dates are compared with the times searched for as is, as in postgres.
+func toDate(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func roundScore(score float64) float64 {
+	return math.Round(score*scorePrecision) / scorePrecision
+}
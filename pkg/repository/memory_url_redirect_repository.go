@@ -0,0 +1,36 @@
+package repository
+
+import (
+	"sync"
+)
+
+type memoryURLRedirectRepo struct {
+	mu        sync.RWMutex
+	redirects map[string]string
+}
+
+// NewMemoryURLRedirectRepo creates a new URLRedirectRepo keeping redirects in memory.
+func NewMemoryURLRedirectRepo() URLRedirectRepo {
+	return &memoryURLRedirectRepo{
+		redirects: make(map[string]string),
+	}
+}
+
+func (r *memoryURLRedirectRepo) FindTarget(sourceURL string) (string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	targetURL, ok := r.redirects[sourceURL]
+	if !ok {
+		return "", ErrNoSuchRedirect
+	}
+	return targetURL, nil
+}
+
+func (r *memoryURLRedirectRepo) Save(sourceURL, targetURL string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.redirects[sourceURL] = targetURL
+	return nil
+}
@@ -0,0 +1,203 @@
+package repository
+
+import (
+	"database/sql"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// MessageLedger records which messages have been processed so that redelivered
+// messages can be skipped. A message is claimed before it is handled so that only one
+// consumer handles it, the claim is then either marked as processed or released.
+// Entries expire and are then no longer considered claimed or processed, a claim is renewed
+// while the message is being handled.
+type MessageLedger interface {
+	Claim(queue, messageID string, now, expiresAt time.Time) (bool, error)
+	Renew(queue, messageID string, expiresAt time.Time) error
+	MarkProcessed(queue, messageID string, expiresAt time.Time) error
+	Release(queue, messageID string) error
+	IsProcessed(queue, messageID string, now time.Time) (bool, error)
+	DeleteExpired(now time.Time) (int64, error)
+}
+
+type pgMessageLedger struct {
+	db *sql.DB
+}
+
+// NewMessageLedger creates a new MessageLedger using the default implementation.
+func NewMessageLedger(db *sql.DB) MessageLedger {
+	return &pgMessageLedger{
+		db: db,
+	}
+}
+
+// claimMessageQuery inserts a claim unless the message is already claimed or processed,
+// expired entries that have not been pruned yet are claimed again.
+const claimMessageQuery = `
+  INSERT INTO processed_message(queue, message_id, claimed_at, processed_at, expires_at)
+  VALUES ($1, $2, $3, NULL, $4)
+  ON CONFLICT ON CONSTRAINT processed_message_pkey
+  DO UPDATE SET claimed_at = $3, processed_at = NULL, expires_at = $4
+  WHERE processed_message.expires_at <= $3`
+
+func (l *pgMessageLedger) Claim(queue, messageID string, now, expiresAt time.Time) (bool, error) {
+	res, err := l.db.Exec(claimMessageQuery, queue, messageID, now, expiresAt)
+	if err != nil {
+		return false, errors.Wrap(err, "pgMessageLedger.Claim failed")
+	}
+
+	claimed, err := res.RowsAffected()
+	if err != nil {
+		return false, errors.Wrap(err, "pgMessageLedger.Claim failed")
+	}
+	return claimed == 1, nil
+}
+
+const renewMessageClaimQuery = `
+  UPDATE processed_message SET expires_at = $3
+  WHERE queue = $1 AND message_id = $2 AND processed_at IS NULL`
+
+func (l *pgMessageLedger) Renew(queue, messageID string, expiresAt time.Time) error {
+	_, err := l.db.Exec(renewMessageClaimQuery, queue, messageID, expiresAt)
+	if err != nil {
+		return errors.Wrap(err, "pgMessageLedger.Renew failed")
+	}
+	return nil
+}
+
+const markMessageProcessedQuery = `
+  INSERT INTO processed_message(queue, message_id, claimed_at, processed_at, expires_at)
+  VALUES ($1, $2, NOW(), NOW(), $3)
+  ON CONFLICT ON CONSTRAINT processed_message_pkey
+  DO UPDATE SET processed_at = NOW(), expires_at = $3`
+
+func (l *pgMessageLedger) MarkProcessed(queue, messageID string, expiresAt time.Time) error {
+	_, err := l.db.Exec(markMessageProcessedQuery, queue, messageID, expiresAt)
+	if err != nil {
+		return errors.Wrap(err, "pgMessageLedger.MarkProcessed failed")
+	}
+	return nil
+}
+
+const releaseMessageQuery = `
+  DELETE FROM processed_message
+  WHERE queue = $1 AND message_id = $2 AND processed_at IS NULL`
+
+func (l *pgMessageLedger) Release(queue, messageID string) error {
+	_, err := l.db.Exec(releaseMessageQuery, queue, messageID)
+	if err != nil {
+		return errors.Wrap(err, "pgMessageLedger.Release failed")
+	}
+	return nil
+}
+
+const isMessageProcessedQuery = `
+  SELECT COUNT(*) FROM processed_message
+  WHERE queue = $1 AND message_id = $2 AND processed_at IS NOT NULL AND expires_at > $3`
+
+func (l *pgMessageLedger) IsProcessed(queue, messageID string, now time.Time) (bool, error) {
+	var count int
+	err := l.db.QueryRow(isMessageProcessedQuery, queue, messageID, now).Scan(&count)
+	if err != nil {
+		return false, errors.Wrap(err, "pgMessageLedger.IsProcessed failed")
+	}
+	return count > 0, nil
+}
+
+const deleteExpiredMessagesQuery = `DELETE FROM processed_message WHERE expires_at <= $1`
+
+func (l *pgMessageLedger) DeleteExpired(now time.Time) (int64, error) {
+	res, err := l.db.Exec(deleteExpiredMessagesQuery, now)
+	if err != nil {
+		return 0, errors.Wrap(err, "pgMessageLedger.DeleteExpired failed")
+	}
+	return res.RowsAffected()
+}
+
+type memoryMessageLedger struct {
+	mu      sync.Mutex
+	entries map[ledgerKey]ledgerEntry
+}
+
+type ledgerKey struct {
+	queue     string
+	messageID string
+}
+
+type ledgerEntry struct {
+	processed bool
+	expiresAt time.Time
+}
+
+// NewMemoryMessageLedger creates a new MessageLedger keeping processed messages in memory.
+func NewMemoryMessageLedger() MessageLedger {
+	return &memoryMessageLedger{
+		entries: make(map[ledgerKey]ledgerEntry),
+	}
+}
+
+func (l *memoryMessageLedger) Claim(queue, messageID string, now, expiresAt time.Time) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	key := ledgerKey{queue: queue, messageID: messageID}
+	if entry, ok := l.entries[key]; ok && entry.expiresAt.After(now) {
+		return false, nil
+	}
+	l.entries[key] = ledgerEntry{expiresAt: expiresAt}
+	return true, nil
+}
+
+func (l *memoryMessageLedger) Renew(queue, messageID string, expiresAt time.Time) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	key := ledgerKey{queue: queue, messageID: messageID}
+	if entry, ok := l.entries[key]; ok && !entry.processed {
+		l.entries[key] = ledgerEntry{expiresAt: expiresAt}
+	}
+	return nil
+}
+
+func (l *memoryMessageLedger) MarkProcessed(queue, messageID string, expiresAt time.Time) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.entries[ledgerKey{queue: queue, messageID: messageID}] = ledgerEntry{processed: true, expiresAt: expiresAt}
+	return nil
+}
+
+func (l *memoryMessageLedger) Release(queue, messageID string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	key := ledgerKey{queue: queue, messageID: messageID}
+	if entry, ok := l.entries[key]; ok && !entry.processed {
+		delete(l.entries, key)
+	}
+	return nil
+}
+
+func (l *memoryMessageLedger) IsProcessed(queue, messageID string, now time.Time) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry, ok := l.entries[ledgerKey{queue: queue, messageID: messageID}]
+	return ok && entry.processed && entry.expiresAt.After(now), nil
+}
+
+func (l *memoryMessageLedger) DeleteExpired(now time.Time) (int64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var deleted int64
+	for key, entry := range l.entries {
+		if !entry.expiresAt.After(now) {
+			delete(l.entries, key)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mimir-news/pkg/id"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryMessageLedger(t *testing.T) {
+	testMessageLedger(t, NewMemoryMessageLedger())
+}
+
+func TestPostgresMessageLedger(t *testing.T) {
+	db := connectTestDB(t)
+	defer db.Close()
+
+	testMessageLedger(t, NewMessageLedger(db))
+}
+
+func testMessageLedger(t *testing.T, ledger MessageLedger) {
+	assert := assert.New(t)
+	now := time.Now().UTC().Truncate(time.Second)
+	messageID := id.New()
+
+	processed, err := ledger.IsProcessed("q-0", messageID, now)
+	assert.Nil(err)
+	assert.False(processed)
+
+	err = ledger.MarkProcessed("q-0", messageID, now.Add(time.Hour))
+	assert.Nil(err)
+	processed, err = ledger.IsProcessed("q-0", messageID, now)
+	assert.Nil(err)
+	assert.True(processed)
+	processed, err = ledger.IsProcessed("q-1", messageID, now)
+	assert.Nil(err)
+	assert.False(processed)
+	processed, err = ledger.IsProcessed("q-0", messageID, now.Add(time.Hour))
+	assert.Nil(err)
+	assert.False(processed)
+
+	err = ledger.MarkProcessed("q-0", messageID, now.Add(2*time.Hour))
+	assert.Nil(err)
+	processed, err = ledger.IsProcessed("q-0", messageID, now.Add(time.Hour))
+	assert.Nil(err)
+	assert.True(processed)
+
+	deleted, err := ledger.DeleteExpired(now.Add(time.Hour))
+	assert.Nil(err)
+	assert.True(deleted >= 0)
+	processed, err = ledger.IsProcessed("q-0", messageID, now.Add(time.Hour))
+	assert.Nil(err)
+	assert.True(processed)
+
+	deleted, err = ledger.DeleteExpired(now.Add(2 * time.Hour))
+	assert.Nil(err)
+	assert.True(deleted >= 1)
+	processed, err = ledger.IsProcessed("q-0", messageID, now)
+	assert.Nil(err)
+	assert.False(processed)
+
+	testMessageLedgerClaims(t, ledger)
+}
+
+func testMessageLedgerClaims(t *testing.T, ledger MessageLedger) {
+	assert := assert.New(t)
+	now := time.Now().UTC().Truncate(time.Second)
+	messageID := id.New()
+
+	claimed, err := ledger.Claim("q-0", messageID, now, now.Add(time.Minute))
+	assert.Nil(err)
+	assert.True(claimed)
+	claimed, err = ledger.Claim("q-0", messageID, now, now.Add(time.Minute))
+	assert.Nil(err)
+	assert.False(claimed)
+	claimed, err = ledger.Claim("q-1", messageID, now, now.Add(time.Minute))
+	assert.Nil(err)
+	assert.True(claimed)
+	err = ledger.Renew("q-1", messageID, now.Add(5*time.Minute))
+	assert.Nil(err)
+	claimed, err = ledger.Claim("q-1", messageID, now.Add(2*time.Minute), now.Add(3*time.Minute))
+	assert.Nil(err)
+	assert.False(claimed)
+	processed, err := ledger.IsProcessed("q-0", messageID, now)
+	assert.Nil(err)
+	assert.False(processed)
+
+	err = ledger.Release("q-0", messageID)
+	assert.Nil(err)
+	claimed, err = ledger.Claim("q-0", messageID, now, now.Add(time.Minute))
+	assert.Nil(err)
+	assert.True(claimed)
+
+	err = ledger.MarkProcessed("q-0", messageID, now.Add(time.Hour))
+	assert.Nil(err)
+	err = ledger.Release("q-0", messageID)
+	assert.Nil(err)
+	err = ledger.Renew("q-0", messageID, now.Add(time.Minute))
+	assert.Nil(err)
+	processed, err = ledger.IsProcessed("q-0", messageID, now)
+	assert.Nil(err)
+	assert.True(processed)
+	claimed, err = ledger.Claim("q-0", messageID, now.Add(30*time.Minute), now.Add(31*time.Minute))
+	assert.Nil(err)
+	assert.False(claimed)
+
+	claimed, err = ledger.Claim("q-0", messageID, now.Add(time.Hour), now.Add(61*time.Minute))
+	assert.Nil(err)
+	assert.True(claimed)
+	processed, err = ledger.IsProcessed("q-0", messageID, now.Add(time.Hour))
+	assert.Nil(err)
+	assert.False(processed)
+}
@@ -0,0 +1,177 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/mimir-news/news-ranker/pkg/domain"
+	"github.com/mimir-news/pkg/dbutil"
+	"github.com/pkg/errors"
+)
+
+// Common pending scrape repository errors.
+var (
+	ErrNoSuchPendingScrape = errors.New("no such pending scrape")
+	ErrPendingScrapeExists = errors.New("pending scrape already exists")
+	// ErrPendingScrapeChanged is returned when a pending scrape was updated or deleted by someone else
+	// after it was read.
+	ErrPendingScrapeChanged = errors.New("pending scrape was concurrently changed")
+)
+
+// PendingScrapeRepo data access interface for articles waiting to be scraped.
+type PendingScrapeRepo interface {
+	FindByURL(url string) (domain.PendingScrape, error)
+	FindByArticleID(articleID string) (domain.PendingScrape, error)
+	FindRequestedBefore(requestedBefore time.Time, limit int) ([]domain.PendingScrape, error)
+	Save(pending domain.PendingScrape) error
+	Update(pending domain.PendingScrape) error
+	Delete(url string) error
+}
+
+type pgPendingScrapeRepo struct {
+	db *sql.DB
+}
+
+// NewPendingScrapeRepo creates a new PendingScrapeRepo using the default implementation.
+func NewPendingScrapeRepo(db *sql.DB) PendingScrapeRepo {
+	return &pgPendingScrapeRepo{
+		db: db,
+	}
+}
+
+const findPendingScrapeByURLQuery = `
+  SELECT url, scrape_url, article_id, subjects, referers, attempts, requested_at, version
+  FROM pending_scrape WHERE url = $1`
+
+func (r *pgPendingScrapeRepo) FindByURL(url string) (domain.PendingScrape, error) {
+	p, err := scanPendingScrape(r.db.QueryRow(findPendingScrapeByURLQuery, url))
+	if err == sql.ErrNoRows {
+		return domain.PendingScrape{}, ErrNoSuchPendingScrape
+	} else if err != nil {
+		return domain.PendingScrape{}, errors.Wrap(err, "pgPendingScrapeRepo.FindByURL failed")
+	}
+	return p, nil
+}
+
+const findPendingScrapeByArticleIDQuery = `
+  SELECT url, scrape_url, article_id, subjects, referers, attempts, requested_at, version
+  FROM pending_scrape WHERE article_id = $1`
+
+func (r *pgPendingScrapeRepo) FindByArticleID(articleID string) (domain.PendingScrape, error) {
+	p, err := scanPendingScrape(r.db.QueryRow(findPendingScrapeByArticleIDQuery, articleID))
+	if err == sql.ErrNoRows {
+		return domain.PendingScrape{}, ErrNoSuchPendingScrape
+	} else if err != nil {
+		return domain.PendingScrape{}, errors.Wrap(err, "pgPendingScrapeRepo.FindByArticleID failed")
+	}
+	return p, nil
+}
+
+const findPendingScrapesRequestedBeforeQuery = `
+  SELECT url, scrape_url, article_id, subjects, referers, attempts, requested_at, version
+  FROM pending_scrape WHERE requested_at < $1
+  ORDER BY requested_at LIMIT $2`
+
+func (r *pgPendingScrapeRepo) FindRequestedBefore(requestedBefore time.Time, limit int) ([]domain.PendingScrape, error) {
+	rows, err := r.db.Query(findPendingScrapesRequestedBeforeQuery, requestedBefore, limit)
+	if err != nil {
+		return nil, errors.Wrap(err, "pgPendingScrapeRepo.FindRequestedBefore failed")
+	}
+	defer rows.Close()
+
+	pendingScrapes := make([]domain.PendingScrape, 0)
+	for rows.Next() {
+		p, err := scanPendingScrape(rows)
+		if err != nil {
+			return nil, errors.Wrap(err, "pgPendingScrapeRepo.FindRequestedBefore failed")
+		}
+		pendingScrapes = append(pendingScrapes, p)
+	}
+	return pendingScrapes, rows.Err()
+}
+
+func scanPendingScrape(row scanner) (domain.PendingScrape, error) {
+	var p domain.PendingScrape
+	var subjects, referers string
+	err := row.Scan(&p.URL, &p.ScrapeURL, &p.ArticleID, &subjects, &referers, &p.Attempts, &p.RequestedAt, &p.Version)
+	if err != nil {
+		return domain.PendingScrape{}, err
+	}
+
+	err = json.Unmarshal([]byte(subjects), &p.Subjects)
+	if err != nil {
+		return domain.PendingScrape{}, err
+	}
+	err = json.Unmarshal([]byte(referers), &p.Referers)
+	if err != nil {
+		return domain.PendingScrape{}, err
+	}
+	return p, nil
+}
+
+const savePendingScrapeQuery = `
+  INSERT INTO pending_scrape(url, scrape_url, article_id, subjects, referers, attempts, requested_at, version)
+  VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+
+// Save stores a new pending scrape, returns ErrPendingScrapeExists if the URL or article is already pending.
+func (r *pgPendingScrapeRepo) Save(pending domain.PendingScrape) error {
+	subjects, referers, err := marshalPendingScrape(pending)
+	if err != nil {
+		return errors.Wrap(err, "pgPendingScrapeRepo.Save failed")
+	}
+
+	_, err = r.db.Exec(
+		savePendingScrapeQuery, pending.URL, pending.ScrapeURL, pending.ArticleID, subjects, referers,
+		pending.Attempts, pending.RequestedAt, pending.Version)
+	if isUniqueViolation(err) {
+		return ErrPendingScrapeExists
+	} else if err != nil {
+		return errors.Wrap(err, "pgPendingScrapeRepo.Save failed")
+	}
+	return nil
+}
+
+const updatePendingScrapeQuery = `
+  UPDATE pending_scrape SET
+    subjects = $1, referers = $2, attempts = $3, requested_at = $4, version = version + 1
+    WHERE url = $5 AND version = $6`
+
+// Update stores the pending scrape if it has not been updated or deleted since it was read,
+// otherwise ErrPendingScrapeChanged is returned.
+func (r *pgPendingScrapeRepo) Update(pending domain.PendingScrape) error {
+	subjects, referers, err := marshalPendingScrape(pending)
+	if err != nil {
+		return errors.Wrap(err, "pgPendingScrapeRepo.Update failed")
+	}
+
+	res, err := r.db.Exec(
+		updatePendingScrapeQuery, subjects, referers, pending.Attempts, pending.RequestedAt,
+		pending.URL, pending.Version)
+	if err != nil {
+		return errors.Wrap(err, "pgPendingScrapeRepo.Update failed")
+	}
+	return dbutil.AssertRowsAffected(res, 1, ErrPendingScrapeChanged)
+}
+
+const deletePendingScrapeQuery = `DELETE FROM pending_scrape WHERE url = $1`
+
+func (r *pgPendingScrapeRepo) Delete(url string) error {
+	_, err := r.db.Exec(deletePendingScrapeQuery, url)
+	if err != nil {
+		return errors.Wrap(err, "pgPendingScrapeRepo.Delete failed")
+	}
+	return nil
+}
+
+func marshalPendingScrape(pending domain.PendingScrape) (string, string, error) {
+	subjects, err := json.Marshal(pending.Subjects)
+	if err != nil {
+		return "", "", err
+	}
+	referers, err := json.Marshal(pending.Referers)
+	if err != nil {
+		return "", "", err
+	}
+	return string(subjects), string(referers), nil
+}
@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mimir-news/news-ranker/pkg/domain"
+	"github.com/mimir-news/pkg/id"
+	"github.com/mimir-news/pkg/schema/news"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryPendingScrapeRepo(t *testing.T) {
+	testPendingScrapeRepo(t, NewMemoryPendingScrapeRepo(NewMemoryStore()))
+}
+
+func TestPostgresPendingScrapeRepo(t *testing.T) {
+	db := connectTestDB(t)
+	defer db.Close()
+
+	testPendingScrapeRepo(t, NewPendingScrapeRepo(db))
+}
+
+func testPendingScrapeRepo(t *testing.T, repo PendingScrapeRepo) {
+	assert := assert.New(t)
+	now := time.Now().UTC().Truncate(time.Second)
+	URL := "http://pending.com/" + id.New()
+
+	_, err := repo.FindByURL(URL)
+	assert.Equal(ErrNoSuchPendingScrape, err)
+	err = repo.Update(domain.PendingScrape{URL: URL})
+	assert.Equal(ErrPendingScrapeChanged, err)
+
+	pending := domain.NewPendingScrape(URL, news.ScrapeTarget{
+		URL:       URL + "?utm_source=twitter",
+		ArticleID: id.New(),
+		Subjects:  []news.Subject{{Symbol: "S0", Name: "subject-0"}},
+		Referer:   news.Referer{ExternalID: "author-0", FollowerCount: 100},
+	}, now.Add(-time.Hour))
+	err = repo.Save(pending)
+	assert.Nil(err)
+	err = repo.Save(pending)
+	assert.Equal(ErrPendingScrapeExists, err)
+	sameArticle := pending
+	sameArticle.URL = URL + "/other"
+	err = repo.Save(sameArticle)
+	assert.Equal(ErrPendingScrapeExists, err)
+
+	_, err = repo.FindByArticleID(id.New())
+	assert.Equal(ErrNoSuchPendingScrape, err)
+	stored, err := repo.FindByArticleID(pending.ArticleID)
+	assert.Nil(err)
+	assert.Equal(URL, stored.URL)
+
+	stored, err = repo.FindByURL(URL)
+	assert.Nil(err)
+	assert.Equal(pending.ArticleID, stored.ArticleID)
+	assert.Equal(URL+"?utm_source=twitter", stored.ScrapeURL)
+	assert.Equal(pending.Subjects, stored.Subjects)
+	assert.Equal(pending.Referers, stored.Referers)
+	assert.Equal(1, stored.Attempts)
+	assert.True(pending.RequestedAt.Equal(stored.RequestedAt))
+
+	timedOut, err := repo.FindRequestedBefore(now.Add(-2*time.Hour), 10)
+	assert.Nil(err)
+	assert.False(containsPendingScrape(timedOut, URL))
+	timedOut, err = repo.FindRequestedBefore(now, 10)
+	assert.Nil(err)
+	assert.True(containsPendingScrape(timedOut, URL))
+
+	concurrent := stored
+	stored.Merge(news.RankObject{Referer: news.Referer{ExternalID: "author-1", FollowerCount: 200}})
+	stored.Attempts++
+	stored.RequestedAt = now
+	err = repo.Update(stored)
+	assert.Nil(err)
+
+	updated, err := repo.FindByURL(URL)
+	assert.Nil(err)
+	assert.Equal(2, len(updated.Referers))
+	assert.Equal(2, updated.Attempts)
+	assert.True(now.Equal(updated.RequestedAt))
+	assert.Equal(stored.Version+1, updated.Version)
+
+	concurrent.Merge(news.RankObject{Referer: news.Referer{ExternalID: "author-2", FollowerCount: 300}})
+	err = repo.Update(concurrent)
+	assert.Equal(ErrPendingScrapeChanged, err)
+	updated, err = repo.FindByURL(URL)
+	assert.Nil(err)
+	assert.Equal(2, len(updated.Referers))
+
+	err = repo.Delete(URL)
+	assert.Nil(err)
+	_, err = repo.FindByURL(URL)
+	assert.Equal(ErrNoSuchPendingScrape, err)
+}
+
+func containsPendingScrape(pendingScrapes []domain.PendingScrape, URL string) bool {
+	for _, pending := range pendingScrapes {
+		if pending.URL == URL {
+			return true
+		}
+	}
+	return false
+}
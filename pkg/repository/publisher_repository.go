@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"database/sql"
+
+	"github.com/mimir-news/news-ranker/pkg/domain"
+	"github.com/mimir-news/pkg/dbutil"
+	"github.com/pkg/errors"
+)
+
+// Publisher errors.
+var (
+	ErrNoSuchPublisher   = errors.New("no such publisher")
+	ErrPublisherExists   = errors.New("publisher already exists")
+	errPublisherNotSaved = errors.New("publisher not saved")
+)
+
+// PublisherRepo data access interface for the registry of publishers.
+type PublisherRepo interface {
+	FindAll() ([]domain.Publisher, error)
+	Find(host string) (domain.Publisher, error)
+	Save(publisher domain.Publisher) error
+	Update(publisher domain.Publisher) error
+}
+
+type pgPublisherRepo struct {
+	db *sql.DB
+}
+
+// NewPublisherRepo creates a new PublisherRepo using the default implementation.
+func NewPublisherRepo(db *sql.DB) PublisherRepo {
+	return &pgPublisherRepo{
+		db: db,
+	}
+}
+
+const findAllPublishersQuery = `
+  SELECT host, weight, paywalled, blocked FROM publisher ORDER BY host`
+
+// FindAll returns every publisher ordered by host.
+func (r *pgPublisherRepo) FindAll() ([]domain.Publisher, error) {
+	rows, err := r.db.Query(findAllPublishersQuery)
+	if err != nil {
+		return nil, errors.Wrap(err, "pgPublisherRepo.FindAll failed")
+	}
+	defer rows.Close()
+
+	publishers := make([]domain.Publisher, 0)
+	for rows.Next() {
+		p, err := scanPublisher(rows)
+		if err != nil {
+			return nil, errors.Wrap(err, "pgPublisherRepo.FindAll failed")
+		}
+		publishers = append(publishers, p)
+	}
+	return publishers, rows.Err()
+}
+
+const findPublisherQuery = `
+  SELECT host, weight, paywalled, blocked FROM publisher WHERE host = $1`
+
+func (r *pgPublisherRepo) Find(host string) (domain.Publisher, error) {
+	p, err := scanPublisher(r.db.QueryRow(findPublisherQuery, host))
+	if err == sql.ErrNoRows {
+		return domain.Publisher{}, ErrNoSuchPublisher
+	} else if err != nil {
+		return domain.Publisher{}, errors.Wrap(err, "pgPublisherRepo.Find failed")
+	}
+	return p, nil
+}
+
+func scanPublisher(row scanner) (domain.Publisher, error) {
+	var p domain.Publisher
+	err := row.Scan(&p.Host, &p.Weight, &p.Paywalled, &p.Blocked)
+	return p, err
+}
+
+const savePublisherQuery = `
+  INSERT INTO publisher(host, weight, paywalled, blocked) VALUES ($1, $2, $3, $4)`
+
+// Save stores a new publisher, returns ErrPublisherExists if the host is already registered.
+func (r *pgPublisherRepo) Save(p domain.Publisher) error {
+	res, err := r.db.Exec(savePublisherQuery, p.Host, p.Weight, p.Paywalled, p.Blocked)
+	if isUniqueViolation(err) {
+		return ErrPublisherExists
+	} else if err != nil {
+		return errors.Wrap(err, "pgPublisherRepo.Save failed")
+	}
+	return dbutil.AssertRowsAffected(res, 1, errPublisherNotSaved)
+}
+
+const updatePublisherQuery = `
+  UPDATE publisher SET weight = $2, paywalled = $3, blocked = $4, updated_at = NOW()
+  WHERE host = $1`
+
+// Update stores the changes to a publisher, returns ErrNoSuchPublisher if the host is not registered.
+func (r *pgPublisherRepo) Update(p domain.Publisher) error {
+	res, err := r.db.Exec(updatePublisherQuery, p.Host, p.Weight, p.Paywalled, p.Blocked)
+	if err != nil {
+		return errors.Wrap(err, "pgPublisherRepo.Update failed")
+	}
+	return dbutil.AssertRowsAffected(res, 1, ErrNoSuchPublisher)
+}
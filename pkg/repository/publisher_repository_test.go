@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/mimir-news/news-ranker/pkg/domain"
+	"github.com/mimir-news/pkg/id"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryPublisherRepo(t *testing.T) {
+	testPublisherRepo(t, NewMemoryPublisherRepo())
+}
+
+func TestPostgresPublisherRepo(t *testing.T) {
+	db := connectTestDB(t)
+	defer db.Close()
+
+	testPublisherRepo(t, NewPublisherRepo(db))
+}
+
+func testPublisherRepo(t *testing.T, repo PublisherRepo) {
+	assert := assert.New(t)
+	first := domain.Publisher{Host: id.New() + ".com", Weight: 1.5}
+	second := domain.Publisher{Host: id.New() + ".com", Weight: 0.5, Paywalled: true}
+
+	_, err := repo.Find(first.Host)
+	assert.Equal(ErrNoSuchPublisher, err)
+	err = repo.Update(first)
+	assert.Equal(ErrNoSuchPublisher, err)
+
+	assert.Nil(repo.Save(first))
+	assert.Nil(repo.Save(second))
+	err = repo.Save(first)
+	assert.Equal(ErrPublisherExists, err)
+
+	stored, err := repo.Find(first.Host)
+	assert.Nil(err)
+	assert.Equal(first, stored)
+
+	first.Weight = 0.0
+	first.Blocked = true
+	assert.Nil(repo.Update(first))
+	stored, err = repo.Find(first.Host)
+	assert.Nil(err)
+	assert.Equal(first, stored)
+
+	publishers, err := repo.FindAll()
+	assert.Nil(err)
+	found := make(map[string]domain.Publisher)
+	for i, publisher := range publishers {
+		found[publisher.Host] = publisher
+		if i > 0 {
+			assert.True(publishers[i-1].Host < publisher.Host)
+		}
+	}
+	assert.Equal(first, found[first.Host])
+	assert.Equal(second, found[second.Host])
+}
@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"database/sql"
+
+	"github.com/mimir-news/news-ranker/pkg/domain"
+	"github.com/mimir-news/pkg/dbutil"
+	"github.com/pkg/errors"
+)
+
+// Referer quality errors.
+var (
+	ErrNoSuchAuthorQuality   = errors.New("no such author quality")
+	errAuthorQualityNotSaved = errors.New("author quality not saved")
+)
+
+// RefererQualityRepo data access interface for the quality of referer authors.
+type RefererQualityRepo interface {
+	Find(author string) (domain.AuthorQuality, error)
+	FindFlagged() ([]domain.AuthorQuality, error)
+	Save(quality domain.AuthorQuality) error
+}
+
+type pgRefererQualityRepo struct {
+	db *sql.DB
+}
+
+// NewRefererQualityRepo creates a new RefererQualityRepo using the default implementation.
+func NewRefererQualityRepo(db *sql.DB) RefererQualityRepo {
+	return &pgRefererQualityRepo{
+		db: db,
+	}
+}
+
+const findAuthorQualityQuery = `
+  SELECT author, quality, follower_count, signals, last_signal, updated_at
+  FROM referer_quality WHERE author = $1`
+
+func (r *pgRefererQualityRepo) Find(author string) (domain.AuthorQuality, error) {
+	q, err := scanAuthorQuality(r.db.QueryRow(findAuthorQualityQuery, author))
+	if err == sql.ErrNoRows {
+		return domain.AuthorQuality{}, ErrNoSuchAuthorQuality
+	} else if err != nil {
+		return domain.AuthorQuality{}, errors.Wrap(err, "pgRefererQualityRepo.Find failed")
+	}
+	return q, nil
+}
+
+const findFlaggedAuthorsQuery = `
+  SELECT author, quality, follower_count, signals, last_signal, updated_at
+  FROM referer_quality WHERE quality < 1 ORDER BY author`
+
+// FindFlagged returns the authors with a quality below the default ordered by author.
+func (r *pgRefererQualityRepo) FindFlagged() ([]domain.AuthorQuality, error) {
+	rows, err := r.db.Query(findFlaggedAuthorsQuery)
+	if err != nil {
+		return nil, errors.Wrap(err, "pgRefererQualityRepo.FindFlagged failed")
+	}
+	defer rows.Close()
+
+	qualities := make([]domain.AuthorQuality, 0)
+	for rows.Next() {
+		q, err := scanAuthorQuality(rows)
+		if err != nil {
+			return nil, errors.Wrap(err, "pgRefererQualityRepo.FindFlagged failed")
+		}
+		qualities = append(qualities, q)
+	}
+	return qualities, rows.Err()
+}
+
+func scanAuthorQuality(row scanner) (domain.AuthorQuality, error) {
+	var q domain.AuthorQuality
+	err := row.Scan(&q.Author, &q.Quality, &q.FollowerCount, &q.Signals, &q.LastSignal, &q.UpdatedAt)
+	return q, err
+}
+
+const saveAuthorQualityQuery = `
+  INSERT INTO referer_quality(author, quality, follower_count, signals, last_signal, updated_at)
+  VALUES ($1, $2, $3, $4, $5, $6)
+  ON CONFLICT (author) DO UPDATE SET
+    quality = EXCLUDED.quality,
+    follower_count = EXCLUDED.follower_count,
+    signals = EXCLUDED.signals,
+    last_signal = EXCLUDED.last_signal,
+    updated_at = EXCLUDED.updated_at`
+
+// Save stores the quality of an author, replacing any previous quality.
+func (r *pgRefererQualityRepo) Save(q domain.AuthorQuality) error {
+	res, err := r.db.Exec(saveAuthorQualityQuery,
+		q.Author, q.Quality, q.FollowerCount, q.Signals, q.LastSignal, q.UpdatedAt)
+	if err != nil {
+		return errors.Wrap(err, "pgRefererQualityRepo.Save failed")
+	}
+	return dbutil.AssertRowsAffected(res, 1, errAuthorQualityNotSaved)
+}
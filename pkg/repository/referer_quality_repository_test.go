@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mimir-news/news-ranker/pkg/domain"
+	"github.com/mimir-news/pkg/id"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryRefererQualityRepo(t *testing.T) {
+	testRefererQualityRepo(t, NewMemoryRefererQualityRepo())
+}
+
+func TestPostgresRefererQualityRepo(t *testing.T) {
+	db := connectTestDB(t)
+	defer db.Close()
+
+	testRefererQualityRepo(t, NewRefererQualityRepo(db))
+}
+
+func testRefererQualityRepo(t *testing.T, repo RefererQualityRepo) {
+	assert := assert.New(t)
+	now := time.Now().UTC().Truncate(time.Second)
+	clean := domain.NewAuthorQuality(id.New())
+	clean.FollowerCount = 100
+	clean.UpdatedAt = now
+	flagged := domain.NewAuthorQuality(id.New())
+	flagged.FollowerCount = 5000
+
+	_, err := repo.Find(clean.Author)
+	assert.Equal(ErrNoSuchAuthorQuality, err)
+
+	assert.Nil(repo.Save(clean))
+	assert.Nil(repo.Save(flagged))
+	stored, err := repo.Find(clean.Author)
+	assert.Nil(err)
+	assert.Equal(clean.Author, stored.Author)
+	assert.Equal(clean.FollowerCount, stored.FollowerCount)
+	assert.True(clean.UpdatedAt.Equal(stored.UpdatedAt))
+
+	flagged = flagged.Observe(50000, []string{domain.FollowerJumpSignal}, domain.RefererQualityConfig{Penalty: 0.5}, now)
+	assert.Nil(repo.Save(flagged))
+	stored, err = repo.Find(flagged.Author)
+	assert.Nil(err)
+	assert.Equal(0.5, stored.Quality)
+	assert.Equal(int64(50000), stored.FollowerCount)
+	assert.Equal(1, stored.Signals)
+	assert.Equal(domain.FollowerJumpSignal, stored.LastSignal)
+
+	qualities, err := repo.FindFlagged()
+	assert.Nil(err)
+	found := make(map[string]bool)
+	for _, quality := range qualities {
+		assert.True(quality.Flagged())
+		found[quality.Author] = true
+	}
+	assert.True(found[flagged.Author])
+	assert.False(found[clean.Author])
+}
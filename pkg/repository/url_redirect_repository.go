@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"database/sql"
+
+	"github.com/pkg/errors"
+)
+
+// ErrNoSuchRedirect is returned when no redirect is known for a URL.
+var ErrNoSuchRedirect = errors.New("no such redirect")
+
+// URLRedirectRepo data access interface for the URLs that article URLs were found to redirect to.
+type URLRedirectRepo interface {
+	FindTarget(sourceURL string) (string, error)
+	Save(sourceURL, targetURL string) error
+}
+
+type pgURLRedirectRepo struct {
+	db *sql.DB
+}
+
+// NewURLRedirectRepo creates a new URLRedirectRepo using the default implementation.
+func NewURLRedirectRepo(db *sql.DB) URLRedirectRepo {
+	return &pgURLRedirectRepo{
+		db: db,
+	}
+}
+
+const findRedirectTargetQuery = `SELECT target_url FROM url_redirect WHERE source_url = $1`
+
+func (r *pgURLRedirectRepo) FindTarget(sourceURL string) (string, error) {
+	var targetURL string
+	err := r.db.QueryRow(findRedirectTargetQuery, sourceURL).Scan(&targetURL)
+	if err == sql.ErrNoRows {
+		return "", ErrNoSuchRedirect
+	} else if err != nil {
+		return "", errors.Wrap(err, "pgURLRedirectRepo.FindTarget failed")
+	}
+	return targetURL, nil
+}
+
+const saveRedirectQuery = `
+  INSERT INTO url_redirect(source_url, target_url) VALUES ($1, $2)
+  ON CONFLICT ON CONSTRAINT url_redirect_pkey DO UPDATE SET target_url = $2`
+
+// Save stores the redirect, replacing any target previously stored for the source URL.
+func (r *pgURLRedirectRepo) Save(sourceURL, targetURL string) error {
+	_, err := r.db.Exec(saveRedirectQuery, sourceURL, targetURL)
+	if err != nil {
+		return errors.Wrap(err, "pgURLRedirectRepo.Save failed")
+	}
+	return nil
+}
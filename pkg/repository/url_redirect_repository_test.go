@@ -0,0 +1,39 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/mimir-news/pkg/id"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryURLRedirectRepo(t *testing.T) {
+	testURLRedirectRepo(t, NewMemoryURLRedirectRepo())
+}
+
+func TestPostgresURLRedirectRepo(t *testing.T) {
+	db := connectTestDB(t)
+	defer db.Close()
+
+	testURLRedirectRepo(t, NewURLRedirectRepo(db))
+}
+
+func testURLRedirectRepo(t *testing.T, repo URLRedirectRepo) {
+	assert := assert.New(t)
+	sourceURL := "https://short.link/" + id.New()
+
+	_, err := repo.FindTarget(sourceURL)
+	assert.Equal(ErrNoSuchRedirect, err)
+
+	err = repo.Save(sourceURL, "https://url.com/first")
+	assert.Nil(err)
+	targetURL, err := repo.FindTarget(sourceURL)
+	assert.Nil(err)
+	assert.Equal("https://url.com/first", targetURL)
+
+	err = repo.Save(sourceURL, "https://url.com/second")
+	assert.Nil(err)
+	targetURL, err = repo.FindTarget(sourceURL)
+	assert.Nil(err)
+	assert.Equal("https://url.com/second", targetURL)
+}